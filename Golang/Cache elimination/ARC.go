@@ -1,9 +1,9 @@
-package主干
+package cache
 
 import (
 	"container/list"
-	"fmt"
 	"sync"
+	"time"
 )
 
 // ARC Cache 自适应替换缓存结构
@@ -21,21 +21,24 @@ type ARCCache struct {
 	t2 *list.List // 频繁访问的条目链表(LRU顺序)
 	b2 *list.List // 从t2淘汰的幽灵条目链表
 
-	lookup map[interface{}]*list.Element // 哈希表，用于快速查找
-	lock   sync.RWMutex                  // 读写锁，保证线程安全
+	lookup    map[interface{}]*list.Element // 哈希表，用于快速查找
+	lock      sync.RWMutex                  // 读写锁，保证线程安全
+	onEvicted func(key, value interface{})  // 因容量淘汰时的回调
 
 	stats struct { // 运行时统计信息
-		hits      int64 // 命中次数
-		misses    int64 // 未命中次数
-		evictions int64 // 淘汰次数
+		hits         int64 // 命中次数
+		misses       int64 // 未命中次数
+		evictions    int64 // 淘汰次数
+		expiredCount int64 // 过期条目数
 	}
+
+	observerHook // 事件观察者
 }
 
-// entry 缓存条目结构
-type entry struct {
-	key   interface{} // 缓存键
-	value interface{} // 缓存值
-	ghost bool        // 是否为幽灵条目(仅记录淘汰历史)
+// arcEntry ARC缓存条目
+type arcEntry struct {
+	entry
+	ghost bool // 是否为幽灵条目(仅记录淘汰历史)
 }
 
 // NewARCCache 创建新的ARC缓存实例
@@ -48,31 +51,42 @@ func NewARCCache(capacity int) *ARCCache {
 		t2:       list.New(),
 		b2:       list.New(),
 		lookup:   make(map[interface{}]*list.Element), // 预分配哈希表
+
+		observerHook: observerHook{policy: "arc"},
 	}
 }
 
 // Get 获取缓存值
 // 1. 检查键是否存在
-// 2. 如果是幽灵条目则返回未命中
-// 3. 如果在t1中则晋升到t2
+// 2. 如果是幽灵条目则返回未命中(只有写入才会调整p)
+// 3. 命中时移动到t2头部(t1中的条目晋升为频繁访问)
 // 4. 返回值和命中状态
-func (a *ARCCache) Get(key interface{}) (interface{}, bool) {
+func (a *ARCCache) Get(key interface{}) (value interface{}, ok bool) {
+	var expired bool
+	if obs, start := a.observeStart(); obs != nil {
+		// 先注册的defer后执行，事件在释放锁之后派发
+		defer func() { a.notifyGet(obs, start, key, ok, expired) }()
+	}
 	a.lock.Lock()
 	defer a.lock.Unlock()
 
 	if elem, ok := a.lookup[key]; ok {
-		ent := elem.Value.(*entry)
+		ent := elem.Value.(*arcEntry)
 		if ent.ghost {
 			a.stats.misses++
 			return nil, false
 		}
 
-		// 如果在t1中，移动到t2(晋升为频繁访问)
-		if a.isInList(elem, a.t1) {
-			a.t1.Remove(elem)
-			elem = a.t2.PushFront(ent) // 移动到t2头部
-			a.lookup[key] = elem
+		// 过期条目直接移除，不进入幽灵队列(并非因容量被淘汰)
+		if !ent.expiresAt.IsZero() && timeNow().After(ent.expiresAt) {
+			a.removeLive(elem)
+			a.stats.misses++
+			a.stats.expiredCount++
+			expired = true
+			return nil, false
 		}
+
+		a.promote(elem)
 		a.stats.hits++
 		return ent.value, true
 	}
@@ -80,83 +94,211 @@ func (a *ARCCache) Get(key interface{}) (interface{}, bool) {
 	return nil, false
 }
 
-// Put 添加或更新缓存
-// 1. 如果键已存在则更新值
-// 2. 如果在幽灵队列中则调整p值
-// 3. 添加新条目到t1
-// 4. 如果超过容量则执行替换
+// Put 添加或更新缓存(永不过期)
 func (a *ARCCache) Put(key, value interface{}) {
+	a.PutWithExpiration(key, value, 0)
+}
+
+// PutWithExpiration 添加或更新缓存(自定义过期时间)
+// 按ARC论文的四种情况处理(Megiddo & Modha, FAST 2003)：
+// 1. 键在t1或t2中：更新值并移动到t2头部
+// 2. 键在b1中：增大p，必要时执行替换，放入t2头部
+// 3. 键在b2中：减小p，必要时执行替换，放入t2头部
+// 4. 全新的键：保证 |t1|+|b1| <= c 且四个链表总长 <= 2c，必要时执行替换，放入t1头部
+// 主动删除和过期会让缓存未满而幽灵队列非空，因此只在t1+t2已满时才执行替换
+func (a *ARCCache) PutWithExpiration(key, value interface{}, expiration time.Duration) {
+	obs, start := a.observeStart()
+	var evicted pendingEvictions
 	a.lock.Lock()
-	defer a.lock.Unlock()
+	defer func() {
+		onEvicted := a.onEvicted
+		a.lock.Unlock()
+		if obs != nil {
+			a.notifyPut(obs, start, key)
+		}
+		evicted.flush(onEvicted, &a.observerHook)
+	}()
+
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = timeNow().Add(expiration)
+	}
 
-	// 如果key已存在，更新值
 	if elem, ok := a.lookup[key]; ok {
-		ent := elem.Value.(*entry)
+		ent := elem.Value.(*arcEntry)
+		// 情况1: 命中
 		if !ent.ghost {
 			ent.value = value
-			// 如果在t1中，移动到t2
-			if a.isInList(elem, a.t1) {
-				a.t1.Remove(elem)
-				elem = a.t2.PushFront(ent)
-				a.lookup[key] = elem
-			}
+			ent.expiresAt = expiresAt
+			a.promote(elem)
 			return
 		}
-	}
 
-	// 如果key在b1中，增加p
-	if elem, ok := a.lookup[key]; ok && a.isInList(elem, a.b1) {
-		a.p = min(a.capacity, a.p+max(1, a.b2.Len()/a.b1.Len()))
-		a.replace(false)
-		a.b1.Remove(elem)
-		delete(a.lookup, key)
+		// 情况2/3: 命中幽灵条目，根据所在队列调整p
+		inB2 := a.isInList(elem, a.b2)
+		if inB2 {
+			a.p = max(0, a.p-max(1, a.b1.Len()/a.b2.Len()))
+		} else {
+			a.p = min(a.capacity, a.p+max(1, a.b2.Len()/a.b1.Len()))
+		}
+		a.removeGhost(elem)
+		if a.t1.Len()+a.t2.Len() >= a.capacity {
+			a.replace(inB2, &evicted)
+		}
+		a.lookup[key] = a.t2.PushFront(&arcEntry{entry: entry{key: key, value: value, expiresAt: expiresAt}})
+		return
 	}
 
-	// 如果key在b2中，减少p
-	if elem, ok := a.lookup[key]; ok && a.isInList(elem, a.b2) {
-		a.p = max(0, a.p-max(1, a.b1.Len()/a.b2.Len()))
-		a.replace(true)
-		a.b2.Remove(elem)
-		delete(a.lookup, key)
+	// 情况4: 全新的键
+	if a.t1.Len()+a.b1.Len() >= a.capacity {
+		if a.t1.Len() < a.capacity {
+			a.removeGhost(a.b1.Back())
+			if a.t1.Len()+a.t2.Len() >= a.capacity {
+				a.replace(false, &evicted)
+			}
+		} else {
+			// b1为空且t1已满：直接淘汰t1最久未访问的条目，不保留幽灵记录
+			ent := a.t1.Remove(a.t1.Back()).(*arcEntry)
+			delete(a.lookup, ent.key)
+			a.stats.evictions++
+			evicted.add(ent.key, ent.value)
+		}
+	} else if total := a.t1.Len() + a.t2.Len() + a.b1.Len() + a.b2.Len(); total >= a.capacity {
+		if total >= 2*a.capacity {
+			a.removeGhost(a.b2.Back())
+		}
+		if a.t1.Len()+a.t2.Len() >= a.capacity {
+			a.replace(false, &evicted)
+		}
 	}
+	a.lookup[key] = a.t1.PushFront(&arcEntry{entry: entry{key: key, value: value, expiresAt: expiresAt}})
+}
 
-	// 添加新条目到t1
-	ent := &entry{key: key, value: value}
-	elem := a.t1.PushFront(ent)
-	a.lookup[key] = elem
+// promote 将命中的条目移动到t2头部
+func (a *ARCCache) promote(elem *list.Element) {
+	if a.isInList(elem, a.t1) {
+		ent := a.t1.Remove(elem).(*arcEntry)
+		a.lookup[ent.key] = a.t2.PushFront(ent)
+		return
+	}
+	a.t2.MoveToFront(elem)
+}
 
-	// 如果总长度超过容量，执行替换
-	if a.t1.Len()+a.t2.Len() >= a.capacity {
-		a.replace(false)
+// removeGhost 移除幽灵条目，elem为nil时不做任何操作
+func (a *ARCCache) removeGhost(elem *list.Element) {
+	if elem == nil {
+		return
 	}
+	a.b1.Remove(elem)
+	a.b2.Remove(elem)
+	delete(a.lookup, elem.Value.(*arcEntry).key)
 }
 
 // replace 执行替换策略
 // 根据p值决定从t1还是t2淘汰条目
 // inB2: 是否因为访问b2中的幽灵条目而触发替换
-func (a *ARCCache) replace(inB2 bool) {
-	// 如果t1不为空且(t1长度大于p 或 因访问b2且t1长度等于p)
-	if a.t1.Len() > 0 && (a.t1.Len() > a.p || (inB2 && a.t1.Len() == a.p)) {
+// 被淘汰的条目记入evicted，供调用方在释放锁后执行回调
+func (a *ARCCache) replace(inB2 bool, evicted *pendingEvictions) {
+	// 如果t1不为空且(t1长度大于p 或 因访问b2且t1长度等于p 或 t2为空)
+	// 论文只在t1+t2已满时调用REPLACE，不会遇到t2为空而t1不满足前两个条件的情况；
+	// 但Delete和Evict会在未满时留下空的t2，这时只能从t1淘汰，否则什么也淘汰不了
+	if a.t1.Len() > 0 && (a.t1.Len() > a.p || (inB2 && a.t1.Len() == a.p) || a.t2.Len() == 0) {
 		// 从t1淘汰最久未访问的条目
 		if elem := a.t1.Back(); elem != nil {
 			a.stats.evictions++
-			ent := a.t1.Remove(elem).(*entry)
+			ent := a.t1.Remove(elem).(*arcEntry)
 			ent.ghost = true           // 转为幽灵条目
 			elem = a.b1.PushFront(ent) // 加入b1记录淘汰历史
 			a.lookup[ent.key] = elem
+			evicted.add(ent.key, ent.value)
 		}
 	} else {
 		// 否则从t2淘汰最久未访问的条目
 		if elem := a.t2.Back(); elem != nil {
 			a.stats.evictions++
-			ent := a.t2.Remove(elem).(*entry)
+			ent := a.t2.Remove(elem).(*arcEntry)
 			ent.ghost = true
 			elem = a.b2.PushFront(ent) // 加入b2记录淘汰历史
 			a.lookup[ent.key] = elem
+			evicted.add(ent.key, ent.value)
 		}
 	}
 }
 
+// OnEvicted 设置条目因容量被淘汰时的回调(过期和主动删除不触发)
+// 回调在释放锁之后同步执行
+func (a *ARCCache) OnEvicted(fn func(key, value interface{})) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.onEvicted = fn
+}
+
+// Evict 按ARC的替换规则主动淘汰最多n个条目，返回实际淘汰的数量
+// 被淘汰的条目与容量淘汰一样进入幽灵队列，计入淘汰统计并触发淘汰回调
+func (a *ARCCache) Evict(n int) int {
+	var evicted pendingEvictions
+	a.lock.Lock()
+	for evicted.len() < n && a.t1.Len()+a.t2.Len() > 0 {
+		a.replace(false, &evicted)
+	}
+	onEvicted := a.onEvicted
+	a.lock.Unlock()
+
+	evicted.flush(onEvicted, &a.observerHook)
+	return evicted.len()
+}
+
+// Delete 删除指定键(含幽灵记录)，返回键是否存在于缓存中
+func (a *ARCCache) Delete(key interface{}) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	elem, ok := a.lookup[key]
+	if !ok {
+		return false
+	}
+	if elem.Value.(*arcEntry).ghost {
+		a.removeGhost(elem)
+		return false
+	}
+	a.removeLive(elem)
+	return true
+}
+
+// removeLive 从t1或t2中移除条目
+// list.Remove在节点不属于该链表时不做任何操作，因此可以对两个链表都调用
+func (a *ARCCache) removeLive(elem *list.Element) {
+	a.t1.Remove(elem)
+	a.t2.Remove(elem)
+	delete(a.lookup, elem.Value.(*arcEntry).key)
+}
+
+// Stats 获取缓存统计信息
+func (a *ARCCache) Stats() (hits, misses, evictions, expired int64) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.stats.hits, a.stats.misses, a.stats.evictions, a.stats.expiredCount
+}
+
+// Len 获取当前缓存大小(t1+t2，不含幽灵条目)
+func (a *ARCCache) Len() int {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.t1.Len() + a.t2.Len()
+}
+
+// Clear 清空缓存(含幽灵记录)
+func (a *ARCCache) Clear() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.p = 0
+	a.t1 = list.New()
+	a.b1 = list.New()
+	a.t2 = list.New()
+	a.b2 = list.New()
+	a.lookup = make(map[interface{}]*list.Element)
+}
+
 // 辅助函数
 func min(a, b int) int {
 	if a < b {
@@ -182,66 +324,3 @@ func (a *ARCCache) isInList(elem *list.Element, l *list.List) bool {
 	}
 	return false
 }
-
-// 修改main函数测试用例
-func main() {
-	cache := NewARCCache(3)
-
-	// 添加更多测试操作
-	cache.Put("A", 1)
-	cache.Put("B", 2)
-	cache.Put("C", 3)
-
-	// 多次访问测试命中率
-	cache.Get("A")
-	cache.Get("B")
-	cache.Get("A")
-
-	// 测试未命中
-	cache.Get("X")
-
-	// 触发淘汰
-	cache.Put("D", 4)
-	cache.Put("E", 5)
-	printCache(cache)
-
-	fmt.Println("\n=== 阶段4: 幽灵条目影响 ===")
-	cache.Put("C", 3) // 重新插入被淘汰的C
-	printCache(cache)
-
-	fmt.Println("\n=== 统计信息 ===")
-	fmt.Printf("命中次数: %d\n", cache.stats.hits)
-	fmt.Printf("未命中次数: %d\n", cache.stats.misses)
-	fmt.Printf("淘汰次数: %d\n", cache.stats.evictions)
-}
-
-// printCache 打印当前缓存内容
-func printCache(c *ARCCache) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-
-	fmt.Print("T1(最近访问): ")
-	for e := c.t1.Front(); e != nil; e = e.Next() {
-		ent := e.Value.(*entry)
-		fmt.Printf("%v(%v) ", ent.key, ent.value)
-	}
-
-	fmt.Print("\nT2(频繁访问): ")
-	for e := c.t2.Front(); e != nil; e = e.Next() {
-		ent := e.Value.(*entry)
-		fmt.Printf("%v(%v) ", ent.key, ent.value)
-	}
-
-	fmt.Print("\nB1(最近淘汰): ")
-	for e := c.b1.Front(); e != nil; e = e.Next() {
-		ent := e.Value.(*entry)
-		fmt.Printf("%v ", ent.key)
-	}
-
-	fmt.Print("\nB2(频繁淘汰): ")
-	for e := c.b2.Front(); e != nil; e = e.Next() {
-		ent := e.Value.(*entry)
-		fmt.Printf("%v ", ent.key)
-	}
-	fmt.Println()
-}
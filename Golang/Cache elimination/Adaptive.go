@@ -0,0 +1,386 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdaptiveConfig 自适应策略切换配置
+type AdaptiveConfig struct {
+	Capacity   int      // 实际缓存容量
+	Policies   []string // 候选策略，默认lru、lfu、arc、fifo
+	Initial    string   // 初始策略，默认Policies[0]
+	SampleRate int      // 每SampleRate个键采样1个进入影子模拟，默认16
+	Window     int      // 每个评估窗口包含的采样访问数，默认10000
+	Hysteresis float64  // 最优策略的命中率至少高出当前策略多少才算领先，默认0.02
+	Patience   int      // 连续领先多少个窗口才切换，默认2
+}
+
+// PolicySwitch 一次策略切换决策
+type PolicySwitch struct {
+	From      string
+	To        string
+	Window    int64              // 发生切换的窗口序号
+	At        time.Time          // 切换时间
+	HitRatios map[string]float64 // 该窗口内各影子策略的命中率
+}
+
+// ShadowStats 影子策略在最近一个完整窗口内的表现
+type ShadowStats struct {
+	Hits     int64
+	Misses   int64
+	HitRatio float64
+}
+
+// AdaptiveStats 自适应缓存统计
+type AdaptiveStats struct {
+	Policy    string                 // 当前生效的策略
+	Hits      int64                  // 实际缓存命中次数
+	Misses    int64                  // 实际缓存未命中次数
+	Windows   int64                  // 已完成的评估窗口数
+	Switches  int64                  // 策略切换次数
+	Shadows   map[string]ShadowStats // 各影子策略最近一个窗口的表现
+	Decisions []PolicySwitch         // 最近的切换记录(最多maxAdaptiveDecisions条)
+}
+
+const maxAdaptiveDecisions = 16
+
+// adaptiveItem 实际缓存中保存的条目，记录过期时间以便提升时保留剩余TTL
+type adaptiveItem struct {
+	value     interface{}
+	expiresAt time.Time // 零值表示永不过期
+}
+
+// shadowCache 只记录键的影子缓存，用于模拟某个策略在采样键上的命中率
+type shadowCache struct {
+	policy       string
+	cache        Cache
+	hits, misses int64 // 当前窗口
+	last         ShadowStats
+}
+
+// AdaptiveCache 根据观测到的负载自动切换淘汰策略的元缓存
+// - 对按哈希采样的键，在每个候选策略的影子缓存(容量按采样率缩小、只存键)上模拟访问
+// - 每个窗口结束时比较各影子策略的命中率，最优策略连续Patience个窗口领先当前策略超过Hysteresis时才切换
+// - 滞后阈值和连续窗口数避免在命中率相近的策略之间来回抖动
+// - 切换时新建空的实际缓存，旧缓存保留一个窗口，之后关闭，这期间总条目数最多为容量的两倍
+// - 重叠窗口内旧缓存命中的键连同剩余TTL提升到新缓存，写入只进入新缓存，窗口内访问过的热点键在切换后仍然保留
+type AdaptiveCache struct {
+	cfg AdaptiveConfig
+
+	lock      sync.RWMutex // 保护live、previous、policy
+	live      Cache
+	previous  Cache // 切换前的缓存，下一个窗口结束时关闭
+	policy    string
+	overlapMu sync.Mutex // 重叠窗口内串行化提升与写入/删除，避免提升的旧值覆盖新写入的值
+
+	simMu    sync.Mutex // 保护影子模拟和窗口状态
+	shadows  []*shadowCache
+	sampled  int    // 当前窗口已采样的访问数
+	windows  int64  // 已完成的窗口数
+	leader   string // 连续领先的策略
+	streak   int    // leader连续领先的窗口数
+	onSwitch func(PolicySwitch)
+
+	decisions []PolicySwitch
+	hits      int64
+	misses    int64
+	switches  int64
+}
+
+// NewAdaptiveCache 创建自适应缓存，策略名不合法时返回错误
+func NewAdaptiveCache(cfg AdaptiveConfig) (*AdaptiveCache, error) {
+	if len(cfg.Policies) == 0 {
+		cfg.Policies = []string{"lru", "lfu", "arc", "fifo"}
+	}
+	if cfg.Initial == "" {
+		cfg.Initial = cfg.Policies[0]
+	}
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 16
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10000
+	}
+	if cfg.Hysteresis <= 0 {
+		cfg.Hysteresis = 0.02
+	}
+	if cfg.Patience <= 0 {
+		cfg.Patience = 2
+	}
+
+	live, err := NewCache(cfg.Initial, cfg.Capacity)
+	if err != nil {
+		return nil, err
+	}
+	a := &AdaptiveCache{cfg: cfg, live: live, policy: cfg.Initial}
+	shadowCap := max(1, cfg.Capacity/cfg.SampleRate)
+	for _, p := range cfg.Policies {
+		c, err := NewCache(p, shadowCap)
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+		a.shadows = append(a.shadows, &shadowCache{policy: p, cache: c})
+	}
+	return a, nil
+}
+
+// OnSwitch 设置策略切换时的回调，回调在释放锁之后同步执行
+func (a *AdaptiveCache) OnSwitch(fn func(PolicySwitch)) {
+	a.simMu.Lock()
+	defer a.simMu.Unlock()
+	a.onSwitch = fn
+}
+
+// Get 获取缓存值，切换后的一个窗口内会继续读取旧缓存，命中的键提升到新缓存
+func (a *AdaptiveCache) Get(key interface{}) (interface{}, bool) {
+	a.lock.RLock()
+	live, previous := a.live, a.previous
+	a.lock.RUnlock()
+
+	var v interface{}
+	raw, ok := live.Get(key)
+	if ok {
+		v = raw.(*adaptiveItem).value
+	} else if previous != nil {
+		v, ok = a.promote(live, previous, key)
+	}
+	if ok {
+		atomic.AddInt64(&a.hits, 1)
+	} else {
+		atomic.AddInt64(&a.misses, 1)
+	}
+	a.observe(key)
+	return v, ok
+}
+
+// promote 把旧缓存中的键连同剩余TTL移到新缓存
+func (a *AdaptiveCache) promote(live, previous Cache, key interface{}) (interface{}, bool) {
+	a.overlapMu.Lock()
+	defer a.overlapMu.Unlock()
+
+	// 等锁期间键可能已被写入或被其他Get提升
+	if raw, ok := live.Get(key); ok {
+		return raw.(*adaptiveItem).value, true
+	}
+	raw, ok := previous.Get(key)
+	if !ok {
+		return nil, false
+	}
+	previous.Delete(key)
+	item := raw.(*adaptiveItem)
+	var ttl time.Duration
+	if !item.expiresAt.IsZero() {
+		if ttl = item.expiresAt.Sub(timeNow()); ttl <= 0 {
+			return nil, false
+		}
+	}
+	live.PutWithExpiration(key, item, ttl)
+	return item.value, true
+}
+
+// Put 添加/更新缓存(永不过期)
+func (a *AdaptiveCache) Put(key, value interface{}) {
+	a.PutWithExpiration(key, value, 0)
+}
+
+// PutWithExpiration 添加/更新缓存，同时从旧缓存中删除该键，保证两者不重叠
+func (a *AdaptiveCache) PutWithExpiration(key, value interface{}, expiration time.Duration) {
+	item := &adaptiveItem{value: value}
+	if expiration > 0 {
+		item.expiresAt = timeNow().Add(expiration)
+	}
+	a.lock.RLock()
+	live, previous := a.live, a.previous
+	a.lock.RUnlock()
+
+	if previous == nil {
+		live.PutWithExpiration(key, item, expiration)
+		return
+	}
+	a.overlapMu.Lock()
+	defer a.overlapMu.Unlock()
+	live.PutWithExpiration(key, item, expiration)
+	previous.Delete(key)
+}
+
+// Delete 删除指定键，返回键是否存在
+func (a *AdaptiveCache) Delete(key interface{}) bool {
+	a.lock.RLock()
+	live, previous := a.live, a.previous
+	a.lock.RUnlock()
+
+	if previous == nil {
+		return live.Delete(key)
+	}
+	a.overlapMu.Lock()
+	defer a.overlapMu.Unlock()
+	ok := live.Delete(key)
+	if previous.Delete(key) {
+		ok = true
+	}
+	return ok
+}
+
+// Len 获取当前缓存大小(含切换后尚未关闭的旧缓存)
+func (a *AdaptiveCache) Len() int {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	n := a.live.Len()
+	if a.previous != nil {
+		n += a.previous.Len()
+	}
+	return n
+}
+
+// Clear 清空缓存，影子模拟的状态保留
+func (a *AdaptiveCache) Clear() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.live.Clear()
+	if a.previous != nil {
+		closeCache(a.previous)
+		a.previous = nil
+	}
+}
+
+// Policy 当前生效的策略
+func (a *AdaptiveCache) Policy() string {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.policy
+}
+
+// Stats 获取统计信息
+func (a *AdaptiveCache) Stats() AdaptiveStats {
+	a.simMu.Lock()
+	shadows := make(map[string]ShadowStats, len(a.shadows))
+	for _, s := range a.shadows {
+		shadows[s.policy] = s.last
+	}
+	decisions := append([]PolicySwitch(nil), a.decisions...)
+	windows := a.windows
+	a.simMu.Unlock()
+
+	return AdaptiveStats{
+		Policy:    a.Policy(),
+		Hits:      atomic.LoadInt64(&a.hits),
+		Misses:    atomic.LoadInt64(&a.misses),
+		Windows:   windows,
+		Switches:  atomic.LoadInt64(&a.switches),
+		Shadows:   shadows,
+		Decisions: decisions,
+	}
+}
+
+// Close 关闭实际缓存和影子缓存的后台协程
+func (a *AdaptiveCache) Close() {
+	a.lock.Lock()
+	closeCache(a.live)
+	if a.previous != nil {
+		closeCache(a.previous)
+	}
+	a.lock.Unlock()
+	for _, s := range a.shadows {
+		closeCache(s.cache)
+	}
+}
+
+// sample 按键的哈希决定是否采样，同一个键总是得到相同的结果
+func (a *AdaptiveCache) sample(key interface{}) bool {
+	if a.cfg.SampleRate == 1 {
+		return true
+	}
+	var h uint32
+	if s, ok := key.(string); ok {
+		h = ringHash(s)
+	} else {
+		h = ringHash(fmt.Sprint(key))
+	}
+	return h%uint32(a.cfg.SampleRate) == 0
+}
+
+// observe 在影子缓存上模拟一次读取：命中计数，未命中时放入(模拟回源后写入)
+func (a *AdaptiveCache) observe(key interface{}) {
+	if !a.sample(key) {
+		return
+	}
+	a.simMu.Lock()
+	for _, s := range a.shadows {
+		if _, ok := s.cache.Get(key); ok {
+			s.hits++
+		} else {
+			s.misses++
+			s.cache.Put(key, struct{}{})
+		}
+	}
+	a.sampled++
+	var decision *PolicySwitch
+	var onSwitch func(PolicySwitch)
+	if a.sampled >= a.cfg.Window {
+		decision = a.endWindow()
+		onSwitch = a.onSwitch
+	}
+	a.simMu.Unlock()
+
+	if decision != nil && onSwitch != nil {
+		onSwitch(*decision)
+	}
+}
+
+// endWindow 结束当前窗口并决定是否切换策略，调用方需持有simMu
+func (a *AdaptiveCache) endWindow() *PolicySwitch {
+	a.windows++
+	a.sampled = 0
+
+	ratios := make(map[string]float64, len(a.shadows))
+	for _, s := range a.shadows {
+		s.last = ShadowStats{Hits: s.hits, Misses: s.misses, HitRatio: hitRatio(s.hits, s.misses)}
+		ratios[s.policy] = s.last.HitRatio
+		s.hits, s.misses = 0, 0
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// 旧缓存只保留一个窗口，窗口内访问过的键已经提升到新缓存
+	if a.previous != nil {
+		closeCache(a.previous)
+		a.previous = nil
+	}
+
+	best := a.policy
+	for _, s := range a.shadows {
+		if ratios[s.policy] > ratios[best] {
+			best = s.policy
+		}
+	}
+	if best == a.policy || ratios[best]-ratios[a.policy] < a.cfg.Hysteresis {
+		a.leader, a.streak = "", 0
+		return nil
+	}
+	if best != a.leader {
+		a.leader, a.streak = best, 0
+	}
+	a.streak++
+	if a.streak < a.cfg.Patience {
+		return nil
+	}
+
+	live, err := NewCache(best, a.cfg.Capacity)
+	if err != nil {
+		return nil
+	}
+	decision := PolicySwitch{From: a.policy, To: best, Window: a.windows, At: timeNow(), HitRatios: ratios}
+	a.previous, a.live, a.policy = a.live, live, best
+	a.leader, a.streak = "", 0
+	atomic.AddInt64(&a.switches, 1)
+	a.decisions = append(a.decisions, decision)
+	if len(a.decisions) > maxAdaptiveDecisions {
+		a.decisions = a.decisions[len(a.decisions)-maxAdaptiveDecisions:]
+	}
+	return &decision
+}
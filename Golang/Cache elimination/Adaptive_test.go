@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readThrough 模拟旁路缓存的读取：未命中时回源并写入
+func readThrough(c Cache, key interface{}) {
+	if _, ok := c.Get(key); !ok {
+		c.Put(key, key)
+	}
+}
+
+func TestAdaptiveConformance(t *testing.T) {
+	runCacheConformance(t, func(n int) Cache {
+		a, err := NewAdaptiveCache(AdaptiveConfig{Capacity: n})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	})
+}
+
+// 热点键与大量一次性扫描混合时，LRU会被扫描冲刷，应切换到LFU
+func TestAdaptiveSwitchesToBetterPolicy(t *testing.T) {
+	clock := useFakeClock(t)
+	a, err := NewAdaptiveCache(AdaptiveConfig{
+		Capacity:   100,
+		Policies:   []string{"lru", "lfu"},
+		SampleRate: 1,
+		Window:     1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	var switches []PolicySwitch
+	a.OnSwitch(func(s PolicySwitch) { switches = append(switches, s) })
+
+	r := rand.New(rand.NewSource(1))
+	scan := 1000
+	for i := 0; i < 10000; i++ {
+		if r.Intn(4) == 0 {
+			readThrough(a, r.Intn(50))
+		} else {
+			scan++
+			readThrough(a, scan)
+		}
+	}
+
+	if a.Policy() != "lfu" {
+		t.Fatalf("policy = %s, stats = %+v", a.Policy(), a.Stats())
+	}
+	if len(switches) != 1 || switches[0].From != "lru" || switches[0].To != "lfu" {
+		t.Fatalf("switches = %+v", switches)
+	}
+	if switches[0].Window != 2 {
+		t.Fatalf("switch should wait Patience windows, got window %d", switches[0].Window)
+	}
+	if !switches[0].At.Equal(clock.Now()) {
+		t.Fatalf("switch at %v, want %v", switches[0].At, clock.Now())
+	}
+	s := a.Stats()
+	if s.Switches != 1 || len(s.Decisions) != 1 || s.Windows != 10 {
+		t.Fatalf("stats = %+v", s)
+	}
+	if s.Shadows["lfu"].HitRatio <= s.Shadows["lru"].HitRatio {
+		t.Fatalf("shadow ratios = %+v", s.Shadows)
+	}
+}
+
+// 命中率相近的策略之间不应切换
+func TestAdaptiveHysteresis(t *testing.T) {
+	a, err := NewAdaptiveCache(AdaptiveConfig{
+		Capacity:   100,
+		Policies:   []string{"lru", "fifo"},
+		SampleRate: 1,
+		Window:     1000,
+		Hysteresis: 0.05,
+		Patience:   1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		readThrough(a, r.Intn(1000))
+	}
+	if s := a.Stats(); s.Switches != 0 || s.Policy != "lru" || s.Windows != 20 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+// 切换后的一个窗口内旧缓存中的键仍可读取，写入和删除不会留下两份
+func TestAdaptiveKeepsPreviousForOneWindow(t *testing.T) {
+	a, err := NewAdaptiveCache(AdaptiveConfig{
+		Capacity:   10,
+		Policies:   []string{"lru", "lfu"},
+		SampleRate: 1,
+		Window:     10,
+		Patience:   1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	a.Put("old", 1)
+	a.Put("both", 1)
+
+	// 让lfu影子领先：lru影子中的热点键被扫描冲刷
+	a.shadows[0].hits, a.shadows[0].misses = 0, 9
+	a.shadows[1].hits, a.shadows[1].misses = 9, 0
+	a.sampled = a.cfg.Window - 1
+	a.Get("trigger")
+	if a.Policy() != "lfu" {
+		t.Fatalf("policy = %s", a.Policy())
+	}
+
+	if v, ok := a.Get("old"); !ok || v != 1 {
+		t.Fatal("previous cache should still serve reads")
+	}
+	a.Put("both", 2)
+	if a.Len() != 2 {
+		t.Fatalf("Len = %d", a.Len())
+	}
+	if v, _ := a.Get("both"); v != 2 {
+		t.Fatalf("both = %v", v)
+	}
+	if !a.Delete("old") || a.Delete("old") {
+		t.Fatal("Delete should succeed exactly once")
+	}
+
+	a.Put("gone", 1)
+	a.Delete("gone")
+	a.Put("stale", 1)
+	a.lock.Lock()
+	a.previous.Put("stale-old", &adaptiveItem{value: 1})
+	a.lock.Unlock()
+	for i := 0; i < a.cfg.Window; i++ {
+		a.Get(i)
+	}
+	if _, ok := a.Get("stale-old"); ok {
+		t.Fatal("previous cache should be dropped after one window")
+	}
+	if _, ok := a.Get("stale"); !ok {
+		t.Fatal("live entries must survive")
+	}
+}
+
+// forceSwitch 让lfu影子领先并结束当前窗口，触发一次从lru到lfu的切换
+func forceSwitch(t *testing.T, a *AdaptiveCache) {
+	t.Helper()
+	a.simMu.Lock()
+	a.shadows[0].hits, a.shadows[0].misses = 0, 9
+	a.shadows[1].hits, a.shadows[1].misses = 9, 0
+	a.endWindow()
+	a.simMu.Unlock()
+	if a.Policy() != "lfu" {
+		t.Fatalf("policy = %s", a.Policy())
+	}
+}
+
+// 重叠窗口内访问过的热点键提升到新缓存，旧缓存关闭后仍然命中，剩余TTL不变
+func TestAdaptiveHotKeysSurviveSwitch(t *testing.T) {
+	clock := useFakeClock(t)
+	a, err := NewAdaptiveCache(AdaptiveConfig{
+		Capacity:   10,
+		Policies:   []string{"lru", "lfu"},
+		SampleRate: 1,
+		Window:     10,
+		Patience:   1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	for _, k := range []string{"hot-1", "hot-2", "cold"} {
+		a.Put(k, k)
+	}
+	a.PutWithExpiration("ttl", "ttl", 10*time.Second)
+	forceSwitch(t, a)
+
+	clock.Advance(4 * time.Second)
+	for _, k := range []string{"hot-1", "hot-2", "ttl"} {
+		if v, ok := a.Get(k); !ok || v != k {
+			t.Fatalf("Get(%s) during overlap = %v, %v", k, v, ok)
+		}
+	}
+	// 结束重叠窗口，旧缓存关闭
+	for i := 0; a.Stats().Windows < 2; i++ {
+		a.Get(i)
+	}
+	a.lock.RLock()
+	previous := a.previous
+	a.lock.RUnlock()
+	if previous != nil || a.Policy() != "lfu" {
+		t.Fatalf("previous = %v, policy = %s", previous, a.Policy())
+	}
+
+	for _, k := range []string{"hot-1", "hot-2", "ttl"} {
+		if v, ok := a.Get(k); !ok || v != k {
+			t.Fatalf("Get(%s) after overlap = %v, %v", k, v, ok)
+		}
+	}
+	if _, ok := a.Get("cold"); ok {
+		t.Fatal("keys not read during the overlap window are dropped with the previous cache")
+	}
+	clock.Advance(6*time.Second + time.Millisecond)
+	if _, ok := a.Get("ttl"); ok {
+		t.Fatal("promotion must keep the remaining TTL")
+	}
+}
+
+// 重叠窗口内并发的提升不能用旧值覆盖新写入的值
+func TestAdaptivePromoteRacesPut(t *testing.T) {
+	a, err := NewAdaptiveCache(AdaptiveConfig{
+		Capacity:   1000,
+		Policies:   []string{"lru", "lfu"},
+		SampleRate: 1000000,
+		Window:     10,
+		Patience:   1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	for i := 0; i < 200; i++ {
+		a.Put(i, "old")
+	}
+	forceSwitch(t, a)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			a.Get(i)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			a.Put(i, "new")
+		}
+	}()
+	wg.Wait()
+	for i := 0; i < 200; i++ {
+		if v, _ := a.Get(i); v != "new" {
+			t.Fatalf("Get(%d) = %v after overwrite", i, v)
+		}
+	}
+}
+
+func TestAdaptiveInvalidPolicy(t *testing.T) {
+	if _, err := NewAdaptiveCache(AdaptiveConfig{Capacity: 10, Policies: []string{"lru", "nope"}}); err == nil {
+		t.Fatal("unknown policy should be rejected")
+	}
+}
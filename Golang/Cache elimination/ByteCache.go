@@ -0,0 +1,376 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ByteCachePolicy ByteCache的淘汰方式
+type ByteCachePolicy int
+
+const (
+	// ByteCacheFIFO 环形缓冲区写满后覆盖最早写入的条目
+	ByteCacheFIFO ByteCachePolicy = iota
+	// ByteCacheLRU 近似LRU(二次机会)：被访问过的条目在即将被覆盖时搬到队尾，
+	// 每次写入最多搬移byteCacheMaxReinserts个，保证写入总能完成
+	ByteCacheLRU
+)
+
+const (
+	// 条目头布局: hash(8) | expireAt(8) | valLen(4) | keyLen(2) | flags(2)
+	byteEntryHeaderSize = 24
+
+	byteFlagDeleted  = 1 << 0 // 已删除或已被覆盖
+	byteFlagAccessed = 1 << 1 // 上次搬移后被访问过(近似LRU使用)
+
+	byteCacheMaxReinserts = 8 // 每次写入最多搬移的条目数
+)
+
+// ErrEntryTooLarge 条目超过单个分片环形缓冲区的1/4时返回
+var ErrEntryTooLarge = errors.New("byte cache: entry too large")
+
+// ByteCacheConfig ByteCache配置
+type ByteCacheConfig struct {
+	Shards      int             // 分片数，向上取整为2的幂，默认256
+	SegmentSize int             // 每个分片环形缓冲区的字节数，默认1MB，最大4GB
+	Policy      ByteCachePolicy // 淘汰方式
+}
+
+// byteShard 一个分片：一块预分配的环形字节缓冲区 + 不含指针的索引
+// head/tail为逻辑写入位置(单调递增)，物理偏移为 pos % len(ring)
+type byteShard struct {
+	mu      sync.Mutex
+	index   map[uint64]uint32 // 键哈希 -> 条目在ring中的物理偏移
+	ring    []byte
+	head    uint64 // 最早的条目的逻辑位置
+	tail    uint64 // 下一次写入的逻辑位置
+	scratch []byte // 搬移条目时使用的临时缓冲区
+	hdr     [byteEntryHeaderSize]byte
+
+	hits, misses, evictions, expired, collisions int64
+}
+
+// ByteCache 面向[]byte的低GC开销缓存(bigcache/freecache风格)
+// 设计特点:
+// - 条目序列化后写入每个分片预分配的大块环形缓冲区，GC只需扫描少量大对象
+// - 索引使用map[uint64]uint32，键值均不含指针，GC标记阶段无需遍历
+// - 更新和删除只标记旧条目，空间在环形缓冲区回绕时回收
+// - 64位哈希冲突时后写入的键覆盖索引，读取时会校验键，冲突只会导致未命中
+type ByteCache struct {
+	shards []*byteShard
+	mask   uint64
+	policy ByteCachePolicy
+}
+
+// NewByteCache 创建ByteCache
+func NewByteCache(cfg ByteCacheConfig) (*ByteCache, error) {
+	if cfg.Shards <= 0 {
+		cfg.Shards = 256
+	}
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = 1 << 20
+	}
+	if cfg.SegmentSize < 4*byteEntryHeaderSize || uint64(cfg.SegmentSize) > 1<<32 {
+		return nil, fmt.Errorf("byte cache: invalid segment size %d", cfg.SegmentSize)
+	}
+	n := 1
+	for n < cfg.Shards {
+		n <<= 1
+	}
+	c := &ByteCache{
+		shards: make([]*byteShard, n),
+		mask:   uint64(n - 1),
+		policy: cfg.Policy,
+	}
+	for i := range c.shards {
+		c.shards[i] = &byteShard{
+			index: make(map[uint64]uint32),
+			ring:  make([]byte, cfg.SegmentSize),
+		}
+	}
+	return c, nil
+}
+
+// byteHash FNV-1a 64位哈希，直接作用于[]byte避免分配
+func byteHash(key []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, b := range key {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return h
+}
+
+// shard 获取键所在的分片
+func (c *ByteCache) shard(hash uint64) *byteShard {
+	return c.shards[hash&c.mask]
+}
+
+// Set 写入条目，ttl<=0表示永不过期
+func (c *ByteCache) Set(key, value []byte, ttl time.Duration) error {
+	if len(key) > 0xffff {
+		return ErrEntryTooLarge
+	}
+	hash := byteHash(key)
+	s := c.shard(hash)
+	size := byteEntryHeaderSize + len(key) + len(value)
+	if size > len(s.ring)/4 {
+		return ErrEntryTooLarge
+	}
+
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = timeNow().Add(ttl).UnixNano()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if off, ok := s.index[hash]; ok {
+		s.markDeleted(off)
+	}
+	s.makeRoom(uint64(size), c.policy)
+
+	hdr := s.hdr[:]
+	binary.LittleEndian.PutUint64(hdr[0:8], hash)
+	binary.LittleEndian.PutUint64(hdr[8:16], uint64(expireAt))
+	binary.LittleEndian.PutUint32(hdr[16:20], uint32(len(value)))
+	binary.LittleEndian.PutUint16(hdr[20:22], uint16(len(key)))
+	binary.LittleEndian.PutUint16(hdr[22:24], 0)
+
+	off := s.phys(s.tail)
+	s.writeAt(s.tail, hdr)
+	s.writeAt(s.tail+byteEntryHeaderSize, key)
+	s.writeAt(s.tail+byteEntryHeaderSize+uint64(len(key)), value)
+	s.tail += uint64(size)
+	s.index[hash] = off
+	return nil
+}
+
+// Get 读取条目，返回值的副本
+func (c *ByteCache) Get(key []byte) ([]byte, bool) {
+	return c.GetAppend(nil, key)
+}
+
+// GetAppend 读取条目并追加到dst，复用dst可避免分配
+func (c *ByteCache) GetAppend(dst, key []byte) ([]byte, bool) {
+	hash := byteHash(key)
+	s := c.shard(hash)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	off, ok := s.index[hash]
+	if !ok {
+		s.misses++
+		return dst, false
+	}
+	pos := s.logical(off)
+	s.readAt(pos, s.hdr[:])
+	expireAt := int64(binary.LittleEndian.Uint64(s.hdr[8:16]))
+	valLen := uint64(binary.LittleEndian.Uint32(s.hdr[16:20]))
+	keyLen := uint64(binary.LittleEndian.Uint16(s.hdr[20:22]))
+	flags := binary.LittleEndian.Uint16(s.hdr[22:24])
+
+	if keyLen != uint64(len(key)) || !s.equalAt(pos+byteEntryHeaderSize, key) {
+		s.misses++
+		s.collisions++
+		return dst, false
+	}
+	if expireAt != 0 && timeNow().UnixNano() >= expireAt {
+		s.markDeleted(off)
+		delete(s.index, hash)
+		s.misses++
+		s.expired++
+		return dst, false
+	}
+
+	if flags&byteFlagAccessed == 0 {
+		binary.LittleEndian.PutUint16(s.hdr[22:24], flags|byteFlagAccessed)
+		s.writeAt(pos+22, s.hdr[22:24])
+	}
+	s.hits++
+
+	start := len(dst)
+	dst = append(dst, make([]byte, valLen)...)
+	s.readAt(pos+byteEntryHeaderSize+keyLen, dst[start:])
+	return dst, true
+}
+
+// Delete 删除条目，返回键是否存在
+func (c *ByteCache) Delete(key []byte) bool {
+	hash := byteHash(key)
+	s := c.shard(hash)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	off, ok := s.index[hash]
+	if !ok {
+		return false
+	}
+	pos := s.logical(off)
+	s.readAt(pos, s.hdr[:])
+	keyLen := binary.LittleEndian.Uint16(s.hdr[20:22])
+	if int(keyLen) != len(key) || !s.equalAt(pos+byteEntryHeaderSize, key) {
+		return false
+	}
+	s.markDeleted(off)
+	delete(s.index, hash)
+	return true
+}
+
+// Len 当前条目数(含尚未被发现的过期条目)
+func (c *ByteCache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += len(s.index)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Cost 环形缓冲区中已占用的字节数(含已删除但尚未回收的条目)
+func (c *ByteCache) Cost() int64 {
+	var n int64
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += int64(s.tail - s.head)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Stats 获取统计信息，与其他淘汰策略的Stats保持一致
+func (c *ByteCache) Stats() (hits, misses, evictions, expired int64) {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		hits += s.hits
+		misses += s.misses
+		evictions += s.evictions
+		expired += s.expired
+		s.mu.Unlock()
+	}
+	return
+}
+
+// Collisions 64位哈希冲突导致的未命中次数
+func (c *ByteCache) Collisions() int64 {
+	var n int64
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.collisions
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Clear 清空缓存，保留已分配的缓冲区
+func (c *ByteCache) Clear() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.index = make(map[uint64]uint32)
+		s.head, s.tail = 0, 0
+		s.mu.Unlock()
+	}
+}
+
+// makeRoom 从队头回收空间直到能写入size字节
+// 近似LRU模式下，被访问过的条目清除访问标记后搬到队尾(二次机会)
+func (s *byteShard) makeRoom(size uint64, policy ByteCachePolicy) {
+	reinserts := 0
+	for uint64(len(s.ring))-(s.tail-s.head) < size {
+		off := s.phys(s.head)
+		s.readAt(s.head, s.hdr[:])
+		hash := binary.LittleEndian.Uint64(s.hdr[0:8])
+		expireAt := int64(binary.LittleEndian.Uint64(s.hdr[8:16]))
+		valLen := uint64(binary.LittleEndian.Uint32(s.hdr[16:20]))
+		keyLen := uint64(binary.LittleEndian.Uint16(s.hdr[20:22]))
+		flags := binary.LittleEndian.Uint16(s.hdr[22:24])
+		entrySize := byteEntryHeaderSize + keyLen + valLen
+
+		live := flags&byteFlagDeleted == 0 && s.index[hash] == off
+		if live && expireAt != 0 && timeNow().UnixNano() >= expireAt {
+			delete(s.index, hash)
+			s.expired++
+			live = false
+		}
+
+		if live && policy == ByteCacheLRU && flags&byteFlagAccessed != 0 && reinserts < byteCacheMaxReinserts {
+			// 先读出整个条目再前移队头，然后写到队尾；环已满时两者可能重叠
+			if cap(s.scratch) < int(entrySize) {
+				s.scratch = make([]byte, entrySize)
+			}
+			buf := s.scratch[:entrySize]
+			s.readAt(s.head, buf)
+			binary.LittleEndian.PutUint16(buf[22:24], flags&^byteFlagAccessed)
+			s.head += entrySize
+			newOff := s.phys(s.tail)
+			s.writeAt(s.tail, buf)
+			s.tail += entrySize
+			s.index[hash] = newOff
+			reinserts++
+			continue
+		}
+
+		if live {
+			delete(s.index, hash)
+			s.evictions++
+		}
+		s.head += entrySize
+	}
+}
+
+// markDeleted 标记偏移处的条目已删除
+func (s *byteShard) markDeleted(off uint32) {
+	pos := s.logical(off)
+	var flags [2]byte
+	s.readAt(pos+22, flags[:])
+	f := binary.LittleEndian.Uint16(flags[:]) | byteFlagDeleted
+	binary.LittleEndian.PutUint16(flags[:], f)
+	s.writeAt(pos+22, flags[:])
+}
+
+// phys 逻辑位置 -> 物理偏移
+func (s *byteShard) phys(pos uint64) uint32 {
+	return uint32(pos % uint64(len(s.ring)))
+}
+
+// logical 物理偏移 -> 逻辑位置，条目一定位于[head, tail)之内
+func (s *byteShard) logical(off uint32) uint64 {
+	size := uint64(len(s.ring))
+	base := s.head - s.head%size
+	pos := base + uint64(off)
+	if pos < s.head {
+		pos += size
+	}
+	return pos
+}
+
+// writeAt 在逻辑位置写入数据，跨越缓冲区末尾时回绕
+func (s *byteShard) writeAt(pos uint64, data []byte) {
+	off := int(s.phys(pos))
+	n := copy(s.ring[off:], data)
+	copy(s.ring, data[n:])
+}
+
+// readAt 从逻辑位置读取数据，跨越缓冲区末尾时回绕
+func (s *byteShard) readAt(pos uint64, dst []byte) {
+	off := int(s.phys(pos))
+	n := copy(dst, s.ring[off:])
+	copy(dst[n:], s.ring)
+}
+
+// equalAt 比较逻辑位置处的数据是否与b相同
+func (s *byteShard) equalAt(pos uint64, b []byte) bool {
+	off := int(s.phys(pos))
+	first := len(s.ring) - off
+	if first >= len(b) {
+		return string(s.ring[off:off+len(b)]) == string(b)
+	}
+	return string(s.ring[off:]) == string(b[:first]) && string(s.ring[:len(b)-first]) == string(b[first:])
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestByteCacheBasic(t *testing.T) {
+	c, err := NewByteCache(ByteCacheConfig{Shards: 4, SegmentSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set([]byte("a"), []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set([]byte("a"), []byte("22"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := c.Get([]byte("a")); !ok || string(v) != "22" {
+		t.Fatalf("Get(a) = %q, %v", v, ok)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("Len = %d", c.Len())
+	}
+	if !c.Delete([]byte("a")) || c.Delete([]byte("a")) {
+		t.Fatal("Delete should succeed exactly once")
+	}
+	if _, ok := c.Get([]byte("a")); ok {
+		t.Fatal("deleted key still readable")
+	}
+	if err := c.Set([]byte("big"), make([]byte, 2048), 0); err != ErrEntryTooLarge {
+		t.Fatalf("expected ErrEntryTooLarge, got %v", err)
+	}
+}
+
+func TestByteCacheTTL(t *testing.T) {
+	clock := useFakeClock(t)
+	c, _ := NewByteCache(ByteCacheConfig{Shards: 1, SegmentSize: 4096})
+	c.Set([]byte("k"), []byte("v"), 20*time.Millisecond)
+	clock.Advance(19 * time.Millisecond)
+	if _, ok := c.Get([]byte("k")); !ok {
+		t.Fatal("entry expired too early")
+	}
+	clock.Advance(time.Millisecond)
+	if _, ok := c.Get([]byte("k")); ok {
+		t.Fatal("entry should have expired")
+	}
+	if _, _, _, expired := c.Stats(); expired != 1 {
+		t.Fatalf("expired = %d", expired)
+	}
+}
+
+// 写入远超容量的数据，验证回绕后的条目仍可正确读取
+func TestByteCacheWrapAround(t *testing.T) {
+	for _, policy := range []ByteCachePolicy{ByteCacheFIFO, ByteCacheLRU} {
+		c, _ := NewByteCache(ByteCacheConfig{Shards: 1, SegmentSize: 1000, Policy: policy})
+		for i := 0; i < 500; i++ {
+			key := []byte("key-" + strconv.Itoa(i))
+			val := bytes.Repeat([]byte{byte(i)}, i%40)
+			if err := c.Set(key, val, 0); err != nil {
+				t.Fatal(err)
+			}
+			if v, ok := c.Get(key); !ok || !bytes.Equal(v, val) {
+				t.Fatalf("policy %d: Get(%s) = %v, %v", policy, key, v, ok)
+			}
+		}
+		if c.Cost() > 1000 {
+			t.Fatalf("cost %d exceeds segment size", c.Cost())
+		}
+		if _, _, evictions, _ := c.Stats(); evictions == 0 {
+			t.Fatalf("policy %d: expected evictions", policy)
+		}
+	}
+}
+
+// 近似LRU模式下，频繁访问的键在回绕时应被保留
+func TestByteCacheLRUKeepsHotKey(t *testing.T) {
+	c, _ := NewByteCache(ByteCacheConfig{Shards: 1, SegmentSize: 2048, Policy: ByteCacheLRU})
+	hot := []byte("hot")
+	c.Set(hot, []byte("value"), 0)
+	for i := 0; i < 1000; i++ {
+		c.Set([]byte(fmt.Sprintf("cold-%d", i)), []byte("xxxxxxxxxxxxxxxx"), 0)
+		if _, ok := c.Get(hot); !ok {
+			t.Fatalf("hot key evicted after %d writes", i)
+		}
+	}
+
+	f, _ := NewByteCache(ByteCacheConfig{Shards: 1, SegmentSize: 2048, Policy: ByteCacheFIFO})
+	f.Set(hot, []byte("value"), 0)
+	for i := 0; i < 1000; i++ {
+		f.Set([]byte(fmt.Sprintf("cold-%d", i)), []byte("xxxxxxxxxxxxxxxx"), 0)
+		f.Get(hot)
+	}
+	if _, ok := f.Get(hot); ok {
+		t.Fatal("FIFO should have overwritten the oldest key")
+	}
+}
+
+const gcBenchEntries = 200000
+
+// benchmarkGC 在缓存存活时反复执行完整GC，ns/op即一次GC的耗时
+// 基于指针的缓存每个条目都有多个堆对象需要标记，ByteCache只有少量大块缓冲区
+func benchmarkGC(b *testing.B, fill func() interface{}) {
+	keep := fill()
+	runtime.GC()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+	}
+	b.StopTimer()
+	runtime.KeepAlive(keep)
+}
+
+func BenchmarkGCWithByteCache(b *testing.B) {
+	benchmarkGC(b, func() interface{} {
+		c, _ := NewByteCache(ByteCacheConfig{SegmentSize: 1 << 16})
+		val := make([]byte, 32)
+		for i := 0; i < gcBenchEntries; i++ {
+			c.Set([]byte(strconv.Itoa(i)), val, 0)
+		}
+		return c
+	})
+}
+
+func BenchmarkGCWithLRUCache(b *testing.B) {
+	benchmarkGC(b, func() interface{} {
+		c := NewLRUCache(gcBenchEntries, 0)
+		for i := 0; i < gcBenchEntries; i++ {
+			c.Put(strconv.Itoa(i), make([]byte, 32))
+		}
+		return c
+	})
+}
+
+func BenchmarkGCWithLFUCache(b *testing.B) {
+	benchmarkGC(b, func() interface{} {
+		c := NewLFUCache(gcBenchEntries)
+		for i := 0; i < gcBenchEntries; i++ {
+			c.Put(strconv.Itoa(i), make([]byte, 32))
+		}
+		return c
+	})
+}
+
+func BenchmarkByteCacheSetParallel(b *testing.B) {
+	c, _ := NewByteCache(ByteCacheConfig{SegmentSize: 1 << 20})
+	val := make([]byte, 64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		key := make([]byte, 0, 16)
+		i := 0
+		for pb.Next() {
+			key = strconv.AppendInt(key[:0], int64(i), 10)
+			c.Set(key, val, 0)
+			i++
+		}
+	})
+}
+
+func BenchmarkByteCacheGetParallel(b *testing.B) {
+	c, _ := NewByteCache(ByteCacheConfig{SegmentSize: 1 << 20})
+	val := make([]byte, 64)
+	for i := 0; i < 10000; i++ {
+		c.Set([]byte(strconv.Itoa(i)), val, 0)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		key := make([]byte, 0, 16)
+		dst := make([]byte, 0, 64)
+		i := 0
+		for pb.Next() {
+			key = strconv.AppendInt(key[:0], int64(i%10000), 10)
+			dst, _ = c.GetAppend(dst[:0], key)
+			i++
+		}
+	})
+}
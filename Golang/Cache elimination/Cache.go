@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeNow 各淘汰策略判断过期时使用的时钟，测试中替换为可控时钟以避免真实等待
+var timeNow = time.Now
+
+// entry 各淘汰策略共用的缓存条目，策略需要的额外字段通过嵌入扩展
+type entry struct {
+	key       interface{} // 缓存键
+	value     interface{} // 缓存值
+	expiresAt time.Time   // 过期时间，零值表示永不过期
+}
+
+// pendingEvictions 持锁期间因容量被淘汰的条目，释放锁之后由flush执行淘汰回调并派发淘汰事件
+type pendingEvictions struct {
+	keys   []interface{}
+	values []interface{}
+}
+
+// add 记录一个被淘汰的条目，调用方需持有锁
+func (p *pendingEvictions) add(key, value interface{}) {
+	p.keys = append(p.keys, key)
+	p.values = append(p.values, value)
+}
+
+// len 已记录的条目数
+func (p *pendingEvictions) len() int { return len(p.keys) }
+
+// flush 依次执行淘汰回调，再向当前观察者派发淘汰事件
+// 必须在释放锁之后调用，回调中可以安全地访问缓存
+func (p *pendingEvictions) flush(onEvicted func(key, value interface{}), h *observerHook) {
+	if onEvicted != nil {
+		for i, key := range p.keys {
+			onEvicted(key, p.values[i])
+		}
+	}
+	if len(p.keys) == 0 {
+		return
+	}
+	if obs, _ := h.observeStart(); obs != nil {
+		h.notifyEach(obs, EventEvict, p.keys)
+	}
+}
+
+// Cache 各淘汰策略的通用接口
+// LRUCache、LFUCache、FIFOCache、ARCCache、LRUKCache 均实现该接口，
+// 上层组件(服务端、分层缓存等)只依赖该接口，可按配置切换策略
+type Cache interface {
+	Get(key interface{}) (interface{}, bool)
+	Put(key, value interface{})
+	PutWithExpiration(key, value interface{}, expiration time.Duration)
+	Delete(key interface{}) bool
+	Len() int
+	Clear()
+}
+
+// NewCache 按策略名创建缓存
+// policy: lru、lfu、fifo、arc、lruk(K=2，不区分大小写)
+func NewCache(policy string, capacity int) (Cache, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("cache: capacity must be positive, got %d", capacity)
+	}
+	switch strings.ToLower(policy) {
+	case "lru":
+		return NewLRUCache(capacity, 0), nil
+	case "lfu":
+		return NewLFUCache(capacity), nil
+	case "fifo":
+		return NewFIFOCache(capacity), nil
+	case "arc":
+		return NewARCCache(capacity), nil
+	case "lruk":
+		c, err := NewLRUKCache(capacity, 2, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("cache: unknown policy %q", policy)
+}
+
+// closeCache 停止缓存的后台清理协程(LFU、FIFO)
+func closeCache(c Cache) {
+	if closer, ok := c.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
@@ -0,0 +1,482 @@
+package cache
+
+import (
+	"time"
+)
+
+// cacheModel 淘汰策略的参考模型
+// 模型用最直接的切片实现，只追求语义清晰，不考虑性能；
+// 被测实现的每一步返回值、大小和最终统计都必须与模型完全一致
+type cacheModel interface {
+	Get(key int) (int, bool)
+	Put(key, value int, ttl time.Duration)
+	Delete(key int) bool
+	Len() int
+	Stats() (hits, misses, evictions, expired int64)
+}
+
+// modelItem 模型中的条目
+type modelItem struct {
+	key       int
+	value     int
+	expiresAt time.Time
+}
+
+func (it *modelItem) expired() bool {
+	return !it.expiresAt.IsZero() && timeNow().After(it.expiresAt)
+}
+
+func modelExpiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return timeNow().Add(ttl)
+}
+
+// modelStats 模型统计
+type modelStats struct {
+	hits, misses, evictions, expired int64
+}
+
+func (s *modelStats) Stats() (hits, misses, evictions, expired int64) {
+	return s.hits, s.misses, s.evictions, s.expired
+}
+
+// indexOf 查找键在切片中的位置，不存在返回-1
+func indexOf(items []*modelItem, key int) int {
+	for i, it := range items {
+		if it.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeAt 删除切片中的第i个元素
+func removeAt(items []*modelItem, i int) []*modelItem {
+	return append(items[:i:i], items[i+1:]...)
+}
+
+// pushFront 插入到切片头部
+func pushFront(items []*modelItem, it *modelItem) []*modelItem {
+	return append([]*modelItem{it}, items...)
+}
+
+// lruModel items[0]最近使用
+type lruModel struct {
+	modelStats
+	capacity int
+	items    []*modelItem
+}
+
+func newLRUModel(capacity int) cacheModel { return &lruModel{capacity: capacity} }
+
+func (m *lruModel) Get(key int) (int, bool) {
+	i := indexOf(m.items, key)
+	if i < 0 {
+		m.misses++
+		return 0, false
+	}
+	it := m.items[i]
+	m.items = removeAt(m.items, i)
+	if it.expired() {
+		m.misses++
+		m.expired++
+		return 0, false
+	}
+	m.items = pushFront(m.items, it)
+	m.hits++
+	return it.value, true
+}
+
+func (m *lruModel) Put(key, value int, ttl time.Duration) {
+	if i := indexOf(m.items, key); i >= 0 {
+		m.items = removeAt(m.items, i)
+	} else if len(m.items) >= m.capacity {
+		m.items = m.items[:len(m.items)-1]
+		m.evictions++
+	}
+	m.items = pushFront(m.items, &modelItem{key: key, value: value, expiresAt: modelExpiry(ttl)})
+}
+
+func (m *lruModel) Delete(key int) bool {
+	i := indexOf(m.items, key)
+	if i < 0 {
+		return false
+	}
+	m.items = removeAt(m.items, i)
+	return true
+}
+
+func (m *lruModel) Len() int { return len(m.items) }
+
+// fifoModel items[0]最早进入，更新不改变位置
+type fifoModel struct {
+	modelStats
+	capacity int
+	items    []*modelItem
+}
+
+func newFIFOModel(capacity int) cacheModel { return &fifoModel{capacity: capacity} }
+
+func (m *fifoModel) Get(key int) (int, bool) {
+	i := indexOf(m.items, key)
+	if i < 0 {
+		m.misses++
+		return 0, false
+	}
+	it := m.items[i]
+	if it.expired() {
+		m.items = removeAt(m.items, i)
+		m.misses++
+		m.expired++
+		return 0, false
+	}
+	m.hits++
+	return it.value, true
+}
+
+func (m *fifoModel) Put(key, value int, ttl time.Duration) {
+	if i := indexOf(m.items, key); i >= 0 {
+		m.items[i].value = value
+		m.items[i].expiresAt = modelExpiry(ttl)
+		return
+	}
+	if len(m.items) >= m.capacity {
+		m.items = m.items[1:]
+		m.evictions++
+	}
+	m.items = append(m.items, &modelItem{key: key, value: value, expiresAt: modelExpiry(ttl)})
+}
+
+func (m *fifoModel) Delete(key int) bool {
+	i := indexOf(m.items, key)
+	if i < 0 {
+		return false
+	}
+	m.items = removeAt(m.items, i)
+	return true
+}
+
+func (m *fifoModel) Len() int { return len(m.items) }
+
+// lfuModel 淘汰频率最低的条目，频率相同时淘汰最久未访问的
+type lfuModel struct {
+	modelStats
+	capacity int
+	items    []*modelItem
+	freq     map[int]int
+	lastUsed map[int]int
+	tick     int
+}
+
+func newLFUModel(capacity int) cacheModel {
+	return &lfuModel{capacity: capacity, freq: map[int]int{}, lastUsed: map[int]int{}}
+}
+
+func (m *lfuModel) touch(key int) {
+	m.tick++
+	m.freq[key]++
+	m.lastUsed[key] = m.tick
+}
+
+func (m *lfuModel) Get(key int) (int, bool) {
+	i := indexOf(m.items, key)
+	if i < 0 {
+		m.misses++
+		return 0, false
+	}
+	it := m.items[i]
+	if it.expired() {
+		m.items = removeAt(m.items, i)
+		m.misses++
+		m.expired++
+		return 0, false
+	}
+	m.touch(key)
+	m.hits++
+	return it.value, true
+}
+
+func (m *lfuModel) Put(key, value int, ttl time.Duration) {
+	if i := indexOf(m.items, key); i >= 0 {
+		m.items[i].value = value
+		m.items[i].expiresAt = modelExpiry(ttl)
+		m.touch(key)
+		return
+	}
+	if len(m.items) >= m.capacity {
+		victim := 0
+		for i, it := range m.items {
+			v := m.items[victim]
+			if m.freq[it.key] < m.freq[v.key] ||
+				(m.freq[it.key] == m.freq[v.key] && m.lastUsed[it.key] < m.lastUsed[v.key]) {
+				victim = i
+			}
+		}
+		m.items = removeAt(m.items, victim)
+		m.evictions++
+	}
+	m.freq[key] = 0
+	m.touch(key)
+	m.items = append(m.items, &modelItem{key: key, value: value, expiresAt: modelExpiry(ttl)})
+}
+
+func (m *lfuModel) Delete(key int) bool {
+	i := indexOf(m.items, key)
+	if i < 0 {
+		return false
+	}
+	m.items = removeAt(m.items, i)
+	return true
+}
+
+func (m *lfuModel) Len() int { return len(m.items) }
+
+// arcModel 按论文描述的ARC，四个切片的下标0均为最近
+type arcModel struct {
+	modelStats
+	capacity       int
+	p              int
+	t1, t2, b1, b2 []*modelItem
+}
+
+func newARCModel(capacity int) cacheModel { return &arcModel{capacity: capacity} }
+
+func (m *arcModel) Get(key int) (int, bool) {
+	for _, l := range []*[]*modelItem{&m.t1, &m.t2} {
+		i := indexOf(*l, key)
+		if i < 0 {
+			continue
+		}
+		it := (*l)[i]
+		*l = removeAt(*l, i)
+		if it.expired() {
+			m.misses++
+			m.expired++
+			return 0, false
+		}
+		m.t2 = pushFront(m.t2, it)
+		m.hits++
+		return it.value, true
+	}
+	m.misses++
+	return 0, false
+}
+
+func (m *arcModel) replace(inB2 bool) {
+	if len(m.t1) > 0 && (len(m.t1) > m.p || (inB2 && len(m.t1) == m.p) || len(m.t2) == 0) {
+		it := m.t1[len(m.t1)-1]
+		m.t1 = m.t1[:len(m.t1)-1]
+		m.b1 = pushFront(m.b1, it)
+	} else {
+		it := m.t2[len(m.t2)-1]
+		m.t2 = m.t2[:len(m.t2)-1]
+		m.b2 = pushFront(m.b2, it)
+	}
+	m.evictions++
+}
+
+func (m *arcModel) full() bool { return len(m.t1)+len(m.t2) >= m.capacity }
+
+func (m *arcModel) Put(key, value int, ttl time.Duration) {
+	it := &modelItem{key: key, value: value, expiresAt: modelExpiry(ttl)}
+	for _, l := range []*[]*modelItem{&m.t1, &m.t2} {
+		if i := indexOf(*l, key); i >= 0 {
+			*l = removeAt(*l, i)
+			m.t2 = pushFront(m.t2, it)
+			return
+		}
+	}
+	if i := indexOf(m.b1, key); i >= 0 {
+		m.p = min(m.capacity, m.p+max(1, len(m.b2)/len(m.b1)))
+		m.b1 = removeAt(m.b1, i)
+		if m.full() {
+			m.replace(false)
+		}
+		m.t2 = pushFront(m.t2, it)
+		return
+	}
+	if i := indexOf(m.b2, key); i >= 0 {
+		m.p = max(0, m.p-max(1, len(m.b1)/len(m.b2)))
+		m.b2 = removeAt(m.b2, i)
+		if m.full() {
+			m.replace(true)
+		}
+		m.t2 = pushFront(m.t2, it)
+		return
+	}
+
+	if len(m.t1)+len(m.b1) >= m.capacity {
+		if len(m.t1) < m.capacity {
+			m.b1 = m.b1[:len(m.b1)-1]
+			if m.full() {
+				m.replace(false)
+			}
+		} else {
+			m.t1 = m.t1[:len(m.t1)-1]
+			m.evictions++
+		}
+	} else if total := len(m.t1) + len(m.t2) + len(m.b1) + len(m.b2); total >= m.capacity {
+		if total >= 2*m.capacity {
+			m.b2 = m.b2[:len(m.b2)-1]
+		}
+		if m.full() {
+			m.replace(false)
+		}
+	}
+	m.t1 = pushFront(m.t1, it)
+}
+
+func (m *arcModel) Delete(key int) bool {
+	for _, l := range []*[]*modelItem{&m.t1, &m.t2} {
+		if i := indexOf(*l, key); i >= 0 {
+			*l = removeAt(*l, i)
+			return true
+		}
+	}
+	for _, l := range []*[]*modelItem{&m.b1, &m.b2} {
+		if i := indexOf(*l, key); i >= 0 {
+			*l = removeAt(*l, i)
+		}
+	}
+	return false
+}
+
+func (m *arcModel) Len() int { return len(m.t1) + len(m.t2) }
+
+// lrukModel 按倒数第k次非相关访问淘汰，已淘汰键的历史保留在容量相同的历史表中
+type lrukModel struct {
+	modelStats
+	capacity int
+	k        int
+	crp      time.Duration
+	items    []*modelItem
+	hist     map[int][]int64 // 缓存中键的访问历史
+	last     map[int]int64
+	history  []int // 历史表中的键，下标0最新
+	retained map[int][]int64
+}
+
+func newLRUKModel(k int, crp time.Duration) func(capacity int) cacheModel {
+	return func(capacity int) cacheModel {
+		return &lrukModel{
+			capacity: capacity,
+			k:        k,
+			crp:      crp,
+			hist:     map[int][]int64{},
+			last:     map[int]int64{},
+			retained: map[int][]int64{},
+		}
+	}
+}
+
+func (m *lrukModel) touch(key int) {
+	now := timeNow().UnixNano()
+	h := m.hist[key]
+	if time.Duration(now-m.last[key]) >= m.crp {
+		period := m.last[key] - h[0]
+		for i := len(h) - 1; i > 0; i-- {
+			if h[i-1] != 0 {
+				h[i] = h[i-1] + period
+			}
+		}
+		h[0] = now
+	}
+	m.last[key] = now
+}
+
+func (m *lrukModel) forget(key int) {
+	for i, k := range m.history {
+		if k == key {
+			m.history = append(m.history[:i:i], m.history[i+1:]...)
+			delete(m.retained, key)
+			return
+		}
+	}
+}
+
+func (m *lrukModel) Get(key int) (int, bool) {
+	i := indexOf(m.items, key)
+	if i < 0 {
+		m.misses++
+		return 0, false
+	}
+	it := m.items[i]
+	if it.expired() {
+		m.items = removeAt(m.items, i)
+		m.misses++
+		m.expired++
+		return 0, false
+	}
+	m.touch(key)
+	m.hits++
+	return it.value, true
+}
+
+func (m *lrukModel) Put(key, value int, ttl time.Duration) {
+	if i := indexOf(m.items, key); i >= 0 {
+		m.items[i].value = value
+		m.items[i].expiresAt = modelExpiry(ttl)
+		m.touch(key)
+		return
+	}
+	h := make([]int64, m.k)
+	var last int64
+	if old, ok := m.retained[key]; ok {
+		copy(h, old)
+		last = m.last[key]
+		m.forget(key)
+	}
+	now := timeNow().UnixNano()
+	if len(m.items) >= m.capacity {
+		less := func(a, b int) bool {
+			ha, hb := m.hist[a], m.hist[b]
+			if ha[m.k-1] != hb[m.k-1] {
+				return ha[m.k-1] < hb[m.k-1]
+			}
+			return ha[0] < hb[0]
+		}
+		victim := -1
+		for i, it := range m.items {
+			if time.Duration(now-m.last[it.key]) >= m.crp && (victim < 0 || less(it.key, m.items[victim].key)) {
+				victim = i
+			}
+		}
+		if victim < 0 {
+			// 全部处于相关访问期内，淘汰倒数第k次访问最早的
+			victim = 0
+			for i, it := range m.items {
+				if less(it.key, m.items[victim].key) {
+					victim = i
+				}
+			}
+		}
+		old := m.items[victim].key
+		m.items = removeAt(m.items, victim)
+		m.history = append([]int{old}, m.history...)
+		m.retained[old] = m.hist[old]
+		if len(m.history) > m.capacity {
+			delete(m.retained, m.history[len(m.history)-1])
+			m.history = m.history[:len(m.history)-1]
+		}
+		m.evictions++
+	}
+
+	m.hist[key] = h
+	m.last[key] = last
+	m.touch(key)
+	m.items = append(m.items, &modelItem{key: key, value: value, expiresAt: modelExpiry(ttl)})
+}
+
+func (m *lrukModel) Delete(key int) bool {
+	m.forget(key)
+	i := indexOf(m.items, key)
+	if i < 0 {
+		return false
+	}
+	m.items = removeAt(m.items, i)
+	return true
+}
+
+func (m *lrukModel) Len() int { return len(m.items) }
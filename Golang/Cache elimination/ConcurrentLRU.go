@@ -0,0 +1,314 @@
+package cache
+
+import (
+	"container/list"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+const (
+	readBufferSize      = 64                 // 每个环形缓冲区的槽位数，必须是2的幂
+	readBufferMask      = readBufferSize - 1 // 位置到槽位下标的掩码
+	readBufferThreshold = readBufferSize / 2 // 积压达到该数量时尝试回放
+)
+
+// readBuffer 有损的单环形缓冲区，多个读协程并发写入，持有写锁的一方批量回放
+// 缓冲区满或CAS竞争失败时直接丢弃本次访问记录：少量访问顺序信息的丢失
+// 只会让LRU顺序变得近似，不影响正确性
+type readBuffer struct {
+	head uint64 // 下一个待回放的位置，只在持有写锁时修改
+	tail uint64 // 下一个写入位置
+	slot [readBufferSize]unsafe.Pointer
+	_    [64]byte // 填充，避免相邻缓冲区伪共享
+}
+
+// offer 记录一次访问，返回积压是否达到回放阈值
+func (b *readBuffer) offer(ent *clruEntry) bool {
+	head := atomic.LoadUint64(&b.head)
+	tail := atomic.LoadUint64(&b.tail)
+	size := tail - head
+	if size >= readBufferSize {
+		return true
+	}
+	if atomic.CompareAndSwapUint64(&b.tail, tail, tail+1) {
+		atomic.StorePointer(&b.slot[tail&readBufferMask], unsafe.Pointer(ent))
+		size++
+	}
+	return size >= readBufferThreshold
+}
+
+// drain 按写入顺序回放积压的访问，调用方需持有写锁
+// 遇到已占位但尚未写入的槽位时停止，剩余部分留到下一次回放
+func (b *readBuffer) drain(apply func(*clruEntry)) {
+	head := atomic.LoadUint64(&b.head)
+	tail := atomic.LoadUint64(&b.tail)
+	for ; head != tail; head++ {
+		p := atomic.SwapPointer(&b.slot[head&readBufferMask], nil)
+		if p == nil {
+			break
+		}
+		apply((*clruEntry)(p))
+	}
+	atomic.StoreUint64(&b.head, head)
+}
+
+// clruEntry 缓存条目，发布后key、value、expiresAt不再修改，更新时整体替换
+type clruEntry struct {
+	key       interface{}
+	value     interface{}
+	expiresAt time.Time
+	elem      *list.Element // 在链表中的节点，受写锁保护
+	removed   bool          // 是否已从链表移除，受写锁保护
+}
+
+// ConcurrentLRUCache 读路径无锁的LRU缓存(Ristretto/Caffeine风格)
+// LRUCache每次命中都要获取写锁执行MoveToFront，热点键的读取会被串行化。
+// 这里把"查找"和"维护访问顺序"分开:
+// - 键值保存在sync.Map中，读取只做一次无锁查找
+// - 命中记录写入按条带划分的有损环形缓冲区，积压到阈值时通过TryLock批量回放到链表
+// - 获取不到锁时把积压留给下一次回放或写操作，读协程从不等待写锁
+// - 写入、删除、淘汰都在写锁下进行，写入前先回放所有缓冲区，使淘汰顺序尽量准确
+type ConcurrentLRUCache struct {
+	capacity   int
+	expiration time.Duration
+	data       sync.Map // key -> *clruEntry
+	buffers    []readBuffer
+	bufMask    uint32
+
+	lock      sync.Mutex // 保护list和条目的elem/removed字段
+	list      *list.List // 头部最新尾部最旧
+	onEvicted func(key, value interface{})
+
+	size                                int64
+	hits, misses, evictions, expiredCnt int64
+
+	observerHook // 事件观察者
+}
+
+// NewConcurrentLRUCache 创建读路径无锁的LRU缓存
+// capacity: 缓存最大容量
+// expiration: 全局默认过期时间，0表示永不过期
+func NewConcurrentLRUCache(capacity int, expiration time.Duration) *ConcurrentLRUCache {
+	n := 1
+	for n < 4*runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	return &ConcurrentLRUCache{
+		capacity:   capacity,
+		expiration: expiration,
+		buffers:    make([]readBuffer, n),
+		bufMask:    uint32(n - 1),
+		list:       list.New(),
+
+		observerHook: observerHook{policy: "concurrent-lru"},
+	}
+}
+
+// Get 获取缓存值，命中路径不获取任何锁
+// 过期条目需要在写锁下删除，这是读路径唯一会加锁的情况
+func (c *ConcurrentLRUCache) Get(key interface{}) (value interface{}, ok bool) {
+	var expired bool
+	if obs, start := c.observeStart(); obs != nil {
+		defer func() { c.notifyGet(obs, start, key, ok, expired) }()
+	}
+	v, ok := c.data.Load(key)
+	if !ok {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+	ent := v.(*clruEntry)
+	if !ent.expiresAt.IsZero() && timeNow().After(ent.expiresAt) {
+		c.lock.Lock()
+		if cur, ok := c.data.Load(key); ok && cur == ent {
+			c.removeLocked(ent)
+			atomic.AddInt64(&c.expiredCnt, 1)
+			expired = true
+		}
+		c.lock.Unlock()
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddInt64(&c.hits, 1)
+	c.recordAccess(ent)
+	return ent.value, true
+}
+
+// recordAccess 把命中写入随机选择的条带，积压过多时尝试回放
+// math/rand的全局函数在新版本Go中使用每线程的快速随机源，不存在锁竞争
+func (c *ConcurrentLRUCache) recordAccess(ent *clruEntry) {
+	buf := &c.buffers[rand.Uint32()&c.bufMask]
+	if buf.offer(ent) && c.lock.TryLock() {
+		c.drainLocked()
+		c.lock.Unlock()
+	}
+}
+
+// drainLocked 回放所有缓冲区，调用方需持有写锁
+func (c *ConcurrentLRUCache) drainLocked() {
+	for i := range c.buffers {
+		c.buffers[i].drain(c.touchLocked)
+	}
+}
+
+// touchLocked 把条目移动到链表头部，已被移除或替换的条目忽略
+func (c *ConcurrentLRUCache) touchLocked(ent *clruEntry) {
+	if !ent.removed {
+		c.list.MoveToFront(ent.elem)
+	}
+}
+
+// Put 添加/更新缓存(使用默认过期时间)
+func (c *ConcurrentLRUCache) Put(key, value interface{}) {
+	c.PutWithExpiration(key, value, c.expiration)
+}
+
+// PutWithExpiration 添加/更新缓存(自定义过期时间)
+// 更新时用新条目整体替换旧条目，并发读取者要么看到旧值要么看到新值
+func (c *ConcurrentLRUCache) PutWithExpiration(key, value interface{}, expiration time.Duration) {
+	obs, start := c.observeStart()
+	var evicted pendingEvictions
+	c.lock.Lock()
+	defer func() {
+		onEvicted := c.onEvicted
+		c.lock.Unlock()
+		if obs != nil {
+			c.notifyPut(obs, start, key)
+		}
+		evicted.flush(onEvicted, &c.observerHook)
+	}()
+
+	c.drainLocked()
+
+	ent := &clruEntry{key: key, value: value}
+	if expiration > 0 {
+		ent.expiresAt = timeNow().Add(expiration)
+	}
+
+	if v, ok := c.data.Load(key); ok {
+		old := v.(*clruEntry)
+		c.list.Remove(old.elem)
+		old.removed = true
+	} else {
+		if c.list.Len() >= c.capacity {
+			if oldest := c.list.Back(); oldest != nil {
+				ent := oldest.Value.(*clruEntry)
+				c.removeLocked(ent)
+				atomic.AddInt64(&c.evictions, 1)
+				evicted.add(ent.key, ent.value)
+			}
+		}
+		atomic.AddInt64(&c.size, 1)
+	}
+	ent.elem = c.list.PushFront(ent)
+	c.data.Store(key, ent)
+}
+
+// removeLocked 从链表和索引中移除条目，调用方需持有写锁
+func (c *ConcurrentLRUCache) removeLocked(ent *clruEntry) {
+	c.list.Remove(ent.elem)
+	ent.removed = true
+	c.data.Delete(ent.key)
+	atomic.AddInt64(&c.size, -1)
+}
+
+// OnEvicted 设置条目因容量被淘汰时的回调(过期和主动删除不触发)
+// 回调在释放锁之后同步执行
+func (c *ConcurrentLRUCache) OnEvicted(fn func(key, value interface{})) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.onEvicted = fn
+}
+
+// Delete 删除指定键，返回键是否存在
+func (c *ConcurrentLRUCache) Delete(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	v, ok := c.data.Load(key)
+	if !ok {
+		return false
+	}
+	c.removeLocked(v.(*clruEntry))
+	return true
+}
+
+// Evict 按LRU顺序主动淘汰最多n个条目，返回实际淘汰的数量
+// 用于内存压力下收缩缓存，计入淘汰统计并触发淘汰回调
+func (c *ConcurrentLRUCache) Evict(n int) int {
+	var evicted pendingEvictions
+	c.lock.Lock()
+	c.drainLocked()
+	for evicted.len() < n {
+		oldest := c.list.Back()
+		if oldest == nil {
+			break
+		}
+		ent := oldest.Value.(*clruEntry)
+		c.removeLocked(ent)
+		atomic.AddInt64(&c.evictions, 1)
+		evicted.add(ent.key, ent.value)
+	}
+	onEvicted := c.onEvicted
+	c.lock.Unlock()
+
+	evicted.flush(onEvicted, &c.observerHook)
+	return evicted.len()
+}
+
+// Cleanup 主动清理过期缓存，返回清理的条目数量
+func (c *ConcurrentLRUCache) Cleanup() int {
+	var expiredKeys []interface{}
+	obs, _ := c.observeStart()
+	if obs != nil {
+		defer func() { c.notifyEach(obs, EventExpire, expiredKeys) }()
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := timeNow()
+	count := 0
+	var next *list.Element
+	for elem := c.list.Front(); elem != nil; elem = next {
+		next = elem.Next()
+		ent := elem.Value.(*clruEntry)
+		if !ent.expiresAt.IsZero() && now.After(ent.expiresAt) {
+			c.removeLocked(ent)
+			count++
+			if obs != nil {
+				expiredKeys = append(expiredKeys, ent.key)
+			}
+		}
+	}
+	atomic.AddInt64(&c.expiredCnt, int64(count))
+	return count
+}
+
+// Stats 获取缓存命中统计
+func (c *ConcurrentLRUCache) Stats() (hits, misses, evictions, expired int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses),
+		atomic.LoadInt64(&c.evictions), atomic.LoadInt64(&c.expiredCnt)
+}
+
+// Len 获取当前缓存大小
+func (c *ConcurrentLRUCache) Len() int {
+	return int(atomic.LoadInt64(&c.size))
+}
+
+// Clear 清空缓存
+func (c *ConcurrentLRUCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.drainLocked()
+	for elem := c.list.Front(); elem != nil; elem = elem.Next() {
+		ent := elem.Value.(*clruEntry)
+		ent.removed = true
+		c.data.Delete(ent.key)
+	}
+	c.list.Init()
+	atomic.StoreInt64(&c.size, 0)
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentLRUConformance(t *testing.T) {
+	runCacheConformance(t, func(n int) Cache { return NewConcurrentLRUCache(n, 0) })
+}
+
+// 单协程下回放及时，被访问过的键不应先于未访问的键被淘汰
+func TestConcurrentLRURecency(t *testing.T) {
+	c := NewConcurrentLRUCache(4, 0)
+	for i := 0; i < 4; i++ {
+		c.Put(i, i)
+	}
+	c.Get(0)
+	c.Put(4, 4) // 淘汰1
+	if _, ok := c.Get(0); !ok {
+		t.Fatal("recently read key was evicted")
+	}
+	if _, ok := c.Get(1); ok {
+		t.Fatal("least recently used key should have been evicted")
+	}
+}
+
+// 热点读取与写入并发时，读取结果必须始终是某次写入的值
+func TestConcurrentLRUReadersSeeWrittenValues(t *testing.T) {
+	c := NewConcurrentLRUCache(16, 0)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if v, ok := c.Get("hot"); ok && v.(int)%2 != 0 {
+					t.Errorf("read a value that was never written: %v", v)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20000; i += 2 {
+		c.Put("hot", i)
+		c.Put(i%32, i)
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// lruBenchCapacity 基准测试的缓存容量，小于键空间以产生淘汰
+const lruBenchCapacity = 4096
+
+// lruBenchCaches 基准测试比较的两种LRU实现
+var lruBenchCaches = []struct {
+	name string
+	new  func(capacity int) Cache
+}{
+	{"LRUCache", func(n int) Cache { return NewLRUCache(n, 0) }},
+	{"ConcurrentLRUCache", func(n int) Cache { return NewConcurrentLRUCache(n, 0) }},
+}
+
+// runLRUBench 用固定数量的协程分摊b.N次操作
+// readPercent为读操作占比，键按Zipf分布选取，模拟少数热点键承担大部分读取
+func runLRUBench(b *testing.B, c Cache, goroutines, readPercent int) {
+	const keys = 10000
+	for i := 0; i < keys/2; i++ {
+		c.Put(i, i)
+	}
+	per := b.N/goroutines + 1
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			zipf := rand.NewZipf(r, 1.1, 1, keys-1)
+			for i := 0; i < per; i++ {
+				key := int(zipf.Uint64())
+				if r.Intn(100) < readPercent {
+					c.Get(key)
+				} else {
+					c.Put(key, i)
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+}
+
+func benchmarkLRUVariants(b *testing.B, readPercent int) {
+	for _, impl := range lruBenchCaches {
+		for _, goroutines := range []int{1, 4, 16, 64} {
+			b.Run(fmt.Sprintf("%s/goroutines-%d", impl.name, goroutines), func(b *testing.B) {
+				runLRUBench(b, impl.new(lruBenchCapacity), goroutines, readPercent)
+			})
+		}
+	}
+}
+
+func BenchmarkLRUReadOnly(b *testing.B) { benchmarkLRUVariants(b, 100) }
+
+func BenchmarkLRUReadHeavy(b *testing.B) { benchmarkLRUVariants(b, 90) }
@@ -0,0 +1,451 @@
+package cache
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 可控时钟，替换timeNow后测试过期语义无需真实等待
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// useFakeClock 在测试期间用可控时钟替换timeNow，测试结束后恢复
+func useFakeClock(t testing.TB) *fakeClock {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	old := timeNow
+	timeNow = clock.Now
+	t.Cleanup(func() { timeNow = old })
+	return clock
+}
+
+// cachePolicy 参与一致性测试的淘汰策略
+type cachePolicy struct {
+	name     string
+	newCache func(capacity int) Cache
+	newModel func(capacity int) cacheModel
+}
+
+var cachePolicies = []cachePolicy{
+	{"lru", func(n int) Cache { return NewLRUCache(n, 0) }, newLRUModel},
+	{"lfu", func(n int) Cache { return NewLFUCache(n) }, newLFUModel},
+	{"fifo", func(n int) Cache { return NewFIFOCache(n) }, newFIFOModel},
+	{"arc", func(n int) Cache { return NewARCCache(n) }, newARCModel},
+	{"lru-2", func(n int) Cache { return mustLRUKCache(n, 2, 0, 0) }, newLRUKModel(2, 0)},
+	{"lru-3-crp", func(n int) Cache { return mustLRUKCache(n, 3, 3*time.Millisecond, 0) }, newLRUKModel(3, 3*time.Millisecond)},
+}
+
+// newTestCache 创建缓存并在测试结束时停止后台协程
+func newTestCache(t testing.TB, newCache func(int) Cache, capacity int) Cache {
+	c := newCache(capacity)
+	t.Cleanup(func() { closeCache(c) })
+	return c
+}
+
+func TestCacheConformance(t *testing.T) {
+	for _, p := range cachePolicies {
+		p := p
+		t.Run(p.name, func(t *testing.T) { runCacheConformance(t, p.newCache) })
+	}
+}
+
+// runCacheConformance 与具体策略无关的通用约束，任何Cache实现都可以直接调用
+func runCacheConformance(t *testing.T, newCache func(capacity int) Cache) {
+	t.Run("Capacity", func(t *testing.T) {
+		c := newTestCache(t, newCache, 8)
+		for i := 0; i < 100; i++ {
+			c.Put(i, i)
+			if c.Len() > 8 {
+				t.Fatalf("Len = %d exceeds capacity after %d puts", c.Len(), i+1)
+			}
+		}
+		if c.Len() != 8 {
+			t.Fatalf("Len = %d, want a full cache", c.Len())
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		c := newTestCache(t, newCache, 4)
+		for i := 0; i < 10; i++ {
+			c.Put("k", i)
+		}
+		if v, ok := c.Get("k"); !ok || v != 9 || c.Len() != 1 {
+			t.Fatalf("Get = %v, %v, Len = %d", v, ok, c.Len())
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c := newTestCache(t, newCache, 4)
+		c.Put("k", 1)
+		if !c.Delete("k") || c.Delete("k") || c.Delete("missing") {
+			t.Fatal("Delete should report presence exactly once")
+		}
+		if _, ok := c.Get("k"); ok || c.Len() != 0 {
+			t.Fatal("deleted key is still visible")
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		clock := useFakeClock(t)
+		c := newTestCache(t, newCache, 4)
+		c.PutWithExpiration("ttl", 1, 10*time.Second)
+		c.PutWithExpiration("cleared", 2, 10*time.Second)
+		c.Put("cleared", 3) // Put覆盖时清除过期时间
+		clock.Advance(10 * time.Second)
+		if _, ok := c.Get("ttl"); !ok {
+			t.Fatal("entry expired at exactly its deadline")
+		}
+		clock.Advance(time.Nanosecond)
+		if _, ok := c.Get("ttl"); ok {
+			t.Fatal("entry should have expired")
+		}
+		if v, ok := c.Get("cleared"); !ok || v != 3 {
+			t.Fatal("Put should clear the previous expiration")
+		}
+		if s, ok := c.(statsSource); ok {
+			if _, _, _, expired := s.Stats(); expired != 1 {
+				t.Fatalf("expired = %d", expired)
+			}
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		clock := useFakeClock(t)
+		c := newTestCache(t, newCache, 16)
+		s, ok := c.(statsSource)
+		if !ok {
+			t.Skip("cache does not report stats")
+		}
+		runStatsConsistency(t, c, s, clock)
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		c := newTestCache(t, newCache, 4)
+		for i := 0; i < 4; i++ {
+			c.Put(i, i)
+		}
+		c.Clear()
+		if c.Len() != 0 {
+			t.Fatalf("Len = %d after Clear", c.Len())
+		}
+		c.Put("k", 1)
+		if v, ok := c.Get("k"); !ok || v != 1 {
+			t.Fatal("cache unusable after Clear")
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		c := newTestCache(t, newCache, 32)
+		if n, ok := c.(evictNotifier); ok {
+			// 回调在释放锁之后执行，回调中访问缓存不能死锁
+			n.OnEvicted(func(key, value interface{}) { c.Len() })
+		}
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+				r := rand.New(rand.NewSource(seed))
+				for i := 0; i < 2000; i++ {
+					key := r.Intn(64)
+					switch r.Intn(4) {
+					case 0, 1:
+						c.Get(key)
+					case 2:
+						c.PutWithExpiration(key, i, time.Duration(r.Intn(3))*time.Millisecond)
+					case 3:
+						c.Delete(key)
+					}
+					if n := c.Len(); n > 32 {
+						t.Errorf("Len = %d exceeds capacity", n)
+						return
+					}
+				}
+			}(int64(g))
+		}
+		wg.Wait()
+	})
+}
+
+// TestGetRacesEviction 容量为1时每次写入都会淘汰另一个键，条目写入后立即过期
+// Get若在读锁和写锁之间放开锁，拿到的条目可能已被淘汰或替换，
+// 随后按过期删除时会删掉同名的新条目，哈希表与链表(堆)不再一致
+func TestGetRacesEviction(t *testing.T) {
+	lru, lfu, fifo := NewLRUCache(1, 0), NewLFUCache(1), NewFIFOCache(1)
+	t.Cleanup(func() { lfu.Close(); fifo.Close() })
+	for _, p := range []struct {
+		name       string
+		c          Cache
+		consistent func() bool
+	}{
+		{"lru", lru, func() bool {
+			lru.lock.RLock()
+			defer lru.lock.RUnlock()
+			return lru.list.Len() == len(lru.cache)
+		}},
+		{"lfu", lfu, func() bool {
+			lfu.lock.RLock()
+			defer lfu.lock.RUnlock()
+			return lfu.heap.Len() == len(lfu.cache)
+		}},
+		{"fifo", fifo, func() bool {
+			fifo.lock.RLock()
+			defer fifo.lock.RUnlock()
+			return fifo.queue.Len() == len(fifo.cache)
+		}},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			c := p.c
+			var wg sync.WaitGroup
+			for g := 0; g < 4; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 20000; i++ {
+						if g%2 == 0 {
+							c.PutWithExpiration(i%2, i, time.Nanosecond)
+							if !p.consistent() {
+								t.Error("index and eviction order disagree")
+								return
+							}
+						} else {
+							c.Get(i % 2)
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+// runStatsConsistency 用随机操作检查统计信息与外部观察一致:
+// 命中/未命中与Get的返回一致，淘汰数与回调次数一致，
+// 未被淘汰或删除的键在过期前必须命中且值正确、过期后必须未命中并计入过期数
+func runStatsConsistency(t *testing.T, c Cache, s statsSource, clock *fakeClock) {
+	type known struct {
+		value     int
+		expiresAt time.Time
+	}
+	present := map[int]known{}
+	var callbacks int64
+	if n, ok := c.(evictNotifier); ok {
+		n.OnEvicted(func(key, value interface{}) {
+			callbacks++
+			delete(present, key.(int))
+		})
+	}
+
+	var hits, misses, expired int64
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		clock.Advance(time.Millisecond)
+		key := r.Intn(40)
+		switch r.Intn(5) {
+		case 0, 1:
+			v, ok := c.Get(key)
+			want, isPresent := present[key]
+			stillValid := isPresent && (want.expiresAt.IsZero() || !timeNow().After(want.expiresAt))
+			switch {
+			case stillValid && (!ok || v != want.value):
+				t.Fatalf("op %d: Get(%d) = %v, %v; want %d", i, key, v, ok, want.value)
+			case !stillValid && ok:
+				t.Fatalf("op %d: Get(%d) returned %v for an absent or expired key", i, key, v)
+			case isPresent && !stillValid:
+				expired++
+				delete(present, key)
+			}
+			if ok {
+				hits++
+			} else {
+				misses++
+			}
+		case 2, 3:
+			var ttl time.Duration
+			if r.Intn(3) == 0 {
+				ttl = time.Duration(1+r.Intn(20)) * time.Millisecond
+			}
+			c.PutWithExpiration(key, i, ttl)
+			k := known{value: i}
+			if ttl > 0 {
+				k.expiresAt = timeNow().Add(ttl)
+			}
+			present[key] = k
+		case 4:
+			_, isPresent := present[key]
+			if c.Delete(key) != isPresent {
+				t.Fatalf("op %d: Delete(%d) disagrees with presence %v", i, key, isPresent)
+			}
+			delete(present, key)
+		}
+		if c.Len() != len(present) {
+			t.Fatalf("op %d: Len = %d, tracked %d", i, c.Len(), len(present))
+		}
+	}
+
+	gotHits, gotMisses, evictions, gotExpired := s.Stats()
+	if gotHits != hits || gotMisses != misses || gotExpired != expired {
+		t.Fatalf("stats hits=%d misses=%d expired=%d, observed %d %d %d",
+			gotHits, gotMisses, gotExpired, hits, misses, expired)
+	}
+	if _, ok := c.(evictNotifier); ok && evictions != callbacks {
+		t.Fatalf("evictions = %d but %d callbacks fired", evictions, callbacks)
+	}
+}
+
+// cacheOp 模型比对使用的一个操作
+type cacheOp struct {
+	kind    byte // 0 Get, 1 Put, 2 Delete, 3 拨动时钟
+	key     int
+	ttl     time.Duration
+	advance time.Duration
+}
+
+// decodeCacheOps 把任意字节序列解码为操作序列，每3个字节一个操作
+func decodeCacheOps(data []byte) []cacheOp {
+	var ops []cacheOp
+	for i := 0; i+2 < len(data); i += 3 {
+		op := cacheOp{key: int(data[i+1] % 16)}
+		switch data[i] % 10 {
+		case 0, 1, 2, 3:
+			op.kind = 0
+		case 4, 5, 6:
+			op.kind = 1
+			if data[i+2]%4 != 0 {
+				op.ttl = time.Duration(data[i+2]%16) * time.Millisecond
+			}
+		case 7:
+			op.kind = 2
+		default:
+			op.kind = 3
+			op.advance = time.Duration(data[i+2]%8) * time.Millisecond
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// runAgainstModel 在实现和参考模型上执行同一操作序列，逐步比对
+func runAgainstModel(t *testing.T, p cachePolicy, capacity int, ops []cacheOp) {
+	clock := useFakeClock(t)
+	c := newTestCache(t, p.newCache, capacity)
+	m := p.newModel(capacity)
+
+	for i, op := range ops {
+		// 每个操作推进时钟，LRU-K依赖访问时间区分先后
+		clock.Advance(time.Millisecond + op.advance)
+		switch op.kind {
+		case 0:
+			v, ok := c.Get(op.key)
+			mv, mok := m.Get(op.key)
+			if ok != mok || (ok && v != mv) {
+				t.Fatalf("%s op %d: Get(%d) = %v, %v; model %v, %v", p.name, i, op.key, v, ok, mv, mok)
+			}
+		case 1:
+			c.PutWithExpiration(op.key, i, op.ttl)
+			m.Put(op.key, i, op.ttl)
+		case 2:
+			if got, want := c.Delete(op.key), m.Delete(op.key); got != want {
+				t.Fatalf("%s op %d: Delete(%d) = %v; model %v", p.name, i, op.key, got, want)
+			}
+		}
+		if c.Len() != m.Len() || c.Len() > capacity {
+			t.Fatalf("%s op %d: Len = %d; model %d", p.name, i, c.Len(), m.Len())
+		}
+	}
+
+	s := c.(statsSource)
+	h, mi, e, x := s.Stats()
+	mh, mmi, me, mx := m.Stats()
+	if h != mh || mi != mmi || e != me || x != mx {
+		t.Fatalf("%s stats = %d/%d/%d/%d; model %d/%d/%d/%d", p.name, h, mi, e, x, mh, mmi, me, mx)
+	}
+}
+
+func TestCacheMatchesModel(t *testing.T) {
+	for _, p := range cachePolicies {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			for seed := int64(0); seed < 20; seed++ {
+				data := make([]byte, 3*2000)
+				rand.New(rand.NewSource(seed)).Read(data)
+				runAgainstModel(t, p, 1+int(seed%8), decodeCacheOps(data))
+			}
+		})
+	}
+}
+
+// TestARCGhostHitGoesToT2 ARC命中幽灵条目后应增大p并直接进入t2，缓存保持满容量
+func TestARCGhostHitGoesToT2(t *testing.T) {
+	c := NewARCCache(2)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Get("a")    // a晋升到t2
+	c.Put("c", 3) // t1中的b进入b1
+	if c.Len() != 2 || !c.isInList(c.lookup["b"], c.b1) {
+		t.Fatal("b should have become a ghost in b1")
+	}
+	c.Put("b", 2) // 命中b1: p增大，t2中的a进入b2
+	if !c.isInList(c.lookup["b"], c.t2) || !c.isInList(c.lookup["a"], c.b2) {
+		t.Fatal("ghost hit should be placed in t2")
+	}
+	if c.p != 1 || c.Len() != 2 {
+		t.Fatalf("p = %d, Len = %d", c.p, c.Len())
+	}
+}
+
+// TestARCEvictWithEmptyT2 主动删除可能让t2为空而|t1|<=p，此时只能从t1淘汰
+// 否则replace选中空的t2什么也不淘汰，Evict的循环永远无法结束
+func TestARCEvictWithEmptyT2(t *testing.T) {
+	c := NewARCCache(2)
+	c.Put("a", 1)
+	c.Get("a") // a晋升到t2
+	c.Put("b", 2)
+	c.Put("c", 3) // t1中的b进入b1
+	c.Put("b", 2) // 命中b1: p=1，t2中的a进入b2
+	c.Delete("b") // t2为空，t1中只有c
+	if c.p != 1 || c.t1.Len() != 1 || c.t2.Len() != 0 {
+		t.Fatalf("p = %d, t1 = %d, t2 = %d", c.p, c.t1.Len(), c.t2.Len())
+	}
+
+	done := make(chan int, 1)
+	go func() { done <- c.Evict(1) }()
+	select {
+	case n := <-done:
+		if n != 1 || c.Len() != 0 || !c.isInList(c.lookup["c"], c.b1) {
+			t.Fatalf("Evict = %d, Len = %d", n, c.Len())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Evict did not return")
+	}
+}
+
+func FuzzCacheMatchesModel(f *testing.F) {
+	f.Add([]byte{4, 1, 0, 4, 2, 0, 4, 3, 0, 0, 1, 0, 4, 4, 0, 4, 5, 0})
+	f.Add([]byte{4, 1, 5, 8, 0, 7, 0, 1, 0, 7, 1, 0, 4, 1, 0, 0, 1, 0})
+	f.Add([]byte{4, 1, 0, 4, 2, 0, 4, 3, 0, 4, 1, 0, 4, 4, 0, 4, 2, 0, 4, 5, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ops := decodeCacheOps(data)
+		for _, p := range cachePolicies {
+			for _, capacity := range []int{1, 3} {
+				runAgainstModel(t, p, capacity, ops)
+			}
+		}
+	})
+}
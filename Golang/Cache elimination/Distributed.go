@@ -0,0 +1,421 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fnv32 FNV-1哈希，与Third.go中ConcurrentMapWithShard使用的fnv32相同
+// 两处目录分属不同的包，无法直接引用，这里保留一份相同的实现
+func fnv32(key string) uint32 {
+	hash := uint32(2166136261)
+	const prime32 = uint32(16777619)
+	for i := 0; i < len(key); i++ {
+		hash *= prime32
+		hash ^= uint32(key[i])
+	}
+	return hash
+}
+
+// ringHash 环上位置的哈希
+// FNV-1的末字节只影响低位，"node#1"、"key-42"这类相近的短字符串会在环上聚集，
+// 因此在fnv32之后再做一次murmur3的fmix32混淆，使高位也充分扩散
+func ringHash(key string) uint32 {
+	h := fnv32(key)
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// HashRing 带虚拟节点的一致性哈希环
+// 每个节点在环上放置replicas个虚拟节点，键顺时针归属第一个虚拟节点对应的节点
+// 节点增减时只有相邻区间的键需要迁移
+type HashRing struct {
+	replicas int               // 每个节点的虚拟节点数
+	hashes   []uint32          // 虚拟节点哈希值，升序
+	owners   map[uint32]string // 虚拟节点哈希 -> 节点
+}
+
+// NewHashRing 创建哈希环
+// replicas: 每个节点的虚拟节点数，<=0时使用50
+func NewHashRing(replicas int, nodes ...string) *HashRing {
+	if replicas <= 0 {
+		replicas = 50
+	}
+	r := &HashRing{
+		replicas: replicas,
+		owners:   make(map[uint32]string),
+	}
+	r.Add(nodes...)
+	return r
+}
+
+// Add 添加节点
+func (r *HashRing) Add(nodes ...string) {
+	for _, node := range nodes {
+		for i := 0; i < r.replicas; i++ {
+			h := ringHash(strconv.Itoa(i) + "#" + node)
+			if _, ok := r.owners[h]; ok {
+				continue // 极少见的哈希冲突，保留先加入的节点
+			}
+			r.owners[h] = node
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+}
+
+// Get 获取键所属的节点，环为空时返回空字符串
+func (r *HashRing) Get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := ringHash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0 // 回绕到环的起点
+	}
+	return r.owners[r.hashes[i]]
+}
+
+// flightCall 一次进行中的加载
+type flightCall struct {
+	done     chan struct{} // 加载结束后关闭
+	val      interface{}
+	err      error
+	panicked bool        // fn是否发生panic
+	panicVal interface{} // fn的panic值，在每个等待者中重新抛出
+}
+
+// flightGroup 合并同一个键的并发加载，同一时刻只有一个加载在执行
+type flightGroup struct {
+	mu sync.Mutex
+	m  map[string]*flightCall
+}
+
+// Do 执行fn，同一键的并发调用共享第一次调用的结果
+// fn在独立协程中以脱离调用方取消的上下文运行，timeout大于0时限制其最长时间；每个调用方只按自己的ctx放弃等待，
+// 第一个调用方取消不会让其他等待者失败。fn发生panic时在所有等待者中重新抛出
+func (g *flightGroup) Do(ctx context.Context, key string, timeout time.Duration, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*flightCall)
+	}
+	c, ok := g.m[key]
+	if !ok {
+		c = &flightCall{done: make(chan struct{})}
+		g.m[key] = c
+		detached := context.WithoutCancel(ctx)
+		go func() {
+			loadCtx := detached
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				loadCtx, cancel = context.WithTimeout(detached, timeout)
+				defer cancel()
+			}
+			g.call(c, key, func() (interface{}, error) { return fn(loadCtx) })
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		if c.panicked {
+			panic(c.panicVal)
+		}
+		return c.val, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// call 执行fn并记录结果，无论正常返回还是panic都会删除键并唤醒等待者
+func (g *flightGroup) call(c *flightCall, key string, fn func() (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.panicked, c.panicVal = true, r
+		}
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn()
+}
+
+// LoaderFunc 回源加载函数，只在键的所属节点上调用
+type LoaderFunc func(ctx context.Context, key string) ([]byte, error)
+
+// errNotFound 回源结果为不存在时，对端以404返回
+var errNotFound = errors.New("distributed cache: key not found")
+
+// DistributedConfig 分布式缓存配置
+type DistributedConfig struct {
+	Self         string        // 本节点地址，如 http://127.0.0.1:8001，须与SetPeers中的地址一致
+	BasePath     string        // 节点间通信的路径前缀，默认 /_dcache/
+	Replicas     int           // 每个节点的虚拟节点数
+	MainCapacity int           // 本节点所属键的缓存容量
+	HotCapacity  int           // 远端键热点缓存容量，默认为MainCapacity的1/8
+	TTL          time.Duration // 条目过期时间，0表示永不过期
+	HotTTL       time.Duration // 热点缓存过期时间，默认与TTL相同；远端键更新不会通知本节点，宜设得较短
+	LoadTimeout  time.Duration // 一次合并加载(对端请求加回源)的最长时间，默认10秒
+	Client       *http.Client  // 请求对端使用的客户端，默认超时5秒
+}
+
+// DistributedCache groupcache风格的分布式缓存
+// 设计特点:
+// - 一致性哈希环将键空间划分到各节点，每个键只有一个所属节点
+// - 非所属节点未命中时通过HTTP转发给所属节点，由所属节点回源一次并缓存
+// - 同一键的并发加载在本节点内合并，所属节点对多个对端的请求也只回源一次
+// - 非所属节点用一个小的热点缓存保存远端键，减少热点键的跨节点请求
+// - 对端不可用时退化为本地回源，保证可用性
+type DistributedCache struct {
+	name   string
+	cfg    DistributedConfig
+	loader LoaderFunc
+
+	mainCache Cache       // 本节点所属的键
+	hotCache  Cache       // 其他节点所属的热点键
+	flight    flightGroup // 合并并发加载
+
+	peersMu sync.RWMutex
+	ring    *HashRing
+
+	stats struct { // 运行时统计信息(原子操作)
+		gets         int64 // Get调用次数
+		mainHits     int64 // 本节点缓存命中
+		hotHits      int64 // 热点缓存命中
+		peerLoads    int64 // 从对端成功获取
+		peerErrors   int64 // 对端请求失败(已退化为本地回源)
+		localLoads   int64 // 本地回源次数
+		loadErrors   int64 // 本地回源失败次数
+		peerRequests int64 // 处理对端请求次数
+	}
+}
+
+// NewDistributedCache 创建分布式缓存节点
+// name: 缓存名称，同一集群内各节点须一致，作为通信路径的一部分
+func NewDistributedCache(name string, loader LoaderFunc, cfg DistributedConfig) *DistributedCache {
+	if cfg.BasePath == "" {
+		cfg.BasePath = "/_dcache/"
+	}
+	if cfg.MainCapacity <= 0 {
+		cfg.MainCapacity = 1024
+	}
+	if cfg.HotCapacity <= 0 {
+		cfg.HotCapacity = max(1, cfg.MainCapacity/8)
+	}
+	if cfg.HotTTL <= 0 {
+		cfg.HotTTL = cfg.TTL
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+	if cfg.LoadTimeout <= 0 {
+		cfg.LoadTimeout = 10 * time.Second
+	}
+	d := &DistributedCache{
+		name:      name,
+		cfg:       cfg,
+		loader:    loader,
+		mainCache: NewLRUCache(cfg.MainCapacity, cfg.TTL),
+		hotCache:  NewLRUCache(cfg.HotCapacity, cfg.HotTTL),
+		ring:      NewHashRing(cfg.Replicas),
+	}
+	return d
+}
+
+// SetPeers 设置集群全部节点(包含本节点)，替换原有节点列表
+func (d *DistributedCache) SetPeers(peers ...string) {
+	ring := NewHashRing(d.cfg.Replicas, peers...)
+	d.peersMu.Lock()
+	d.ring = ring
+	d.peersMu.Unlock()
+	d.hotCache.Clear() // 归属关系变化后热点缓存可能过时
+}
+
+// Owner 返回键所属的节点
+func (d *DistributedCache) Owner(key string) string {
+	d.peersMu.RLock()
+	defer d.peersMu.RUnlock()
+	return d.ring.Get(key)
+}
+
+// Get 获取键对应的值
+// 1. 查本节点缓存和热点缓存
+// 2. 键属于其他节点时转发给所属节点，成功则放入热点缓存
+// 3. 键属于本节点或对端不可用时本地回源
+func (d *DistributedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	atomic.AddInt64(&d.stats.gets, 1)
+	if v, ok := d.mainCache.Get(key); ok {
+		atomic.AddInt64(&d.stats.mainHits, 1)
+		return cloneBytes(v.([]byte)), nil
+	}
+	if v, ok := d.hotCache.Get(key); ok {
+		atomic.AddInt64(&d.stats.hotHits, 1)
+		return cloneBytes(v.([]byte)), nil
+	}
+
+	val, err := d.flight.Do(ctx, key, d.cfg.LoadTimeout, func(ctx context.Context) (interface{}, error) {
+		// 查缓存与进入flight之间上一次加载可能刚好完成并离开flight，再查一次避免重复加载
+		if v, ok := d.hotCache.Get(key); ok {
+			return v, nil
+		}
+		owner := d.Owner(key)
+		if owner != "" && owner != d.cfg.Self {
+			val, err := d.fetchFromPeer(ctx, owner, key)
+			if err == nil {
+				atomic.AddInt64(&d.stats.peerLoads, 1)
+				d.hotCache.Put(key, val)
+				return val, nil
+			}
+			if errors.Is(err, errNotFound) || ctx.Err() != nil {
+				return nil, err
+			}
+			atomic.AddInt64(&d.stats.peerErrors, 1)
+		}
+		return d.loadLocally(ctx, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cloneBytes(val.([]byte)), nil
+}
+
+// Remove 从本节点的缓存中删除键(不通知其他节点)
+func (d *DistributedCache) Remove(key string) {
+	d.mainCache.Delete(key)
+	d.hotCache.Delete(key)
+}
+
+// getAsOwner 处理对端转发来的请求，不再向外转发以避免环路
+func (d *DistributedCache) getAsOwner(ctx context.Context, key string) ([]byte, error) {
+	if v, ok := d.mainCache.Get(key); ok {
+		atomic.AddInt64(&d.stats.mainHits, 1)
+		return v.([]byte), nil
+	}
+	val, err := d.flight.Do(ctx, key, d.cfg.LoadTimeout, func(ctx context.Context) (interface{}, error) {
+		return d.loadLocally(ctx, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.([]byte), nil
+}
+
+// loadLocally 本地回源并放入本节点缓存，在flight中调用
+// 调用方查缓存未命中后，上一次加载可能在其进入flight之前完成，因此回源前再查一次本节点缓存
+func (d *DistributedCache) loadLocally(ctx context.Context, key string) ([]byte, error) {
+	if v, ok := d.mainCache.Get(key); ok {
+		return v.([]byte), nil
+	}
+	atomic.AddInt64(&d.stats.localLoads, 1)
+	val, err := d.loader(ctx, key)
+	if err != nil {
+		atomic.AddInt64(&d.stats.loadErrors, 1)
+		return nil, err
+	}
+	if val == nil {
+		return nil, errNotFound
+	}
+	d.mainCache.Put(key, cloneBytes(val))
+	return val, nil
+}
+
+// fetchFromPeer 通过HTTP向所属节点请求键
+func (d *DistributedCache) fetchFromPeer(ctx context.Context, peer, key string) ([]byte, error) {
+	u := strings.TrimRight(peer, "/") + d.cfg.BasePath + url.PathEscape(d.name) + "/" + url.PathEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, errNotFound
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("distributed cache: peer %s returned %s: %s", peer, resp.Status, strings.TrimSpace(string(msg)))
+	}
+}
+
+// ServeHTTP 处理对端请求：GET {BasePath}{name}/{key}
+func (d *DistributedCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, d.cfg.BasePath) {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.SplitN(path[len(d.cfg.BasePath):], "/", 2)
+	if len(parts) != 2 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	name, err1 := url.PathUnescape(parts[0])
+	key, err2 := url.PathUnescape(parts[1])
+	if err1 != nil || err2 != nil || name != d.name {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	atomic.AddInt64(&d.stats.peerRequests, 1)
+	val, err := d.getAsOwner(r.Context(), key)
+	if errors.Is(err, errNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(val)
+}
+
+// DistributedStats 分布式缓存统计信息
+type DistributedStats struct {
+	Gets, MainHits, HotHits, PeerLoads, PeerErrors, LocalLoads, LoadErrors, PeerRequests int64
+}
+
+// Stats 获取统计信息
+func (d *DistributedCache) Stats() DistributedStats {
+	return DistributedStats{
+		Gets:         atomic.LoadInt64(&d.stats.gets),
+		MainHits:     atomic.LoadInt64(&d.stats.mainHits),
+		HotHits:      atomic.LoadInt64(&d.stats.hotHits),
+		PeerLoads:    atomic.LoadInt64(&d.stats.peerLoads),
+		PeerErrors:   atomic.LoadInt64(&d.stats.peerErrors),
+		LocalLoads:   atomic.LoadInt64(&d.stats.localLoads),
+		LoadErrors:   atomic.LoadInt64(&d.stats.loadErrors),
+		PeerRequests: atomic.LoadInt64(&d.stats.peerRequests),
+	}
+}
+
+// cloneBytes 复制字节切片，避免调用方修改缓存中的数据
+func cloneBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
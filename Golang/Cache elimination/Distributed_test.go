@@ -0,0 +1,270 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCluster 在回环地址上启动多个节点
+type testCluster struct {
+	nodes   []*DistributedCache
+	servers []*httptest.Server
+	loads   []int64 // 各节点的回源次数
+}
+
+func newTestCluster(t *testing.T, n int) *testCluster {
+	t.Helper()
+	tc := &testCluster{loads: make([]int64, n)}
+	var peers []string
+	for i := 0; i < n; i++ {
+		i := i
+		node := NewDistributedCache("test", func(ctx context.Context, key string) ([]byte, error) {
+			atomic.AddInt64(&tc.loads[i], 1)
+			if key == "missing" {
+				return nil, nil
+			}
+			if key == "broken" {
+				return nil, errors.New("origin down")
+			}
+			return []byte("value-of-" + key), nil
+		}, DistributedConfig{MainCapacity: 128})
+		srv := httptest.NewServer(node)
+		node.cfg.Self = srv.URL
+		tc.nodes = append(tc.nodes, node)
+		tc.servers = append(tc.servers, srv)
+		peers = append(peers, srv.URL)
+	}
+	for _, node := range tc.nodes {
+		node.SetPeers(peers...)
+	}
+	t.Cleanup(func() {
+		for _, srv := range tc.servers {
+			srv.Close()
+		}
+	})
+	return tc
+}
+
+func (tc *testCluster) totalLoads() int64 {
+	var total int64
+	for i := range tc.loads {
+		total += atomic.LoadInt64(&tc.loads[i])
+	}
+	return total
+}
+
+func TestHashRingDistribution(t *testing.T) {
+	ring := NewHashRing(100, "a", "b", "c")
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		counts[ring.Get(fmt.Sprintf("key-%d", i))]++
+	}
+	for _, node := range []string{"a", "b", "c"} {
+		if counts[node] < 500 {
+			t.Errorf("node %s owns only %d of 3000 keys: %v", node, counts[node], counts)
+		}
+	}
+
+	// 新增节点只迁移部分键
+	before := make(map[string]string)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = ring.Get(key)
+	}
+	ring.Add("d")
+	moved := 0
+	for key, owner := range before {
+		if now := ring.Get(key); now != owner {
+			if now != "d" {
+				t.Fatalf("key %s moved from %s to %s instead of the new node", key, owner, now)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 1500 {
+		t.Errorf("moved %d of 3000 keys after adding a node", moved)
+	}
+}
+
+func TestDistributedCacheLoadsOnceOnOwner(t *testing.T) {
+	tc := newTestCluster(t, 3)
+	ctx := context.Background()
+
+	for round := 0; round < 2; round++ {
+		for _, node := range tc.nodes {
+			val, err := node.Get(ctx, "user:42")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(val) != "value-of-user:42" {
+				t.Fatalf("got %q", val)
+			}
+		}
+	}
+	if got := tc.totalLoads(); got != 1 {
+		t.Fatalf("expected exactly one origin load, got %d", got)
+	}
+
+	owner := tc.nodes[0].Owner("user:42")
+	for i, node := range tc.nodes {
+		if node.cfg.Self == owner {
+			if tc.loads[i] != 1 {
+				t.Fatalf("owner %s did not perform the load", owner)
+			}
+			continue
+		}
+		// 第二轮应命中热点缓存，不再请求所属节点
+		if s := node.Stats(); s.PeerLoads != 1 || s.HotHits != 1 {
+			t.Errorf("node %s stats = %+v", node.cfg.Self, s)
+		}
+	}
+}
+
+func TestDistributedCacheConcurrentGets(t *testing.T) {
+	tc := newTestCluster(t, 3)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			node := tc.nodes[i%len(tc.nodes)]
+			if _, err := node.Get(ctx, "hot"); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := tc.totalLoads(); got != 1 {
+		t.Fatalf("expected one origin load under concurrency, got %d", got)
+	}
+}
+
+func TestDistributedCacheErrors(t *testing.T) {
+	tc := newTestCluster(t, 2)
+	ctx := context.Background()
+
+	for _, node := range tc.nodes {
+		if _, err := node.Get(ctx, "missing"); !errors.Is(err, errNotFound) {
+			t.Errorf("missing key: got %v", err)
+		}
+		if _, err := node.Get(ctx, "broken"); err == nil {
+			t.Error("expected origin error")
+		}
+	}
+}
+
+func TestDistributedCachePeerDown(t *testing.T) {
+	tc := newTestCluster(t, 2)
+	ctx := context.Background()
+
+	// 找到一个属于节点1的键，然后关闭节点1
+	var key string
+	for i := 0; ; i++ {
+		key = fmt.Sprintf("k%d", i)
+		if tc.nodes[0].Owner(key) == tc.nodes[1].cfg.Self {
+			break
+		}
+	}
+	tc.servers[1].Close()
+
+	val, err := tc.nodes[0].Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "value-of-"+key {
+		t.Fatalf("got %q", val)
+	}
+	if s := tc.nodes[0].Stats(); s.PeerErrors != 1 || s.LocalLoads != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+// 第一个调用方取消只影响它自己，其他等待者仍拿到加载结果，加载本身不被取消
+func TestFlightGroupCallerCancel(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-release:
+			return "v", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := g.Do(first, "k", time.Second, load)
+		firstErr <- err
+	}()
+	// 等第一个调用发起加载，第二个调用加入等待
+	for {
+		g.mu.Lock()
+		_, started := g.m["k"]
+		g.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan interface{}, 1)
+	go func() {
+		v, err := g.Do(context.Background(), "k", time.Second, func(context.Context) (interface{}, error) {
+			t.Error("second caller should join the running load")
+			return nil, nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		second <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first caller err = %v", err)
+	}
+	close(release)
+	if v := <-second; v != "v" {
+		t.Fatalf("second caller got %v", v)
+	}
+}
+
+// fn发生panic时每个等待者都收到panic，键被删除，下一次调用重新加载
+func TestFlightGroupPanic(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Errorf("recovered %v", r)
+				}
+			}()
+			g.Do(context.Background(), "k", 0, func(context.Context) (interface{}, error) {
+				<-release
+				panic("boom")
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	v, err := g.Do(context.Background(), "k", 0, func(context.Context) (interface{}, error) { return "v", nil })
+	if err != nil || v != "v" {
+		t.Fatalf("Do after panic = %v, %v", v, err)
+	}
+}
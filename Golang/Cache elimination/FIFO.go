@@ -1,8 +1,7 @@
-package主干
+package cache
 
 import (
 	"container/list"
-	"fmt"
 	"sync"
 	"time"
 )
@@ -10,22 +9,26 @@ import (
 // FIFO Cache 线程安全的FIFO缓存结构
 // 使用哈希表+双向链表实现，哈希表提供O(1)访问，链表维护FIFO顺序
 type FIFOCache struct {
-	capacity int                    // 缓存最大容量
-	cache    map[interface{}]*entry // 哈希表存储键和entry指针
-	queue    *list.List             // 双向链表，维护插入顺序(FIFO)
-	lock     sync.RWMutex           // 读写锁，保证线程安全
-	stats    struct {               // 运行时统计信息
-		hits   int64 // 命中次数
-		misses int64 // 未命中次数
+	capacity int                        // 缓存最大容量
+	cache    map[interface{}]*fifoEntry // 哈希表存储键和entry指针
+	queue    *list.List                 // 双向链表，维护插入顺序(FIFO)
+	lock     sync.RWMutex               // 读写锁，保证线程安全
+	stats    struct {                   // 运行时统计信息
+		hits         int64 // 命中次数
+		misses       int64 // 未命中次数
+		evictions    int64 // 因容量淘汰的条目数
+		expiredCount int64 // 因过期淘汰的条目数
 	}
-	stopChan chan struct{} // 用于停止后台清理协程
+	stopChan  chan struct{}                // 用于停止后台清理协程
+	onEvicted func(key, value interface{}) // 因容量淘汰时的回调
+
+	observerHook // 事件观察者
 }
 
-// entry 缓存条目结构
-type entry struct {
-	key       interface{} // 缓存键
-	value     interface{} // 缓存值
-	expiresAt time.Time   // 过期时间(零值表示永不过期)
+// fifoEntry FIFO缓存条目
+type fifoEntry struct {
+	entry
+	elem *list.Element // 在FIFO队列中的节点，用于O(1)删除
 }
 
 // NewFIFOCache 创建新的FIFO缓存实例
@@ -33,9 +36,11 @@ type entry struct {
 func NewFIFOCache(capacity int) *FIFOCache {
 	c := &FIFOCache{
 		capacity: capacity,
-		cache:    make(map[interface{}]*entry, capacity+1), // 预分配空间减少扩容
-		queue:    list.New(),                               // 初始化双向链表
-		stopChan: make(chan struct{}),                      // 初始化停止通道
+		cache:    make(map[interface{}]*fifoEntry, capacity+1), // 预分配空间减少扩容
+		queue:    list.New(),                                   // 初始化双向链表
+		stopChan: make(chan struct{}),                          // 初始化停止通道
+
+		observerHook: observerHook{policy: "fifo"},
 	}
 	// 启动后台协程定期清理过期条目
 	go c.startCleaner(1 * time.Minute)
@@ -46,24 +51,27 @@ func NewFIFOCache(capacity int) *FIFOCache {
 // 1. 检查键是否存在
 // 2. 检查是否过期(过期则删除)
 // 3. 返回值和状态
-func (f *FIFOCache) Get(key interface{}) (interface{}, bool) {
-	f.lock.RLock()
-	elem, ok := f.cache[key]
-	f.lock.RUnlock()
+func (f *FIFOCache) Get(key interface{}) (value interface{}, ok bool) {
+	var expired bool
+	if obs, start := f.observeStart(); obs != nil {
+		// 先注册的defer后执行，事件在释放锁之后派发
+		defer func() { f.notifyGet(obs, start, key, ok, expired) }()
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
 
+	elem, ok := f.cache[key]
 	if !ok {
-		f.lock.Lock()
 		f.stats.misses++
-		f.lock.Unlock()
 		return nil, false
 	}
 
-	f.lock.Lock()
-	defer f.lock.Unlock()
-
-	if !elem.expiresAt.IsZero() && time.Now().After(elem.expiresAt) {
+	if !elem.expiresAt.IsZero() && timeNow().After(elem.expiresAt) {
 		delete(f.cache, key)
+		f.queue.Remove(elem.elem)
 		f.stats.misses++
+		f.stats.expiredCount++
+		expired = true
 		return nil, false
 	}
 
@@ -76,12 +84,21 @@ func (f *FIFOCache) Get(key interface{}) (interface{}, bool) {
 // 2. 不存在则添加新条目
 // 3. 缓存满时淘汰最早进入的项(FIFO)
 func (f *FIFOCache) PutWithExpiration(key, value interface{}, expiration time.Duration) {
+	obs, start := f.observeStart()
+	var evicted pendingEvictions
 	f.lock.Lock()
-	defer f.lock.Unlock()
+	defer func() {
+		onEvicted := f.onEvicted
+		f.lock.Unlock()
+		if obs != nil {
+			f.notifyPut(obs, start, key)
+		}
+		evicted.flush(onEvicted, &f.observerHook)
+	}()
 
 	var expiresAt time.Time
 	if expiration > 0 {
-		expiresAt = time.Now().Add(expiration)
+		expiresAt = timeNow().Add(expiration)
 	}
 
 	// 如果键已存在，更新值
@@ -95,18 +112,17 @@ func (f *FIFOCache) PutWithExpiration(key, value interface{}, expiration time.Du
 	if len(f.cache) >= f.capacity {
 		oldest := f.queue.Front()
 		if oldest != nil {
-			delete(f.cache, oldest.Value.(*entry).key)
+			ent := oldest.Value.(*fifoEntry)
+			delete(f.cache, ent.key)
 			f.queue.Remove(oldest)
+			f.stats.evictions++
+			evicted.add(ent.key, ent.value)
 		}
 	}
 
 	// 添加新项到链表尾部
-	elem := &entry{
-		key:       key,
-		value:     value,
-		expiresAt: expiresAt,
-	}
-	f.queue.PushBack(elem)
+	elem := &fifoEntry{entry: entry{key: key, value: value, expiresAt: expiresAt}}
+	elem.elem = f.queue.PushBack(elem)
 	f.cache[key] = elem
 }
 
@@ -115,6 +131,51 @@ func (f *FIFOCache) Put(key, value interface{}) {
 	f.PutWithExpiration(key, value, 0)
 }
 
+// OnEvicted 设置条目因容量被淘汰时的回调(过期和主动删除不触发)
+// 回调在释放锁之后同步执行
+func (f *FIFOCache) OnEvicted(fn func(key, value interface{})) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.onEvicted = fn
+}
+
+// Evict 按进入顺序主动淘汰最多n个条目，返回实际淘汰的数量
+// 用于内存压力下收缩缓存，计入淘汰统计并触发淘汰回调
+func (f *FIFOCache) Evict(n int) int {
+	var evicted pendingEvictions
+	f.lock.Lock()
+	for evicted.len() < n {
+		oldest := f.queue.Front()
+		if oldest == nil {
+			break
+		}
+		ent := oldest.Value.(*fifoEntry)
+		delete(f.cache, ent.key)
+		f.queue.Remove(oldest)
+		f.stats.evictions++
+		evicted.add(ent.key, ent.value)
+	}
+	onEvicted := f.onEvicted
+	f.lock.Unlock()
+
+	evicted.flush(onEvicted, &f.observerHook)
+	return evicted.len()
+}
+
+// Delete 删除指定键，返回键是否存在
+func (f *FIFOCache) Delete(key interface{}) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	elem, ok := f.cache[key]
+	if !ok {
+		return false
+	}
+	delete(f.cache, key)
+	f.queue.Remove(elem.elem)
+	return true
+}
+
 // Len 获取当前缓存大小
 func (f *FIFOCache) Len() int {
 	f.lock.RLock()
@@ -122,11 +183,18 @@ func (f *FIFOCache) Len() int {
 	return len(f.cache)
 }
 
+// Stats 获取缓存统计信息
+func (f *FIFOCache) Stats() (hits, misses, evictions, expired int64) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.stats.hits, f.stats.misses, f.stats.evictions, f.stats.expiredCount
+}
+
 // Clear 清空缓存
 func (f *FIFOCache) Clear() {
 	f.lock.Lock()
 	defer f.lock.Unlock()
-	f.cache = make(map[interface{}]*entry)
+	f.cache = make(map[interface{}]*fifoEntry)
 	f.queue = list.New()
 }
 
@@ -150,6 +218,11 @@ func (f *FIFOCache) startCleaner(interval time.Duration) {
 // 遍历链表，删除所有已过期的条目
 // 返回清理的条目数量
 func (f *FIFOCache) cleanupExpired() int {
+	var expiredKeys []interface{}
+	obs, _ := f.observeStart()
+	if obs != nil {
+		defer func() { f.notifyEach(obs, EventExpire, expiredKeys) }()
+	}
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
@@ -157,13 +230,17 @@ func (f *FIFOCache) cleanupExpired() int {
 	var next *list.Element
 	for e := f.queue.Front(); e != nil; e = next {
 		next = e.Next() // 先获取下一个元素
-		ent := e.Value.(*entry)
-		if !ent.expiresAt.IsZero() && time.Now().After(ent.expiresAt) {
+		ent := e.Value.(*fifoEntry)
+		if !ent.expiresAt.IsZero() && timeNow().After(ent.expiresAt) {
 			delete(f.cache, ent.key)
 			f.queue.Remove(e)
 			count++
+			if obs != nil {
+				expiredKeys = append(expiredKeys, ent.key)
+			}
 		}
 	}
+	f.stats.expiredCount += int64(count)
 	return count
 }
 
@@ -171,46 +248,3 @@ func (f *FIFOCache) cleanupExpired() int {
 func (f *FIFOCache) Close() {
 	close(f.stopChan)
 }
-
-func main() {
-	cache := NewFIFOCache(3)
-	defer cache.Close()
-
-	// 初始填充缓存
-	fmt.Println("=== 初始填充缓存 ===")
-	cache.Put("A", 1)
-	cache.Put("B", 2)
-	cache.Put("C", 3)
-	printCache(cache) // 输出: A(1) B(2) C(3)
-
-	// 测试FIFO淘汰策略
-	fmt.Println("\n=== 测试FIFO淘汰 ===")
-	cache.Put("D", 4) // 应该淘汰最早进入的A
-	printCache(cache)
-
-	// 测试过期功能
-	fmt.Println("\n=== 测试过期功能 ===")
-	cache.PutWithExpiration("E", 5, 2*time.Second)
-	printCache(cache)
-	time.Sleep(3 * time.Second)
-	if _, ok := cache.Get("E"); !ok {
-		fmt.Println("E已过期")
-	}
-	printCache(cache)
-}
-
-// printCache 打印当前缓存内容
-func printCache(c *FIFOCache) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-
-	fmt.Print("当前缓存: ")
-	for e := c.queue.Front(); e != nil; e = e.Next() {
-		ent := e.Value.(*entry)
-		if ent.expiresAt.IsZero() || time.Now().Before(ent.expiresAt) {
-			fmt.Printf("%v(%v) ", ent.key, ent.value)
-		}
-	}
-	fmt.Println()
-}
-
@@ -1,36 +1,42 @@
-package main
+package cache
 
 import (
 	"container/heap"
-	"fmt"
 	"sync"
-	"testing"
 	"time"
 )
 
 // LFUCache 线程安全的LFU缓存结构
 // 使用哈希表+最小堆实现，哈希表提供O(1)访问，最小堆维护使用频率
 type LFUCache struct {
-	capacity int                    // 缓存容量
-	cache    map[interface{}]*entry // 哈希表存储键和entry指针
-	heap     *minHeap               // 最小堆，按使用频率排序
-	lock     sync.RWMutex           // 读写锁保证线程安全
-	stats    struct {               // 运行时统计信息
+	capacity int                       // 缓存容量
+	cache    map[interface{}]*lfuEntry // 哈希表存储键和entry指针
+	heap     *minHeap                  // 最小堆，按使用频率排序
+	lock     sync.RWMutex              // 读写锁保证线程安全
+	stats    struct {                  // 运行时统计信息
 		hits         int64 // 命中次数
 		misses       int64 // 未命中次数
 		evictions    int64 // 淘汰次数
 		expiredCount int64 // 过期条目数
 	}
-	stopChan chan struct{} // 用于停止后台清理协程
+	stopChan  chan struct{}                // 用于停止后台清理协程
+	onEvicted func(key, value interface{}) // 因容量淘汰时的回调
+	tick      uint64                       // 逻辑时钟，每次访问加一
+
+	observerHook // 事件观察者
 }
 
 // 定义minHeap类型，实现heap.Interface接口
-type minHeap []*entry
+type minHeap []*lfuEntry
 
 func (h minHeap) Len() int { return len(h) }
 
+// Less 频率低的优先淘汰，频率相同时淘汰最久未访问的条目，保证淘汰顺序确定
 func (h minHeap) Less(i, j int) bool {
-	return h[i].freq < h[j].freq
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].lastUsed < h[j].lastUsed
 }
 
 func (h minHeap) Swap(i, j int) {
@@ -41,7 +47,7 @@ func (h minHeap) Swap(i, j int) {
 
 func (h *minHeap) Push(x interface{}) {
 	n := len(*h)
-	ent := x.(*entry)
+	ent := x.(*lfuEntry)
 	ent.index = n
 	*h = append(*h, ent)
 }
@@ -56,13 +62,12 @@ func (h *minHeap) Pop() interface{} {
 	return ent
 }
 
-// entry 存储键值对和访问信息
-type entry struct {
-	key       interface{} // 缓存键
-	value     interface{} // 缓存值
-	freq      int         // 访问频率计数器
-	index     int         // 在堆中的索引位置
-	expiresAt time.Time   // 过期时间
+// lfuEntry 存储键值对和访问信息
+type lfuEntry struct {
+	entry
+	freq     int    // 访问频率计数器
+	lastUsed uint64 // 最近一次访问的逻辑时间
+	index    int    // 在堆中的索引位置
 }
 
 // NewLFUCache 创建LFU缓存实例
@@ -70,9 +75,11 @@ type entry struct {
 func NewLFUCache(capacity int) *LFUCache {
 	c := &LFUCache{
 		capacity: capacity,
-		cache:    make(map[interface{}]*entry, capacity+1), // 预分配空间减少扩容
+		cache:    make(map[interface{}]*lfuEntry, capacity+1), // 预分配空间减少扩容
 		heap:     &minHeap{},
 		stopChan: make(chan struct{}),
+
+		observerHook: observerHook{policy: "lfu"},
 	}
 	// 启动后台清理协程，定期清理过期条目
 	go c.startCleaner(1 * time.Minute)
@@ -84,30 +91,33 @@ func NewLFUCache(capacity int) *LFUCache {
 // 2. 检查是否过期
 // 3. 增加访问频率并调整堆
 // 4. 更新统计信息
-func (l *LFUCache) Get(key interface{}) (interface{}, bool) {
-	l.lock.RLock()
-	ent, ok := l.cache[key]
-	l.lock.RUnlock()
+// 命中时需要调整堆，整个过程持有写锁，避免条目在加锁间隙被淘汰
+func (l *LFUCache) Get(key interface{}) (value interface{}, ok bool) {
+	var expired bool
+	if obs, start := l.observeStart(); obs != nil {
+		// 先注册的defer后执行，事件在释放锁之后派发
+		defer func() { l.notifyGet(obs, start, key, ok, expired) }()
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
 
+	ent, ok := l.cache[key]
 	if !ok {
-		l.lock.Lock()
 		l.stats.misses++
-		l.lock.Unlock()
 		return nil, false
 	}
 
-	l.lock.Lock()
-	defer l.lock.Unlock()
-
-	if !ent.expiresAt.IsZero() && time.Now().After(ent.expiresAt) {
+	if !ent.expiresAt.IsZero() && timeNow().After(ent.expiresAt) {
 		delete(l.cache, key)
 		heap.Remove(l.heap, ent.index)
 		l.stats.misses++
 		l.stats.expiredCount++
+		expired = true
 		return nil, false
 	}
 
 	ent.freq++
+	ent.lastUsed = l.nextTick()
 	heap.Fix(l.heap, ent.index)
 	l.stats.hits++
 	return ent.value, true
@@ -118,38 +128,88 @@ func (l *LFUCache) Get(key interface{}) (interface{}, bool) {
 // 2. 不存在则添加新条目
 // 3. 容量满时淘汰频率最低的条目
 func (l *LFUCache) PutWithExpiration(key, value interface{}, expiration time.Duration) {
+	obs, start := l.observeStart()
+	var evicted pendingEvictions
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer func() {
+		onEvicted := l.onEvicted
+		l.lock.Unlock()
+		if obs != nil {
+			l.notifyPut(obs, start, key)
+		}
+		evicted.flush(onEvicted, &l.observerHook)
+	}()
 
 	var expiresAt time.Time
 	if expiration > 0 {
-		expiresAt = time.Now().Add(expiration)
+		expiresAt = timeNow().Add(expiration)
 	}
 
 	if ent, ok := l.cache[key]; ok {
 		ent.value = value
 		ent.freq++
+		ent.lastUsed = l.nextTick()
 		ent.expiresAt = expiresAt
 		heap.Fix(l.heap, ent.index)
 		return
 	}
 
 	if len(l.cache) >= l.capacity {
-		oldest := heap.Pop(l.heap).(*entry)
-		delete(l.cache, oldest.key)
+		ent := heap.Pop(l.heap).(*lfuEntry)
+		delete(l.cache, ent.key)
 		l.stats.evictions++
+		evicted.add(ent.key, ent.value)
 	}
 
-	ent := &entry{
-		key:       key,
-		value:     value,
-		freq:      1,
-		expiresAt: expiresAt,
+	ent := &lfuEntry{
+		entry:    entry{key: key, value: value, expiresAt: expiresAt},
+		freq:     1,
+		lastUsed: l.nextTick(),
 	}
 	heap.Push(l.heap, ent)
 	l.cache[key] = ent
 }
 
+// OnEvicted 设置条目因容量被淘汰时的回调(过期和主动删除不触发)
+// 回调在释放锁之后同步执行
+func (l *LFUCache) OnEvicted(fn func(key, value interface{})) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.onEvicted = fn
+}
+
+// Evict 按使用频率从低到高主动淘汰最多n个条目，返回实际淘汰的数量
+// 用于内存压力下收缩缓存，计入淘汰统计并触发淘汰回调
+func (l *LFUCache) Evict(n int) int {
+	var evicted pendingEvictions
+	l.lock.Lock()
+	for evicted.len() < n && l.heap.Len() > 0 {
+		ent := heap.Pop(l.heap).(*lfuEntry)
+		delete(l.cache, ent.key)
+		l.stats.evictions++
+		evicted.add(ent.key, ent.value)
+	}
+	onEvicted := l.onEvicted
+	l.lock.Unlock()
+
+	evicted.flush(onEvicted, &l.observerHook)
+	return evicted.len()
+}
+
+// Delete 删除指定键，返回键是否存在
+func (l *LFUCache) Delete(key interface{}) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	ent, ok := l.cache[key]
+	if !ok {
+		return false
+	}
+	delete(l.cache, key)
+	heap.Remove(l.heap, ent.index)
+	return true
+}
+
 // startCleaner 启动后台清理协程
 // interval: 清理间隔时间
 func (l *LFUCache) startCleaner(interval time.Duration) {
@@ -173,17 +233,25 @@ func (l *LFUCache) Close() {
 // Cleanup 清理过期缓存条目
 // 返回清理的条目数量
 func (l *LFUCache) Cleanup() int {
+	var expiredKeys []interface{}
+	obs, _ := l.observeStart()
+	if obs != nil {
+		defer func() { l.notifyEach(obs, EventExpire, expiredKeys) }()
+	}
 	l.lock.Lock()
 	defer l.lock.Unlock()
 
 	count := 0
 	for i := 0; i < l.heap.Len(); i++ {
 		ent := (*l.heap)[i]
-		if !ent.expiresAt.IsZero() && time.Now().After(ent.expiresAt) {
+		if !ent.expiresAt.IsZero() && timeNow().After(ent.expiresAt) {
 			delete(l.cache, ent.key)
 			heap.Remove(l.heap, i)
 			count++
 			i--
+			if obs != nil {
+				expiredKeys = append(expiredKeys, ent.key)
+			}
 		}
 	}
 	l.stats.expiredCount += int64(count)
@@ -205,28 +273,15 @@ func (l *LFUCache) HitRate() float64 {
 	return float64(hits) / float64(total)
 }
 
+// Put 添加缓存(永不过期)，覆盖已有键时同时清除其过期时间
 func (l *LFUCache) Put(key, value interface{}) {
-	l.lock.Lock()
-	defer l.lock.Unlock()
-
-	// 如果键已存在，更新值并增加频率
-	if ent, ok := l.cache[key]; ok {
-		ent.value = value
-		ent.freq++
-		heap.Fix(l.heap, ent.index)
-		return
-	}
-
-	// 如果缓存已满，淘汰使用频率最低的项
-	if len(l.cache) >= l.capacity {
-		oldest := heap.Pop(l.heap).(*entry)
-		delete(l.cache, oldest.key)
-	}
+	l.PutWithExpiration(key, value, 0)
+}
 
-	// 添加新项
-	ent := &entry{key: key, value: value, freq: 1}
-	heap.Push(l.heap, ent)
-	l.cache[key] = ent
+// nextTick 推进逻辑时钟，调用方需持有写锁
+func (l *LFUCache) nextTick() uint64 {
+	l.tick++
+	return l.tick
 }
 
 // Len 获取当前缓存大小
@@ -240,50 +295,6 @@ func (l *LFUCache) Len() int {
 func (l *LFUCache) Clear() {
 	l.lock.Lock()
 	defer l.lock.Unlock()
-	l.cache = make(map[interface{}]*entry)
+	l.cache = make(map[interface{}]*lfuEntry)
 	l.heap = &minHeap{}
 }
-
-func main() {
-	cache := NewLFUCache(3)
-	defer cache.Close()
-
-	// 测试用例展示LFU特性
-	cache.PutWithExpiration("A", 1, 0)
-	cache.PutWithExpiration("B", 2, 0)
-	cache.PutWithExpiration("C", 3, 0)
-
-	cache.Get("A") // A频率=2
-	cache.Get("A") // A频率=3
-	cache.Get("B") // B频率=2
-
-	cache.PutWithExpiration("D", 4, 0) // 应该淘汰C(频率最低)
-
-	fmt.Printf("命中率: %.2f%%\n", cache.HitRate()*100)
-}
-
-func TestLFU(t *testing.T) {
-	cache := NewLFUCache(2)
-
-	// 测试1: 基本功能
-	cache.Put("X", 10)
-	if val, _ := cache.Get("X"); val != 10 {
-		t.Error("基本功能测试失败")
-	}
-
-	// 测试2: LFU淘汰策略
-	cache.Put("Y", 20)
-	cache.Get("X")     // X频率=2
-	cache.Put("Z", 30) // 应该淘汰Y(频率=1)
-
-	if _, ok := cache.Get("Y"); ok {
-		t.Error("LFU淘汰策略失败")
-	}
-
-	// 测试3: 过期功能
-	cache.PutWithExpiration("T", "temp", time.Millisecond*50)
-	time.Sleep(time.Millisecond * 100)
-	if _, ok := cache.Get("T"); ok {
-		t.Error("过期检查失败")
-	}
-}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLFU(t *testing.T) {
+	clock := useFakeClock(t)
+	cache := NewLFUCache(2)
+	defer cache.Close()
+
+	// 测试1: 基本功能
+	cache.Put("X", 10)
+	if val, _ := cache.Get("X"); val != 10 {
+		t.Error("基本功能测试失败")
+	}
+
+	// 测试2: LFU淘汰策略
+	cache.Put("Y", 20)
+	cache.Get("X")     // X频率=2
+	cache.Put("Z", 30) // 应该淘汰Y(频率=1)
+
+	if _, ok := cache.Get("Y"); ok {
+		t.Error("LFU淘汰策略失败")
+	}
+
+	// 测试3: 过期功能
+	cache.PutWithExpiration("T", "temp", time.Millisecond*50)
+	clock.Advance(time.Millisecond * 100)
+	if _, ok := cache.Get("T"); ok {
+		t.Error("过期检查失败")
+	}
+}
+
+// 频率相同时淘汰最久未访问的条目
+func TestLFUTieBreak(t *testing.T) {
+	cache := NewLFUCache(2)
+	defer cache.Close()
+
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Get("a")
+	cache.Get("b") // a和b频率都为2，a最久未访问
+	cache.Put("c", 3)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("a should have been evicted")
+	}
+	if _, ok := cache.Get("b"); !ok {
+		t.Error("b should still be cached")
+	}
+}
@@ -1,8 +1,7 @@
-package main
+package cache
 
 import (
 	"container/list"
-	"fmt"
 	"sync"
 	"time"
 )
@@ -15,20 +14,15 @@ type LRUCache struct {
 	list       *list.List                    // 双向链表，头部最新尾部最旧
 	lock       sync.RWMutex                  // 读写锁，支持并发读写
 	expiration time.Duration                 // 全局默认过期时间
+	onEvicted  func(key, value interface{})  // 因容量淘汰时的回调
 	stats      struct {                      // 运行时统计信息
 		hits         int64 // 缓存命中次数
 		misses       int64 // 缓存未命中次数
 		evictions    int64 // 因容量淘汰的条目数
 		expiredCount int64 // 因过期淘汰的条目数
 	}
-}
 
-// entry 链表节点数据结构
-// 存储键值对和过期时间信息
-type entry struct {
-	key       interface{} // 缓存键
-	value     interface{} // 缓存值
-	expiresAt time.Time   // 过期时间戳，零值表示永不过期
+	observerHook // 事件观察者
 }
 
 // NewLRUCache 构造函数
@@ -40,6 +34,8 @@ func NewLRUCache(capacity int, expiration time.Duration) *LRUCache {
 		cache:      make(map[interface{}]*list.Element, capacity), // 预分配空间
 		list:       list.New(),                                    // 初始化双向链表
 		expiration: expiration,
+
+		observerHook: observerHook{policy: "lru"},
 	}
 }
 
@@ -48,34 +44,34 @@ func NewLRUCache(capacity int, expiration time.Duration) *LRUCache {
 // 2. 检查是否过期
 // 3. 更新访问时间(移动到链表头部)
 // 4. 更新统计信息
-func (l *LRUCache) Get(key interface{}) (interface{}, bool) {
-	l.lock.RLock()
+// 命中时需要移动链表节点，整个过程持有写锁，避免条目在加锁间隙被淘汰或替换
+func (l *LRUCache) Get(key interface{}) (value interface{}, ok bool) {
+	var expired bool
+	if obs, start := l.observeStart(); obs != nil {
+		// 先注册的defer后执行，事件在释放锁之后派发
+		defer func() { l.notifyGet(obs, start, key, ok, expired) }()
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
 	elem, ok := l.cache[key]
 	if !ok {
-		l.lock.RUnlock()
-		l.lock.Lock()
 		l.stats.misses++
-		l.lock.Unlock()
 		return nil, false
 	}
 
 	ent := elem.Value.(*entry)
-	if !ent.expiresAt.IsZero() && time.Now().After(ent.expiresAt) {
-		l.lock.RUnlock()
-		l.lock.Lock()
+	if !ent.expiresAt.IsZero() && timeNow().After(ent.expiresAt) {
 		delete(l.cache, key)
 		l.list.Remove(elem)
 		l.stats.misses++
 		l.stats.expiredCount++
-		l.lock.Unlock()
+		expired = true
 		return nil, false
 	}
-	l.lock.RUnlock()
 
-	l.lock.Lock()
 	l.list.MoveToFront(elem)
 	l.stats.hits++
-	l.lock.Unlock()
 	return ent.value, true
 }
 
@@ -89,12 +85,21 @@ func (l *LRUCache) Put(key, value interface{}) {
 // 2. 不存在则添加新条目
 // 3. 容量满时淘汰最久未使用的条目
 func (l *LRUCache) PutWithExpiration(key, value interface{}, expiration time.Duration) {
+	obs, start := l.observeStart()
+	var evicted pendingEvictions
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer func() {
+		onEvicted := l.onEvicted
+		l.lock.Unlock()
+		if obs != nil {
+			l.notifyPut(obs, start, key)
+		}
+		evicted.flush(onEvicted, &l.observerHook)
+	}()
 
 	var expiresAt time.Time
 	if expiration > 0 {
-		expiresAt = time.Now().Add(expiration)
+		expiresAt = timeNow().Add(expiration)
 	}
 
 	// 如果键已存在，更新值并移动到链表头部
@@ -110,9 +115,11 @@ func (l *LRUCache) PutWithExpiration(key, value interface{}, expiration time.Dur
 	if len(l.cache) >= l.capacity {
 		oldest := l.list.Back()
 		if oldest != nil {
-			delete(l.cache, oldest.Value.(*entry).key)
+			ent := oldest.Value.(*entry)
+			delete(l.cache, ent.key)
 			l.list.Remove(oldest)
 			l.stats.evictions++
+			evicted.add(ent.key, ent.value)
 		}
 	}
 
@@ -125,6 +132,51 @@ func (l *LRUCache) PutWithExpiration(key, value interface{}, expiration time.Dur
 	l.cache[key] = elem
 }
 
+// OnEvicted 设置条目因容量被淘汰时的回调(过期和主动删除不触发)
+// 回调在释放锁之后同步执行
+func (l *LRUCache) OnEvicted(fn func(key, value interface{})) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.onEvicted = fn
+}
+
+// Evict 按LRU顺序主动淘汰最多n个条目，返回实际淘汰的数量
+// 用于内存压力下收缩缓存，计入淘汰统计并触发淘汰回调
+func (l *LRUCache) Evict(n int) int {
+	var evicted pendingEvictions
+	l.lock.Lock()
+	for evicted.len() < n {
+		oldest := l.list.Back()
+		if oldest == nil {
+			break
+		}
+		ent := oldest.Value.(*entry)
+		delete(l.cache, ent.key)
+		l.list.Remove(oldest)
+		l.stats.evictions++
+		evicted.add(ent.key, ent.value)
+	}
+	onEvicted := l.onEvicted
+	l.lock.Unlock()
+
+	evicted.flush(onEvicted, &l.observerHook)
+	return evicted.len()
+}
+
+// Delete 删除指定键，返回键是否存在
+func (l *LRUCache) Delete(key interface{}) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	elem, ok := l.cache[key]
+	if !ok {
+		return false
+	}
+	delete(l.cache, key)
+	l.list.Remove(elem)
+	return true
+}
+
 // Stats 获取缓存命中统计
 func (l *LRUCache) Stats() (hits, misses, evictions, expired int64) {
 	l.lock.RLock()
@@ -136,18 +188,26 @@ func (l *LRUCache) Stats() (hits, misses, evictions, expired int64) {
 // 从链表尾部开始检查(最久未使用)
 // 返回清理的条目数量
 func (l *LRUCache) Cleanup() int {
+	var expiredKeys []interface{}
+	obs, _ := l.observeStart()
+	if obs != nil {
+		defer func() { l.notifyEach(obs, EventExpire, expiredKeys) }()
+	}
 	l.lock.Lock()
 	defer l.lock.Unlock()
 
 	count := 0
 	for elem := l.list.Back(); elem != nil; elem = l.list.Back() {
 		ent := elem.Value.(*entry)
-		if ent.expiresAt.IsZero() || time.Now().Before(ent.expiresAt) {
+		if ent.expiresAt.IsZero() || timeNow().Before(ent.expiresAt) {
 			break
 		}
 		delete(l.cache, ent.key)
 		l.list.Remove(elem)
 		count++
+		if obs != nil {
+			expiredKeys = append(expiredKeys, ent.key)
+		}
 	}
 	l.stats.expiredCount += int64(count)
 	return count
@@ -173,38 +233,3 @@ func (l *LRUCache) Clear() {
 		expiredCount int64
 	}{}
 }
-
-func main() {
-	// 创建容量为3，默认过期10秒的缓存
-	cache := NewLRUCache(3, 10*time.Second)
-
-	// 基本操作演示
-	cache.Put("name", "PaiCloud")
-	cache.Put("age", 25)
-	cache.Put("job", "Engineer")
-
-	// 获取存在的值
-	if val, ok := cache.Get("name"); ok {
-		fmt.Printf("name: %v\n", val)
-	}
-
-	// 触发淘汰（容量已满时添加新条目）
-	cache.Put("salary", 50000) // 淘汰最久未使用的"age"
-
-	// 检查被淘汰的键
-	if _, ok := cache.Get("age"); !ok {
-		fmt.Println("age已被淘汰") // 输出: age已被淘汰
-	}
-
-	// 过期功能演示
-	cache.PutWithExpiration("temp", "data", 2*time.Second)
-	time.Sleep(3 * time.Second)
-	if _, ok := cache.Get("temp"); !ok {
-		fmt.Println("temp已过期") // 输出: temp已过期
-	}
-
-	// 统计信息
-	hits, misses, evictions, expired := cache.Stats()
-	fmt.Printf("命中率: %.1f%%\n", float64(hits)/float64(hits+misses)*100)
-	fmt.Printf("淘汰次数: %d, 过期次数: %d\n", evictions, expired)
-}
@@ -0,0 +1,445 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LRUKCache 线程安全的LRU-K缓存
+// LRU只看最近一次访问，偶发的一次性扫描就能把热点数据挤出缓存；
+// LRU-K按倒数第K次访问时间淘汰，访问不足K次的条目视为"倒数第K次访问无穷久远"，最先被淘汰。
+// - 相关访问期(CRP)内的重复访问(如同一事务内多次读同一页)只算作一次访问
+// - 被淘汰键的访问历史保留在历史表中，再次进入缓存时恢复，避免刚淘汰的热点重新从零计数；
+// 恢复时本次访问与淘汰前最后一次访问同样按相关访问期判断
+// - 历史只在保留期(RIP)内有效，过期的历史不再恢复，由Cleanup清除
+// 与论文的差异：历史表另有与缓存容量相同的条目上限，超出时丢弃最早淘汰的历史，
+// 避免RIP较长时大量一次性键撑大内存；论文中由后台进程按RIP清理，这里由Cleanup完成
+// 参考: O'Neil et al., "The LRU-K Page Replacement Algorithm For Database Disk Buffering", SIGMOD 1993
+type LRUKCache struct {
+	capacity   int                           // 缓存最大容量
+	k          int                           // 按倒数第k次访问淘汰
+	crp        time.Duration                 // 相关访问期
+	rip        time.Duration                 // 历史保留期，0表示只受历史表容量限制
+	cache      map[interface{}]*lrukEntry    // 缓存中的条目
+	byK        lrukHeap                      // 全部条目，按倒数第k次访问时间排序
+	ready      lrukHeap                      // 已过相关访问期的条目，按倒数第k次访问时间排序
+	young      lrukHeap                      // 仍在相关访问期内的条目，按最近访问时间(即离开相关期的先后)排序
+	history    map[interface{}]*list.Element // 已淘汰键的访问历史
+	historyLRU *list.List                    // 历史表，头部最新，超过容量时丢弃尾部
+	historyCap int                           // 历史表容量
+	lock       sync.Mutex                    // 读取也会更新访问历史，因此使用互斥锁
+	onEvicted  func(key, value interface{})  // 因容量淘汰时的回调
+	stats      struct {                      // 运行时统计信息
+		hits         int64 // 缓存命中次数
+		misses       int64 // 缓存未命中次数
+		evictions    int64 // 因容量淘汰的条目数
+		expiredCount int64 // 因过期淘汰的条目数
+	}
+
+	observerHook // 事件观察者
+}
+
+// lrukEntry 缓存条目
+type lrukEntry struct {
+	key       interface{}
+	value     interface{}
+	hist      []int64   // 最近k次非相关访问的时间(纳秒)，hist[0]最新，0表示不存在
+	last      int64     // 最近一次访问(含相关访问)的时间
+	index     [2]int    // index[0]为在byK中的下标，index[1]为在ready或young中的下标
+	young     bool      // 是否在young中
+	expiresAt time.Time // 过期时间，零值表示永不过期
+}
+
+// lrukHistory 已淘汰键保留的访问历史
+type lrukHistory struct {
+	key  interface{}
+	hist []int64
+	last int64
+}
+
+// lrukHeap 条目的最小堆，顺序由less决定，条目在堆中的下标保存在index[slot]
+type lrukHeap struct {
+	entries []*lrukEntry
+	less    func(a, b *lrukEntry) bool
+	slot    int
+}
+
+// kthLess 倒数第k次访问最早的在前，相同时最近访问更早的优先
+func kthLess(a, b *lrukEntry) bool {
+	ka, kb := a.hist[len(a.hist)-1], b.hist[len(b.hist)-1]
+	if ka != kb {
+		return ka < kb
+	}
+	return a.hist[0] < b.hist[0]
+}
+
+// lastLess 最近访问更早的在前，即先离开相关访问期
+func lastLess(a, b *lrukEntry) bool { return a.last < b.last }
+
+func (h *lrukHeap) Len() int { return len(h.entries) }
+
+func (h *lrukHeap) Less(i, j int) bool { return h.less(h.entries[i], h.entries[j]) }
+
+func (h *lrukHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index[h.slot] = i
+	h.entries[j].index[h.slot] = j
+}
+
+func (h *lrukHeap) Push(x interface{}) {
+	ent := x.(*lrukEntry)
+	ent.index[h.slot] = len(h.entries)
+	h.entries = append(h.entries, ent)
+}
+
+func (h *lrukHeap) Pop() interface{} {
+	n := len(h.entries)
+	ent := h.entries[n-1]
+	h.entries[n-1] = nil // 避免内存泄漏
+	ent.index[h.slot] = -1
+	h.entries = h.entries[:n-1]
+	return ent
+}
+
+// top 堆顶条目，堆为空时返回nil
+func (h *lrukHeap) top() *lrukEntry {
+	if len(h.entries) == 0 {
+		return nil
+	}
+	return h.entries[0]
+}
+
+// NewLRUKCache 创建LRU-K缓存
+// capacity: 缓存最大容量，必须为正，历史表容量与之相同
+// k: 按倒数第k次访问淘汰，小于1时按2处理(LRU-1即普通LRU)
+// correlatedPeriod: 相关访问期，0表示每次访问都独立计数
+// retainedPeriod: 历史保留期，键最后一次访问超过该时长后历史失效，0表示不按时间失效
+func NewLRUKCache(capacity, k int, correlatedPeriod, retainedPeriod time.Duration) (*LRUKCache, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("cache: capacity must be positive, got %d", capacity)
+	}
+	if correlatedPeriod < 0 || retainedPeriod < 0 {
+		return nil, fmt.Errorf("cache: negative correlated period %v or retained period %v", correlatedPeriod, retainedPeriod)
+	}
+	if k < 1 {
+		k = 2
+	}
+	return &LRUKCache{
+		capacity:   capacity,
+		k:          k,
+		crp:        correlatedPeriod,
+		rip:        retainedPeriod,
+		cache:      make(map[interface{}]*lrukEntry, capacity),
+		byK:        lrukHeap{less: kthLess},
+		ready:      lrukHeap{less: kthLess, slot: 1},
+		young:      lrukHeap{less: lastLess, slot: 1},
+		history:    make(map[interface{}]*list.Element),
+		historyLRU: list.New(),
+		historyCap: capacity,
+
+		observerHook: observerHook{policy: "lru-k"},
+	}, nil
+}
+
+// Get 获取缓存值，命中时记录一次访问
+func (c *LRUKCache) Get(key interface{}) (value interface{}, ok bool) {
+	var expired bool
+	if obs, start := c.observeStart(); obs != nil {
+		// 先注册的defer后执行，事件在释放锁之后派发
+		defer func() { c.notifyGet(obs, start, key, ok, expired) }()
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ent, ok := c.cache[key]
+	if !ok {
+		c.stats.misses++
+		return nil, false
+	}
+	if !ent.expiresAt.IsZero() && timeNow().After(ent.expiresAt) {
+		c.remove(ent)
+		c.stats.misses++
+		c.stats.expiredCount++
+		expired = true
+		return nil, false
+	}
+	c.touch(ent, timeNow().UnixNano())
+	c.stats.hits++
+	return ent.value, true
+}
+
+// Put 添加/更新缓存(永不过期)
+func (c *LRUKCache) Put(key, value interface{}) {
+	c.PutWithExpiration(key, value, 0)
+}
+
+// PutWithExpiration 添加/更新缓存(自定义过期时间)
+// 1. 已存在则更新值和过期时间，并记录一次访问
+// 2. 缓存已满时淘汰倒数第k次访问最早且不在相关访问期内的条目
+// 3. 历史表中有该键且未超过保留期的记录时恢复其访问历史
+func (c *LRUKCache) PutWithExpiration(key, value interface{}, expiration time.Duration) {
+	obs, start := c.observeStart()
+	var evicted pendingEvictions
+	c.lock.Lock()
+	defer func() {
+		onEvicted := c.onEvicted
+		c.lock.Unlock()
+		if obs != nil {
+			c.notifyPut(obs, start, key)
+		}
+		evicted.flush(onEvicted, &c.observerHook)
+	}()
+
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = timeNow().Add(expiration)
+	}
+	now := timeNow().UnixNano()
+
+	if ent, ok := c.cache[key]; ok {
+		ent.value = value
+		ent.expiresAt = expiresAt
+		c.touch(ent, now)
+		return
+	}
+
+	ent := &lrukEntry{
+		key:       key,
+		value:     value,
+		hist:      make([]int64, c.k),
+		expiresAt: expiresAt,
+	}
+	// 先取出历史，避免淘汰时把即将恢复的历史挤出历史表
+	if elem, ok := c.history[key]; ok {
+		h := c.historyLRU.Remove(elem).(*lrukHistory)
+		delete(c.history, key)
+		if !c.stale(h, now) {
+			// 恢复历史后本次访问与淘汰前的访问一样参与相关访问期判断
+			copy(ent.hist, h.hist)
+			ent.last = h.last
+		}
+	}
+
+	if len(c.cache) >= c.capacity {
+		if victim := c.victim(now); victim != nil {
+			delete(c.cache, victim.key)
+			c.remember(victim, now)
+			c.stats.evictions++
+			evicted.add(victim.key, victim.value)
+		}
+	}
+
+	c.record(ent, now)
+	heap.Push(&c.byK, ent)
+	c.enqueue(ent)
+	c.cache[key] = ent
+}
+
+// touch 记录一次访问并调整堆中的位置
+// 最近访问时间总会变化，条目重新进入相关访问期
+func (c *LRUKCache) touch(ent *lrukEntry, now int64) {
+	if c.record(ent, now) {
+		heap.Fix(&c.byK, ent.index[0])
+	}
+	c.dequeue(ent)
+	c.enqueue(ent)
+}
+
+// enqueue 刚被访问的条目放入young；相关访问期为0时访问后立即可以淘汰，直接放入ready
+func (c *LRUKCache) enqueue(ent *lrukEntry) {
+	ent.young = c.crp > 0
+	if ent.young {
+		heap.Push(&c.young, ent)
+	} else {
+		heap.Push(&c.ready, ent)
+	}
+}
+
+// dequeue 把条目移出ready或young
+func (c *LRUKCache) dequeue(ent *lrukEntry) {
+	if ent.young {
+		heap.Remove(&c.young, ent.index[1])
+	} else {
+		heap.Remove(&c.ready, ent.index[1])
+	}
+}
+
+// record 记录一次访问，返回访问历史是否改变
+// 距上次访问不小于相关访问期时为非相关访问：历史整体右移，并把上一个相关期的长度加到旧记录上，
+// 使相关期内的多次访问折算为一次；否则只更新最近访问时间
+// 没有历史的新条目last为0，总是按非相关访问记录
+func (c *LRUKCache) record(ent *lrukEntry, now int64) bool {
+	uncorrelated := time.Duration(now-ent.last) >= c.crp
+	if uncorrelated {
+		period := ent.last - ent.hist[0]
+		for i := len(ent.hist) - 1; i > 0; i-- {
+			if ent.hist[i-1] != 0 {
+				ent.hist[i] = ent.hist[i-1] + period
+			}
+		}
+		ent.hist[0] = now
+	}
+	ent.last = now
+	return uncorrelated
+}
+
+// stale 历史是否已超过保留期
+func (c *LRUKCache) stale(h *lrukHistory, now int64) bool {
+	return c.rip > 0 && time.Duration(now-h.last) > c.rip
+}
+
+// victim 选出淘汰对象并将其移出堆：跳过仍处于相关访问期内的条目
+// 先把已离开相关访问期的条目从young移到ready，再取ready的堆顶；
+// 所有条目都在相关访问期内时退化为淘汰byK的堆顶。每个条目每次访问后最多移动一次，淘汰为O(log n)
+func (c *LRUKCache) victim(now int64) *lrukEntry {
+	for ent := c.young.top(); ent != nil && time.Duration(now-ent.last) >= c.crp; ent = c.young.top() {
+		heap.Pop(&c.young)
+		ent.young = false
+		heap.Push(&c.ready, ent)
+	}
+	found := c.ready.top()
+	if found == nil {
+		found = c.byK.top()
+	}
+	if found != nil {
+		c.unlink(found)
+	}
+	return found
+}
+
+// unlink 把条目移出所有堆
+func (c *LRUKCache) unlink(ent *lrukEntry) {
+	heap.Remove(&c.byK, ent.index[0])
+	c.dequeue(ent)
+}
+
+// remove 从缓存和堆中移除条目
+func (c *LRUKCache) remove(ent *lrukEntry) {
+	c.unlink(ent)
+	delete(c.cache, ent.key)
+}
+
+// remember 把被淘汰条目的访问历史放入历史表
+// 超过容量或尾部已超过保留期时丢弃最早淘汰的历史
+func (c *LRUKCache) remember(ent *lrukEntry, now int64) {
+	elem := c.historyLRU.PushFront(&lrukHistory{key: ent.key, hist: ent.hist, last: ent.last})
+	c.history[ent.key] = elem
+	for oldest := c.historyLRU.Back(); oldest != nil; oldest = c.historyLRU.Back() {
+		h := oldest.Value.(*lrukHistory)
+		if c.historyLRU.Len() <= c.historyCap && !c.stale(h, now) {
+			break
+		}
+		c.historyLRU.Remove(oldest)
+		delete(c.history, h.key)
+	}
+}
+
+// OnEvicted 设置条目因容量被淘汰时的回调(过期和主动删除不触发)
+// 回调在释放锁之后同步执行
+func (c *LRUKCache) OnEvicted(fn func(key, value interface{})) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.onEvicted = fn
+}
+
+// Evict 按LRU-K顺序主动淘汰最多n个条目，返回实际淘汰的数量
+// 用于内存压力下收缩缓存，计入淘汰统计并触发淘汰回调
+func (c *LRUKCache) Evict(n int) int {
+	var evicted pendingEvictions
+	c.lock.Lock()
+	now := timeNow().UnixNano()
+	for evicted.len() < n {
+		ent := c.victim(now)
+		if ent == nil {
+			break
+		}
+		delete(c.cache, ent.key)
+		c.remember(ent, now)
+		c.stats.evictions++
+		evicted.add(ent.key, ent.value)
+	}
+	onEvicted := c.onEvicted
+	c.lock.Unlock()
+
+	evicted.flush(onEvicted, &c.observerHook)
+	return evicted.len()
+}
+
+// Delete 删除指定键(同时丢弃其历史)，返回键是否存在
+func (c *LRUKCache) Delete(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.history[key]; ok {
+		c.historyLRU.Remove(elem)
+		delete(c.history, key)
+	}
+	ent, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	c.remove(ent)
+	return true
+}
+
+// Stats 获取缓存命中统计
+func (c *LRUKCache) Stats() (hits, misses, evictions, expired int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.stats.hits, c.stats.misses, c.stats.evictions, c.stats.expiredCount
+}
+
+// Cleanup 主动清理过期缓存和超过保留期的历史，返回清理的缓存条目数量
+func (c *LRUKCache) Cleanup() int {
+	var expiredKeys []interface{}
+	obs, _ := c.observeStart()
+	if obs != nil {
+		defer func() { c.notifyEach(obs, EventExpire, expiredKeys) }()
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := timeNow()
+	count := 0
+	for _, ent := range c.cache {
+		if !ent.expiresAt.IsZero() && now.After(ent.expiresAt) {
+			c.remove(ent)
+			count++
+			if obs != nil {
+				expiredKeys = append(expiredKeys, ent.key)
+			}
+		}
+	}
+	c.stats.expiredCount += int64(count)
+
+	nowNano := now.UnixNano()
+	for elem := c.historyLRU.Front(); elem != nil; {
+		next := elem.Next()
+		if h := elem.Value.(*lrukHistory); c.stale(h, nowNano) {
+			c.historyLRU.Remove(elem)
+			delete(c.history, h.key)
+		}
+		elem = next
+	}
+	return count
+}
+
+// Len 获取当前缓存大小(不含历史表)
+func (c *LRUKCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.cache)
+}
+
+// Clear 清空缓存和历史表
+func (c *LRUKCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cache = make(map[interface{}]*lrukEntry, c.capacity)
+	c.byK.entries, c.ready.entries, c.young.entries = nil, nil, nil
+	c.history = make(map[interface{}]*list.Element)
+	c.historyLRU = list.New()
+}
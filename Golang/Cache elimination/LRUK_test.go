@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// mustLRUKCache 创建LRU-K缓存，参数非法时panic，供不便传入testing.T的地方使用
+func mustLRUKCache(capacity, k int, correlatedPeriod, retainedPeriod time.Duration) *LRUKCache {
+	c, err := NewLRUKCache(capacity, k, correlatedPeriod, retainedPeriod)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestNewLRUKCacheValidation(t *testing.T) {
+	for _, capacity := range []int{0, -1} {
+		if _, err := NewLRUKCache(capacity, 2, 0, 0); err == nil {
+			t.Fatalf("capacity %d should be rejected", capacity)
+		}
+	}
+	if _, err := NewLRUKCache(1, 2, -time.Second, 0); err == nil {
+		t.Fatal("negative correlated period should be rejected")
+	}
+	if _, err := NewLRUKCache(1, 2, 0, -time.Second); err == nil {
+		t.Fatal("negative retained period should be rejected")
+	}
+}
+
+// 一次性扫描不应把访问过两次的热点键挤出缓存
+func TestLRUKScanResistance(t *testing.T) {
+	c := mustLRUKCache(10, 2, 0, 0)
+	for i := 0; i < 5; i++ {
+		c.Put(i, i)
+		c.Get(i)
+	}
+	for i := 100; i < 200; i++ {
+		c.Put(i, i)
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := c.Get(i); !ok {
+			t.Fatalf("hot key %d evicted by scan", i)
+		}
+	}
+	if c.Len() != 10 {
+		t.Fatalf("Len = %d", c.Len())
+	}
+	if _, _, evictions, _ := c.Stats(); evictions != 95 {
+		t.Fatalf("evictions = %d", evictions)
+	}
+}
+
+// 相关访问期内的重复访问只算一次，不能让键获得第二次访问记录
+func TestLRUKCorrelatedReferences(t *testing.T) {
+	c := mustLRUKCache(2, 2, time.Hour, 0)
+	c.Put("burst", 1)
+	for i := 0; i < 10; i++ {
+		c.Get("burst")
+	}
+	if c.cache["burst"].hist[1] != 0 {
+		t.Fatal("correlated references must not count as a second access")
+	}
+
+	c = mustLRUKCache(2, 2, 0, 0)
+	c.Put("k", 1)
+	c.Get("k")
+	if c.cache["k"].hist[1] == 0 {
+		t.Fatal("uncorrelated reference should be recorded")
+	}
+}
+
+// 被淘汰键的历史保留在历史表中，重新进入时恢复
+func TestLRUKRetainedHistory(t *testing.T) {
+	c := mustLRUKCache(2, 2, 0, 0)
+	c.Put("a", 1)
+	c.Get("a")
+	c.Put("b", 2)
+	c.Get("b")
+	c.Put("c", 3) // 淘汰a(倒数第2次访问最早)
+	if _, ok := c.cache["a"]; ok {
+		t.Fatal("a should have been evicted")
+	}
+	if _, ok := c.history["a"]; !ok {
+		t.Fatal("history of a should be retained")
+	}
+
+	c.Put("a", 1)
+	if c.cache["a"].hist[1] == 0 {
+		t.Fatal("history should be restored on re-admission")
+	}
+	if _, ok := c.history["a"]; ok {
+		t.Fatal("restored history should leave the history table")
+	}
+}
+
+// 淘汰后在相关访问期内重新进入只算相关访问，不增加访问记录
+func TestLRUKCorrelatedReferenceOnRestore(t *testing.T) {
+	clock := useFakeClock(t)
+	c := mustLRUKCache(1, 2, time.Minute, 0)
+	c.Put("a", 1)
+	c.Put("b", 2) // 所有条目都在相关访问期内时仍淘汰a
+	c.Put("a", 1)
+	if c.cache["a"].hist[1] != 0 {
+		t.Fatal("re-admission within the correlated period must not count as a second access")
+	}
+
+	clock.Advance(2 * time.Minute)
+	c.Put("b", 2)
+	clock.Advance(2 * time.Minute)
+	c.Put("a", 1)
+	if c.cache["a"].hist[1] == 0 {
+		t.Fatal("re-admission after the correlated period should restore the history")
+	}
+}
+
+// 超过保留期的历史不再恢复，Cleanup会清除它
+func TestLRUKRetainedInformationPeriod(t *testing.T) {
+	clock := useFakeClock(t)
+	c := mustLRUKCache(2, 2, 0, time.Minute)
+	for _, key := range []string{"a", "b"} {
+		c.Put(key, 1)
+		clock.Advance(time.Millisecond)
+		c.Get(key)
+	}
+	c.Put("c", 3) // 淘汰a
+	clock.Advance(2 * time.Minute)
+	c.Put("a", 1) // 淘汰只访问过一次的c
+	if c.cache["a"].hist[1] != 0 {
+		t.Fatal("history older than the retained period must not be restored")
+	}
+
+	clock.Advance(30 * time.Second)
+	c.Put("d", 4) // 淘汰a，历史仍在保留期内
+	if _, ok := c.history["a"]; !ok {
+		t.Fatal("history of a should be in the table")
+	}
+	clock.Advance(2 * time.Minute)
+	c.Cleanup()
+	if _, ok := c.history["a"]; ok {
+		t.Fatal("Cleanup should drop history older than the retained period")
+	}
+}
+
+func TestLRUKExpirationAndDelete(t *testing.T) {
+	clock := useFakeClock(t)
+	c := mustLRUKCache(4, 2, 0, 0)
+	var evicted []interface{}
+	c.OnEvicted(func(key, value interface{}) { evicted = append(evicted, key) })
+
+	c.PutWithExpiration("ttl", 1, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	if _, ok := c.Get("ttl"); ok {
+		t.Fatal("entry should have expired")
+	}
+	c.Put("x", 1)
+	if !c.Delete("x") || c.Delete("x") {
+		t.Fatal("Delete should succeed exactly once")
+	}
+	for i := 0; i < 6; i++ {
+		c.Put(i, i)
+	}
+	if len(evicted) != 2 {
+		t.Fatalf("evicted = %v", evicted)
+	}
+	if hits, misses, _, expired := c.Stats(); hits != 0 || misses != 1 || expired != 1 {
+		t.Fatalf("stats = %d %d %d", hits, misses, expired)
+	}
+}
+
+// 所有条目都在相关访问期内时每次写入都要走退化分支淘汰，淘汰应保持O(log n)
+func BenchmarkLRUKEvictInCRP(b *testing.B) {
+	const capacity = 10000
+	c := mustLRUKCache(capacity, 2, time.Hour, 0)
+	for i := 0; i < capacity; i++ {
+		c.Put(i, i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Put(capacity+i, i)
+	}
+}
@@ -0,0 +1,727 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memcached文本协议相关常量
+const (
+	mcMaxKeyLength   = 250               // 协议规定的最大键长度
+	mcMaxLineLength  = 64 * 1024         // 命令行最大长度，足够一条get带上约250个最长的键
+	mcReadBufferSize = 4096              // 每个连接的读缓冲，更长的行分段读取
+	mcRelativeExpiry = 60 * 60 * 24 * 30 // 超过30天的exptime视为绝对Unix时间戳
+	mcVersion        = "1.6.0-go"        // version命令返回的版本号
+	mcLockStripes    = 256               // 复合命令的分段锁数量
+)
+
+// ErrServerClosed 服务端(memcached、RESP)的Serve在Shutdown之后返回的错误
+var ErrServerClosed = errors.New("cache server: server closed")
+
+// errLineTooLong 命令行超过长度限制
+var errLineTooLong = errors.New("cache server: line too long")
+
+// mcItem memcached条目，作为值存入底层缓存
+type mcItem struct {
+	flags   uint32    // 客户端自定义标志
+	exptime time.Time // 过期时间(零值表示永不过期)
+	cas     uint64    // CAS版本号，每次写入递增
+	data    []byte    // 数据
+}
+
+// expired 判断条目是否已过期
+func (it *mcItem) expired(now time.Time) bool {
+	return !it.exptime.IsZero() && !now.Before(it.exptime)
+}
+
+// MemcachedConfig 服务端限制配置
+type MemcachedConfig struct {
+	MaxConns    int           // 最大并发连接数，0表示不限制
+	MaxItemSize int           // 单个value最大字节数，默认1MB
+	IdleTimeout time.Duration // 连接空闲超时，0表示不超时
+}
+
+// MemcachedServer 基于memcached ASCII协议对外提供任意淘汰策略的缓存
+// 特点:
+// - 每个连接一个协程，支持流水线(pipelining)：读缓冲区为空时才刷新输出
+// - cas/incr/add等读改写命令通过按键分段的锁保证原子性
+// - Shutdown优雅退出：停止接收新连接，等待已有连接处理完当前命令
+type MemcachedServer struct {
+	cache Cache
+	cfg   MemcachedConfig
+	locks [mcLockStripes]sync.Mutex
+	casID uint64 // 全局CAS计数器(原子操作)
+
+	mu         sync.Mutex
+	listeners  map[net.Listener]struct{}
+	conns      map[net.Conn]struct{}
+	inShutdown int32 // 是否正在关闭(原子操作)
+	wg         sync.WaitGroup
+	flushTimer *time.Timer // flush_all延迟执行的定时器
+
+	started time.Time
+	stats   struct { // 运行时统计信息(原子操作)
+		currConns  int64
+		totalConns int64
+		cmdGet     int64
+		cmdSet     int64
+		cmdTouch   int64
+		cmdFlush   int64
+		getHits    int64
+		getMisses  int64
+		deleteHits int64
+		deleteMiss int64
+		incrHits   int64
+		incrMisses int64
+		decrHits   int64
+		decrMisses int64
+		casHits    int64
+		casMisses  int64
+		casBadval  int64
+		touchHits  int64
+		touchMiss  int64
+		rejected   int64
+	}
+}
+
+// NewMemcachedServer 创建服务端实例
+// cache: 底层缓存，可以是任意淘汰策略
+func NewMemcachedServer(cache Cache, cfg MemcachedConfig) *MemcachedServer {
+	if cfg.MaxItemSize <= 0 {
+		cfg.MaxItemSize = 1 << 20
+	}
+	return &MemcachedServer{
+		cache:     cache,
+		cfg:       cfg,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+		started:   time.Now(),
+	}
+}
+
+// ListenAndServe 监听network/addr并提供服务，network为tcp或unix
+func (s *MemcachedServer) ListenAndServe(network, addr string) error {
+	if network == "unix" {
+		os.Remove(addr) // 清理上次残留的socket文件
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在监听器上接收连接，直到Shutdown被调用
+func (s *MemcachedServer) Serve(l net.Listener) error {
+	if !s.trackListener(l, true) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&s.inShutdown) == 1 {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		if s.cfg.MaxConns > 0 && atomic.LoadInt64(&s.stats.currConns) >= int64(s.cfg.MaxConns) {
+			atomic.AddInt64(&s.stats.rejected, 1)
+			io.WriteString(conn, "SERVER_ERROR too many open connections\r\n")
+			conn.Close()
+			continue
+		}
+		if !s.trackConn(conn, true) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Shutdown 优雅关闭服务端
+// 1. 关闭所有监听器，不再接收新连接
+// 2. 唤醒阻塞在读取上的空闲连接，让其处理完已读取的命令后退出
+// 3. 等待所有连接退出，ctx超时则强制关闭剩余连接
+func (s *MemcachedServer) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.inShutdown, 1)
+
+	s.mu.Lock()
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.SetReadDeadline(time.Now())
+	}
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// trackListener 登记/注销监听器，关闭中时拒绝登记
+func (s *MemcachedServer) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if atomic.LoadInt32(&s.inShutdown) == 1 {
+			return false
+		}
+		s.listeners[l] = struct{}{}
+	} else {
+		delete(s.listeners, l)
+	}
+	return true
+}
+
+// trackConn 登记/注销连接，关闭中时拒绝登记
+func (s *MemcachedServer) trackConn(c net.Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if atomic.LoadInt32(&s.inShutdown) == 1 {
+			return false
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		atomic.AddInt64(&s.stats.currConns, 1)
+		atomic.AddInt64(&s.stats.totalConns, 1)
+	} else {
+		delete(s.conns, c)
+		s.wg.Done()
+		atomic.AddInt64(&s.stats.currConns, -1)
+	}
+	return true
+}
+
+// mcConn 单个客户端连接的读写状态
+type mcConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// serveConn 处理单个连接
+// 循环读取命令并执行，读缓冲区中没有后续命令时才刷新输出，
+// 这样流水线发送的多条命令只需一次系统调用即可返回全部响应
+func (s *MemcachedServer) serveConn(conn net.Conn) {
+	defer s.trackConn(conn, false)
+	defer conn.Close()
+
+	c := &mcConn{
+		conn: conn,
+		r:    bufio.NewReaderSize(conn, mcReadBufferSize),
+		w:    bufio.NewWriter(conn),
+	}
+	for {
+		if atomic.LoadInt32(&s.inShutdown) == 1 && c.r.Buffered() == 0 {
+			c.w.Flush()
+			return
+		}
+		if s.cfg.IdleTimeout > 0 && atomic.LoadInt32(&s.inShutdown) == 0 {
+			conn.SetReadDeadline(time.Now().Add(s.cfg.IdleTimeout))
+		}
+
+		line, err := readLine(c.r, mcMaxLineLength)
+		if err != nil {
+			if errors.Is(err, errLineTooLong) {
+				c.w.WriteString("CLIENT_ERROR line too long\r\n")
+			}
+			c.w.Flush()
+			return
+		}
+
+		if !s.dispatch(c, line) {
+			c.w.Flush()
+			return
+		}
+		if c.r.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readLine 读取一行并去掉结尾的\r\n，超过读缓冲的行分段拼接，总长度超过limit时返回errLineTooLong
+// memcached和RESP服务端共用
+func readLine(r *bufio.Reader, limit int) (string, error) {
+	var sb strings.Builder
+	for {
+		chunk, err := r.ReadSlice('\n')
+		sb.Write(chunk)
+		if sb.Len() > limit {
+			return "", errLineTooLong
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+	}
+	return strings.TrimRight(sb.String(), "\r\n"), nil
+}
+
+// dispatch 解析并执行一条命令，返回false表示需要关闭连接
+func (s *MemcachedServer) dispatch(c *mcConn, line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		c.w.WriteString("ERROR\r\n")
+		return true
+	}
+
+	switch cmd, args := fields[0], fields[1:]; cmd {
+	case "get", "gets":
+		s.cmdGet(c, args, cmd == "gets")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.cmdStore(c, cmd, args)
+	case "delete":
+		s.cmdDelete(c, args)
+	case "incr", "decr":
+		s.cmdIncr(c, cmd == "incr", args)
+	case "touch":
+		s.cmdTouch(c, args)
+	case "stats":
+		s.cmdStats(c, args)
+	case "flush_all":
+		s.cmdFlushAll(c, args)
+	case "version":
+		c.w.WriteString("VERSION " + mcVersion + "\r\n")
+	case "verbosity":
+		s.reply(c, hasNoreply(args), "OK")
+	case "quit":
+		return false
+	default:
+		c.w.WriteString("ERROR\r\n")
+	}
+	return true
+}
+
+// reply 写出单行响应，noreply时不输出
+func (s *MemcachedServer) reply(c *mcConn, noreply bool, msg string) {
+	if !noreply {
+		c.w.WriteString(msg + "\r\n")
+	}
+}
+
+// clientError 写出客户端错误
+func (s *MemcachedServer) clientError(c *mcConn, msg string) {
+	c.w.WriteString("CLIENT_ERROR " + msg + "\r\n")
+}
+
+// lockKey 获取键所在分段的锁
+func (s *MemcachedServer) lockKey(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.locks[h.Sum32()%mcLockStripes]
+}
+
+// load 读取未过期的条目
+func (s *MemcachedServer) load(key string) (*mcItem, bool) {
+	v, ok := s.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	it := v.(*mcItem)
+	if it.expired(time.Now()) {
+		s.cache.Delete(key)
+		return nil, false
+	}
+	return it, true
+}
+
+// store 写入条目并分配新的CAS版本号，过期时间已过则直接删除
+func (s *MemcachedServer) store(key string, it *mcItem) {
+	it.cas = atomic.AddUint64(&s.casID, 1)
+	if it.exptime.IsZero() {
+		s.cache.Put(key, it)
+		return
+	}
+	ttl := time.Until(it.exptime)
+	if ttl <= 0 {
+		s.cache.Delete(key)
+		return
+	}
+	s.cache.PutWithExpiration(key, it, ttl)
+}
+
+// cmdGet get/gets <key>*
+func (s *MemcachedServer) cmdGet(c *mcConn, keys []string, withCAS bool) {
+	if len(keys) == 0 {
+		c.w.WriteString("ERROR\r\n")
+		return
+	}
+	for _, key := range keys {
+		if !validKey(key) {
+			s.clientError(c, "bad command line format")
+			return
+		}
+	}
+
+	for _, key := range keys {
+		atomic.AddInt64(&s.stats.cmdGet, 1)
+		it, ok := s.load(key)
+		if !ok {
+			atomic.AddInt64(&s.stats.getMisses, 1)
+			continue
+		}
+		atomic.AddInt64(&s.stats.getHits, 1)
+		if withCAS {
+			fmt.Fprintf(c.w, "VALUE %s %d %d %d\r\n", key, it.flags, len(it.data), it.cas)
+		} else {
+			fmt.Fprintf(c.w, "VALUE %s %d %d\r\n", key, it.flags, len(it.data))
+		}
+		c.w.Write(it.data)
+		c.w.WriteString("\r\n")
+	}
+	c.w.WriteString("END\r\n")
+}
+
+// cmdStore set/add/replace/append/prepend <key> <flags> <exptime> <bytes> [noreply]
+// cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+// 返回false表示数据块格式错误，需要关闭连接
+func (s *MemcachedServer) cmdStore(c *mcConn, cmd string, args []string) bool {
+	want := 4
+	if cmd == "cas" {
+		want = 5
+	}
+	if len(args) < want || len(args) > want+1 {
+		c.w.WriteString("ERROR\r\n")
+		return true
+	}
+	noreply := len(args) == want+1 && args[want] == "noreply"
+
+	key := args[0]
+	flags, err1 := strconv.ParseUint(args[1], 10, 32)
+	exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+	size, err3 := strconv.Atoi(args[3])
+	var casUnique uint64
+	var err4 error
+	if cmd == "cas" {
+		casUnique, err4 = strconv.ParseUint(args[4], 10, 64)
+	}
+	if !validKey(key) || err1 != nil || err2 != nil || err3 != nil || err4 != nil || size < 0 {
+		s.clientError(c, "bad command line format")
+		return true
+	}
+
+	// 数据块过大时丢弃数据块，保持流同步
+	if size > s.cfg.MaxItemSize {
+		if _, err := c.r.Discard(size + 2); err != nil {
+			return false
+		}
+		c.w.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return true
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return false
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		s.clientError(c, "bad data chunk")
+		return false
+	}
+	data = data[:size]
+
+	atomic.AddInt64(&s.stats.cmdSet, 1)
+	it := &mcItem{flags: uint32(flags), exptime: mcExpiry(exptime), data: data}
+
+	mu := s.lockKey(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	old, exists := s.load(key)
+	switch cmd {
+	case "add":
+		if exists {
+			s.reply(c, noreply, "NOT_STORED")
+			return true
+		}
+	case "replace":
+		if !exists {
+			s.reply(c, noreply, "NOT_STORED")
+			return true
+		}
+	case "append", "prepend":
+		if !exists {
+			s.reply(c, noreply, "NOT_STORED")
+			return true
+		}
+		// append/prepend忽略flags和exptime，保留原值
+		merged := make([]byte, 0, len(old.data)+len(data))
+		if cmd == "append" {
+			merged = append(append(merged, old.data...), data...)
+		} else {
+			merged = append(append(merged, data...), old.data...)
+		}
+		it = &mcItem{flags: old.flags, exptime: old.exptime, data: merged}
+	case "cas":
+		if !exists {
+			atomic.AddInt64(&s.stats.casMisses, 1)
+			s.reply(c, noreply, "NOT_FOUND")
+			return true
+		}
+		if old.cas != casUnique {
+			atomic.AddInt64(&s.stats.casBadval, 1)
+			s.reply(c, noreply, "EXISTS")
+			return true
+		}
+		atomic.AddInt64(&s.stats.casHits, 1)
+	}
+
+	s.store(key, it)
+	s.reply(c, noreply, "STORED")
+	return true
+}
+
+// cmdDelete delete <key> [noreply]
+func (s *MemcachedServer) cmdDelete(c *mcConn, args []string) {
+	if len(args) < 1 || len(args) > 2 || !validKey(args[0]) {
+		c.w.WriteString("ERROR\r\n")
+		return
+	}
+	noreply := hasNoreply(args[1:])
+
+	mu := s.lockKey(args[0])
+	mu.Lock()
+	_, exists := s.load(args[0])
+	if exists {
+		s.cache.Delete(args[0])
+	}
+	mu.Unlock()
+
+	if exists {
+		atomic.AddInt64(&s.stats.deleteHits, 1)
+		s.reply(c, noreply, "DELETED")
+	} else {
+		atomic.AddInt64(&s.stats.deleteMiss, 1)
+		s.reply(c, noreply, "NOT_FOUND")
+	}
+}
+
+// cmdIncr incr/decr <key> <value> [noreply]
+// incr按64位无符号整数回绕，decr最小减到0
+func (s *MemcachedServer) cmdIncr(c *mcConn, incr bool, args []string) {
+	if len(args) < 2 || len(args) > 3 || !validKey(args[0]) {
+		c.w.WriteString("ERROR\r\n")
+		return
+	}
+	noreply := hasNoreply(args[2:])
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		s.clientError(c, "invalid numeric delta argument")
+		return
+	}
+
+	hits, misses := &s.stats.incrHits, &s.stats.incrMisses
+	if !incr {
+		hits, misses = &s.stats.decrHits, &s.stats.decrMisses
+	}
+
+	mu := s.lockKey(args[0])
+	mu.Lock()
+	defer mu.Unlock()
+
+	it, ok := s.load(args[0])
+	if !ok {
+		atomic.AddInt64(misses, 1)
+		s.reply(c, noreply, "NOT_FOUND")
+		return
+	}
+	cur, err := strconv.ParseUint(strings.TrimSpace(string(it.data)), 10, 64)
+	if err != nil {
+		s.clientError(c, "cannot increment or decrement non-numeric value")
+		return
+	}
+	if incr {
+		cur += delta
+	} else if delta > cur {
+		cur = 0
+	} else {
+		cur -= delta
+	}
+
+	atomic.AddInt64(hits, 1)
+	val := strconv.FormatUint(cur, 10)
+	s.store(args[0], &mcItem{flags: it.flags, exptime: it.exptime, data: []byte(val)})
+	s.reply(c, noreply, val)
+}
+
+// cmdTouch touch <key> <exptime> [noreply]
+func (s *MemcachedServer) cmdTouch(c *mcConn, args []string) {
+	if len(args) < 2 || len(args) > 3 || !validKey(args[0]) {
+		c.w.WriteString("ERROR\r\n")
+		return
+	}
+	noreply := hasNoreply(args[2:])
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		s.clientError(c, "invalid exptime argument")
+		return
+	}
+	atomic.AddInt64(&s.stats.cmdTouch, 1)
+
+	mu := s.lockKey(args[0])
+	mu.Lock()
+	defer mu.Unlock()
+
+	it, ok := s.load(args[0])
+	if !ok {
+		atomic.AddInt64(&s.stats.touchMiss, 1)
+		s.reply(c, noreply, "NOT_FOUND")
+		return
+	}
+	atomic.AddInt64(&s.stats.touchHits, 1)
+	s.store(args[0], &mcItem{flags: it.flags, exptime: mcExpiry(exptime), data: it.data})
+	s.reply(c, noreply, "TOUCHED")
+}
+
+// cmdFlushAll flush_all [delay] [noreply]
+func (s *MemcachedServer) cmdFlushAll(c *mcConn, args []string) {
+	noreply := hasNoreply(args)
+	if noreply {
+		args = args[:len(args)-1]
+	}
+	delay := int64(0)
+	if len(args) > 0 {
+		d, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || d < 0 {
+			s.clientError(c, "invalid exptime argument")
+			return
+		}
+		delay = d
+	}
+	atomic.AddInt64(&s.stats.cmdFlush, 1)
+
+	s.mu.Lock()
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	if delay == 0 {
+		s.cache.Clear()
+	} else {
+		s.flushTimer = time.AfterFunc(time.Duration(delay)*time.Second, s.cache.Clear)
+	}
+	s.mu.Unlock()
+	s.reply(c, noreply, "OK")
+}
+
+// cmdStats stats，仅支持通用统计
+func (s *MemcachedServer) cmdStats(c *mcConn, args []string) {
+	if len(args) > 0 {
+		c.w.WriteString("END\r\n")
+		return
+	}
+	now := time.Now()
+	stat := func(name string, v interface{}) {
+		fmt.Fprintf(c.w, "STAT %s %v\r\n", name, v)
+	}
+	load := func(p *int64) int64 { return atomic.LoadInt64(p) }
+
+	stat("pid", os.Getpid())
+	stat("uptime", int64(now.Sub(s.started).Seconds()))
+	stat("time", now.Unix())
+	stat("version", mcVersion)
+	stat("curr_connections", load(&s.stats.currConns))
+	stat("total_connections", load(&s.stats.totalConns))
+	stat("rejected_connections", load(&s.stats.rejected))
+	stat("cmd_get", load(&s.stats.cmdGet))
+	stat("cmd_set", load(&s.stats.cmdSet))
+	stat("cmd_flush", load(&s.stats.cmdFlush))
+	stat("cmd_touch", load(&s.stats.cmdTouch))
+	stat("get_hits", load(&s.stats.getHits))
+	stat("get_misses", load(&s.stats.getMisses))
+	stat("delete_hits", load(&s.stats.deleteHits))
+	stat("delete_misses", load(&s.stats.deleteMiss))
+	stat("incr_hits", load(&s.stats.incrHits))
+	stat("incr_misses", load(&s.stats.incrMisses))
+	stat("decr_hits", load(&s.stats.decrHits))
+	stat("decr_misses", load(&s.stats.decrMisses))
+	stat("cas_hits", load(&s.stats.casHits))
+	stat("cas_misses", load(&s.stats.casMisses))
+	stat("cas_badval", load(&s.stats.casBadval))
+	stat("touch_hits", load(&s.stats.touchHits))
+	stat("touch_misses", load(&s.stats.touchMiss))
+	stat("curr_items", s.cache.Len())
+	if src, ok := s.cache.(statsSource); ok {
+		_, _, evictions, expired := src.Stats()
+		stat("evictions", evictions)
+		stat("expired_unfetched", expired)
+	}
+	stat("item_size_max", s.cfg.MaxItemSize)
+	stat("max_connections", s.cfg.MaxConns)
+	c.w.WriteString("END\r\n")
+}
+
+// mcExpiry 将协议中的exptime转换为过期时间点
+// 0表示永不过期；负数表示立即过期；不超过30天为相对秒数；否则为Unix时间戳
+func mcExpiry(exptime int64) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime < 0:
+		return time.Unix(0, 1)
+	case exptime <= mcRelativeExpiry:
+		return time.Now().Add(time.Duration(exptime) * time.Second)
+	default:
+		return time.Unix(exptime, 0)
+	}
+}
+
+// validKey 校验键：长度不超过250且不含空白和控制字符
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > mcMaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// hasNoreply 判断参数末尾是否带noreply
+func hasNoreply(args []string) bool {
+	return len(args) > 0 && args[len(args)-1] == "noreply"
+}
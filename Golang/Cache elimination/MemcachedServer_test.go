@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mcClient 测试用的原始协议客户端
+type mcClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// startMemcached 在回环地址上启动服务端并建立一个连接
+func startMemcached(t *testing.T, cfg MemcachedConfig) (*MemcachedServer, *mcClient) {
+	t.Helper()
+	c := NewLRUCache(100, 0)
+	srv := NewMemcachedServer(c, cfg)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(l) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		if err := <-served; err != ErrServerClosed {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	})
+	return srv, dialMemcached(t, l.Addr().String())
+}
+
+func dialMemcached(t *testing.T, addr string) *mcClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return &mcClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// do 发送请求(\n会被替换为\r\n)，逐行比较响应
+func (c *mcClient) do(req string, want ...string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.conn, strings.ReplaceAll(req, "\n", "\r\n")); err != nil {
+		c.t.Fatal(err)
+	}
+	for _, w := range want {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("%q: reading %q: %v", req, w, err)
+		}
+		if got := strings.TrimSuffix(line, "\r\n"); got != w {
+			c.t.Fatalf("%q: got %q, want %q", req, got, w)
+		}
+	}
+}
+
+func TestMemcachedStorageCommands(t *testing.T) {
+	_, c := startMemcached(t, MemcachedConfig{})
+
+	c.do("get a\n", "END")
+	c.do("set a 5 0 3\nfoo\n", "STORED")
+	c.do("get a b\n", "VALUE a 5 3", "foo", "END")
+	c.do("add a 0 0 1\nx\n", "NOT_STORED")
+	c.do("add b 0 0 3\nbar\n", "STORED")
+	c.do("replace c 0 0 1\nx\n", "NOT_STORED")
+	c.do("replace b 1 0 3\nbaz\n", "STORED")
+	c.do("append a 9 0 2\n!!\n", "STORED")
+	c.do("prepend a 9 0 2\n<<\n", "STORED")
+	// append/prepend保留原flags
+	c.do("get a b\n", "VALUE a 5 7", "<<foo!!", "VALUE b 1 3", "baz", "END")
+	c.do("append missing 0 0 1\nx\n", "NOT_STORED")
+
+	c.do("delete a\n", "DELETED")
+	c.do("delete a\n", "NOT_FOUND")
+	c.do("get a\n", "END")
+
+	// 负的exptime表示立即过期
+	c.do("set gone 0 -1 1\nx\n", "STORED")
+	c.do("get gone\n", "END")
+	c.do("touch b -1\n", "TOUCHED")
+	c.do("get b\n", "END")
+	c.do("touch b 0\n", "NOT_FOUND")
+
+	c.do("version\n", "VERSION "+mcVersion)
+	c.do("set k 0 0 1\nv\nflush_all\nget k\n", "STORED", "OK", "END")
+}
+
+func TestMemcachedCAS(t *testing.T) {
+	srv, c := startMemcached(t, MemcachedConfig{})
+	c.do("cas k 0 0 1 1\nx\n", "NOT_FOUND")
+	c.do("set k 0 0 1\nx\n", "STORED")
+
+	it, _ := srv.load("k")
+	unique := it.cas
+	c.do("gets k\n", "VALUE k 0 1 "+strconv.FormatUint(unique, 10), "x", "END")
+	c.do("cas k 0 0 1 "+strconv.FormatUint(unique+1, 10)+"\ny\n", "EXISTS")
+	c.do("cas k 0 0 1 "+strconv.FormatUint(unique, 10)+"\ny\n", "STORED")
+	// 写入后版本号变化，旧版本号不能再用
+	c.do("cas k 0 0 1 "+strconv.FormatUint(unique, 10)+"\nz\n", "EXISTS")
+	c.do("get k\n", "VALUE k 0 1", "y", "END")
+}
+
+func TestMemcachedIncrDecr(t *testing.T) {
+	_, c := startMemcached(t, MemcachedConfig{})
+	c.do("incr n 1\n", "NOT_FOUND")
+	c.do("set n 3 0 2\n10\n", "STORED")
+	c.do("incr n 5\n", "15")
+	c.do("decr n 4\n", "11")
+	c.do("decr n 100\n", "0") // decr最小为0
+	c.do("get n\n", "VALUE n 3 1", "0", "END")
+
+	c.do("set max 0 0 20\n18446744073709551615\n", "STORED")
+	c.do("incr max 2\n", "1") // incr按64位回绕
+
+	c.do("set s 0 0 3\nabc\n", "STORED")
+	c.do("incr s 1\n", "CLIENT_ERROR cannot increment or decrement non-numeric value")
+	c.do("incr n abc\n", "CLIENT_ERROR invalid numeric delta argument")
+}
+
+// noreply的命令不输出任何响应，之后的响应不能错位
+func TestMemcachedNoreply(t *testing.T) {
+	_, c := startMemcached(t, MemcachedConfig{})
+	c.do("set a 0 0 1 noreply\n1\n"+
+		"add a 0 0 1 noreply\n2\n"+
+		"set n 0 0 1 noreply\n5\n"+
+		"incr n 3 noreply\n"+
+		"delete missing noreply\n"+
+		"touch a 0 noreply\n"+
+		"verbosity 1 noreply\n"+
+		"get a n\n",
+		"VALUE a 0 1", "1", "VALUE n 0 1", "8", "END")
+	c.do("delete a noreply\nflush_all noreply\nget a n\n", "END")
+}
+
+func TestMemcachedMalformed(t *testing.T) {
+	_, c := startMemcached(t, MemcachedConfig{MaxItemSize: 4})
+	c.do("bogus\n", "ERROR")
+	c.do("\n", "ERROR")
+	c.do("get\n", "ERROR")
+	c.do("set k 0 0\n", "ERROR")
+	c.do("set k x 0 1\n", "CLIENT_ERROR bad command line format")
+	c.do("set k 0 0 -1\n", "CLIENT_ERROR bad command line format")
+	c.do("get "+strings.Repeat("k", mcMaxKeyLength+1)+"\n", "CLIENT_ERROR bad command line format")
+	c.do("delete\n", "ERROR")
+	c.do("incr n\n", "ERROR")
+	c.do("touch k x\n", "CLIENT_ERROR invalid exptime argument")
+	c.do("flush_all soon\n", "CLIENT_ERROR invalid exptime argument")
+
+	// 超过MaxItemSize的数据块被丢弃，流保持同步
+	c.do("set big 0 0 10\n0123456789\nset ok 0 0 2\nhi\n", "SERVER_ERROR object too large for cache", "STORED")
+	c.do("get big ok\n", "VALUE ok 0 2", "hi", "END")
+
+	// 一条get带很多长键，超过读缓冲但未超过行长度限制
+	var keys []string
+	for i := 0; i < 200; i++ {
+		keys = append(keys, fmt.Sprintf("%0250d", i))
+	}
+	c.do("get "+strings.Join(keys, " ")+" ok\n", "VALUE ok 0 2", "hi", "END")
+
+	// 过长的命令行
+	c.do("get "+strings.Repeat("k ", mcMaxLineLength)+"\n", "CLIENT_ERROR line too long")
+	assertClosed(t, c)
+
+	// 数据块长度与声明不符时关闭连接
+	_, c = startMemcached(t, MemcachedConfig{})
+	c.do("set k 0 0 1\nabc\n", "CLIENT_ERROR bad data chunk")
+	assertClosed(t, c)
+}
+
+func TestMemcachedQuitAndMaxConns(t *testing.T) {
+	_, c := startMemcached(t, MemcachedConfig{MaxConns: 1})
+	c.do("version\n", "VERSION "+mcVersion)
+	// 已有一个连接，第二个连接被拒绝
+	other := dialMemcached(t, c.conn.RemoteAddr().String())
+	other.do("", "SERVER_ERROR too many open connections")
+	assertClosed(t, other)
+
+	c.do("quit\n")
+	assertClosed(t, c)
+}
+
+// assertClosed 服务端已关闭连接，未读完的请求会让关闭表现为连接重置
+func assertClosed(t *testing.T, c *mcClient) {
+	t.Helper()
+	if line, err := c.r.ReadString('\n'); err == nil {
+		t.Fatalf("connection still open: %q, %v", line, err)
+	}
+}
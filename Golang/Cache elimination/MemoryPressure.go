@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryReader 返回当前堆内存使用量(字节)，测试时可替换为假实现
+// 读数不变时MemoryGuard认为内存尚未重新测量，不会对同一个读数重复收缩
+type MemoryReader func() uint64
+
+const (
+	// heapLiveMetric 上一次GC标记出的存活堆字节数，不含已死亡但尚未回收的对象
+	heapLiveMetric = "/gc/heap/live:bytes"
+	// heapObjectsMetric 存活及尚未回收的堆对象占用的字节数，第一次GC完成前作为替代
+	heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+)
+
+// RuntimeMemoryReader 通过runtime/metrics读取GC之后的存活堆大小
+// 存活堆只在GC时更新，不会因为两次GC之间分配的垃圾而上涨，避免把即将回收的内存当作压力去淘汰缓存
+// 与runtime.ReadMemStats不同，读取过程不需要暂停整个程序
+func RuntimeMemoryReader() uint64 {
+	samples := []metrics.Sample{{Name: heapLiveMetric}, {Name: heapObjectsMetric}}
+	metrics.Read(samples)
+	for _, s := range samples {
+		if s.Value.Kind() == metrics.KindUint64 && s.Value.Uint64() > 0 {
+			return s.Value.Uint64()
+		}
+	}
+	return 0
+}
+
+// evictable 可以按自身淘汰策略主动收缩的缓存
+// LRUCache、LFUCache、FIFOCache、ARCCache、LRUKCache 均实现该接口
+type evictable interface {
+	Evict(n int) int
+	Len() int
+}
+
+// MemoryPressureLevel 内存压力等级
+type MemoryPressureLevel int32
+
+const (
+	PressureNormal MemoryPressureLevel = iota // 低于软限制
+	PressureSoft                              // 超过软限制，按比例收缩缓存
+	PressureHard                              // 超过硬限制，收缩缓存并拒绝写入
+)
+
+func (l MemoryPressureLevel) String() string {
+	switch l {
+	case PressureSoft:
+		return "soft"
+	case PressureHard:
+		return "hard"
+	}
+	return "normal"
+}
+
+// MemoryPressureConfig 内存压力配置
+// 软/硬限制是进入对应等级的高水位，内存回落到低水位以下才退出，避免在限制附近来回切换
+type MemoryPressureConfig struct {
+	SoftLimit        uint64        // 软限制(字节)，超过后开始收缩缓存，0表示不启用
+	HardLimit        uint64        // 硬限制(字节)，超过后拒绝写入，0表示不启用
+	SoftLowWatermark uint64        // 低于该值才退出软限制等级，默认SoftLimit的90%
+	HardLowWatermark uint64        // 低于该值才退出硬限制等级(恢复写入)，默认HardLimit的90%
+	Interval         time.Duration // 采样间隔，默认1秒
+	Reader           MemoryReader  // 内存读取函数，默认RuntimeMemoryReader
+}
+
+// MemoryPressureStats 内存压力统计
+type MemoryPressureStats struct {
+	Level     MemoryPressureLevel
+	HeapBytes uint64 // 最近一次采样的堆内存
+	Samples   int64  // 采样次数
+	Shrinks   int64  // 执行收缩的次数
+	Evicted   int64  // 因内存压力淘汰的条目数
+	Rejected  int64  // 硬限制下被拒绝的写入数
+}
+
+// MemoryGuard 内存压力感知的缓存注册中心
+// 后台协程按固定间隔采样堆内存:
+// - 超过软限制时，按超出比例对所有注册的缓存执行淘汰，每个缓存淘汰的条目数与其大小成正比
+// - 超过硬限制时同样收缩，并通过Admit/Guard拒绝新的写入，直到内存回落到硬限制的低水位以下
+type MemoryGuard struct {
+	cfg    MemoryPressureConfig
+	mu     sync.Mutex
+	caches map[string]evictable
+	level  int32 // MemoryPressureLevel
+	stop   chan struct{}
+	once   sync.Once
+
+	heapBytes, samples, shrinks, evicted, rejected int64
+
+	// shrunkAt 上一次触发收缩的读数，0表示尚未收缩或压力已解除
+	// 存活堆只在GC后更新，收缩后到下一次GC之前读数不变，据此保证每个GC周期最多收缩一次
+	shrunkAt uint64
+}
+
+// NewMemoryGuard 创建内存守卫并启动采样协程
+func NewMemoryGuard(cfg MemoryPressureConfig) *MemoryGuard {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.Reader == nil {
+		cfg.Reader = RuntimeMemoryReader
+	}
+	cfg.SoftLowWatermark = lowWatermark(cfg.SoftLowWatermark, cfg.SoftLimit)
+	cfg.HardLowWatermark = lowWatermark(cfg.HardLowWatermark, cfg.HardLimit)
+	g := &MemoryGuard{
+		cfg:    cfg,
+		caches: make(map[string]evictable),
+		stop:   make(chan struct{}),
+	}
+	go g.run()
+	return g
+}
+
+// lowWatermark 未设置时取限制的90%，不能高于限制
+func lowWatermark(low, limit uint64) uint64 {
+	if low == 0 || low > limit {
+		return limit - limit/10
+	}
+	return low
+}
+
+// Register 注册需要在内存压力下收缩的缓存，名称重复时返回错误
+func (g *MemoryGuard) Register(name string, c evictable) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.caches[name]; ok {
+		return fmt.Errorf("memory guard: cache %q already registered", name)
+	}
+	g.caches[name] = c
+	return nil
+}
+
+// Unregister 取消注册
+func (g *MemoryGuard) Unregister(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.caches, name)
+}
+
+// Level 当前内存压力等级
+func (g *MemoryGuard) Level() MemoryPressureLevel {
+	return MemoryPressureLevel(atomic.LoadInt32(&g.level))
+}
+
+// Admit 是否允许写入新条目，超过硬限制时返回false
+func (g *MemoryGuard) Admit() bool {
+	if g.Level() == PressureHard {
+		atomic.AddInt64(&g.rejected, 1)
+		return false
+	}
+	return true
+}
+
+// Check 立即采样一次并在需要时收缩缓存，返回采样后的压力等级
+// 后台协程按间隔调用，测试中可直接调用
+func (g *MemoryGuard) Check() MemoryPressureLevel {
+	used := g.cfg.Reader()
+	atomic.StoreInt64(&g.heapBytes, int64(used))
+	atomic.AddInt64(&g.samples, 1)
+
+	// 超过高水位进入，已处于该等级时低于低水位才退出
+	prev := g.Level()
+	level := PressureNormal
+	switch {
+	case g.cfg.HardLimit > 0 && used >= g.cfg.HardLimit,
+		g.cfg.HardLimit > 0 && prev == PressureHard && used >= g.cfg.HardLowWatermark:
+		level = PressureHard
+	case g.cfg.SoftLimit > 0 && used >= g.cfg.SoftLimit,
+		g.cfg.SoftLimit > 0 && prev != PressureNormal && used >= g.cfg.SoftLowWatermark:
+		level = PressureSoft
+	}
+	atomic.StoreInt32(&g.level, int32(level))
+
+	if level != PressureNormal {
+		// 目标是回到软限制以下；未设置软限制时以硬限制为目标
+		target := g.cfg.SoftLimit
+		if target == 0 {
+			target = g.cfg.HardLimit
+		}
+		// 同一个读数已经按比例收缩过，在内存重新测量之前再次收缩会把缓存一直清空
+		if used > target && atomic.SwapUint64(&g.shrunkAt, used) != used {
+			g.shrink(float64(used-target) / float64(used))
+		}
+		return level
+	}
+	atomic.StoreUint64(&g.shrunkAt, 0)
+	return level
+}
+
+// shrink 对每个注册的缓存淘汰 ceil(Len*ratio) 个条目
+// 缓存的内存占用无法精确得知，以条目数近似，使各缓存按大小成比例收缩
+func (g *MemoryGuard) shrink(ratio float64) {
+	g.mu.Lock()
+	caches := make([]evictable, 0, len(g.caches))
+	for _, c := range g.caches {
+		caches = append(caches, c)
+	}
+	g.mu.Unlock()
+
+	var total int
+	for _, c := range caches {
+		n := int(math.Ceil(float64(c.Len()) * ratio))
+		if n > 0 {
+			total += c.Evict(n)
+		}
+	}
+	atomic.AddInt64(&g.shrinks, 1)
+	atomic.AddInt64(&g.evicted, int64(total))
+}
+
+// Guard 包装缓存，超过硬限制时丢弃写入(读取和删除不受影响)
+func (g *MemoryGuard) Guard(c Cache) Cache {
+	return &guardedCache{Cache: c, guard: g}
+}
+
+// Stats 获取统计信息
+func (g *MemoryGuard) Stats() MemoryPressureStats {
+	return MemoryPressureStats{
+		Level:     g.Level(),
+		HeapBytes: uint64(atomic.LoadInt64(&g.heapBytes)),
+		Samples:   atomic.LoadInt64(&g.samples),
+		Shrinks:   atomic.LoadInt64(&g.shrinks),
+		Evicted:   atomic.LoadInt64(&g.evicted),
+		Rejected:  atomic.LoadInt64(&g.rejected),
+	}
+}
+
+// Close 停止采样协程
+func (g *MemoryGuard) Close() {
+	g.once.Do(func() { close(g.stop) })
+}
+
+// run 采样协程
+func (g *MemoryGuard) run() {
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.Check()
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// guardedCache 硬限制下拒绝写入的缓存包装
+type guardedCache struct {
+	Cache
+	guard *MemoryGuard
+}
+
+func (c *guardedCache) Put(key, value interface{}) {
+	if c.guard.Admit() {
+		c.Cache.Put(key, value)
+	}
+}
+
+func (c *guardedCache) PutWithExpiration(key, value interface{}, expiration time.Duration) {
+	if c.guard.Admit() {
+		c.Cache.PutWithExpiration(key, value, expiration)
+	}
+}
@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeMemory 可控的内存读取器
+type fakeMemory struct{ used uint64 }
+
+func (f *fakeMemory) set(n uint64)       { atomic.StoreUint64(&f.used, n) }
+func (f *fakeMemory) read() uint64       { return atomic.LoadUint64(&f.used) }
+func newFakeMemory(n uint64) *fakeMemory { return &fakeMemory{used: n} }
+
+func TestMemoryGuardShrinksProportionally(t *testing.T) {
+	mem := newFakeMemory(0)
+	g := NewMemoryGuard(MemoryPressureConfig{SoftLimit: 800, HardLimit: 1000, Interval: time.Hour, Reader: mem.read})
+	defer g.Close()
+
+	big, small := NewLRUCache(1000, 0), NewFIFOCache(100)
+	defer small.Close()
+	for i := 0; i < 1000; i++ {
+		big.Put(i, i)
+		if i < 100 {
+			small.Put(i, i)
+		}
+	}
+	if err := g.Register("big", big); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Register("big", big); err == nil {
+		t.Fatal("duplicate registration should fail")
+	}
+	g.Register("small", small)
+
+	if g.Check() != PressureNormal || big.Len() != 1000 {
+		t.Fatal("no eviction expected below the soft limit")
+	}
+
+	// 已用内存中约20%超出软限制：每个缓存淘汰约20%的条目
+	mem.set(999)
+	if level := g.Check(); level != PressureSoft {
+		t.Fatalf("level = %v", level)
+	}
+	if big.Len() != 800 || small.Len() != 80 {
+		t.Fatalf("big=%d small=%d", big.Len(), small.Len())
+	}
+	// LRU淘汰最久未使用的键
+	if _, ok := big.Get(0); ok {
+		t.Fatal("oldest key should have been evicted")
+	}
+	if _, ok := big.Get(999); !ok {
+		t.Fatal("newest key should survive")
+	}
+	if s := g.Stats(); s.Shrinks != 1 || s.Evicted != 220 || s.HeapBytes != 999 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+// 存活堆在下一次GC之前不变，同一个读数只收缩一次，否则每次采样都会再淘汰一轮直到缓存清空
+func TestMemoryGuardShrinksOncePerSample(t *testing.T) {
+	mem := newFakeMemory(1000)
+	g := NewMemoryGuard(MemoryPressureConfig{SoftLimit: 800, Interval: time.Hour, Reader: mem.read})
+	defer g.Close()
+
+	c := NewLRUCache(100, 0)
+	for i := 0; i < 100; i++ {
+		c.Put(i, i)
+	}
+	g.Register("lru", c)
+
+	for i := 0; i < 5; i++ {
+		if level := g.Check(); level != PressureSoft {
+			t.Fatalf("level = %v", level)
+		}
+	}
+	if s := g.Stats(); c.Len() != 80 || s.Shrinks != 1 || s.Samples != 5 {
+		t.Fatalf("len = %d, stats = %+v", c.Len(), s)
+	}
+
+	// 下一次GC之后读数变化，仍超过软限制时继续收缩
+	mem.set(900)
+	g.Check()
+	if s := g.Stats(); c.Len() != 71 || s.Shrinks != 2 {
+		t.Fatalf("len = %d, stats = %+v", c.Len(), s)
+	}
+
+	// 压力解除后再次出现同样的读数，重新收缩
+	mem.set(100)
+	g.Check()
+	mem.set(900)
+	g.Check()
+	if s := g.Stats(); s.Shrinks != 3 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestMemoryGuardHardLimitRefusesAdmission(t *testing.T) {
+	mem := newFakeMemory(2000)
+	g := NewMemoryGuard(MemoryPressureConfig{SoftLimit: 800, HardLimit: 1000, Interval: time.Hour, Reader: mem.read})
+	defer g.Close()
+
+	c := g.Guard(NewARCCache(100))
+	if g.Check() != PressureHard {
+		t.Fatal("expected hard pressure")
+	}
+	c.Put("k", "v")
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("write should have been refused")
+	}
+
+	mem.set(100)
+	g.Check()
+	c.Put("k", "v")
+	if _, ok := c.Get("k"); !ok {
+		t.Fatal("write should be admitted after pressure drops")
+	}
+	if s := g.Stats(); s.Rejected != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestMemoryGuardSamplingGoroutine(t *testing.T) {
+	mem := newFakeMemory(500)
+	g := NewMemoryGuard(MemoryPressureConfig{SoftLimit: 100, Interval: 5 * time.Millisecond, Reader: mem.read})
+	defer g.Close()
+
+	lfu := NewLFUCache(100)
+	defer lfu.Close()
+	for i := 0; i < 100; i++ {
+		lfu.Put(i, i)
+	}
+	g.Register("lfu", lfu)
+
+	deadline := time.Now().Add(time.Second)
+	for lfu.Len() == 100 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if lfu.Len() == 100 {
+		t.Fatal("sampling goroutine never shrank the cache")
+	}
+}
+
+// 进入等级看高水位，退出等级看低水位
+func TestMemoryGuardHysteresis(t *testing.T) {
+	mem := newFakeMemory(0)
+	g := NewMemoryGuard(MemoryPressureConfig{SoftLimit: 800, HardLimit: 1000, HardLowWatermark: 850, Interval: time.Hour, Reader: mem.read})
+	defer g.Close()
+
+	for _, step := range []struct {
+		used uint64
+		want MemoryPressureLevel
+	}{
+		{790, PressureNormal},
+		{800, PressureSoft},
+		{730, PressureSoft}, // 软限制低水位默认720
+		{1000, PressureHard},
+		{900, PressureHard},
+		{849, PressureSoft},
+		{1000, PressureHard},
+		{700, PressureNormal},
+		{750, PressureNormal},
+	} {
+		mem.set(step.used)
+		if level := g.Check(); level != step.want {
+			t.Fatalf("used=%d: level = %v, want %v", step.used, level, step.want)
+		}
+		if admit := g.Admit(); admit != (step.want != PressureHard) {
+			t.Fatalf("used=%d: Admit = %v", step.used, admit)
+		}
+	}
+}
+
+// 读取的是GC之后的存活堆，释放的内存在下一次GC后不再计入
+func TestRuntimeMemoryReader(t *testing.T) {
+	const size = 64 << 20
+	runtime.GC()
+	base := RuntimeMemoryReader()
+	if base == 0 {
+		t.Fatal("expected a non-zero heap size")
+	}
+	var with uint64
+	func() {
+		buf := make([]byte, size)
+		runtime.GC()
+		with = RuntimeMemoryReader()
+		runtime.KeepAlive(buf)
+	}()
+	if with < base+size*9/10 {
+		t.Fatalf("live heap %d with a %d byte buffer, base %d", with, size, base)
+	}
+	runtime.GC()
+	if after := RuntimeMemoryReader(); after > with-size/2 {
+		t.Fatalf("live heap %d after freeing the buffer, was %d", after, with)
+	}
+}
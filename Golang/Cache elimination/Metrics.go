@@ -0,0 +1,375 @@
+package cache
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statsSource 可被指标系统采集的缓存
+// LRUCache、LFUCache、FIFOCache、ARCCache 均满足该接口
+type statsSource interface {
+	Stats() (hits, misses, evictions, expired int64)
+	Len() int
+}
+
+// costSource 可选接口，缓存若能报告总成本(如字节数)则实现它
+// 未实现时成本按条目数计算
+type costSource interface {
+	Cost() int64
+}
+
+// loadBuckets 加载耗时直方图的桶上界
+var loadBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// statsSample 某一时刻的计数器快照，用于计算滑动窗口命中率
+type statsSample struct {
+	at     time.Time
+	hits   int64
+	misses int64
+}
+
+// cacheCollector 单个已注册缓存的采集状态
+type cacheCollector struct {
+	name    string
+	source  statsSource
+	samples []statsSample // 按时间递增的快照，超出最大窗口的会被丢弃
+
+	loads      int64   // 加载次数
+	loadErrors int64   // 加载失败次数
+	loadNanos  int64   // 加载总耗时(纳秒)
+	loadHist   []int64 // 各桶的计数(非累积)，最后一个为+Inf
+}
+
+// CacheSnapshot 某个缓存在某一时刻的全部指标
+type CacheSnapshot struct {
+	Name       string
+	Hits       int64
+	Misses     int64
+	Evictions  int64
+	Expired    int64
+	Size       int
+	Cost       int64
+	HitRatio   float64                   // 生命周期命中率
+	Windows    map[time.Duration]float64 // 滑动窗口命中率
+	Loads      int64
+	LoadErrors int64
+	LoadTime   time.Duration // 加载总耗时
+}
+
+// CacheMetrics 缓存指标注册表
+// 注册命名缓存后，以Prometheus文本格式(http.Handler)和expvar两种方式导出
+// 后台协程按sampleInterval定期采样计数器，用于计算滑动窗口命中率
+type CacheMetrics struct {
+	mu       sync.RWMutex
+	caches   map[string]*cacheCollector
+	windows  []time.Duration // 滑动窗口列表，升序
+	interval time.Duration   // 采样间隔
+	stopChan chan struct{}
+	once     sync.Once
+}
+
+// NewCacheMetrics 创建指标注册表
+// sampleInterval: 采样间隔，<=0时使用10秒
+// windows: 滑动窗口大小，为空时使用1m/5m/15m
+func NewCacheMetrics(sampleInterval time.Duration, windows ...time.Duration) *CacheMetrics {
+	if sampleInterval <= 0 {
+		sampleInterval = 10 * time.Second
+	}
+	if len(windows) == 0 {
+		windows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+	}
+	ws := append([]time.Duration(nil), windows...)
+	sort.Slice(ws, func(i, j int) bool { return ws[i] < ws[j] })
+
+	m := &CacheMetrics{
+		caches:   make(map[string]*cacheCollector),
+		windows:  ws,
+		interval: sampleInterval,
+		stopChan: make(chan struct{}),
+	}
+	go m.startSampler()
+	return m
+}
+
+// Register 注册一个命名缓存，名称重复时返回错误
+func (m *CacheMetrics) Register(name string, c statsSource) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.caches[name]; ok {
+		return fmt.Errorf("cache metrics: %q already registered", name)
+	}
+	col := &cacheCollector{
+		name:     name,
+		source:   c,
+		loadHist: make([]int64, len(loadBuckets)+1),
+	}
+	col.sample(timeNow(), m.maxWindow())
+	m.caches[name] = col
+	return nil
+}
+
+// Unregister 取消注册
+func (m *CacheMetrics) Unregister(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.caches, name)
+}
+
+// ObserveLoad 记录一次回源加载的耗时和结果
+// 缓存本身不感知加载过程，由调用方在回源后上报
+func (m *CacheMetrics) ObserveLoad(name string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	col, ok := m.caches[name]
+	if !ok {
+		return
+	}
+	col.loads++
+	col.loadNanos += int64(d)
+	if err != nil {
+		col.loadErrors++
+	}
+	i := sort.Search(len(loadBuckets), func(i int) bool { return d <= loadBuckets[i] })
+	col.loadHist[i]++
+}
+
+// Snapshot 获取所有缓存的当前指标，按名称排序
+func (m *CacheMetrics) Snapshot() []CacheSnapshot {
+	now := timeNow()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.caches))
+	for name := range m.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	snaps := make([]CacheSnapshot, 0, len(names))
+	for _, name := range names {
+		snaps = append(snaps, m.caches[name].snapshot(now, m.windows))
+	}
+	return snaps
+}
+
+// Close 停止后台采样协程
+func (m *CacheMetrics) Close() {
+	m.once.Do(func() { close(m.stopChan) })
+}
+
+// startSampler 定期为所有缓存记录计数器快照
+func (m *CacheMetrics) startSampler() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			m.sampleAll(now)
+		case <-m.stopChan:
+			return
+		}
+	}
+}
+
+// sampleAll 为所有缓存记录一次快照
+func (m *CacheMetrics) sampleAll(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, col := range m.caches {
+		col.sample(now, m.maxWindow())
+	}
+}
+
+// maxWindow 最大的滑动窗口，决定快照的保留时长
+func (m *CacheMetrics) maxWindow() time.Duration {
+	return m.windows[len(m.windows)-1]
+}
+
+// sample 追加一个快照，并丢弃比最大窗口更早的快照(保留一个作为窗口起点)
+func (c *cacheCollector) sample(now time.Time, keep time.Duration) {
+	hits, misses, _, _ := c.source.Stats()
+	c.samples = append(c.samples, statsSample{at: now, hits: hits, misses: misses})
+
+	cutoff := now.Add(-keep)
+	drop := 0
+	for drop < len(c.samples)-1 && !c.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	c.samples = c.samples[drop:]
+}
+
+// snapshot 计算当前指标
+func (c *cacheCollector) snapshot(now time.Time, windows []time.Duration) CacheSnapshot {
+	hits, misses, evictions, expired := c.source.Stats()
+	size := c.source.Len()
+	cost := int64(size)
+	if cs, ok := c.source.(costSource); ok {
+		cost = cs.Cost()
+	}
+
+	s := CacheSnapshot{
+		Name:       c.name,
+		Hits:       hits,
+		Misses:     misses,
+		Evictions:  evictions,
+		Expired:    expired,
+		Size:       size,
+		Cost:       cost,
+		HitRatio:   hitRatio(hits, misses),
+		Windows:    make(map[time.Duration]float64, len(windows)),
+		Loads:      c.loads,
+		LoadErrors: c.loadErrors,
+		LoadTime:   time.Duration(c.loadNanos),
+	}
+
+	// 窗口起点取窗口开始前最近的一个快照，没有则取最早的快照
+	for _, w := range windows {
+		cutoff := now.Add(-w)
+		base := c.samples[0]
+		for _, smp := range c.samples {
+			if smp.at.After(cutoff) {
+				break
+			}
+			base = smp
+		}
+		s.Windows[w] = hitRatio(hits-base.hits, misses-base.misses)
+	}
+	return s
+}
+
+// hitRatio 计算命中率，无访问时为0
+func hitRatio(hits, misses int64) float64 {
+	total := hits + misses
+	if total <= 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// ServeHTTP 以Prometheus文本格式输出指标
+func (m *CacheMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+// WritePrometheus 按Prometheus文本暴露格式写出所有指标
+func (m *CacheMetrics) WritePrometheus(w io.Writer) error {
+	snaps := m.Snapshot()
+
+	var b strings.Builder
+	counter := func(name, help string, value func(s CacheSnapshot) int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, s := range snaps {
+			fmt.Fprintf(&b, "%s{cache=\"%s\"} %d\n", name, escapeLabel(s.Name), value(s))
+		}
+	}
+	gauge := func(name, help string, value func(s CacheSnapshot) int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, s := range snaps {
+			fmt.Fprintf(&b, "%s{cache=\"%s\"} %d\n", name, escapeLabel(s.Name), value(s))
+		}
+	}
+
+	counter("cache_hits_total", "Total number of cache hits.", func(s CacheSnapshot) int64 { return s.Hits })
+	counter("cache_misses_total", "Total number of cache misses.", func(s CacheSnapshot) int64 { return s.Misses })
+	counter("cache_evictions_total", "Total number of entries evicted for capacity.", func(s CacheSnapshot) int64 { return s.Evictions })
+	counter("cache_expirations_total", "Total number of entries removed after expiring.", func(s CacheSnapshot) int64 { return s.Expired })
+	gauge("cache_size", "Current number of entries in the cache.", func(s CacheSnapshot) int64 { return int64(s.Size) })
+	gauge("cache_cost", "Current total cost of the entries in the cache.", func(s CacheSnapshot) int64 { return s.Cost })
+
+	b.WriteString("# HELP cache_hit_ratio Cache hit ratio over the lifetime and over sliding windows.\n")
+	b.WriteString("# TYPE cache_hit_ratio gauge\n")
+	for _, s := range snaps {
+		name := escapeLabel(s.Name)
+		fmt.Fprintf(&b, "cache_hit_ratio{cache=\"%s\",window=\"lifetime\"} %g\n", name, s.HitRatio)
+		for _, win := range m.windows {
+			fmt.Fprintf(&b, "cache_hit_ratio{cache=\"%s\",window=\"%s\"} %g\n", name, win, s.Windows[win])
+		}
+	}
+
+	counter("cache_load_errors_total", "Total number of failed loads.", func(s CacheSnapshot) int64 { return s.LoadErrors })
+
+	b.WriteString("# HELP cache_load_duration_seconds Latency of loading missing entries from the origin.\n")
+	b.WriteString("# TYPE cache_load_duration_seconds histogram\n")
+	m.mu.RLock()
+	for _, s := range snaps {
+		col, ok := m.caches[s.Name]
+		if !ok {
+			continue
+		}
+		name := escapeLabel(s.Name)
+		var cumulative int64
+		for i, le := range loadBuckets {
+			cumulative += col.loadHist[i]
+			fmt.Fprintf(&b, "cache_load_duration_seconds_bucket{cache=\"%s\",le=\"%g\"} %d\n", name, le.Seconds(), cumulative)
+		}
+		cumulative += col.loadHist[len(loadBuckets)]
+		fmt.Fprintf(&b, "cache_load_duration_seconds_bucket{cache=\"%s\",le=\"+Inf\"} %d\n", name, cumulative)
+		fmt.Fprintf(&b, "cache_load_duration_seconds_sum{cache=\"%s\"} %g\n", name, time.Duration(col.loadNanos).Seconds())
+		fmt.Fprintf(&b, "cache_load_duration_seconds_count{cache=\"%s\"} %d\n", name, col.loads)
+	}
+	m.mu.RUnlock()
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabel 按Prometheus规则转义标签值
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// PublishExpvar 将所有缓存指标以name发布到expvar(/debug/vars)
+// expvar的变量不能取消发布，name已被占用时返回错误而不是像expvar.Publish那样panic
+func (m *CacheMetrics) PublishExpvar(name string) error {
+	publishMu.Lock()
+	defer publishMu.Unlock()
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("cache metrics: expvar %q already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		out := make(map[string]interface{})
+		for _, s := range m.Snapshot() {
+			windows := make(map[string]float64, len(s.Windows))
+			for w, ratio := range s.Windows {
+				windows[w.String()] = ratio
+			}
+			out[s.Name] = map[string]interface{}{
+				"hits":             s.Hits,
+				"misses":           s.Misses,
+				"evictions":        s.Evictions,
+				"expirations":      s.Expired,
+				"size":             s.Size,
+				"cost":             s.Cost,
+				"hit_ratio":        s.HitRatio,
+				"hit_ratio_window": windows,
+				"loads":            s.Loads,
+				"load_errors":      s.LoadErrors,
+				"load_seconds":     s.LoadTime.Seconds(),
+			}
+		}
+		return out
+	}))
+	return nil
+}
+
+// publishMu 保证检查与发布之间没有其他PublishExpvar插入
+var publishMu sync.Mutex
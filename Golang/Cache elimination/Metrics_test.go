@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestMetrics 采样间隔足够长，测试中通过sampleAll手动采样
+func newTestMetrics(t *testing.T, windows ...time.Duration) *CacheMetrics {
+	t.Helper()
+	m := NewCacheMetrics(time.Hour, windows...)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// access 产生指定次数的命中和未命中
+func access(c Cache, hits, misses int) {
+	c.Put("hit", 1)
+	for i := 0; i < hits; i++ {
+		c.Get("hit")
+	}
+	for i := 0; i < misses; i++ {
+		c.Get("miss")
+	}
+}
+
+func TestMetricsSnapshot(t *testing.T) {
+	useFakeClock(t)
+	m := newTestMetrics(t)
+	c := NewLRUCache(10, 0)
+	if err := m.Register("users", c); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register("users", c); err == nil {
+		t.Fatal("duplicate Register should fail")
+	}
+
+	access(c, 3, 1)
+	m.ObserveLoad("users", 3*time.Millisecond, nil)
+	m.ObserveLoad("users", 2*time.Second, errors.New("origin down"))
+	m.ObserveLoad("unknown", time.Second, nil) // 未注册的名称被忽略
+
+	snaps := m.Snapshot()
+	if len(snaps) != 1 {
+		t.Fatalf("Snapshot() returned %d caches", len(snaps))
+	}
+	s := snaps[0]
+	if s.Name != "users" || s.Hits != 3 || s.Misses != 1 || s.Size != 1 || s.Cost != 1 || s.HitRatio != 0.75 {
+		t.Fatalf("snapshot = %+v", s)
+	}
+	if s.Loads != 2 || s.LoadErrors != 1 || s.LoadTime != 2003*time.Millisecond {
+		t.Fatalf("load stats = %d/%d/%v", s.Loads, s.LoadErrors, s.LoadTime)
+	}
+
+	m.Unregister("users")
+	if snaps := m.Snapshot(); len(snaps) != 0 {
+		t.Fatalf("Snapshot() after Unregister = %+v", snaps)
+	}
+}
+
+// 窗口命中率只统计窗口内的访问，超出最大窗口的快照被丢弃
+func TestMetricsWindowRollover(t *testing.T) {
+	clock := useFakeClock(t)
+	m := newTestMetrics(t, 5*time.Minute, time.Minute)
+	c := NewLRUCache(10, 0)
+	m.Register("c", c)
+	window := func(w time.Duration) float64 { return m.Snapshot()[0].Windows[w] }
+
+	access(c, 4, 0)
+	clock.Advance(time.Minute)
+	m.sampleAll(clock.Now())
+	access(c, 0, 4)
+	if w1, w5 := window(time.Minute), window(5*time.Minute); w1 != 0.5 || w5 != 0.5 {
+		t.Fatalf("at 1m: windows = %v/%v, want 0.5/0.5", w1, w5)
+	}
+
+	// 1分钟窗口从第二个快照开始，只看到后4次未命中
+	clock.Advance(time.Minute)
+	m.sampleAll(clock.Now())
+	if w1, w5 := window(time.Minute), window(5*time.Minute); w1 != 0 || w5 != 0.5 {
+		t.Fatalf("at 2m: windows = %v/%v, want 0/0.5", w1, w5)
+	}
+
+	// 5分钟之后，早于窗口起点的快照只保留一个
+	clock.Advance(5 * time.Minute)
+	m.sampleAll(clock.Now())
+	if n := len(m.caches["c"].samples); n != 2 {
+		t.Fatalf("%d samples retained, want 2", n)
+	}
+	access(c, 2, 0)
+	s := m.Snapshot()[0]
+	if s.Windows[5*time.Minute] != 1 || s.HitRatio != 0.6 {
+		t.Fatalf("at 7m: window = %v, lifetime = %v", s.Windows[5*time.Minute], s.HitRatio)
+	}
+}
+
+func TestMetricsWritePrometheus(t *testing.T) {
+	useFakeClock(t)
+	m := newTestMetrics(t, time.Minute)
+	c := NewLRUCache(10, 0)
+	m.Register(`a"b`, c)
+	access(c, 1, 1)
+	m.ObserveLoad(`a"b`, 3*time.Millisecond, nil)
+	m.ObserveLoad(`a"b`, 10*time.Second, errors.New("timeout"))
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	out := rec.Body.String()
+	for _, want := range []string{
+		"# HELP cache_hits_total Total number of cache hits.\n# TYPE cache_hits_total counter\n" +
+			`cache_hits_total{cache="a\"b"} 1` + "\n",
+		`cache_misses_total{cache="a\"b"} 1`,
+		`cache_evictions_total{cache="a\"b"} 0`,
+		"# TYPE cache_size gauge\n" + `cache_size{cache="a\"b"} 1`,
+		`cache_cost{cache="a\"b"} 1`,
+		`cache_hit_ratio{cache="a\"b",window="lifetime"} 0.5`,
+		`cache_hit_ratio{cache="a\"b",window="1m0s"} 0.5`,
+		`cache_load_errors_total{cache="a\"b"} 1`,
+		"# TYPE cache_load_duration_seconds histogram\n" +
+			`cache_load_duration_seconds_bucket{cache="a\"b",le="0.001"} 0` + "\n" +
+			`cache_load_duration_seconds_bucket{cache="a\"b",le="0.005"} 1` + "\n",
+		`cache_load_duration_seconds_bucket{cache="a\"b",le="5"} 1` + "\n" +
+			`cache_load_duration_seconds_bucket{cache="a\"b",le="+Inf"} 2` + "\n" +
+			`cache_load_duration_seconds_sum{cache="a\"b"} 10.003` + "\n" +
+			`cache_load_duration_seconds_count{cache="a\"b"} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestMetricsPublishExpvar(t *testing.T) {
+	m := newTestMetrics(t)
+	c := NewLRUCache(10, 0)
+	m.Register("c", c)
+	access(c, 1, 0)
+
+	const name = "cache_metrics_test"
+	if err := m.PublishExpvar(name); err != nil {
+		t.Fatal(err)
+	}
+	// 同名再次发布返回错误，不panic，也不替换已发布的变量
+	other := newTestMetrics(t)
+	if err := other.PublishExpvar(name); err == nil {
+		t.Fatal("second PublishExpvar with the same name should fail")
+	}
+
+	var vars map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if got := vars["c"]; got["hits"] != 1.0 || got["size"] != 1.0 || got["hit_ratio"] != 1.0 {
+		t.Fatalf("expvar = %v", vars)
+	}
+}
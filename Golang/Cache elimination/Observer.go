@@ -0,0 +1,429 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventKind 缓存事件类型
+type EventKind uint8
+
+const (
+	EventHit    EventKind = iota // 读取命中
+	EventMiss                    // 读取未命中(含因过期未命中)
+	EventPut                     // 写入
+	EventEvict                   // 因容量被淘汰
+	EventExpire                  // 过期被删除
+	EventLoad                    // 回源加载
+)
+
+var eventKindNames = [...]string{"hit", "miss", "put", "evict", "expire", "load"}
+
+func (k EventKind) String() string {
+	if int(k) < len(eventKindNames) {
+		return eventKindNames[k]
+	}
+	return fmt.Sprintf("event(%d)", k)
+}
+
+// parseEventKind 解析String()的输出
+func parseEventKind(s string) (EventKind, error) {
+	for i, name := range eventKindNames {
+		if name == s {
+			return EventKind(i), nil
+		}
+	}
+	return 0, fmt.Errorf("cache trace: unknown event %q", s)
+}
+
+// CacheEvent 一次缓存事件
+type CacheEvent struct {
+	Kind    EventKind
+	Policy  string        // 产生事件的策略(lru、lfu、fifo、arc、lru-k、concurrent-lru)或xfetch
+	Key     interface{}   // 缓存键
+	Time    time.Time     // 事件发生时间
+	Latency time.Duration // Get/Put为含等锁的调用耗时，Load为回源耗时，Evict/Expire为0
+	Err     error         // 仅Load事件，回源失败的原因
+}
+
+// Observer 缓存事件观察者
+// 事件在缓存释放锁之后同步派发，实现需要自行保证并发安全，
+// 耗时的处理应交给后台协程，避免拖慢缓存操作
+type Observer interface {
+	OnHit(e CacheEvent)
+	OnMiss(e CacheEvent)
+	OnPut(e CacheEvent)
+	OnEvict(e CacheEvent)
+	OnExpire(e CacheEvent)
+	OnLoad(e CacheEvent)
+}
+
+// ObserverFunc 把所有事件交给同一个函数处理的观察者
+type ObserverFunc func(e CacheEvent)
+
+func (f ObserverFunc) OnHit(e CacheEvent)    { f(e) }
+func (f ObserverFunc) OnMiss(e CacheEvent)   { f(e) }
+func (f ObserverFunc) OnPut(e CacheEvent)    { f(e) }
+func (f ObserverFunc) OnEvict(e CacheEvent)  { f(e) }
+func (f ObserverFunc) OnExpire(e CacheEvent) { f(e) }
+func (f ObserverFunc) OnLoad(e CacheEvent)   { f(e) }
+
+// dispatchEvent 按事件类型调用观察者的对应方法
+func dispatchEvent(o Observer, e CacheEvent) {
+	switch e.Kind {
+	case EventHit:
+		o.OnHit(e)
+	case EventMiss:
+		o.OnMiss(e)
+	case EventPut:
+		o.OnPut(e)
+	case EventEvict:
+		o.OnEvict(e)
+	case EventExpire:
+		o.OnExpire(e)
+	case EventLoad:
+		o.OnLoad(e)
+	}
+}
+
+// observerBox atomic.Value要求每次存入相同的具体类型
+type observerBox struct{ o Observer }
+
+// observerHook 嵌入各缓存策略，提供SetObserver并负责派发事件
+// 观察者保存在atomic.Value中，未设置观察者时每次操作只多一次原子读取，也不读取时钟
+type observerHook struct {
+	policy   string
+	observer atomic.Value // observerBox
+}
+
+// SetObserver 设置事件观察者，传入nil取消观察
+func (h *observerHook) SetObserver(o Observer) {
+	h.observer.Store(observerBox{o})
+}
+
+// observeStart 返回当前观察者及操作开始时间，未设置观察者时返回nil
+func (h *observerHook) observeStart() (Observer, time.Time) {
+	box, _ := h.observer.Load().(observerBox)
+	if box.o == nil {
+		return nil, time.Time{}
+	}
+	return box.o, timeNow()
+}
+
+func (h *observerHook) emit(o Observer, kind EventKind, key interface{}, start time.Time) {
+	now := timeNow()
+	e := CacheEvent{Kind: kind, Policy: h.policy, Key: key, Time: now}
+	if !start.IsZero() {
+		e.Latency = now.Sub(start)
+	}
+	dispatchEvent(o, e)
+}
+
+// notifyGet 派发一次读取的结果，因过期未命中时先派发Expire再派发Miss
+func (h *observerHook) notifyGet(o Observer, start time.Time, key interface{}, hit, expired bool) {
+	switch {
+	case hit:
+		h.emit(o, EventHit, key, start)
+	case expired:
+		h.emit(o, EventExpire, key, time.Time{})
+		h.emit(o, EventMiss, key, start)
+	default:
+		h.emit(o, EventMiss, key, start)
+	}
+}
+
+// notifyPut 派发一次写入，写入引起的淘汰由pendingEvictions.flush随后派发
+func (h *observerHook) notifyPut(o Observer, start time.Time, key interface{}) {
+	h.emit(o, EventPut, key, start)
+}
+
+// notifyLoad 派发一次回源加载及其结果
+func (h *observerHook) notifyLoad(o Observer, start time.Time, key interface{}, err error) {
+	now := timeNow()
+	dispatchEvent(o, CacheEvent{Kind: EventLoad, Policy: h.policy, Key: key, Time: now, Latency: now.Sub(start), Err: err})
+}
+
+// notifyEach 为每个键派发一个不带耗时的事件(批量淘汰、清理过期)
+func (h *observerHook) notifyEach(o Observer, kind EventKind, keys []interface{}) {
+	for _, key := range keys {
+		h.emit(o, kind, key, time.Time{})
+	}
+}
+
+// SampledObserver 按键采样的观察者
+// 同一个键的所有事件要么全部保留要么全部丢弃，采样后的轨迹仍可用于回放
+type SampledObserver struct {
+	next Observer
+	rate uint32
+}
+
+// NewSampledObserver 每rate个键保留1个，rate<=1时保留全部
+func NewSampledObserver(next Observer, rate int) *SampledObserver {
+	if rate < 1 {
+		rate = 1
+	}
+	return &SampledObserver{next: next, rate: uint32(rate)}
+}
+
+func (s *SampledObserver) keep(key interface{}) bool {
+	if s.rate == 1 {
+		return true
+	}
+	k, ok := key.(string)
+	if !ok {
+		k = fmt.Sprint(key)
+	}
+	return ringHash(k)%s.rate == 0
+}
+
+func (s *SampledObserver) OnHit(e CacheEvent) {
+	if s.keep(e.Key) {
+		s.next.OnHit(e)
+	}
+}
+
+func (s *SampledObserver) OnMiss(e CacheEvent) {
+	if s.keep(e.Key) {
+		s.next.OnMiss(e)
+	}
+}
+
+func (s *SampledObserver) OnPut(e CacheEvent) {
+	if s.keep(e.Key) {
+		s.next.OnPut(e)
+	}
+}
+
+func (s *SampledObserver) OnEvict(e CacheEvent) {
+	if s.keep(e.Key) {
+		s.next.OnEvict(e)
+	}
+}
+
+func (s *SampledObserver) OnExpire(e CacheEvent) {
+	if s.keep(e.Key) {
+		s.next.OnExpire(e)
+	}
+}
+
+func (s *SampledObserver) OnLoad(e CacheEvent) {
+	if s.keep(e.Key) {
+		s.next.OnLoad(e)
+	}
+}
+
+// ChannelObserver 把事件非阻塞地写入通道，通道满时丢弃并计数
+type ChannelObserver struct {
+	ch      chan<- CacheEvent
+	dropped int64
+}
+
+// NewChannelObserver 创建通道观察者，消费者应及时读取通道
+func NewChannelObserver(ch chan<- CacheEvent) *ChannelObserver {
+	return &ChannelObserver{ch: ch}
+}
+
+// Dropped 因通道满被丢弃的事件数
+func (c *ChannelObserver) Dropped() int64 {
+	return atomic.LoadInt64(&c.dropped)
+}
+
+func (c *ChannelObserver) send(e CacheEvent) {
+	select {
+	case c.ch <- e:
+	default:
+		atomic.AddInt64(&c.dropped, 1)
+	}
+}
+
+func (c *ChannelObserver) OnHit(e CacheEvent)    { c.send(e) }
+func (c *ChannelObserver) OnMiss(e CacheEvent)   { c.send(e) }
+func (c *ChannelObserver) OnPut(e CacheEvent)    { c.send(e) }
+func (c *ChannelObserver) OnEvict(e CacheEvent)  { c.send(e) }
+func (c *ChannelObserver) OnExpire(e CacheEvent) { c.send(e) }
+func (c *ChannelObserver) OnLoad(e CacheEvent)   { c.send(e) }
+
+// SlogObserver 把事件写入结构化日志
+// 命中、未命中、写入按level记录，回源失败按Warn记录
+type SlogObserver struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// NewSlogObserver 创建日志观察者，logger为nil时使用slog.Default()
+func NewSlogObserver(logger *slog.Logger, level slog.Level) *SlogObserver {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogObserver{logger: logger, level: level}
+}
+
+func (s *SlogObserver) log(e CacheEvent) {
+	level := s.level
+	if e.Err != nil {
+		level = slog.LevelWarn
+	}
+	ctx := context.Background()
+	if !s.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("policy", e.Policy),
+		slog.Any("key", e.Key),
+		slog.Duration("latency", e.Latency),
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+	s.logger.LogAttrs(ctx, level, "cache "+e.Kind.String(), attrs...)
+}
+
+func (s *SlogObserver) OnHit(e CacheEvent)    { s.log(e) }
+func (s *SlogObserver) OnMiss(e CacheEvent)   { s.log(e) }
+func (s *SlogObserver) OnPut(e CacheEvent)    { s.log(e) }
+func (s *SlogObserver) OnEvict(e CacheEvent)  { s.log(e) }
+func (s *SlogObserver) OnExpire(e CacheEvent) { s.log(e) }
+func (s *SlogObserver) OnLoad(e CacheEvent)   { s.log(e) }
+
+// TraceRecord JSON Lines轨迹中的一行
+// 键统一按fmt.Sprint转成字符串，回放时以字符串作为键
+type TraceRecord struct {
+	Time    int64  `json:"t"`  // Unix纳秒
+	Event   string `json:"ev"` // hit、miss、put、evict、expire、load
+	Policy  string `json:"policy,omitempty"`
+	Key     string `json:"key"`
+	Latency int64  `json:"lat,omitempty"` // 纳秒
+	Err     string `json:"err,omitempty"`
+}
+
+// TraceWriter 把事件写成JSON Lines轨迹文件，可作为ReplayTrace的输入
+// 写入经过缓冲，结束时需调用Flush或Close
+type TraceWriter struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	enc *json.Encoder
+	c   io.Closer
+	err error // 第一次写入错误，之后的事件全部丢弃
+}
+
+// NewTraceWriter 创建轨迹写入器，w实现io.Closer时Close会一并关闭
+func NewTraceWriter(w io.Writer) *TraceWriter {
+	bw := bufio.NewWriter(w)
+	t := &TraceWriter{w: bw, enc: json.NewEncoder(bw)}
+	if c, ok := w.(io.Closer); ok {
+		t.c = c
+	}
+	return t
+}
+
+func (t *TraceWriter) write(e CacheEvent) {
+	rec := TraceRecord{
+		Time:    e.Time.UnixNano(),
+		Event:   e.Kind.String(),
+		Policy:  e.Policy,
+		Key:     fmt.Sprint(e.Key),
+		Latency: int64(e.Latency),
+	}
+	if e.Err != nil {
+		rec.Err = e.Err.Error()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = t.enc.Encode(&rec)
+	}
+}
+
+func (t *TraceWriter) OnHit(e CacheEvent)    { t.write(e) }
+func (t *TraceWriter) OnMiss(e CacheEvent)   { t.write(e) }
+func (t *TraceWriter) OnPut(e CacheEvent)    { t.write(e) }
+func (t *TraceWriter) OnEvict(e CacheEvent)  { t.write(e) }
+func (t *TraceWriter) OnExpire(e CacheEvent) { t.write(e) }
+func (t *TraceWriter) OnLoad(e CacheEvent)   { t.write(e) }
+
+// Flush 把缓冲的事件写出，返回第一次写入错误
+func (t *TraceWriter) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = t.w.Flush()
+	}
+	return t.err
+}
+
+// Close 写出缓冲并关闭底层文件
+func (t *TraceWriter) Close() error {
+	err := t.Flush()
+	if t.c != nil {
+		if cerr := t.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ReplayResult 轨迹回放结果
+type ReplayResult struct {
+	Requests       int64 // 回放的读取次数
+	Hits           int64 // 模拟策略的命中次数
+	Misses         int64 // 模拟策略的未命中次数
+	OriginalHits   int64 // 轨迹中记录的命中次数
+	OriginalMisses int64 // 轨迹中记录的未命中次数
+}
+
+// HitRatio 模拟策略的命中率
+func (r ReplayResult) HitRatio() float64 { return hitRatio(r.Hits, r.Misses) }
+
+// OriginalHitRatio 轨迹记录时实际策略的命中率
+func (r ReplayResult) OriginalHitRatio() float64 {
+	return hitRatio(r.OriginalHits, r.OriginalMisses)
+}
+
+// ReplayTrace 在缓存c上按顺序重放轨迹中的请求，用于离线比较不同策略
+// - hit/miss事件重放为Get，put事件重放为Put，值为空结构体
+// - evict、expire、load是原策略的结果而不是请求，回放时忽略
+// 轨迹不含过期时间，回放时所有写入都不过期
+func ReplayTrace(r io.Reader, c Cache) (ReplayResult, error) {
+	var res ReplayResult
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec TraceRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return res, fmt.Errorf("cache trace: line %d: %w", line, err)
+		}
+		kind, err := parseEventKind(rec.Event)
+		if err != nil {
+			return res, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch kind {
+		case EventHit, EventMiss:
+			res.Requests++
+			if kind == EventHit {
+				res.OriginalHits++
+			} else {
+				res.OriginalMisses++
+			}
+			if _, ok := c.Get(rec.Key); ok {
+				res.Hits++
+			} else {
+				res.Misses++
+			}
+		case EventPut:
+			c.Put(rec.Key, struct{}{})
+		}
+	}
+	return res, sc.Err()
+}
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// observable 支持设置观察者的缓存
+type observable interface {
+	Cache
+	SetObserver(o Observer)
+}
+
+// eventRecorder 按顺序记录收到的事件
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []CacheEvent
+}
+
+func (r *eventRecorder) observer() Observer {
+	return ObserverFunc(func(e CacheEvent) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, e)
+	})
+}
+
+func (r *eventRecorder) count(kind EventKind) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, e := range r.events {
+		if e.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// 每个策略都要报告命中、未命中、写入、淘汰和过期，且事件与统计一致
+func TestObserverEventsForEveryPolicy(t *testing.T) {
+	policies := append([]cachePolicy{
+		{name: "concurrent-lru", newCache: func(n int) Cache { return NewConcurrentLRUCache(n, 0) }},
+	}, cachePolicies...)
+	for _, p := range policies {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			clock := useFakeClock(t)
+			c := newTestCache(t, p.newCache, 2).(observable)
+			var rec eventRecorder
+			c.SetObserver(rec.observer())
+
+			c.Put("a", 1)
+			c.Put("b", 2)
+			c.Get("a")
+			c.Get("x")
+			c.Put("c", 3)
+			c.Delete("c")
+			c.PutWithExpiration("d", 4, 10*time.Millisecond)
+			clock.Advance(20 * time.Millisecond)
+			c.Get("d")
+
+			hits, misses, evictions, expired := c.(statsSource).Stats()
+			want := map[EventKind]int{
+				EventHit:    int(hits),
+				EventMiss:   int(misses),
+				EventPut:    4,
+				EventEvict:  int(evictions),
+				EventExpire: int(expired),
+			}
+			for kind, n := range want {
+				if got := rec.count(kind); got != n {
+					t.Errorf("%s events = %d, want %d", kind, got, n)
+				}
+			}
+			if hits != 1 || misses != 2 || evictions != 1 || expired != 1 {
+				t.Fatalf("stats = %d %d %d %d", hits, misses, evictions, expired)
+			}
+			last := rec.events[len(rec.events)-1]
+			if last.Kind != EventMiss || last.Key != "d" || last.Policy == "" || last.Time.IsZero() {
+				t.Fatalf("last event = %+v", last)
+			}
+
+			n := len(rec.events)
+			c.SetObserver(nil)
+			c.Get("a")
+			if len(rec.events) != n {
+				t.Fatal("no events should be delivered after SetObserver(nil)")
+			}
+		})
+	}
+}
+
+// Evict和Cleanup同样派发事件
+func TestObserverEvictAndCleanup(t *testing.T) {
+	clock := useFakeClock(t)
+	c := NewLRUCache(4, 0)
+	var rec eventRecorder
+	c.SetObserver(rec.observer())
+	c.PutWithExpiration("t1", 1, time.Millisecond)
+	c.PutWithExpiration("t2", 1, time.Millisecond)
+	c.Put("k1", 1)
+	c.Put("k2", 1)
+	clock.Advance(time.Second)
+	if n := c.Cleanup(); n != 2 || rec.count(EventExpire) != 2 {
+		t.Fatalf("cleanup = %d, expire events = %d", n, rec.count(EventExpire))
+	}
+	if n := c.Evict(5); n != 2 || rec.count(EventEvict) != 2 {
+		t.Fatalf("evict = %d, evict events = %d", n, rec.count(EventEvict))
+	}
+}
+
+// 观察者在释放锁之后调用，可以在回调中访问缓存
+func TestObserverMayReenterCache(t *testing.T) {
+	c := NewLRUCache(1, 0)
+	c.SetObserver(ObserverFunc(func(e CacheEvent) {
+		if e.Kind == EventEvict {
+			c.Len()
+		}
+	}))
+	c.Put(1, 1)
+	c.Put(2, 2)
+	c.Get(2)
+}
+
+func TestXFetchLoadEvents(t *testing.T) {
+	x := NewXFetchCache(NewLRUCache(4, 0), XFetchConfig{})
+	var rec eventRecorder
+	x.SetObserver(rec.observer())
+	x.Fetch("ok", time.Minute, func() (interface{}, error) { return 1, nil })
+	x.Fetch("bad", time.Minute, func() (interface{}, error) { return nil, errors.New("boom") })
+	if rec.count(EventLoad) != 2 {
+		t.Fatalf("load events = %d", rec.count(EventLoad))
+	}
+	if e := rec.events[1]; e.Key != "bad" || e.Err == nil || e.Policy != "xfetch" {
+		t.Fatalf("failed load event = %+v", e)
+	}
+}
+
+// 采样按键进行：一个键的事件要么全部保留要么全部丢弃
+func TestSampledChannelObserver(t *testing.T) {
+	ch := make(chan CacheEvent, 1000)
+	co := NewChannelObserver(ch)
+	c := NewLRUCache(100, 0)
+	c.SetObserver(NewSampledObserver(co, 4))
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprint("k", i)
+		c.Get(key)
+		c.Put(key, i)
+		c.Get(key)
+	}
+	close(ch)
+
+	perKey := map[interface{}]int{}
+	for e := range ch {
+		perKey[e.Key]++
+	}
+	if len(perKey) == 0 || len(perKey) >= 100 {
+		t.Fatalf("sampled %d of 100 keys", len(perKey))
+	}
+	for key, n := range perKey {
+		if n != 3 {
+			t.Fatalf("key %v has %d events, want all 3", key, n)
+		}
+	}
+
+	full := NewChannelObserver(make(chan CacheEvent))
+	full.OnHit(CacheEvent{})
+	if full.Dropped() != 1 {
+		t.Fatal("events must be dropped instead of blocking when the channel is full")
+	}
+}
+
+func TestSlogObserver(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := NewLRUCache(4, 0)
+	c.SetObserver(NewSlogObserver(logger, slog.LevelDebug))
+	c.Put("user:1", 1)
+	c.Get("user:1")
+
+	out := buf.String()
+	for _, want := range []string{`"msg":"cache put"`, `"msg":"cache hit"`, `"key":"user:1"`, `"policy":"lru"`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("log output missing %s:\n%s", want, out)
+		}
+	}
+}
+
+// 用同一策略回放轨迹应得到与记录时完全相同的命中率，换用其他策略可以离线比较
+func TestTraceReplay(t *testing.T) {
+	var trace bytes.Buffer
+	tw := NewTraceWriter(&trace)
+	c := NewLRUCache(50, 0)
+	c.SetObserver(tw)
+
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.2, 1, 500)
+	for i := 0; i < 5000; i++ {
+		key := int(zipf.Uint64())
+		if _, ok := c.Get(key); !ok {
+			c.Put(key, i)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := trace.Bytes()
+	res, err := ReplayTrace(bytes.NewReader(data), NewLRUCache(50, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 5000 || res.Hits != res.OriginalHits || res.Misses != res.OriginalMisses {
+		t.Fatalf("replay = %+v", res)
+	}
+	hits, _, _, _ := c.Stats()
+	if res.OriginalHits != hits {
+		t.Fatalf("trace hits = %d, cache hits = %d", res.OriginalHits, hits)
+	}
+
+	lfu := NewLFUCache(50)
+	defer lfu.Close()
+	if _, err := ReplayTrace(bytes.NewReader(data), lfu); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReplayTrace(strings.NewReader(`{"t":1,"ev":"bogus","key":"a"}`), NewLRUCache(1, 0)); err == nil {
+		t.Fatal("unknown event should be rejected")
+	}
+}
@@ -0,0 +1,1061 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RESP协议相关常量
+const (
+	respMaxInlineLength = 64 * 1024 // 内联命令最大长度
+	respMaxArgs         = 1024 * 1024
+	respVersion         = "7.0.0-go" // HELLO返回的版本号
+)
+
+// errWrongType 对错误类型的键执行命令时返回
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// respEntry RESP键值条目，作为值存入底层缓存
+// value为[]byte(字符串)或*zset(有序集合)
+type respEntry struct {
+	value     interface{}
+	expiresAt time.Time // 过期时间(零值表示永不过期)
+}
+
+// zmember 有序集合成员
+type zmember struct {
+	member string
+	score  float64
+}
+
+// zset 有序集合
+// 哈希表提供O(1)查分，切片按(score, member)有序，支持按分数二分查找
+type zset struct {
+	scores map[string]float64
+	sorted []zmember
+}
+
+func newZSet() *zset {
+	return &zset{scores: make(map[string]float64)}
+}
+
+// search 返回第一个不小于(score, member)的位置
+func (z *zset) search(score float64, member string) int {
+	return sort.Search(len(z.sorted), func(i int) bool {
+		m := z.sorted[i]
+		return m.score > score || (m.score == score && m.member >= member)
+	})
+}
+
+// add 添加或更新成员，返回是否为新成员
+func (z *zset) add(member string, score float64) bool {
+	old, exists := z.scores[member]
+	if exists {
+		if old == score {
+			return false
+		}
+		z.remove(member)
+	}
+	i := z.search(score, member)
+	z.sorted = append(z.sorted, zmember{})
+	copy(z.sorted[i+1:], z.sorted[i:])
+	z.sorted[i] = zmember{member: member, score: score}
+	z.scores[member] = score
+	return !exists
+}
+
+// remove 删除成员
+func (z *zset) remove(member string) {
+	score, ok := z.scores[member]
+	if !ok {
+		return
+	}
+	i := z.search(score, member)
+	z.sorted = append(z.sorted[:i], z.sorted[i+1:]...)
+	delete(z.scores, member)
+}
+
+// scoreRange 返回分数落在区间内的成员下标范围[lo, hi)
+func (z *zset) scoreRange(min, max scoreBound) (int, int) {
+	lo := sort.Search(len(z.sorted), func(i int) bool { return min.below(z.sorted[i].score) })
+	hi := sort.Search(len(z.sorted), func(i int) bool { return !max.above(z.sorted[i].score) })
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// removeRange 删除下标范围[lo, hi)内的成员
+func (z *zset) removeRange(lo, hi int) int {
+	for _, m := range z.sorted[lo:hi] {
+		delete(z.scores, m.member)
+	}
+	z.sorted = append(z.sorted[:lo], z.sorted[hi:]...)
+	return hi - lo
+}
+
+// scoreBound ZCOUNT等命令的分数边界，支持"("开区间和±inf
+type scoreBound struct {
+	value     float64
+	exclusive bool
+}
+
+// below 判断score是否满足作为下界的条件
+func (b scoreBound) below(score float64) bool {
+	if b.exclusive {
+		return score > b.value
+	}
+	return score >= b.value
+}
+
+// above 判断score是否满足作为上界的条件
+func (b scoreBound) above(score float64) bool {
+	if b.exclusive {
+		return score < b.value
+	}
+	return score <= b.value
+}
+
+// parseScoreBound 解析分数边界
+func parseScoreBound(s string) (scoreBound, error) {
+	var b scoreBound
+	if strings.HasPrefix(s, "(") {
+		b.exclusive = true
+		s = s[1:]
+	}
+	v, err := parseScore(s)
+	if err != nil {
+		return b, errors.New("ERR min or max is not a float")
+	}
+	b.value = v
+	return b, nil
+}
+
+// parseScore 解析分数，支持inf/+inf/-inf
+func parseScore(s string) (float64, error) {
+	switch strings.ToLower(s) {
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, errors.New("ERR value is not a valid float")
+	}
+	return v, nil
+}
+
+// RESPConfig RESP服务端配置
+type RESPConfig struct {
+	MaxConns    int           // 最大并发连接数，0表示不限制
+	MaxBulkSize int           // 单个参数最大字节数，默认16MB
+	IdleTimeout time.Duration // 连接空闲超时，0表示不超时
+}
+
+// RESPServer 兼容Redis协议(RESP2/RESP3)的进程内服务端，底层使用本包的淘汰策略缓存
+// 用于本地测试和小规模部署时替代Redis，支持RedisSWC.go中滑动窗口限流用到的ZSET命令
+// 特点:
+// - 每个连接一个协程解析请求，命令在全局锁下串行执行，与Redis单线程语义一致
+// - 支持流水线和内联命令，通过HELLO切换RESP3
+// - 容量不足时键按底层缓存的策略淘汰，有序集合整体作为一个条目
+// - 不支持EVAL/EVALSHA，RateLimiting中基于Lua脚本的限流器(GCRA、RedisStore)不能用它代替Redis
+type RESPServer struct {
+	cache Cache
+	cfg   RESPConfig
+	mu    sync.Mutex // 串行执行命令，保证读改写命令的原子性
+
+	connMu     sync.Mutex
+	listeners  map[net.Listener]struct{}
+	conns      map[net.Conn]struct{}
+	inShutdown int32 // 是否正在关闭(原子操作)
+	wg         sync.WaitGroup
+	nextID     int64 // 客户端ID(原子操作)
+	currConns  int64 // 当前连接数(原子操作)
+}
+
+// NewRESPServer 创建RESP服务端实例
+func NewRESPServer(cache Cache, cfg RESPConfig) *RESPServer {
+	if cfg.MaxBulkSize <= 0 {
+		cfg.MaxBulkSize = 16 << 20
+	}
+	return &RESPServer{
+		cache:     cache,
+		cfg:       cfg,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe 监听network/addr并提供服务
+func (s *RESPServer) ListenAndServe(network, addr string) error {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在监听器上接收连接，直到Shutdown被调用
+func (s *RESPServer) Serve(l net.Listener) error {
+	s.connMu.Lock()
+	if atomic.LoadInt32(&s.inShutdown) == 1 {
+		s.connMu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.connMu.Unlock()
+	defer func() {
+		s.connMu.Lock()
+		delete(s.listeners, l)
+		s.connMu.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&s.inShutdown) == 1 {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		if s.cfg.MaxConns > 0 && atomic.LoadInt64(&s.currConns) >= int64(s.cfg.MaxConns) {
+			io.WriteString(conn, "-ERR max number of clients reached\r\n")
+			conn.Close()
+			continue
+		}
+
+		s.connMu.Lock()
+		if atomic.LoadInt32(&s.inShutdown) == 1 {
+			s.connMu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		atomic.AddInt64(&s.currConns, 1)
+		s.connMu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Shutdown 优雅关闭：停止接收新连接，已有连接处理完已读取的命令后退出
+// ctx超时则强制关闭剩余连接
+func (s *RESPServer) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.inShutdown, 1)
+
+	s.connMu.Lock()
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.SetReadDeadline(time.Now())
+	}
+	s.connMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.connMu.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.connMu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// respConn 单个客户端连接的状态
+type respConn struct {
+	id    int64
+	proto int // 协议版本，2或3
+	name  string
+	r     *bufio.Reader
+	w     *bufio.Writer
+}
+
+// serveConn 处理单个连接，读缓冲区为空时才刷新输出以支持流水线
+func (s *RESPServer) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.connMu.Lock()
+		delete(s.conns, conn)
+		s.connMu.Unlock()
+		atomic.AddInt64(&s.currConns, -1)
+		s.wg.Done()
+	}()
+
+	c := &respConn{
+		id:    atomic.AddInt64(&s.nextID, 1),
+		proto: 2,
+		r:     bufio.NewReader(conn),
+		w:     bufio.NewWriter(conn),
+	}
+	for {
+		if atomic.LoadInt32(&s.inShutdown) == 1 && c.r.Buffered() == 0 {
+			c.w.Flush()
+			return
+		}
+		if s.cfg.IdleTimeout > 0 && atomic.LoadInt32(&s.inShutdown) == 0 {
+			conn.SetReadDeadline(time.Now().Add(s.cfg.IdleTimeout))
+		}
+
+		args, err := s.readCommand(c.r)
+		if err != nil {
+			var pe respProtocolError
+			if errors.As(err, &pe) {
+				c.writeError("ERR Protocol error: " + string(pe))
+			}
+			c.w.Flush()
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		if !s.execute(c, args) {
+			c.w.Flush()
+			return
+		}
+		if c.r.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// respProtocolError 请求格式错误，回复后关闭连接
+type respProtocolError string
+
+func (e respProtocolError) Error() string { return string(e) }
+
+// readCommand 读取一条命令，支持多条批量字符串组成的数组和内联命令两种格式
+func (s *RESPServer) readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(r, respMaxInlineLength)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > respMaxArgs {
+		return nil, respProtocolError("invalid multibulk length")
+	}
+	args := make([]string, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		hdr, err := readRESPLine(r, respMaxInlineLength)
+		if err != nil {
+			return nil, err
+		}
+		if len(hdr) == 0 || hdr[0] != '$' {
+			return nil, respProtocolError(fmt.Sprintf("expected '$', got '%.1s'", hdr))
+		}
+		size, err := strconv.Atoi(hdr[1:])
+		if err != nil || size < 0 || size > s.cfg.MaxBulkSize {
+			return nil, respProtocolError("invalid bulk length")
+		}
+		// 不按声明的长度一次性分配，数据到达多少缓冲多少，只发送长度头的连接占不了内存
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, r, int64(size)+2); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf.Bytes()[:size]))
+	}
+	return args, nil
+}
+
+// readRESPLine 读取一行并去掉结尾的\r\n
+func readRESPLine(r *bufio.Reader, limit int) (string, error) {
+	line, err := readLine(r, limit)
+	if errors.Is(err, errLineTooLong) {
+		return "", respProtocolError("too big inline request")
+	}
+	return line, err
+}
+
+// ---------- 响应编码 ----------
+
+func (c *respConn) writeSimple(s string) { c.w.WriteString("+" + s + "\r\n") }
+func (c *respConn) writeError(s string)  { c.w.WriteString("-" + s + "\r\n") }
+func (c *respConn) writeInt(n int64)     { c.w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n") }
+func (c *respConn) writeArrayHeader(n int) {
+	c.w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+func (c *respConn) writeBulk(b []byte) {
+	c.w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	c.w.Write(b)
+	c.w.WriteString("\r\n")
+}
+
+// writeNull 空值：RESP2为空批量字符串，RESP3为Null类型
+func (c *respConn) writeNull() {
+	if c.proto == 3 {
+		c.w.WriteString("_\r\n")
+	} else {
+		c.w.WriteString("$-1\r\n")
+	}
+}
+
+// writeMapHeader 映射：RESP3为Map类型，RESP2退化为键值交替的数组
+func (c *respConn) writeMapHeader(n int) {
+	if c.proto == 3 {
+		c.w.WriteString("%" + strconv.Itoa(n) + "\r\n")
+	} else {
+		c.writeArrayHeader(2 * n)
+	}
+}
+
+// writeDouble 浮点数：RESP3为Double类型，RESP2为批量字符串
+func (c *respConn) writeDouble(f float64) {
+	s := formatScore(f)
+	if c.proto == 3 {
+		c.w.WriteString("," + s + "\r\n")
+	} else {
+		c.writeBulk([]byte(s))
+	}
+}
+
+// formatScore 按Redis的格式输出分数
+func formatScore(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', 17, 64)
+}
+
+// ---------- 键空间访问(调用方持有s.mu) ----------
+
+// lookup 读取未过期的条目
+func (s *RESPServer) lookup(key string) (*respEntry, bool) {
+	v, ok := s.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	ent := v.(*respEntry)
+	if !ent.expiresAt.IsZero() && !time.Now().Before(ent.expiresAt) {
+		s.cache.Delete(key)
+		return nil, false
+	}
+	return ent, true
+}
+
+// save 写入条目，底层缓存的TTL与条目过期时间保持一致
+func (s *RESPServer) save(key string, ent *respEntry) {
+	if ent.expiresAt.IsZero() {
+		s.cache.Put(key, ent)
+		return
+	}
+	ttl := time.Until(ent.expiresAt)
+	if ttl <= 0 {
+		s.cache.Delete(key)
+		return
+	}
+	s.cache.PutWithExpiration(key, ent, ttl)
+}
+
+// lookupString 读取字符串类型的值
+func (s *RESPServer) lookupString(key string) ([]byte, *respEntry, error) {
+	ent, ok := s.lookup(key)
+	if !ok {
+		return nil, nil, nil
+	}
+	b, ok := ent.value.([]byte)
+	if !ok {
+		return nil, nil, errWrongType
+	}
+	return b, ent, nil
+}
+
+// lookupZSet 读取有序集合类型的值
+func (s *RESPServer) lookupZSet(key string) (*zset, *respEntry, error) {
+	ent, ok := s.lookup(key)
+	if !ok {
+		return nil, nil, nil
+	}
+	z, ok := ent.value.(*zset)
+	if !ok {
+		return nil, nil, errWrongType
+	}
+	return z, ent, nil
+}
+
+// ---------- 命令执行 ----------
+
+// respCommand 命令处理函数及参数个数限制
+// arity为正表示参数个数固定，为负表示至少-arity个(均包含命令名)
+type respCommand struct {
+	fn    func(s *RESPServer, c *respConn, args []string)
+	arity int
+}
+
+var respCommands map[string]respCommand
+
+func init() {
+	respCommands = map[string]respCommand{
+		"PING":             {(*RESPServer).cmdPing, -1},
+		"ECHO":             {(*RESPServer).cmdEcho, 2},
+		"HELLO":            {(*RESPServer).cmdHello, -1},
+		"SELECT":           {(*RESPServer).cmdSelect, 2},
+		"CLIENT":           {(*RESPServer).cmdClient, -2},
+		"GET":              {(*RESPServer).cmdGet, 2},
+		"SET":              {(*RESPServer).cmdSet, -3},
+		"DEL":              {(*RESPServer).cmdDel, -2},
+		"EXISTS":           {(*RESPServer).cmdExists, -2},
+		"EXPIRE":           {(*RESPServer).cmdExpire, 3},
+		"PEXPIRE":          {(*RESPServer).cmdExpire, 3},
+		"TTL":              {(*RESPServer).cmdTTL, 2},
+		"PTTL":             {(*RESPServer).cmdTTL, 2},
+		"PERSIST":          {(*RESPServer).cmdPersist, 2},
+		"INCR":             {(*RESPServer).cmdIncr, 2},
+		"DECR":             {(*RESPServer).cmdIncr, 2},
+		"INCRBY":           {(*RESPServer).cmdIncr, 3},
+		"DECRBY":           {(*RESPServer).cmdIncr, 3},
+		"MGET":             {(*RESPServer).cmdMGet, -2},
+		"MSET":             {(*RESPServer).cmdMSet, -3},
+		"ZADD":             {(*RESPServer).cmdZAdd, -4},
+		"ZREMRANGEBYSCORE": {(*RESPServer).cmdZRemRangeByScore, 4},
+		"ZCOUNT":           {(*RESPServer).cmdZCount, 4},
+		"ZCARD":            {(*RESPServer).cmdZCard, 2},
+		"ZSCORE":           {(*RESPServer).cmdZScore, 3},
+		"DBSIZE":           {(*RESPServer).cmdDBSize, 1},
+		"FLUSHDB":          {(*RESPServer).cmdFlush, -1},
+		"FLUSHALL":         {(*RESPServer).cmdFlush, -1},
+	}
+}
+
+// execute 执行一条命令，返回false表示需要关闭连接
+func (s *RESPServer) execute(c *respConn, args []string) bool {
+	name := strings.ToUpper(args[0])
+	if name == "QUIT" {
+		c.writeSimple("OK")
+		return false
+	}
+
+	cmd, ok := respCommands[name]
+	if !ok {
+		c.writeError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return true
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.writeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(args[0])))
+		return true
+	}
+
+	s.mu.Lock()
+	cmd.fn(s, c, args)
+	s.mu.Unlock()
+	return true
+}
+
+func (s *RESPServer) cmdPing(c *respConn, args []string) {
+	if len(args) > 1 {
+		c.writeBulk([]byte(args[1]))
+		return
+	}
+	c.writeSimple("PONG")
+}
+
+func (s *RESPServer) cmdEcho(c *respConn, args []string) {
+	c.writeBulk([]byte(args[1]))
+}
+
+// cmdHello HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (s *RESPServer) cmdHello(c *respConn, args []string) {
+	proto := c.proto
+	if len(args) > 1 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil || (v != 2 && v != 3) {
+			c.writeError("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = v
+	}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "AUTH":
+			i += 2 // 不校验认证信息
+		case "SETNAME":
+			if i+1 < len(args) {
+				c.name = args[i+1]
+			}
+			i++
+		}
+	}
+	c.proto = proto
+
+	c.writeMapHeader(7)
+	c.writeBulk([]byte("server"))
+	c.writeBulk([]byte("redis"))
+	c.writeBulk([]byte("version"))
+	c.writeBulk([]byte(respVersion))
+	c.writeBulk([]byte("proto"))
+	c.writeInt(int64(proto))
+	c.writeBulk([]byte("id"))
+	c.writeInt(c.id)
+	c.writeBulk([]byte("mode"))
+	c.writeBulk([]byte("standalone"))
+	c.writeBulk([]byte("role"))
+	c.writeBulk([]byte("master"))
+	c.writeBulk([]byte("modules"))
+	c.writeArrayHeader(0)
+}
+
+// cmdSelect 仅支持0号数据库
+func (s *RESPServer) cmdSelect(c *respConn, args []string) {
+	if args[1] != "0" {
+		c.writeError("ERR DB index is out of range")
+		return
+	}
+	c.writeSimple("OK")
+}
+
+// cmdClient 客户端库连接时会发送CLIENT SETNAME/SETINFO等命令
+func (s *RESPServer) cmdClient(c *respConn, args []string) {
+	switch strings.ToUpper(args[1]) {
+	case "SETNAME":
+		if len(args) == 3 {
+			c.name = args[2]
+		}
+		c.writeSimple("OK")
+	case "GETNAME":
+		if c.name == "" {
+			c.writeNull()
+		} else {
+			c.writeBulk([]byte(c.name))
+		}
+	case "ID":
+		c.writeInt(c.id)
+	default:
+		c.writeSimple("OK")
+	}
+}
+
+func (s *RESPServer) cmdGet(c *respConn, args []string) {
+	b, _, err := s.lookupString(args[1])
+	switch {
+	case err != nil:
+		c.writeError(err.Error())
+	case b == nil:
+		c.writeNull()
+	default:
+		c.writeBulk(b)
+	}
+}
+
+// cmdSet SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|KEEPTTL]
+func (s *RESPServer) cmdSet(c *respConn, args []string) {
+	key, value := args[1], []byte(args[2])
+	var nx, xx, get, keepTTL bool
+	var ttl time.Duration
+
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX":
+			if i+1 >= len(args) || ttl != 0 {
+				c.writeError("ERR syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				c.writeError("ERR value is not an integer or out of range")
+				return
+			}
+			if n <= 0 {
+				c.writeError("ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			c.writeError("ERR syntax error")
+			return
+		}
+	}
+	if (nx && xx) || (keepTTL && ttl != 0) {
+		c.writeError("ERR syntax error")
+		return
+	}
+
+	ent, exists := s.lookup(key)
+	var old []byte
+	if exists && get {
+		b, ok := ent.value.([]byte)
+		if !ok {
+			c.writeError(errWrongType.Error())
+			return
+		}
+		old = b
+	}
+
+	if (nx && exists) || (xx && !exists) {
+		if get && old != nil {
+			c.writeBulk(old)
+		} else {
+			c.writeNull()
+		}
+		return
+	}
+
+	next := &respEntry{value: value}
+	switch {
+	case ttl > 0:
+		next.expiresAt = time.Now().Add(ttl)
+	case keepTTL && exists:
+		next.expiresAt = ent.expiresAt
+	}
+	s.save(key, next)
+
+	switch {
+	case !get:
+		c.writeSimple("OK")
+	case old == nil:
+		c.writeNull()
+	default:
+		c.writeBulk(old)
+	}
+}
+
+func (s *RESPServer) cmdDel(c *respConn, args []string) {
+	var n int64
+	for _, key := range args[1:] {
+		if _, ok := s.lookup(key); ok {
+			s.cache.Delete(key)
+			n++
+		}
+	}
+	c.writeInt(n)
+}
+
+func (s *RESPServer) cmdExists(c *respConn, args []string) {
+	var n int64
+	for _, key := range args[1:] {
+		if _, ok := s.lookup(key); ok {
+			n++
+		}
+	}
+	c.writeInt(n)
+}
+
+// cmdExpire EXPIRE key seconds / PEXPIRE key milliseconds
+// 过期时间不为正时立即删除键
+func (s *RESPServer) cmdExpire(c *respConn, args []string) {
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		c.writeError("ERR value is not an integer or out of range")
+		return
+	}
+	unit := time.Second
+	if strings.ToUpper(args[0]) == "PEXPIRE" {
+		unit = time.Millisecond
+	}
+
+	ent, ok := s.lookup(args[1])
+	if !ok {
+		c.writeInt(0)
+		return
+	}
+	if n <= 0 {
+		s.cache.Delete(args[1])
+		c.writeInt(1)
+		return
+	}
+	s.save(args[1], &respEntry{value: ent.value, expiresAt: time.Now().Add(time.Duration(n) * unit)})
+	c.writeInt(1)
+}
+
+// cmdTTL TTL/PTTL：键不存在返回-2，无过期时间返回-1
+func (s *RESPServer) cmdTTL(c *respConn, args []string) {
+	ent, ok := s.lookup(args[1])
+	switch {
+	case !ok:
+		c.writeInt(-2)
+	case ent.expiresAt.IsZero():
+		c.writeInt(-1)
+	case strings.ToUpper(args[0]) == "PTTL":
+		c.writeInt(time.Until(ent.expiresAt).Milliseconds())
+	default:
+		// 与Redis一致，按四舍五入取整秒
+		c.writeInt((time.Until(ent.expiresAt).Milliseconds() + 500) / 1000)
+	}
+}
+
+func (s *RESPServer) cmdPersist(c *respConn, args []string) {
+	ent, ok := s.lookup(args[1])
+	if !ok || ent.expiresAt.IsZero() {
+		c.writeInt(0)
+		return
+	}
+	s.save(args[1], &respEntry{value: ent.value})
+	c.writeInt(1)
+}
+
+// cmdIncr INCR/DECR/INCRBY/DECRBY，保留原有过期时间
+func (s *RESPServer) cmdIncr(c *respConn, args []string) {
+	delta := int64(1)
+	name := strings.ToUpper(args[0])
+	if name == "INCRBY" || name == "DECRBY" {
+		d, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			c.writeError("ERR value is not an integer or out of range")
+			return
+		}
+		delta = d
+	}
+	if name == "DECR" || name == "DECRBY" {
+		if delta == math.MinInt64 {
+			c.writeError("ERR decrement would overflow")
+			return
+		}
+		delta = -delta
+	}
+
+	b, ent, err := s.lookupString(args[1])
+	if err != nil {
+		c.writeError(err.Error())
+		return
+	}
+	var cur int64
+	if b != nil {
+		cur, err = strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			c.writeError("ERR value is not an integer or out of range")
+			return
+		}
+	}
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		c.writeError("ERR increment or decrement would overflow")
+		return
+	}
+	cur += delta
+
+	next := &respEntry{value: []byte(strconv.FormatInt(cur, 10))}
+	if ent != nil {
+		next.expiresAt = ent.expiresAt
+	}
+	s.save(args[1], next)
+	c.writeInt(cur)
+}
+
+// cmdMGet 不存在或类型不是字符串的键返回空值
+func (s *RESPServer) cmdMGet(c *respConn, args []string) {
+	c.writeArrayHeader(len(args) - 1)
+	for _, key := range args[1:] {
+		b, _, err := s.lookupString(key)
+		if err != nil || b == nil {
+			c.writeNull()
+			continue
+		}
+		c.writeBulk(b)
+	}
+}
+
+func (s *RESPServer) cmdMSet(c *respConn, args []string) {
+	if len(args)%2 != 1 {
+		c.writeError("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+	for i := 1; i < len(args); i += 2 {
+		s.save(args[i], &respEntry{value: []byte(args[i+1])})
+	}
+	c.writeSimple("OK")
+}
+
+// cmdZAdd ZADD key [NX|XX] [CH] score member [score member ...]
+func (s *RESPServer) cmdZAdd(c *respConn, args []string) {
+	var nx, xx, ch bool
+	i := 2
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+			continue
+		case "XX":
+			xx = true
+			continue
+		case "CH":
+			ch = true
+			continue
+		}
+		break
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 || (nx && xx) {
+		c.writeError("ERR syntax error")
+		return
+	}
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := parseScore(pairs[j])
+		if err != nil {
+			c.writeError(err.Error())
+			return
+		}
+		scores = append(scores, score)
+	}
+
+	z, ent, err := s.lookupZSet(args[1])
+	if err != nil {
+		c.writeError(err.Error())
+		return
+	}
+	if z == nil {
+		if xx {
+			c.writeInt(0)
+			return
+		}
+		z = newZSet()
+		ent = &respEntry{value: z}
+	}
+
+	var added, changed int64
+	for j, score := range scores {
+		member := pairs[2*j+1]
+		old, exists := z.scores[member]
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		if z.add(member, score) {
+			added++
+		} else if old != score {
+			changed++
+		}
+	}
+	if len(z.scores) > 0 {
+		s.save(args[1], ent)
+	}
+	if ch {
+		c.writeInt(added + changed)
+	} else {
+		c.writeInt(added)
+	}
+}
+
+// cmdZRemRangeByScore ZREMRANGEBYSCORE key min max
+func (s *RESPServer) cmdZRemRangeByScore(c *respConn, args []string) {
+	from, err1 := parseScoreBound(args[2])
+	to, err2 := parseScoreBound(args[3])
+	if err := errors.Join(err1, err2); err != nil {
+		c.writeError("ERR min or max is not a float")
+		return
+	}
+	z, _, err := s.lookupZSet(args[1])
+	if err != nil {
+		c.writeError(err.Error())
+		return
+	}
+	if z == nil {
+		c.writeInt(0)
+		return
+	}
+	lo, hi := z.scoreRange(from, to)
+	n := z.removeRange(lo, hi)
+	if len(z.scores) == 0 {
+		s.cache.Delete(args[1]) // 与Redis一致，空集合即删除键
+	}
+	c.writeInt(int64(n))
+}
+
+// cmdZCount ZCOUNT key min max
+func (s *RESPServer) cmdZCount(c *respConn, args []string) {
+	from, err1 := parseScoreBound(args[2])
+	to, err2 := parseScoreBound(args[3])
+	if err := errors.Join(err1, err2); err != nil {
+		c.writeError("ERR min or max is not a float")
+		return
+	}
+	z, _, err := s.lookupZSet(args[1])
+	if err != nil {
+		c.writeError(err.Error())
+		return
+	}
+	if z == nil {
+		c.writeInt(0)
+		return
+	}
+	lo, hi := z.scoreRange(from, to)
+	c.writeInt(int64(hi - lo))
+}
+
+func (s *RESPServer) cmdZCard(c *respConn, args []string) {
+	z, _, err := s.lookupZSet(args[1])
+	switch {
+	case err != nil:
+		c.writeError(err.Error())
+	case z == nil:
+		c.writeInt(0)
+	default:
+		c.writeInt(int64(len(z.scores)))
+	}
+}
+
+func (s *RESPServer) cmdZScore(c *respConn, args []string) {
+	z, _, err := s.lookupZSet(args[1])
+	if err != nil {
+		c.writeError(err.Error())
+		return
+	}
+	if z == nil {
+		c.writeNull()
+		return
+	}
+	score, ok := z.scores[args[2]]
+	if !ok {
+		c.writeNull()
+		return
+	}
+	c.writeDouble(score)
+}
+
+func (s *RESPServer) cmdDBSize(c *respConn, args []string) {
+	c.writeInt(int64(s.cache.Len()))
+}
+
+func (s *RESPServer) cmdFlush(c *respConn, args []string) {
+	s.cache.Clear()
+	c.writeSimple("OK")
+}
@@ -0,0 +1,627 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Codec 值的序列化方式，用于写入磁盘层
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// GobCodec 基于encoding/gob的默认编解码器
+// 值以interface{}编码，自定义类型需要先调用gob.Register注册
+type GobCodec struct{}
+
+// Marshal 编码值
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal 解码值
+func (GobCodec) Unmarshal(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// ---------- L2: 磁盘存储 ----------
+
+// diskFileMagic 磁盘条目文件的魔数
+var diskFileMagic = [4]byte{'T', 'C', '0', '1'}
+
+// diskHeaderSize 文件头大小：魔数(4) + 过期时间(8) + 键长度(4)
+const diskHeaderSize = 16
+
+// diskEntry 磁盘条目的内存索引
+type diskEntry struct {
+	key       string
+	file      string    // 文件名(不含目录)
+	size      int64     // 文件大小
+	expiresAt time.Time // 过期时间(零值表示永不过期)
+}
+
+// DiskStore 磁盘键值存储
+// 每个条目一个文件，内存中保存索引和LRU顺序，总大小超过maxBytes时淘汰最久未访问的文件
+// 文件格式: 魔数 | 过期时间(UnixNano，0为永不过期) | 键长度 | 键 | 值
+type DiskStore struct {
+	dir      string
+	maxBytes int64
+
+	lock  sync.Mutex
+	index map[string]*list.Element // 键 -> LRU链表节点
+	lru   *list.List               // 头部最新尾部最旧
+	bytes int64                    // 当前总大小
+
+	stats struct {
+		hits         int64
+		misses       int64
+		evictions    int64
+		expiredCount int64
+	}
+}
+
+// OpenDiskStore 打开(或创建)磁盘存储，并从已有文件重建索引
+// 重建时按文件修改时间确定LRU顺序，损坏或已过期的文件会被删除
+func OpenDiskStore(dir string, maxBytes int64) (*DiskStore, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("disk store: maxBytes must be positive, got %d", maxBytes)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	d := &DiskStore{
+		dir:      dir,
+		maxBytes: maxBytes,
+		index:    make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type loaded struct {
+		ent     *diskEntry
+		modTime time.Time
+	}
+	var found []loaded
+	now := timeNow()
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name())
+		if strings.HasPrefix(f.Name(), "tmp-") {
+			os.Remove(path) // 上次写入中断留下的临时文件
+			continue
+		}
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".entry") {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		key, expiresAt, err := readDiskHeader(path)
+		if err != nil || (!expiresAt.IsZero() && now.After(expiresAt)) {
+			os.Remove(path)
+			continue
+		}
+		found = append(found, loaded{
+			ent:     &diskEntry{key: key, file: f.Name(), size: info.Size(), expiresAt: expiresAt},
+			modTime: info.ModTime(),
+		})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].modTime.Before(found[j].modTime) })
+	for _, l := range found {
+		d.index[l.ent.key] = d.lru.PushFront(l.ent)
+		d.bytes += l.ent.size
+	}
+	d.lock.Lock()
+	d.evictLocked()
+	d.lock.Unlock()
+	return d, nil
+}
+
+// fileName 键对应的文件名，对键做哈希以避免非法字符
+func (d *DiskStore) fileName(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:]) + ".entry"
+}
+
+// Put 写入条目，先写临时文件再重命名，保证文件完整
+func (d *DiskStore) Put(key string, value []byte, expiresAt time.Time) error {
+	var buf bytes.Buffer
+	buf.Grow(diskHeaderSize + len(key) + len(value))
+	buf.Write(diskFileMagic[:])
+	var exp int64
+	if !expiresAt.IsZero() {
+		exp = expiresAt.UnixNano()
+	}
+	binary.Write(&buf, binary.BigEndian, exp)
+	binary.Write(&buf, binary.BigEndian, uint32(len(key)))
+	buf.WriteString(key)
+	buf.Write(value)
+
+	size := int64(buf.Len())
+	if size > d.maxBytes {
+		return fmt.Errorf("disk store: entry of %d bytes exceeds limit %d", size, d.maxBytes)
+	}
+
+	name := d.fileName(key)
+	tmp, err := os.CreateTemp(d.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := os.Rename(tmp.Name(), filepath.Join(d.dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if elem, ok := d.index[key]; ok {
+		d.bytes -= elem.Value.(*diskEntry).size
+		d.lru.Remove(elem)
+	}
+	d.index[key] = d.lru.PushFront(&diskEntry{key: key, file: name, size: size, expiresAt: expiresAt})
+	d.bytes += size
+	d.evictLocked()
+	return nil
+}
+
+// Get 读取条目，返回值和过期时间
+func (d *DiskStore) Get(key string) ([]byte, time.Time, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	elem, ok := d.index[key]
+	if !ok {
+		d.stats.misses++
+		return nil, time.Time{}, false
+	}
+	ent := elem.Value.(*diskEntry)
+	if !ent.expiresAt.IsZero() && timeNow().After(ent.expiresAt) {
+		d.removeLocked(elem)
+		d.stats.misses++
+		d.stats.expiredCount++
+		return nil, time.Time{}, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(d.dir, ent.file))
+	if err != nil || len(data) < diskHeaderSize {
+		d.removeLocked(elem) // 文件丢失或损坏，视为未命中
+		d.stats.misses++
+		return nil, time.Time{}, false
+	}
+	keyLen := int(binary.BigEndian.Uint32(data[12:16]))
+	if diskHeaderSize+keyLen > len(data) || string(data[diskHeaderSize:diskHeaderSize+keyLen]) != key {
+		d.removeLocked(elem)
+		d.stats.misses++
+		return nil, time.Time{}, false
+	}
+
+	d.lru.MoveToFront(elem)
+	d.stats.hits++
+	return data[diskHeaderSize+keyLen:], ent.expiresAt, true
+}
+
+// Delete 删除条目
+func (d *DiskStore) Delete(key string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	elem, ok := d.index[key]
+	if !ok {
+		return false
+	}
+	d.removeLocked(elem)
+	return true
+}
+
+// Len 当前条目数
+func (d *DiskStore) Len() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return len(d.index)
+}
+
+// Size 当前占用的字节数
+func (d *DiskStore) Size() int64 {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.bytes
+}
+
+// Stats 获取统计信息
+func (d *DiskStore) Stats() (hits, misses, evictions, expired int64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.stats.hits, d.stats.misses, d.stats.evictions, d.stats.expiredCount
+}
+
+// Clear 删除所有条目
+func (d *DiskStore) Clear() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for elem := d.lru.Front(); elem != nil; elem = d.lru.Front() {
+		d.removeLocked(elem)
+	}
+}
+
+// evictLocked 淘汰最久未访问的条目直到总大小不超过上限
+func (d *DiskStore) evictLocked() {
+	for d.bytes > d.maxBytes {
+		elem := d.lru.Back()
+		if elem == nil {
+			return
+		}
+		d.removeLocked(elem)
+		d.stats.evictions++
+	}
+}
+
+// removeLocked 删除条目及其文件
+func (d *DiskStore) removeLocked(elem *list.Element) {
+	ent := d.lru.Remove(elem).(*diskEntry)
+	delete(d.index, ent.key)
+	d.bytes -= ent.size
+	os.Remove(filepath.Join(d.dir, ent.file))
+}
+
+// readDiskHeader 读取文件头中的键和过期时间
+func readDiskHeader(path string) (string, time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer f.Close()
+
+	var hdr [diskHeaderSize]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return "", time.Time{}, err
+	}
+	if !bytes.Equal(hdr[:4], diskFileMagic[:]) {
+		return "", time.Time{}, errors.New("disk store: bad magic")
+	}
+	var expiresAt time.Time
+	if exp := int64(binary.BigEndian.Uint64(hdr[4:12])); exp != 0 {
+		expiresAt = time.Unix(0, exp)
+	}
+	key := make([]byte, binary.BigEndian.Uint32(hdr[12:16]))
+	if _, err := io.ReadFull(f, key); err != nil {
+		return "", time.Time{}, err
+	}
+	return string(key), expiresAt, nil
+}
+
+// ---------- 两级缓存 ----------
+
+// evictNotifier 支持淘汰回调的缓存，L1须实现该接口才能降级到L2
+type evictNotifier interface {
+	OnEvicted(fn func(key, value interface{}))
+}
+
+// tieredItem L1中保存的值，记录过期时间以便降级到L2时保留TTL
+type tieredItem struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// TierStats 单层统计信息
+type TierStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64 // 因容量淘汰的条目数(L1的淘汰即降级到L2)
+	Expired   int64
+	Entries   int
+	Bytes     int64 // 仅L2有效
+}
+
+// TieredStats 两级缓存统计信息
+type TieredStats struct {
+	L1          TierStats
+	L2          TierStats
+	Promotions  int64 // L2命中后提升到L1的次数
+	Demotions   int64 // L1淘汰后降级到L2的次数
+	DemoteFails int64 // 降级失败次数(编码或写盘失败)
+}
+
+// TieredCache 两级缓存：L1为任意内存淘汰策略，L2为磁盘存储
+// 设计特点:
+// - L1因容量淘汰的条目降级写入L2，L2命中的条目提升回L1并从L2删除(两层互斥)
+// - 条目的过期时间在两层之间保持不变
+// - 同一键的读写通过分段锁串行化，避免提升与写入交错导致旧值覆盖新值
+// - 降级同样在被淘汰键的分段锁下进行，淘汰与写盘之间被删除或覆盖的条目不会降级
+type TieredCache struct {
+	l1    Cache
+	l2    *DiskStore
+	codec Codec
+	locks [64]sync.Mutex
+
+	demoteMu sync.Mutex
+	live     map[string]*tieredItem // 每个键最近一次写入L1的条目
+	pending  map[string]*tieredItem // 已被L1淘汰、等待写入L2的条目
+
+	stats struct { // 原子操作
+		l1Hits      int64
+		l1Misses    int64
+		promotions  int64
+		demotions   int64
+		demoteFails int64
+	}
+}
+
+// NewTieredCache 创建两级缓存
+// l1: 内存缓存，须实现OnEvicted(LRUCache、LFUCache、FIFOCache、ARCCache均已实现)
+// codec: 为nil时使用GobCodec
+func NewTieredCache(l1 Cache, l2 *DiskStore, codec Codec) (*TieredCache, error) {
+	notifier, ok := l1.(evictNotifier)
+	if !ok {
+		return nil, errors.New("tiered cache: L1 cache does not support eviction callbacks")
+	}
+	if codec == nil {
+		codec = GobCodec{}
+	}
+	t := &TieredCache{
+		l1:      l1,
+		l2:      l2,
+		codec:   codec,
+		live:    make(map[string]*tieredItem),
+		pending: make(map[string]*tieredItem),
+	}
+	notifier.OnEvicted(t.demote)
+	return t, nil
+}
+
+// lockKey 获取键所在分段的锁
+func (t *TieredCache) lockKey(key string) *sync.Mutex {
+	return &t.locks[fnv32(key)%uint32(len(t.locks))]
+}
+
+// Get 先查L1，未命中再查L2，L2命中则提升到L1
+func (t *TieredCache) Get(key string) (interface{}, bool) {
+	defer t.flushDemotions()
+	mu := t.lockKey(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if v, ok := t.l1.Get(key); ok {
+		item := v.(*tieredItem)
+		if item.alive() {
+			atomic.AddInt64(&t.stats.l1Hits, 1)
+			return item.value, true
+		}
+		t.l1.Delete(key)
+	}
+	atomic.AddInt64(&t.stats.l1Misses, 1)
+
+	// 刚被L1淘汰、还没写入L2的条目直接放回L1
+	if item := t.takePending(key); item != nil && item.alive() {
+		atomic.AddInt64(&t.stats.promotions, 1)
+		t.putL1(key, item)
+		return item.value, true
+	}
+	t.setLive(key, nil) // L1中已没有该键(过期被丢弃)
+
+	data, expiresAt, ok := t.l2.Get(key)
+	if !ok {
+		return nil, false
+	}
+	value, err := t.codec.Unmarshal(data)
+	if err != nil {
+		t.l2.Delete(key)
+		return nil, false
+	}
+
+	// 提升到L1，剩余TTL保持不变
+	t.l2.Delete(key)
+	atomic.AddInt64(&t.stats.promotions, 1)
+	t.putL1(key, &tieredItem{value: value, expiresAt: expiresAt})
+	return value, true
+}
+
+// Put 写入L1(永不过期)，并删除L2中的旧值
+func (t *TieredCache) Put(key string, value interface{}) {
+	t.PutWithExpiration(key, value, 0)
+}
+
+// PutWithExpiration 写入L1并设置过期时间，删除L2中的旧值
+func (t *TieredCache) PutWithExpiration(key string, value interface{}, expiration time.Duration) {
+	defer t.flushDemotions()
+	mu := t.lockKey(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	item := &tieredItem{value: value}
+	if expiration > 0 {
+		item.expiresAt = timeNow().Add(expiration)
+	}
+	t.l2.Delete(key)
+	t.putL1(key, item)
+}
+
+// Delete 从两层中删除键，包括已被L1淘汰、尚未写入L2的条目
+func (t *TieredCache) Delete(key string) bool {
+	defer t.flushDemotions()
+	mu := t.lockKey(key)
+	mu.Lock()
+	defer mu.Unlock()
+	pending := t.takePending(key) != nil
+	t.setLive(key, nil)
+	inL1 := t.l1.Delete(key)
+	inL2 := t.l2.Delete(key)
+	return inL1 || inL2 || pending
+}
+
+// alive 条目是否未过期
+func (item *tieredItem) alive() bool {
+	return item.expiresAt.IsZero() || timeNow().Before(item.expiresAt)
+}
+
+// putL1 按剩余TTL写入L1，调用方须持有键的分段锁
+func (t *TieredCache) putL1(key string, item *tieredItem) {
+	var ttl time.Duration
+	if !item.expiresAt.IsZero() {
+		if ttl = item.expiresAt.Sub(timeNow()); ttl <= 0 {
+			t.setLive(key, nil)
+			return
+		}
+	}
+	t.setLive(key, item)
+	t.l1.PutWithExpiration(key, item, ttl)
+}
+
+// setLive 记录键最近一次写入L1的条目，item为nil表示键已不在L1中
+func (t *TieredCache) setLive(key string, item *tieredItem) {
+	t.demoteMu.Lock()
+	defer t.demoteMu.Unlock()
+	if item == nil {
+		delete(t.live, key)
+	} else {
+		t.live[key] = item
+	}
+}
+
+// takePending 取出键待降级的条目，条目已被覆盖或删除时返回nil，调用方须持有键的分段锁
+func (t *TieredCache) takePending(key string) *tieredItem {
+	t.demoteMu.Lock()
+	defer t.demoteMu.Unlock()
+	item, ok := t.pending[key]
+	if !ok {
+		return nil
+	}
+	delete(t.pending, key)
+	if t.live[key] != item {
+		return nil
+	}
+	return item
+}
+
+// demote L1淘汰回调：只登记条目，由flushDemotions写入L2
+// 回调执行时持有的是引起淘汰的键的分段锁，此时写L2无法与被淘汰键的Delete、Put串行化
+func (t *TieredCache) demote(key, value interface{}) {
+	item, ok := value.(*tieredItem)
+	k, isString := key.(string)
+	if !ok || !isString {
+		return
+	}
+	t.demoteMu.Lock()
+	t.pending[k] = item
+	t.demoteMu.Unlock()
+}
+
+// flushDemotions 逐个持有被淘汰键的分段锁，将待降级的条目写入L2，调用方不能持有任何分段锁
+// 分段锁正被占用的键跳过，持有者释放锁之后会再次执行flushDemotions；
+// L1在TieredCache之外被淘汰(如内存压力收缩)的条目在下一次操作时写入
+func (t *TieredCache) flushDemotions() {
+	for {
+		t.demoteMu.Lock()
+		keys := make([]string, 0, len(t.pending))
+		for k := range t.pending {
+			keys = append(keys, k)
+		}
+		t.demoteMu.Unlock()
+		if len(keys) == 0 {
+			t.pruneLive()
+			return
+		}
+
+		progressed := false
+		for _, key := range keys {
+			mu := t.lockKey(key)
+			if !mu.TryLock() {
+				continue
+			}
+			progressed = true
+			if item := t.takePending(key); item != nil {
+				t.setLive(key, nil)
+				t.writeL2(key, item)
+			}
+			mu.Unlock()
+		}
+		if !progressed {
+			return
+		}
+	}
+}
+
+// pruneLive 清理L1已因过期自行丢弃的条目记录，记录数远超L1条目数时才扫描
+func (t *TieredCache) pruneLive() {
+	limit := 2*t.l1.Len() + 64
+	t.demoteMu.Lock()
+	defer t.demoteMu.Unlock()
+	if len(t.live) <= limit {
+		return
+	}
+	for k, item := range t.live {
+		if !item.alive() {
+			delete(t.live, k)
+		}
+	}
+}
+
+// writeL2 将未过期的条目编码后写入L2
+func (t *TieredCache) writeL2(k string, item *tieredItem) {
+	if !item.alive() {
+		return
+	}
+	data, err := t.codec.Marshal(item.value)
+	if err == nil {
+		err = t.l2.Put(k, data, item.expiresAt)
+	}
+	if err != nil {
+		atomic.AddInt64(&t.stats.demoteFails, 1)
+		return
+	}
+	atomic.AddInt64(&t.stats.demotions, 1)
+}
+
+// Stats 获取各层统计信息
+func (t *TieredCache) Stats() TieredStats {
+	s := TieredStats{
+		L1: TierStats{
+			Hits:    atomic.LoadInt64(&t.stats.l1Hits),
+			Misses:  atomic.LoadInt64(&t.stats.l1Misses),
+			Entries: t.l1.Len(),
+		},
+		Promotions:  atomic.LoadInt64(&t.stats.promotions),
+		Demotions:   atomic.LoadInt64(&t.stats.demotions),
+		DemoteFails: atomic.LoadInt64(&t.stats.demoteFails),
+	}
+	if src, ok := t.l1.(statsSource); ok {
+		_, _, s.L1.Evictions, s.L1.Expired = src.Stats()
+	}
+	s.L2.Hits, s.L2.Misses, s.L2.Evictions, s.L2.Expired = t.l2.Stats()
+	s.L2.Entries = t.l2.Len()
+	s.L2.Bytes = t.l2.Size()
+	return s
+}
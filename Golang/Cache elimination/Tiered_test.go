@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestTiered(t *testing.T, l1 Cache) *TieredCache {
+	t.Helper()
+	l2, err := OpenDiskStore(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewTieredCache(l1, l2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// L1淘汰的条目降级到L2，L2命中后提升回L1并从L2删除
+func TestTieredDemotionAndPromotion(t *testing.T) {
+	c := newTestTiered(t, NewLRUCache(2, 0))
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("c", 3) // a降级
+
+	s := c.Stats()
+	if s.Demotions != 1 || s.L1.Entries != 2 || s.L2.Entries != 1 {
+		t.Fatalf("after demotion stats = %+v", s)
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %v, %v", v, ok)
+	}
+	// a提升回L1，挤出最久未访问的b
+	s = c.Stats()
+	if s.Promotions != 1 || s.Demotions != 2 || s.L1.Entries != 2 || s.L2.Entries != 1 {
+		t.Fatalf("after promotion stats = %+v", s)
+	}
+	for key, want := range map[string]int{"a": 1, "b": 2, "c": 3} {
+		if v, ok := c.Get(key); !ok || v != want {
+			t.Fatalf("Get(%s) = %v, %v", key, v, ok)
+		}
+	}
+
+	// 写入新值时删除L2中的旧值
+	c.Put("x", 0)
+	c.Put("y", 0)
+	c.Put("a", 10)
+	if v, _ := c.Get("a"); v != 10 {
+		t.Fatalf("Get(a) after overwrite = %v", v)
+	}
+	if !c.Delete("b") {
+		t.Fatal("Delete(b) should find the demoted entry")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("deleted key still readable")
+	}
+}
+
+// 剩余TTL在两层之间保持不变，已过期的条目不降级
+func TestTieredTTLAcrossTiers(t *testing.T) {
+	clock := useFakeClock(t)
+	c := newTestTiered(t, NewLRUCache(1, 0))
+
+	c.PutWithExpiration("a", "v", 10*time.Second)
+	clock.Advance(4 * time.Second)
+	c.Put("filler", 0) // a带着剩余6秒降级
+	if s := c.Stats(); s.L2.Entries != 1 {
+		t.Fatalf("a not demoted: %+v", s)
+	}
+
+	clock.Advance(5 * time.Second)
+	if v, ok := c.Get("a"); !ok || v != "v" {
+		t.Fatalf("Get(a) before expiry = %v, %v", v, ok)
+	}
+	c.Put("filler", 0) // a带着剩余1秒再次降级
+	clock.Advance(2 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("a should expire 10s after it was written, in whichever tier it is")
+	}
+
+	c.PutWithExpiration("b", "v", time.Second)
+	clock.Advance(2 * time.Second)
+	before := c.Stats()
+	c.Put("filler2", 0) // 挤出已过期的b
+	if s := c.Stats(); s.Demotions != before.Demotions || s.L2.Entries != before.L2.Entries {
+		t.Fatalf("expired entry demoted: %+v", s)
+	}
+}
+
+// hookedLRU 在淘汰回调之前执行钩子，用于在淘汰与降级之间插入并发操作
+type hookedLRU struct {
+	*LRUCache
+	hook func(key interface{})
+}
+
+func (h *hookedLRU) OnEvicted(fn func(key, value interface{})) {
+	h.LRUCache.OnEvicted(func(key, value interface{}) {
+		if hook := h.hook; hook != nil {
+			h.hook = nil
+			hook(key)
+		}
+		fn(key, value)
+	})
+}
+
+// differentStripe 返回与key不在同一分段的键
+func differentStripe(c *TieredCache, key string) string {
+	for i := 0; ; i++ {
+		other := fmt.Sprintf("other-%d", i)
+		if c.lockKey(other) != c.lockKey(key) {
+			return other
+		}
+	}
+}
+
+// 被淘汰的键在降级写入L2之前被删除或覆盖，降级不能让旧值复活
+func TestTieredEvictRace(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		race func(c *TieredCache, key string)
+		want interface{} // nil表示键不存在
+	}{
+		{"delete", func(c *TieredCache, key string) { c.Delete(key) }, nil},
+		{"overwrite", func(c *TieredCache, key string) { c.Put(key, "new") }, "new"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l1 := &hookedLRU{LRUCache: NewLRUCache(1, 0)}
+			c := newTestTiered(t, l1)
+			c.Put("victim", "old")
+			trigger := differentStripe(c, "victim")
+
+			// 降级回调运行在触发淘汰的Put中，另一个协程此时对被淘汰的键操作
+			l1.hook = func(key interface{}) {
+				done := make(chan struct{})
+				go func() {
+					tc.race(c, key.(string))
+					close(done)
+				}()
+				<-done
+			}
+			c.Put(trigger, 0)
+
+			// 把可能存在的新值挤到L2，再读回来
+			c.Put(differentStripe(c, trigger), 0)
+			v, ok := c.Get("victim")
+			if tc.want == nil && ok {
+				t.Fatalf("deleted key resurrected with %v", v)
+			}
+			if tc.want != nil && v != tc.want {
+				t.Fatalf("Get = %v, %v, want %v", v, ok, tc.want)
+			}
+		})
+	}
+}
+
+// 并发读写删除时，删除后不再写入的键不能被读到
+func TestTieredConcurrentDelete(t *testing.T) {
+	c := newTestTiered(t, NewLRUCache(8, 0))
+	stop := make(chan struct{})
+	fillerDone := make(chan struct{})
+	go func() { // 持续写入无关的键制造淘汰
+		defer close(fillerDone)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				c.Put(fmt.Sprintf("filler-%d", i%32), i)
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("k-%d-%d", g, i)
+				c.Put(key, i)
+				c.Delete(key)
+				if v, ok := c.Get(key); ok {
+					t.Errorf("%s readable after delete: %v", key, v)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(stop)
+	<-fillerDone
+}
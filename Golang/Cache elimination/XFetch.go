@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// XFetchConfig 概率提前过期配置
+type XFetchConfig struct {
+	// Beta 提前刷新的激进程度，默认1；大于1更早刷新，小于1更接近真实过期时间
+	Beta float64
+	// Jitter 写入时TTL的随机缩短比例，取值[0,1)，例如0.1表示TTL在[0.9*ttl, ttl]之间，
+	// 避免同一批写入的键在同一时刻过期
+	Jitter float64
+}
+
+// xfetchItem 缓存中保存的条目，记录上次重新计算的耗时
+type xfetchItem struct {
+	value     interface{}
+	delta     time.Duration // 上次回源耗时
+	expiresAt time.Time     // 零值表示永不过期
+}
+
+// XFetchStats XFetch统计信息
+type XFetchStats struct {
+	Hits           int64 // 直接命中
+	Misses         int64 // 未命中或已过期
+	EarlyRefreshes int64 // 未过期但被概率选中提前刷新的次数
+	Loads          int64 // 实际回源次数
+	LoadErrors     int64
+}
+
+// XFetchCache 概率提前过期(XFetch)包装器
+// 进程内的并发回源由flightGroup合并，但多个进程仍会在键过期的同一时刻一起回源。
+// XFetch在读取时按 now - delta*beta*ln(rand) >= expiry 判断是否提前刷新：
+// 回源越慢(delta越大)、越接近过期，提前刷新的概率越高，
+// 从而让少数请求在过期前完成刷新，其余请求继续读取旧值
+// 参考: Vattani et al., "Optimal Probabilistic Cache Stampede Prevention", VLDB 2015
+type XFetchCache struct {
+	cache  Cache
+	cfg    XFetchConfig
+	flight flightGroup
+	random func() float64 // 返回(0,1]之间的随机数，测试时可替换
+
+	hits, misses, earlyRefreshes, loads, loadErrors int64
+
+	observerHook // 观察回源加载，命中和淘汰事件由底层缓存自己的观察者报告
+}
+
+// NewXFetchCache 在任意淘汰策略之上创建XFetch缓存
+func NewXFetchCache(c Cache, cfg XFetchConfig) *XFetchCache {
+	if cfg.Beta <= 0 {
+		cfg.Beta = 1
+	}
+	if cfg.Jitter < 0 || cfg.Jitter >= 1 {
+		cfg.Jitter = 0
+	}
+	return &XFetchCache{
+		cache:  c,
+		cfg:    cfg,
+		random: func() float64 { return 1 - rand.Float64() },
+
+		observerHook: observerHook{policy: "xfetch"},
+	}
+}
+
+// Fetch 读取键，未命中、已过期或被选中提前刷新时调用load回源并以ttl写入
+// 提前刷新失败时返回仍未过期的旧值，不向调用方暴露错误
+func (x *XFetchCache) Fetch(key string, ttl time.Duration, load func() (interface{}, error)) (interface{}, error) {
+	if v, ok := x.cache.Get(key); ok {
+		item := v.(*xfetchItem)
+		now := timeNow()
+		if item.expiresAt.IsZero() || now.Before(item.expiresAt) {
+			if !x.shouldRefresh(item, now) {
+				atomic.AddInt64(&x.hits, 1)
+				return item.value, nil
+			}
+			val, err := x.load(key, ttl, load, true)
+			if err != nil {
+				return item.value, nil
+			}
+			return val, nil
+		}
+		x.cache.Delete(key)
+	}
+	atomic.AddInt64(&x.misses, 1)
+	return x.load(key, ttl, load, false)
+}
+
+// Get 只读取，不触发回源和提前刷新
+func (x *XFetchCache) Get(key string) (interface{}, bool) {
+	v, ok := x.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	item := v.(*xfetchItem)
+	if !item.expiresAt.IsZero() && !timeNow().Before(item.expiresAt) {
+		return nil, false
+	}
+	return item.value, true
+}
+
+// Put 直接写入，ttl<=0表示永不过期
+// 没有回源耗时记录，直到下一次回源前不会被提前刷新
+func (x *XFetchCache) Put(key string, value interface{}, ttl time.Duration) {
+	x.store(key, value, 0, ttl)
+}
+
+// Delete 删除键
+func (x *XFetchCache) Delete(key string) bool {
+	return x.cache.Delete(key)
+}
+
+// Stats 获取统计信息
+func (x *XFetchCache) Stats() XFetchStats {
+	return XFetchStats{
+		Hits:           atomic.LoadInt64(&x.hits),
+		Misses:         atomic.LoadInt64(&x.misses),
+		EarlyRefreshes: atomic.LoadInt64(&x.earlyRefreshes),
+		Loads:          atomic.LoadInt64(&x.loads),
+		LoadErrors:     atomic.LoadInt64(&x.loadErrors),
+	}
+}
+
+// shouldRefresh XFetch判定: now - delta*beta*ln(rand) >= expiry
+func (x *XFetchCache) shouldRefresh(item *xfetchItem, now time.Time) bool {
+	if item.expiresAt.IsZero() || item.delta <= 0 {
+		return false
+	}
+	gap := time.Duration(-float64(item.delta) * x.cfg.Beta * math.Log(x.random()))
+	return !now.Add(gap).Before(item.expiresAt)
+}
+
+// load 合并并发回源，记录回源耗时并写入缓存
+func (x *XFetchCache) load(key string, ttl time.Duration, load func() (interface{}, error), early bool) (interface{}, error) {
+	// load不接收ctx，调用方也无法取消等待
+	return x.flight.Do(context.Background(), key, 0, func(context.Context) (interface{}, error) {
+		if early {
+			atomic.AddInt64(&x.earlyRefreshes, 1)
+		}
+		atomic.AddInt64(&x.loads, 1)
+		obs, observed := x.observeStart()
+		start := timeNow()
+		val, err := load()
+		if obs != nil {
+			x.notifyLoad(obs, observed, key, err)
+		}
+		if err != nil {
+			atomic.AddInt64(&x.loadErrors, 1)
+			return nil, err
+		}
+		x.store(key, val, timeNow().Sub(start), ttl)
+		return val, nil
+	})
+}
+
+// store 按抖动后的TTL写入底层缓存
+func (x *XFetchCache) store(key string, value interface{}, delta, ttl time.Duration) {
+	item := &xfetchItem{value: value, delta: delta}
+	if ttl <= 0 {
+		x.cache.Put(key, item)
+		return
+	}
+	if x.cfg.Jitter > 0 {
+		ttl -= time.Duration(float64(ttl) * x.cfg.Jitter * (1 - x.random()))
+	}
+	item.expiresAt = timeNow().Add(ttl)
+	x.cache.PutWithExpiration(key, item, ttl)
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestXFetchEarlyRefresh(t *testing.T) {
+	clock := useFakeClock(t)
+	x := NewXFetchCache(NewLRUCache(16, 0), XFetchConfig{Beta: 1})
+	loads := 0
+	load := func() (interface{}, error) {
+		loads++
+		clock.Advance(5 * time.Millisecond)
+		return loads, nil
+	}
+
+	if v, err := x.Fetch("k", time.Second, load); err != nil || v != 1 {
+		t.Fatalf("Fetch = %v, %v", v, err)
+	}
+
+	// 随机数接近1时 ln(rand)≈0，离过期还很远，不应提前刷新
+	x.random = func() float64 { return 1 }
+	if v, _ := x.Fetch("k", time.Second, load); v != 1 {
+		t.Fatalf("unexpected refresh, got %v", v)
+	}
+
+	// 随机数极小时 -ln(rand) 很大，即使离过期还有一秒也会提前刷新
+	x.random = func() float64 { return 1e-300 }
+	if v, _ := x.Fetch("k", time.Second, load); v != 2 {
+		t.Fatalf("expected early refresh, got %v", v)
+	}
+
+	s := x.Stats()
+	if s.EarlyRefreshes != 1 || s.Loads != 2 || s.Hits != 1 || s.Misses != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestXFetchRefreshErrorServesStale(t *testing.T) {
+	clock := useFakeClock(t)
+	x := NewXFetchCache(NewLRUCache(16, 0), XFetchConfig{})
+	x.Fetch("k", 500*time.Millisecond, func() (interface{}, error) {
+		clock.Advance(time.Millisecond)
+		return "old", nil
+	})
+	x.random = func() float64 { return 1e-300 }
+	v, err := x.Fetch("k", 500*time.Millisecond, func() (interface{}, error) {
+		return nil, errors.New("origin down")
+	})
+	if err != nil || v != "old" {
+		t.Fatalf("Fetch = %v, %v", v, err)
+	}
+	if s := x.Stats(); s.LoadErrors != 1 || s.EarlyRefreshes != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+func TestXFetchJitterShortensTTL(t *testing.T) {
+	clock := useFakeClock(t)
+	x := NewXFetchCache(NewLRUCache(16, 0), XFetchConfig{Jitter: 0.5})
+	x.random = func() float64 { return 1e-9 } // 抖动取最大值
+	x.Put("k", "v", 100*time.Millisecond)
+	v, _ := x.cache.Get("k")
+	remaining := v.(*xfetchItem).expiresAt.Sub(clock.Now())
+	if remaining > 51*time.Millisecond || remaining < 50*time.Millisecond {
+		t.Fatalf("remaining TTL %v, expected about 50ms", remaining)
+	}
+}
+
+func TestXFetchCoalescesMisses(t *testing.T) {
+	x := NewXFetchCache(NewLRUCache(16, 0), XFetchConfig{})
+	var mu sync.Mutex
+	loads := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			x.Fetch("k", time.Minute, func() (interface{}, error) {
+				mu.Lock()
+				loads++
+				mu.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				return "v", nil
+			})
+		}()
+	}
+	wg.Wait()
+	if loads != 1 {
+		t.Fatalf("expected one load, got %d", loads)
+	}
+}
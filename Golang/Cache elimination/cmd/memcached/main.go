@@ -0,0 +1,92 @@
+// memcached 基于memcached文本协议对外提供缓存服务
+//
+//	go run ./cmd/memcached -policy arc -capacity 100000 -addr 127.0.0.1:11211 -metrics 127.0.0.1:9150
+package main
+
+import (
+	"cache"
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	policy := flag.String("policy", "lru", "淘汰策略: lru、lfu、fifo、arc、lruk")
+	capacity := flag.Int("capacity", 100000, "缓存最大条目数")
+	addr := flag.String("addr", "127.0.0.1:11211", "TCP监听地址，为空时不监听TCP")
+	unixSock := flag.String("unix", "", "unix socket路径，为空时不监听")
+	maxConns := flag.Int("max-conns", 1024, "最大并发连接数")
+	maxItem := flag.Int("max-item-size", 1<<20, "单个value最大字节数")
+	idle := flag.Duration("idle-timeout", 0, "连接空闲超时")
+	metricsAddr := flag.String("metrics", "", "HTTP指标监听地址(/metrics为Prometheus格式，/debug/vars为expvar)，为空时不导出")
+	flag.Parse()
+
+	c, err := cache.NewCache(*policy, *capacity)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if closer, ok := c.(interface{ Close() }); ok {
+		defer closer.Close()
+	}
+
+	if *metricsAddr != "" {
+		serveMetrics(*metricsAddr, *policy, c)
+	}
+
+	srv := cache.NewMemcachedServer(c, cache.MemcachedConfig{
+		MaxConns:    *maxConns,
+		MaxItemSize: *maxItem,
+		IdleTimeout: *idle,
+	})
+
+	serve := func(network, address string) {
+		log.Printf("memcached(%s) 监听 %s %s", *policy, network, address)
+		if err := srv.ListenAndServe(network, address); err != nil && err != cache.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}
+	if *addr != "" {
+		go serve("tcp", *addr)
+	}
+	if *unixSock != "" {
+		go serve("unix", *unixSock)
+	}
+
+	// 收到退出信号后优雅关闭
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("关闭超时: %v", err)
+	}
+}
+
+// serveMetrics 注册缓存指标并在addr上提供HTTP导出，expvar的/debug/vars由默认ServeMux提供
+func serveMetrics(addr, policy string, c cache.Cache) {
+	src, ok := c.(interface {
+		Stats() (hits, misses, evictions, expired int64)
+		Len() int
+	})
+	if !ok {
+		log.Fatalf("策略%s不支持指标导出", policy)
+	}
+	metrics := cache.NewCacheMetrics(0)
+	if err := metrics.Register(policy, src); err != nil {
+		log.Fatal(err)
+	}
+	if err := metrics.PublishExpvar("cache"); err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/metrics", metrics)
+	go func() {
+		log.Printf("指标 监听 %s", addr)
+		log.Fatal(http.ListenAndServe(addr, nil))
+	}()
+}
@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// exampleClock 示例中用可控时钟代替真实等待，返回恢复timeNow的函数
+func exampleClock() (*fakeClock, func()) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	timeNow = clock.Now
+	return clock, func() { timeNow = time.Now }
+}
+
+// printCache 打印当前缓存内容，FIFO按进入顺序，ARC按四个链表分别打印
+func printCache(c Cache) {
+	printList := func(l *list.List, withValue bool) {
+		var items []string
+		for e := l.Front(); e != nil; e = e.Next() {
+			var ent *entry
+			switch v := e.Value.(type) {
+			case *fifoEntry:
+				ent = &v.entry
+			case *arcEntry:
+				ent = &v.entry
+			}
+			if !ent.expiresAt.IsZero() && !timeNow().Before(ent.expiresAt) {
+				continue
+			}
+			if withValue {
+				items = append(items, fmt.Sprintf("%v(%v)", ent.key, ent.value))
+			} else {
+				items = append(items, fmt.Sprint(ent.key))
+			}
+		}
+		if len(items) > 0 {
+			fmt.Print(" " + strings.Join(items, " "))
+		}
+	}
+
+	switch c := c.(type) {
+	case *FIFOCache:
+		c.lock.RLock()
+		defer c.lock.RUnlock()
+		fmt.Print("当前缓存:")
+		printList(c.queue, true)
+	case *ARCCache:
+		c.lock.RLock()
+		defer c.lock.RUnlock()
+		fmt.Print("T1(最近访问):")
+		printList(c.t1, true)
+		fmt.Print("\nT2(频繁访问):")
+		printList(c.t2, true)
+		fmt.Print("\nB1(最近淘汰):")
+		printList(c.b1, false)
+		fmt.Print("\nB2(频繁淘汰):")
+		printList(c.b2, false)
+	}
+	fmt.Println()
+}
+
+func ExampleLRUCache() {
+	clock, restore := exampleClock()
+	defer restore()
+
+	// 创建容量为3，默认过期10秒的缓存
+	cache := NewLRUCache(3, 10*time.Second)
+
+	// 基本操作演示
+	cache.Put("name", "PaiCloud")
+	cache.Put("age", 25)
+	cache.Put("job", "Engineer")
+
+	// 获取存在的值
+	if val, ok := cache.Get("name"); ok {
+		fmt.Printf("name: %v\n", val)
+	}
+
+	// 触发淘汰（容量已满时添加新条目）
+	cache.Put("salary", 50000) // 淘汰最久未使用的"age"
+
+	// 检查被淘汰的键
+	if _, ok := cache.Get("age"); !ok {
+		fmt.Println("age已被淘汰")
+	}
+
+	// 过期功能演示
+	cache.PutWithExpiration("temp", "data", 2*time.Second)
+	clock.Advance(3 * time.Second)
+	if _, ok := cache.Get("temp"); !ok {
+		fmt.Println("temp已过期")
+	}
+
+	// 统计信息
+	hits, misses, evictions, expired := cache.Stats()
+	fmt.Printf("命中率: %.1f%%\n", float64(hits)/float64(hits+misses)*100)
+	fmt.Printf("淘汰次数: %d, 过期次数: %d\n", evictions, expired)
+	// Output:
+	// name: PaiCloud
+	// age已被淘汰
+	// temp已过期
+	// 命中率: 33.3%
+	// 淘汰次数: 2, 过期次数: 1
+}
+
+func ExampleLFUCache() {
+	cache := NewLFUCache(3)
+	defer cache.Close()
+
+	// 测试用例展示LFU特性
+	cache.PutWithExpiration("A", 1, 0)
+	cache.PutWithExpiration("B", 2, 0)
+	cache.PutWithExpiration("C", 3, 0)
+
+	cache.Get("A") // A频率=2
+	cache.Get("A") // A频率=3
+	cache.Get("B") // B频率=2
+
+	cache.PutWithExpiration("D", 4, 0) // 应该淘汰C(频率最低)
+
+	_, ok := cache.Get("C")
+	fmt.Printf("C仍在缓存中: %v\n", ok)
+	fmt.Printf("命中率: %.2f%%\n", cache.HitRate()*100)
+	// Output:
+	// C仍在缓存中: false
+	// 命中率: 75.00%
+}
+
+func ExampleFIFOCache() {
+	clock, restore := exampleClock()
+	defer restore()
+
+	cache := NewFIFOCache(3)
+	defer cache.Close()
+
+	// 初始填充缓存
+	fmt.Println("=== 初始填充缓存 ===")
+	cache.Put("A", 1)
+	cache.Put("B", 2)
+	cache.Put("C", 3)
+	printCache(cache)
+
+	// 测试FIFO淘汰策略
+	fmt.Println("\n=== 测试FIFO淘汰 ===")
+	cache.Put("D", 4) // 应该淘汰最早进入的A
+	printCache(cache)
+
+	// 测试过期功能
+	fmt.Println("\n=== 测试过期功能 ===")
+	cache.PutWithExpiration("E", 5, 2*time.Second)
+	printCache(cache)
+	clock.Advance(3 * time.Second)
+	if _, ok := cache.Get("E"); !ok {
+		fmt.Println("E已过期")
+	}
+	printCache(cache)
+	// Output:
+	// === 初始填充缓存 ===
+	// 当前缓存: A(1) B(2) C(3)
+	//
+	// === 测试FIFO淘汰 ===
+	// 当前缓存: B(2) C(3) D(4)
+	//
+	// === 测试过期功能 ===
+	// 当前缓存: C(3) D(4) E(5)
+	// E已过期
+	// 当前缓存: C(3) D(4)
+}
+
+func ExampleARCCache() {
+	cache := NewARCCache(3)
+
+	// 添加更多测试操作
+	cache.Put("A", 1)
+	cache.Put("B", 2)
+	cache.Put("C", 3)
+
+	// 多次访问测试命中率
+	cache.Get("A")
+	cache.Get("B")
+	cache.Get("A")
+
+	// 测试未命中
+	cache.Get("X")
+
+	// 触发淘汰
+	cache.Put("D", 4)
+	cache.Put("E", 5)
+	printCache(cache)
+
+	fmt.Println("\n=== 阶段4: 幽灵条目影响 ===")
+	cache.Put("C", 3) // 重新插入被淘汰的C
+	printCache(cache)
+
+	fmt.Println("\n=== 统计信息 ===")
+	hits, misses, evictions, _ := cache.Stats()
+	fmt.Printf("命中次数: %d\n", hits)
+	fmt.Printf("未命中次数: %d\n", misses)
+	fmt.Printf("淘汰次数: %d\n", evictions)
+	// Output:
+	// T1(最近访问): E(5)
+	// T2(频繁访问): A(1) B(2)
+	// B1(最近淘汰): D C
+	// B2(频繁淘汰):
+	//
+	// === 阶段4: 幽灵条目影响 ===
+	// T1(最近访问): E(5)
+	// T2(频繁访问): C(3) A(1)
+	// B1(最近淘汰): D
+	// B2(频繁淘汰): B
+	//
+	// === 统计信息 ===
+	// 命中次数: 3
+	// 未命中次数: 1
+	// 淘汰次数: 3
+}
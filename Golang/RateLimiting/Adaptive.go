@@ -0,0 +1,222 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// ConcurrencyLimit 并发上限算法，根据请求的往返时间和丢弃情况调整允许同时进行的请求数
+// 由AdaptiveLimiter在持有锁时调用，实现不需要自己加锁
+type ConcurrencyLimit interface {
+	// Limit 当前的并发上限，至少为1
+	Limit() int
+	// Update 一个请求结束，rtt为往返时间，inflight为该请求开始时正在进行的请求数(包括它自己)，dropped表示被下游丢弃或超时
+	Update(rtt time.Duration, inflight int, dropped bool)
+}
+
+// FixedLimit 固定的并发上限，此时AdaptiveLimiter等价于信号量
+type FixedLimit int
+
+// Limit 实现ConcurrencyLimit
+func (f FixedLimit) Limit() int { return max(int(f), 1) }
+
+// Update 实现ConcurrencyLimit，固定上限不随请求变化
+func (FixedLimit) Update(time.Duration, int, bool) {}
+
+// AdaptiveLimiter 自适应并发限流器
+// 限制同时进行的请求数而不是速率，上限由ConcurrencyLimit根据观测到的延迟和丢弃动态调整
+// 下游变慢时上限自动降低，请求在本地排队而不是堆积在下游
+// 用法与信号量相同：Acquire获取许可，请求结束后调用许可的OnSuccess、OnDropped或OnIgnore之一释放
+type AdaptiveLimiter struct {
+	limit ConcurrencyLimit
+
+	mu       sync.Mutex
+	inflight int
+	waiters  list.List // *adaptiveWaiter，先到先得
+}
+
+type adaptiveWaiter struct {
+	ready    chan struct{}
+	granted  bool
+	inflight int
+}
+
+// Listener 一次获取到的许可，记录请求开始的时间
+// 三个方法只有第一次调用生效
+type Listener struct {
+	a        *AdaptiveLimiter
+	start    time.Time
+	inflight int
+	once     sync.Once
+}
+
+// NewAdaptiveLimiter 创建自适应并发限流器
+func NewAdaptiveLimiter(limit ConcurrencyLimit) *AdaptiveLimiter {
+	return &AdaptiveLimiter{limit: limit}
+}
+
+// Acquire 阻塞直到获得许可，ctx结束时返回错误
+// 排队按先后顺序，上限提高或其他请求结束时唤醒
+func (a *AdaptiveLimiter) Acquire(ctx context.Context) (*Listener, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	if a.waiters.Len() == 0 && a.inflight < a.limit.Limit() {
+		a.inflight++
+		inflight := a.inflight
+		a.mu.Unlock()
+		return a.listener(inflight), nil
+	}
+	w := &adaptiveWaiter{ready: make(chan struct{})}
+	el := a.waiters.PushBack(w)
+	a.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return a.listener(w.inflight), nil
+	case <-ctx.Done():
+		a.mu.Lock()
+		if w.granted {
+			// 取消与唤醒同时发生，归还已经分配的许可
+			a.inflight--
+			a.wake()
+		} else {
+			a.waiters.Remove(el)
+		}
+		a.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// TryAcquire 在timeout内尝试获得许可，timeout不大于0时不等待
+func (a *AdaptiveLimiter) TryAcquire(timeout time.Duration) (*Listener, bool) {
+	if timeout <= 0 {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.waiters.Len() > 0 || a.inflight >= a.limit.Limit() {
+			return nil, false
+		}
+		a.inflight++
+		return a.listener(a.inflight), true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	l, err := a.Acquire(ctx)
+	return l, err == nil
+}
+
+// Limit 当前的并发上限
+func (a *AdaptiveLimiter) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit.Limit()
+}
+
+// Inflight 正在进行的请求数
+func (a *AdaptiveLimiter) Inflight() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inflight
+}
+
+func (a *AdaptiveLimiter) listener(inflight int) *Listener {
+	return &Listener{a: a, start: timeNow(), inflight: inflight}
+}
+
+// wake 在上限允许时按顺序唤醒排队的请求，调用方持有锁
+func (a *AdaptiveLimiter) wake() {
+	for a.waiters.Len() > 0 && a.inflight < a.limit.Limit() {
+		w := a.waiters.Remove(a.waiters.Front()).(*adaptiveWaiter)
+		a.inflight++
+		w.granted = true
+		w.inflight = a.inflight
+		close(w.ready)
+	}
+}
+
+// OnSuccess 请求成功，往返时间用于调整上限
+func (l *Listener) OnSuccess() {
+	l.release(true, false)
+}
+
+// OnDropped 请求被下游丢弃、超时或拒绝，上限会降低
+func (l *Listener) OnDropped() {
+	l.release(true, true)
+}
+
+// OnIgnore 请求结束但不参与调整，如参数错误等与下游负载无关的失败
+func (l *Listener) OnIgnore() {
+	l.release(false, false)
+}
+
+func (l *Listener) release(sample, dropped bool) {
+	l.once.Do(func() {
+		rtt := timeNow().Sub(l.start)
+		a := l.a
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if sample {
+			a.limit.Update(rtt, l.inflight, dropped)
+		}
+		a.inflight--
+		a.wake()
+	})
+}
+
+// Semaphore 把AdaptiveLimiter包装成Third.go中Semaphore的形状(Acquire、Release、TryAcquire)
+// 可以不改调用方直接替换原有的信号量
+// Release不区分是哪次Acquire，按先进先出归还最早的许可并按成功计入，
+// 请求乱序结束时往返时间只是近似值；需要精确采样或上报丢弃时直接使用Listener
+type Semaphore struct {
+	a *AdaptiveLimiter
+
+	mu   sync.Mutex
+	held list.List // *Listener，按获得许可的先后排列
+}
+
+// NewSemaphore 创建基于自适应并发上限的信号量
+func NewSemaphore(limit ConcurrencyLimit) *Semaphore {
+	return &Semaphore{a: NewAdaptiveLimiter(limit)}
+}
+
+// Acquire 阻塞直到获得许可
+func (s *Semaphore) Acquire() {
+	l, _ := s.a.Acquire(context.Background())
+	s.hold(l)
+}
+
+// TryAcquire 在timeout内尝试获得许可，超时返回false
+func (s *Semaphore) TryAcquire(timeout time.Duration) bool {
+	l, ok := s.a.TryAcquire(timeout)
+	if ok {
+		s.hold(l)
+	}
+	return ok
+}
+
+// Release 归还一个许可，没有持有许可时panic，与sync.WaitGroup计数为负时一致
+func (s *Semaphore) Release() {
+	s.mu.Lock()
+	front := s.held.Front()
+	if front == nil {
+		s.mu.Unlock()
+		panic("ratelimit: Release without Acquire")
+	}
+	l := s.held.Remove(front).(*Listener)
+	s.mu.Unlock()
+	l.OnSuccess()
+}
+
+// Limiter 底层的AdaptiveLimiter，用于查看当前上限和正在进行的请求数
+func (s *Semaphore) Limiter() *AdaptiveLimiter {
+	return s.a
+}
+
+func (s *Semaphore) hold(l *Listener) {
+	s.mu.Lock()
+	s.held.PushBack(l)
+	s.mu.Unlock()
+}
@@ -0,0 +1,246 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// 自适应并发上限算法，参考Netflix concurrency-limits
+// - AIMD: 加性增乘性减，只依据丢弃和超时，最简单
+// - Vegas: 由最小RTT估计下游的排队长度，排队少时增加，排队多时减少
+// - Gradient2: 比较短期RTT与长期平均RTT的比值(梯度)，延迟上升时按比例收缩
+
+// limitRange 填充初始值和上下限的默认值并校验
+func limitRange(initial, lo, hi *int) error {
+	if *lo <= 0 {
+		*lo = 1
+	}
+	if *hi <= 0 {
+		*hi = 1000
+	}
+	if *initial <= 0 {
+		*initial = 20
+	}
+	if *lo > *hi {
+		return fmt.Errorf("ratelimit: min limit %d exceeds max limit %d", *lo, *hi)
+	}
+	*initial = min(max(*initial, *lo), *hi)
+	return nil
+}
+
+// AIMDConfig AIMD算法配置
+type AIMDConfig struct {
+	Initial      int           // 初始上限，默认20
+	Min          int           // 最小上限，默认1
+	Max          int           // 最大上限，默认1000
+	BackoffRatio float64       // 丢弃或超时时上限乘以该比例，取值(0,1)，默认0.9
+	Timeout      time.Duration // RTT超过该值视为丢弃，默认5秒
+}
+
+// AIMDLimit 加性增乘性减
+// 请求成功且上限被用到一半以上时加1，丢弃或超时时乘以BackoffRatio
+type AIMDLimit struct {
+	cfg   AIMDConfig
+	limit int
+}
+
+// NewAIMDLimit 创建AIMD算法
+func NewAIMDLimit(cfg AIMDConfig) (*AIMDLimit, error) {
+	if err := limitRange(&cfg.Initial, &cfg.Min, &cfg.Max); err != nil {
+		return nil, err
+	}
+	if cfg.BackoffRatio == 0 {
+		cfg.BackoffRatio = 0.9
+	}
+	if cfg.BackoffRatio <= 0 || cfg.BackoffRatio >= 1 {
+		return nil, fmt.Errorf("ratelimit: backoff ratio %v must be in (0, 1)", cfg.BackoffRatio)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	return &AIMDLimit{cfg: cfg, limit: cfg.Initial}, nil
+}
+
+// Limit 实现ConcurrencyLimit
+func (a *AIMDLimit) Limit() int {
+	return a.limit
+}
+
+// Update 实现ConcurrencyLimit
+func (a *AIMDLimit) Update(rtt time.Duration, inflight int, dropped bool) {
+	switch {
+	case dropped || rtt > a.cfg.Timeout:
+		a.limit = max(int(float64(a.limit)*a.cfg.BackoffRatio), a.cfg.Min)
+	case inflight*2 >= a.limit:
+		// 上限远未用满时不增加，避免空闲期间上限无限增长
+		a.limit = min(a.limit+1, a.cfg.Max)
+	}
+}
+
+// VegasConfig Vegas算法配置
+type VegasConfig struct {
+	Initial         int     // 初始上限，默认20
+	Min             int     // 最小上限，默认1
+	Max             int     // 最大上限，默认1000
+	Smoothing       float64 // 新上限的权重，取值(0,1]，默认1即不平滑
+	ProbeMultiplier int     // 每处理约ProbeMultiplier*上限个请求重新探测一次最小RTT，默认30
+}
+
+// VegasLimit 基于延迟的Vegas算法
+// 最小RTT视为无负载时的延迟，排队长度估计为limit*(1-minRTT/rtt)
+// 排队长度以log10(limit)为单位：不超过1个单位时快速增加，少于alpha时缓慢增加，超过beta时减少
+type VegasLimit struct {
+	cfg       VegasConfig
+	limit     float64
+	rttNoLoad time.Duration // 观测到的最小RTT，0表示尚未观测
+	probe     int           // 距离上次探测处理的请求数
+}
+
+// NewVegasLimit 创建Vegas算法
+func NewVegasLimit(cfg VegasConfig) (*VegasLimit, error) {
+	if err := limitRange(&cfg.Initial, &cfg.Min, &cfg.Max); err != nil {
+		return nil, err
+	}
+	if cfg.Smoothing == 0 {
+		cfg.Smoothing = 1
+	}
+	if cfg.Smoothing < 0 || cfg.Smoothing > 1 {
+		return nil, fmt.Errorf("ratelimit: smoothing %v must be in (0, 1]", cfg.Smoothing)
+	}
+	if cfg.ProbeMultiplier <= 0 {
+		cfg.ProbeMultiplier = 30
+	}
+	return &VegasLimit{cfg: cfg, limit: float64(cfg.Initial)}, nil
+}
+
+// Limit 实现ConcurrencyLimit
+func (v *VegasLimit) Limit() int {
+	return int(v.limit)
+}
+
+// Update 实现ConcurrencyLimit
+func (v *VegasLimit) Update(rtt time.Duration, inflight int, dropped bool) {
+	if rtt <= 0 {
+		return
+	}
+	v.probe++
+	if v.probe >= v.cfg.ProbeMultiplier*int(v.limit) {
+		// 下游的无负载延迟可能变化(如扩容或迁移)，定期用当前RTT重新开始估计
+		v.probe = 0
+		v.rttNoLoad = rtt
+		return
+	}
+	if v.rttNoLoad == 0 || rtt < v.rttNoLoad {
+		v.rttNoLoad = rtt
+		return
+	}
+
+	step := max(math.Log10(v.limit), 1)
+	var next float64
+	switch {
+	case dropped:
+		next = v.limit - step
+	case float64(inflight)*2 < v.limit:
+		return
+	default:
+		queue := math.Ceil(v.limit * (1 - float64(v.rttNoLoad)/float64(rtt)))
+		alpha, beta := 3*step, 6*step
+		switch {
+		case queue <= step:
+			next = v.limit + beta
+		case queue < alpha:
+			next = v.limit + step
+		case queue > beta:
+			next = v.limit - step
+		default:
+			return
+		}
+	}
+	next = min(max(next, float64(v.cfg.Min)), float64(v.cfg.Max))
+	v.limit = (1-v.cfg.Smoothing)*v.limit + v.cfg.Smoothing*next
+}
+
+// Gradient2Config Gradient2算法配置
+type Gradient2Config struct {
+	Initial      int     // 初始上限，默认20
+	Min          int     // 最小上限，默认1
+	Max          int     // 最大上限，默认1000
+	Smoothing    float64 // 新上限的权重，取值(0,1]，默认0.2
+	RTTTolerance float64 // 允许短期RTT超过长期RTT的倍数，不小于1，默认1.5
+	LongWindow   int     // 长期RTT指数移动平均的窗口(请求数)，默认600
+	QueueSize    float64 // 每次调整额外允许的排队数，用于在延迟稳定时探测更高的上限，默认4
+}
+
+// Gradient2Limit 比较短期与长期RTT的梯度算法
+// gradient = clamp(RTTTolerance*longRTT/rtt, 0.5, 1)，新上限 = limit*gradient + QueueSize
+// 长期RTT远高于当前RTT时(如下游恢复后)逐步衰减，使上限能够重新增长
+// 丢弃的请求按最小梯度0.5处理
+type Gradient2Limit struct {
+	cfg     Gradient2Config
+	limit   float64
+	longRTT float64 // 长期RTT的指数移动平均(纳秒)
+	samples int     // 预热期间的样本数
+}
+
+// gradient2Warmup 预热期间长期RTT取简单平均
+const gradient2Warmup = 10
+
+// NewGradient2Limit 创建Gradient2算法
+func NewGradient2Limit(cfg Gradient2Config) (*Gradient2Limit, error) {
+	if err := limitRange(&cfg.Initial, &cfg.Min, &cfg.Max); err != nil {
+		return nil, err
+	}
+	if cfg.Smoothing == 0 {
+		cfg.Smoothing = 0.2
+	}
+	if cfg.Smoothing < 0 || cfg.Smoothing > 1 {
+		return nil, fmt.Errorf("ratelimit: smoothing %v must be in (0, 1]", cfg.Smoothing)
+	}
+	if cfg.RTTTolerance == 0 {
+		cfg.RTTTolerance = 1.5
+	}
+	if cfg.RTTTolerance < 1 {
+		return nil, fmt.Errorf("ratelimit: rtt tolerance %v must be at least 1", cfg.RTTTolerance)
+	}
+	if cfg.LongWindow <= 0 {
+		cfg.LongWindow = 600
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 4
+	}
+	return &Gradient2Limit{cfg: cfg, limit: float64(cfg.Initial)}, nil
+}
+
+// Limit 实现ConcurrencyLimit
+func (g *Gradient2Limit) Limit() int {
+	return int(g.limit)
+}
+
+// Update 实现ConcurrencyLimit
+func (g *Gradient2Limit) Update(rtt time.Duration, inflight int, dropped bool) {
+	if rtt <= 0 {
+		return
+	}
+	short := float64(rtt)
+	if g.samples < gradient2Warmup {
+		g.samples++
+		g.longRTT += (short - g.longRTT) / float64(g.samples)
+	} else {
+		g.longRTT += (short - g.longRTT) * 2 / float64(g.cfg.LongWindow+1)
+	}
+	if g.longRTT/short > 2 {
+		g.longRTT *= 0.95
+	}
+	if !dropped && float64(inflight) < g.limit/2 {
+		return
+	}
+
+	gradient := 0.5
+	if !dropped {
+		gradient = min(max(g.cfg.RTTTolerance*g.longRTT/short, 0.5), 1)
+	}
+	next := g.limit*gradient + g.cfg.QueueSize
+	next = (1-g.cfg.Smoothing)*g.limit + g.cfg.Smoothing*next
+	g.limit = min(max(next, float64(g.cfg.Min)), float64(g.cfg.Max))
+}
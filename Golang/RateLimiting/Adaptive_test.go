@@ -0,0 +1,324 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// FixedLimit时AdaptiveLimiter可以直接替代信号量
+func TestAdaptiveLimiterAsSemaphore(t *testing.T) {
+	sem := NewAdaptiveLimiter(FixedLimit(3))
+	var running, peak int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := sem.Acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer l.OnSuccess()
+			n := atomic.AddInt64(&running, 1)
+			for {
+				p := atomic.LoadInt64(&peak)
+				if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&running, -1)
+		}()
+	}
+	wg.Wait()
+	if peak > 3 || sem.Inflight() != 0 {
+		t.Fatalf("peak concurrency = %d, inflight = %d", peak, sem.Inflight())
+	}
+}
+
+// Third.go中Semaphore的用法：Acquire/Release成对调用，TryAcquire带超时
+type thirdSemaphore interface {
+	Acquire()
+	Release()
+	TryAcquire(timeout time.Duration) bool
+}
+
+var _ thirdSemaphore = (*Semaphore)(nil)
+
+func TestSemaphoreLikeThird(t *testing.T) {
+	var sem thirdSemaphore = NewSemaphore(FixedLimit(2))
+	var running, peak int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem.Acquire()
+			defer sem.Release()
+			n := atomic.AddInt64(&running, 1)
+			for {
+				p := atomic.LoadInt64(&peak)
+				if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&running, -1)
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Fatalf("peak concurrency = %d", peak)
+	}
+
+	sem.Acquire()
+	if !sem.TryAcquire(0) {
+		t.Fatal("second permit should be available")
+	}
+	if sem.TryAcquire(10 * time.Millisecond) {
+		t.Fatal("TryAcquire should time out when all permits are held")
+	}
+	sem.Release()
+	if !sem.TryAcquire(10 * time.Millisecond) {
+		t.Fatal("TryAcquire should succeed after Release")
+	}
+	sem.Release()
+	sem.Release()
+	if a := sem.(*Semaphore).Limiter(); a.Inflight() != 0 {
+		t.Fatalf("inflight = %d", a.Inflight())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Release without Acquire should panic")
+		}
+	}()
+	sem.Release()
+}
+
+// 通过Semaphore释放的许可同样参与上限调整
+func TestSemaphoreAdjustsLimit(t *testing.T) {
+	clock := useFakeClock(t)
+	limit, err := NewAIMDLimit(AIMDConfig{Initial: 4, Min: 1, Max: 8, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sem := NewSemaphore(limit)
+	sem.Acquire()
+	clock.Advance(2 * time.Second)
+	sem.Release()
+	if got := sem.Limiter().Limit(); got >= 4 {
+		t.Fatalf("limit after slow request = %d", got)
+	}
+}
+
+func TestAdaptiveLimiterQueue(t *testing.T) {
+	a := NewAdaptiveLimiter(FixedLimit(1))
+	first, ok := a.TryAcquire(0)
+	if !ok {
+		t.Fatal("first acquire should succeed")
+	}
+	if _, ok := a.TryAcquire(0); ok {
+		t.Fatal("acquire beyond the limit should fail without waiting")
+	}
+	if _, ok := a.TryAcquire(10 * time.Millisecond); ok {
+		t.Fatal("acquire should time out while the permit is held")
+	}
+
+	// 取消等待的请求不占用许可
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error)
+	go func() {
+		_, err := a.Acquire(ctx)
+		canceled <- err
+	}()
+	// 排队的请求按先后顺序获得许可
+	order := make(chan int, 2)
+	var wg sync.WaitGroup
+	for i := 1; i <= 2; i++ {
+		for a.waitersLen() != i {
+			time.Sleep(time.Millisecond)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l, err := a.Acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+			l.OnIgnore()
+		}(i)
+	}
+	for a.waitersLen() != 3 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled acquire = %v", err)
+	}
+
+	first.OnSuccess()
+	first.OnSuccess() // 重复释放无效
+	wg.Wait()
+	if x, y := <-order, <-order; x != 1 || y != 2 {
+		t.Fatalf("order = %d, %d", x, y)
+	}
+	if a.Inflight() != 0 || a.waitersLen() != 0 {
+		t.Fatalf("inflight = %d, waiters = %d", a.Inflight(), a.waitersLen())
+	}
+}
+
+func (a *AdaptiveLimiter) waitersLen() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.waiters.Len()
+}
+
+func TestAIMDLimit(t *testing.T) {
+	l, err := NewAIMDLimit(AIMDConfig{Initial: 10, Min: 5, Max: 12, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		rtt      time.Duration
+		inflight int
+		dropped  bool
+		want     int
+	}{
+		{10 * time.Millisecond, 5, false, 11},
+		{10 * time.Millisecond, 2, false, 11}, // 上限远未用满
+		{10 * time.Millisecond, 6, false, 12},
+		{10 * time.Millisecond, 12, false, 12}, // 不超过Max
+		{10 * time.Millisecond, 12, true, 10},
+		{2 * time.Second, 1, false, 9}, // 超时视为丢弃
+		{time.Second, 9, true, 8},
+		{time.Second, 9, true, 7},
+		{time.Second, 9, true, 6},
+		{time.Second, 9, true, 5},
+		{time.Second, 9, true, 5}, // 不低于Min
+	}
+	for i, s := range steps {
+		l.Update(s.rtt, s.inflight, s.dropped)
+		if got := l.Limit(); got != s.want {
+			t.Fatalf("step %d: limit = %d, want %d", i, got, s.want)
+		}
+	}
+}
+
+func TestConcurrencyLimitValidation(t *testing.T) {
+	if _, err := NewAIMDLimit(AIMDConfig{Min: 10, Max: 5}); err == nil {
+		t.Fatal("min > max should be rejected")
+	}
+	if _, err := NewAIMDLimit(AIMDConfig{BackoffRatio: 1.5}); err == nil {
+		t.Fatal("backoff ratio >= 1 should be rejected")
+	}
+	if _, err := NewVegasLimit(VegasConfig{Smoothing: 2}); err == nil {
+		t.Fatal("smoothing > 1 should be rejected")
+	}
+	if _, err := NewGradient2Limit(Gradient2Config{RTTTolerance: 0.5}); err == nil {
+		t.Fatal("rtt tolerance < 1 should be rejected")
+	}
+	if l, _ := NewVegasLimit(VegasConfig{Initial: 5000}); l.Limit() != 1000 {
+		t.Fatalf("initial limit should be clamped to max, got %d", l.Limit())
+	}
+}
+
+// simServer 模拟的下游服务
+// 并发不超过capacity时延迟为base，超过后请求排队，延迟按并发数线性增长，超过capacity+queue的请求被丢弃
+type simServer struct {
+	base     time.Duration
+	capacity int
+	queue    int
+}
+
+// simulate 每轮在上限内发出尽可能多的请求(需求远大于容量)，推进时钟后全部完成，返回每轮结束后的上限
+func simulate(clock *fakeClock, a *AdaptiveLimiter, server *simServer, rounds int) []int {
+	const demand = 500
+	limits := make([]int, 0, rounds)
+	for r := 0; r < rounds; r++ {
+		var ls []*Listener
+		for len(ls) < demand {
+			l, ok := a.TryAcquire(0)
+			if !ok {
+				break
+			}
+			ls = append(ls, l)
+		}
+		n := len(ls)
+		rtt := server.base
+		if n > server.capacity {
+			rtt = server.base * time.Duration(n) / time.Duration(server.capacity)
+		}
+		clock.Advance(rtt)
+		for i, l := range ls {
+			if i >= server.capacity+server.queue {
+				l.OnDropped()
+			} else {
+				l.OnSuccess()
+			}
+		}
+		limits = append(limits, a.Limit())
+	}
+	return limits
+}
+
+func meanLimit(limits []int) float64 {
+	sum := 0
+	for _, l := range limits {
+		sum += l
+	}
+	return float64(sum) / float64(len(limits))
+}
+
+// 延迟模拟：上限收敛到下游容量附近，下游变慢时降低，恢复后回升
+func TestAdaptiveLimitSimulation(t *testing.T) {
+	algorithms := []struct {
+		name  string
+		limit func() (ConcurrencyLimit, error)
+	}{
+		{"aimd", func() (ConcurrencyLimit, error) {
+			return NewAIMDLimit(AIMDConfig{Initial: 10, Timeout: 100 * time.Millisecond})
+		}},
+		{"vegas", func() (ConcurrencyLimit, error) { return NewVegasLimit(VegasConfig{Initial: 10}) }},
+		{"gradient2", func() (ConcurrencyLimit, error) { return NewGradient2Limit(Gradient2Config{Initial: 10}) }},
+	}
+	for _, alg := range algorithms {
+		t.Run(alg.name, func(t *testing.T) {
+			clock := useFakeClock(t)
+			limit, err := alg.limit()
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := NewAdaptiveLimiter(limit)
+			server := &simServer{base: 10 * time.Millisecond, capacity: 40, queue: 20}
+
+			healthy := meanLimit(simulate(clock, a, server, 2000)[1500:])
+			t.Logf("healthy: mean limit %.1f", healthy)
+			if healthy < 20 || healthy > 70 {
+				t.Fatalf("healthy limit %.1f should converge near capacity 40", healthy)
+			}
+
+			// 下游容量降到1/4，延迟随之上升
+			server.capacity, server.queue = 10, 5
+			degraded := meanLimit(simulate(clock, a, server, 2000)[1500:])
+			t.Logf("degraded: mean limit %.1f", degraded)
+			if degraded > 25 || degraded > healthy*0.6 {
+				t.Fatalf("degraded limit %.1f should drop toward capacity 10", degraded)
+			}
+
+			server.capacity, server.queue = 40, 20
+			recovered := meanLimit(simulate(clock, a, server, 4000)[3500:])
+			t.Logf("recovered: mean limit %.1f", recovered)
+			if recovered < 20 || recovered < degraded*1.5 {
+				t.Fatalf("recovered limit %.1f should grow back toward capacity 40", recovered)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 基于Store的分布式限流器，多个进程共享同一份配额
+// 每次判定在后端执行一个原子脚本，时间取自后端，n超过容量时不访问后端直接拒绝
+
+// DistributedSlidingWindow 分布式滑动窗口限流器
+type DistributedSlidingWindow struct {
+	store  Store
+	limit  int
+	window time.Duration
+}
+
+// NewDistributedSlidingWindow 创建分布式滑动窗口限流器，每个键window内最多允许limit个请求
+// 后端以毫秒计时，window不能短于1毫秒
+func NewDistributedSlidingWindow(store Store, limit int, window time.Duration) (*DistributedSlidingWindow, error) {
+	if err := checkWindow(window); err != nil {
+		return nil, err
+	}
+	return &DistributedSlidingWindow{store: store, limit: limit, window: window}, nil
+}
+
+// Decide 判定key上的n个请求能否立即通过
+func (d *DistributedSlidingWindow) Decide(ctx context.Context, key string, n int) (Result, error) {
+	if n > d.limit {
+		return Result{Limit: d.limit, RetryAfter: infDuration}, nil
+	}
+	return d.store.SlidingWindow(ctx, key, d.limit, d.window, n)
+}
+
+// DistributedTokenBucket 分布式令牌桶
+type DistributedTokenBucket struct {
+	store    Store
+	capacity int
+	rate     float64
+}
+
+// NewDistributedTokenBucket 创建分布式令牌桶，容量为capacity，每秒补充rate个令牌
+func NewDistributedTokenBucket(store Store, capacity int, rate float64) *DistributedTokenBucket {
+	return &DistributedTokenBucket{store: store, capacity: capacity, rate: rate}
+}
+
+// Decide 判定key上的n个请求能否立即通过
+func (d *DistributedTokenBucket) Decide(ctx context.Context, key string, n int) (Result, error) {
+	if n > d.capacity {
+		return Result{Limit: d.capacity, RetryAfter: infDuration}, nil
+	}
+	return d.store.TokenBucket(ctx, key, d.capacity, d.rate, n)
+}
+
+// DistributedLeakyBucket 分布式漏桶
+type DistributedLeakyBucket struct {
+	store    Store
+	capacity int
+	rate     float64
+}
+
+// NewDistributedLeakyBucket 创建分布式漏桶，容量为capacity，每秒漏出rate个请求
+func NewDistributedLeakyBucket(store Store, capacity int, rate float64) *DistributedLeakyBucket {
+	return &DistributedLeakyBucket{store: store, capacity: capacity, rate: rate}
+}
+
+// Decide 判定key上的n个请求能否立即通过
+func (d *DistributedLeakyBucket) Decide(ctx context.Context, key string, n int) (Result, error) {
+	if n > d.capacity {
+		return Result{Limit: d.capacity, RetryAfter: infDuration}, nil
+	}
+	return d.store.LeakyBucket(ctx, key, d.capacity, d.rate, n)
+}
+
+// DistributedFixedWindow 分布式固定窗口
+type DistributedFixedWindow struct {
+	store  Store
+	limit  int
+	window time.Duration
+}
+
+// NewDistributedFixedWindow 创建分布式固定窗口，每个对齐的window内最多允许limit个请求
+// 后端以毫秒计时，window不能短于1毫秒
+func NewDistributedFixedWindow(store Store, limit int, window time.Duration) (*DistributedFixedWindow, error) {
+	if err := checkWindow(window); err != nil {
+		return nil, err
+	}
+	return &DistributedFixedWindow{store: store, limit: limit, window: window}, nil
+}
+
+// checkWindow 后端按毫秒对齐和计时，短于1毫秒的窗口换算后为0
+func checkWindow(window time.Duration) error {
+	if window < time.Millisecond {
+		return fmt.Errorf("ratelimit: window %v is shorter than 1ms", window)
+	}
+	return nil
+}
+
+// Decide 判定key上的n个请求能否立即通过
+func (d *DistributedFixedWindow) Decide(ctx context.Context, key string, n int) (Result, error) {
+	if n > d.limit {
+		return Result{Limit: d.limit, RetryAfter: infDuration}, nil
+	}
+	return d.store.FixedWindow(ctx, key, d.limit, d.window, n)
+}
+
+// FallbackPolicy 后端不可用时的处理策略
+type FallbackPolicy int
+
+const (
+	FailOpen   FallbackPolicy = iota // 放行请求，优先保证可用性
+	FailClosed                       // 拒绝请求，优先保护下游
+	FailLocal                        // 降级到本地限流器
+)
+
+func (p FallbackPolicy) String() string {
+	switch p {
+	case FailOpen:
+		return "fail-open"
+	case FailClosed:
+		return "fail-closed"
+	case FailLocal:
+		return "fail-local"
+	}
+	return "unknown"
+}
+
+// FallbackConfig 后端降级配置
+type FallbackConfig struct {
+	Policy     FallbackPolicy
+	Local      Decider                     // FailLocal时使用，通常是MemoryStore上的同类限流器，配额按进程数分摊
+	RetryAfter time.Duration               // FailClosed时返回的重试时间，默认1秒
+	OnError    func(key string, err error) // 后端出错时回调，用于日志和告警
+}
+
+// Fallback 在分布式限流器的后端出错时按策略给出判定，不把错误传给调用方
+// 调用方自己的ctx已经结束时仍然返回错误
+type Fallback struct {
+	primary  Decider
+	cfg      FallbackConfig
+	failures atomic.Int64
+}
+
+// NewFallback 为primary包装降级策略
+func NewFallback(primary Decider, cfg FallbackConfig) (*Fallback, error) {
+	if cfg.Policy == FailLocal && cfg.Local == nil {
+		return nil, errors.New("ratelimit: FailLocal requires a local limiter")
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = time.Second
+	}
+	return &Fallback{primary: primary, cfg: cfg}, nil
+}
+
+// Decide 判定key上的n个请求能否立即通过，后端出错时按策略降级
+func (f *Fallback) Decide(ctx context.Context, key string, n int) (Result, error) {
+	res, err := f.primary.Decide(ctx, key, n)
+	if err == nil || ctx.Err() != nil {
+		return res, err
+	}
+	f.failures.Add(1)
+	if f.cfg.OnError != nil {
+		f.cfg.OnError(key, err)
+	}
+	switch f.cfg.Policy {
+	case FailClosed:
+		return Result{Limit: res.Limit, RetryAfter: f.cfg.RetryAfter}, nil
+	case FailLocal:
+		return f.cfg.Local.Decide(ctx, key, n)
+	default:
+		return Result{Allowed: true, Limit: res.Limit, Remaining: res.Limit}, nil
+	}
+}
+
+// Failures 后端出错的次数
+func (f *Fallback) Failures() int64 {
+	return f.failures.Load()
+}
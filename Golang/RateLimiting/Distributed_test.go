@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newDistributedSlidingWindow(t testing.TB, store Store, limit int, window time.Duration) *DistributedSlidingWindow {
+	t.Helper()
+	d, err := NewDistributedSlidingWindow(store, limit, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func newDistributedFixedWindow(t testing.TB, store Store, limit int, window time.Duration) *DistributedFixedWindow {
+	t.Helper()
+	d, err := NewDistributedFixedWindow(store, limit, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// 分布式限流器在MemoryStore上与本地算法给出相同的判定
+func TestDistributedMatchesLocal(t *testing.T) {
+	clock := useFakeClock(t)
+	store := NewMemoryStore()
+	ctx := context.Background()
+	pairs := []struct {
+		name  string
+		dist  Decider
+		local Limiter
+	}{
+		{"token bucket", NewDistributedTokenBucket(store, 5, 10), NewTokenBucket(5, 10)},
+		{"leaky bucket", NewDistributedLeakyBucket(store, 5, 10), NewLeakyBucket(5, 10)},
+		{"fixed window", newDistributedFixedWindow(t, store, 5, time.Second), NewFixedWindowLimiter(1000, 5)},
+	}
+	for _, p := range pairs {
+		for i := 0; i < 200; i++ {
+			clock.Advance(time.Duration(i%7) * 13 * time.Millisecond)
+			n := 1 + i%3
+			res, err := p.dist.Decide(ctx, p.name, n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := p.local.AllowN(n); res.Allowed != want {
+				t.Fatalf("%s step %d: distributed = %v, local = %v", p.name, i, res.Allowed, want)
+			}
+		}
+	}
+}
+
+func TestDistributedResults(t *testing.T) {
+	clock := useFakeClock(t)
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	tb := NewDistributedTokenBucket(store, 4, 2)
+	tb.Decide(ctx, "tb", 4)
+	res, _ := tb.Decide(ctx, "tb", 1)
+	if res.Allowed || res.RetryAfter != 500*time.Millisecond || res.ResetAfter != 2*time.Second {
+		t.Fatalf("token bucket = %+v", res)
+	}
+	if res, _ := tb.Decide(ctx, "tb", 5); res.Allowed || res.RetryAfter != infDuration {
+		t.Fatalf("n > capacity = %+v", res)
+	}
+
+	lb := NewDistributedLeakyBucket(store, 4, 2)
+	lb.Decide(ctx, "lb", 3)
+	res, _ = lb.Decide(ctx, "lb", 2)
+	if res.Allowed || res.Remaining != 1 || res.RetryAfter != 500*time.Millisecond || res.ResetAfter != 1500*time.Millisecond {
+		t.Fatalf("leaky bucket = %+v", res)
+	}
+
+	fw := newDistributedFixedWindow(t, store, 2, time.Second)
+	clock.Advance(250 * time.Millisecond)
+	fw.Decide(ctx, "fw", 2)
+	res, _ = fw.Decide(ctx, "fw", 1)
+	if res.Allowed || res.RetryAfter != 750*time.Millisecond || res.ResetAfter != 750*time.Millisecond {
+		t.Fatalf("fixed window = %+v", res)
+	}
+
+	// 恢复到初始状态的键可以被清理
+	clock.Advance(3 * time.Second)
+	if n := store.Cleanup(); n != 3 || store.Len() != 0 {
+		t.Fatalf("cleanup = %d, Len = %d", n, store.Len())
+	}
+}
+
+// failingStore 模拟不可用的后端
+type failingStore struct{ Store }
+
+var errBackendDown = errors.New("backend down")
+
+func (failingStore) TokenBucket(context.Context, string, int, float64, int) (Result, error) {
+	return Result{Limit: 5}, errBackendDown
+}
+
+func TestFallbackPolicies(t *testing.T) {
+	useFakeClock(t)
+	ctx := context.Background()
+	primary := NewDistributedTokenBucket(failingStore{}, 5, 1)
+
+	var errs []error
+	open, _ := NewFallback(primary, FallbackConfig{Policy: FailOpen, OnError: func(key string, err error) {
+		errs = append(errs, err)
+	}})
+	for i := 0; i < 10; i++ {
+		if res, err := open.Decide(ctx, "k", 1); err != nil || !res.Allowed {
+			t.Fatalf("fail-open = %+v, %v", res, err)
+		}
+	}
+	if open.Failures() != 10 || len(errs) != 10 || !errors.Is(errs[0], errBackendDown) {
+		t.Fatalf("failures = %d, errors = %d", open.Failures(), len(errs))
+	}
+
+	closed, _ := NewFallback(primary, FallbackConfig{Policy: FailClosed, RetryAfter: 2 * time.Second})
+	if res, err := closed.Decide(ctx, "k", 1); err != nil || res.Allowed || res.RetryAfter != 2*time.Second || res.Limit != 5 {
+		t.Fatalf("fail-closed = %+v, %v", res, err)
+	}
+
+	local, _ := NewFallback(primary, FallbackConfig{
+		Policy: FailLocal,
+		Local:  NewDistributedTokenBucket(NewMemoryStore(), 2, 1),
+	})
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if res, _ := local.Decide(ctx, "k", 1); res.Allowed {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Fatalf("fail-local allowed %d, want the local quota of 2", allowed)
+	}
+
+	if _, err := NewFallback(primary, FallbackConfig{Policy: FailLocal}); err == nil {
+		t.Fatal("FailLocal without a local limiter should be rejected")
+	}
+
+	// 调用方的ctx已经结束时返回错误而不是降级
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := open.Decide(canceled, "k", 1); err == nil {
+		t.Fatal("caller cancellation must not be masked")
+	}
+}
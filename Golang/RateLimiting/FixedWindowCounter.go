@@ -1,17 +1,20 @@
-package主干
+package ratelimit
 
 import (
-	"log"
-	"sync/atomic"
+	"context"
+	"sync"
 	"time"
 )
 
 // FixedWindowLimiter 固定窗口限流器结构体
+// 窗口按windowSize对齐，预定(Reserve/Wait)最多可以预定到下一个窗口
 type FixedWindowLimiter struct {
-	windowSize  int64 // 窗口大小（毫秒）
-	maxRequests int32 // 窗口内最大请求数
-	counter     int32 // 当前窗口内的请求数
-	lastWindow  int64 // 上一个窗口的开始时间戳（毫秒）
+	windowSize  int64      // 窗口大小（毫秒）
+	maxRequests int32      // 窗口内最大请求数
+	counter     int32      // 当前窗口内的请求数
+	next        int32      // 已预定到下一个窗口的请求数
+	lastWindow  int64      // 当前窗口的开始时间戳（毫秒）
+	mu          sync.Mutex // 窗口切换和计数需要作为一个整体执行
 }
 
 // NewFixedWindowLimiter 创建一个新的固定窗口限流器
@@ -20,41 +23,114 @@ func NewFixedWindowLimiter(windowSize int64, maxRequests int32) *FixedWindowLimi
 		windowSize:  windowSize,
 		maxRequests: maxRequests,
 		counter:     0,
-		lastWindow:  time.Now().UnixNano() / int64(time.Millisecond),
+		lastWindow:  timeNow().UnixMilli(),
 	}
 }
 
 // Allow 判断是否允许请求通过
 func (f *FixedWindowLimiter) Allow() bool {
-	now := time.Now().UnixNano() / int64(time.Millisecond)
-	lastWindow := atomic.LoadInt64(&f.lastWindow)
+	return f.AllowN(1)
+}
 
-	if now-lastWindow > f.windowSize {
-		// 新窗口开始，重置计数器
-		if atomic.CompareAndSwapInt64(&f.lastWindow, lastWindow, now) {
-			atomic.StoreInt32(&f.counter, 0)
-		}
+// AllowN 判断当前窗口是否还能容纳n个请求
+func (f *FixedWindowLimiter) AllowN(n int) bool {
+	return allowN(f, n)
+}
+
+// Reserve 预定n个请求，当前窗口已满时预定到下一个窗口
+func (f *FixedWindowLimiter) Reserve(n int) *Reservation {
+	return reserveN(f, n)
+}
+
+// Wait 阻塞直到n个请求被允许
+func (f *FixedWindowLimiter) Wait(ctx context.Context, n int) error {
+	return waitN(ctx, f, n)
+}
+
+// Decide 判定当前窗口能否容纳n个请求，返回窗口剩余配额、窗口结束时间和重试时间
+func (f *FixedWindowLimiter) Decide(n int) Result {
+	now := timeNow().UnixMilli()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.advance(now)
+	res := Result{Limit: int(f.maxRequests)}
+	end := f.lastWindow + f.windowSize // 当前窗口的结束时间
+	switch {
+	case int64(n) > int64(f.maxRequests):
+		res.RetryAfter = infDuration
+	case f.counter+int32(n) <= f.maxRequests:
+		f.counter += int32(n)
+		res.Allowed = true
+	case f.next+int32(n) <= f.maxRequests:
+		res.RetryAfter = time.Duration(end-now) * time.Millisecond
+	default:
+		// 下一个窗口已被预定满，再等一个窗口
+		res.RetryAfter = time.Duration(end+f.windowSize-now) * time.Millisecond
+	}
+	res.Remaining = int(f.maxRequests - f.counter)
+	switch {
+	case f.next > 0:
+		res.ResetAfter = time.Duration(end+f.windowSize-now) * time.Millisecond
+	case f.counter > 0:
+		res.ResetAfter = time.Duration(end-now) * time.Millisecond
 	}
+	return res
+}
 
-	for {
-		count := atomic.LoadInt32(&f.counter)
-		if count >= f.maxRequests {
-			log.Println("请求被限流")
-			return false
-		}
-		if atomic.CompareAndSwapInt32(&f.counter, count, count+1) {
-			log.Println("请求通过")
-			return true
-		}
+// advance 切换到now所在的窗口，调用方需持有锁
+func (f *FixedWindowLimiter) advance(now int64) {
+	elapsed := now - f.lastWindow
+	if elapsed < f.windowSize {
+		return
+	}
+	// 新窗口开始，预定到该窗口的请求成为它的计数；跳过了不止一个窗口时预定早已执行完毕
+	if elapsed < 2*f.windowSize {
+		f.counter = f.next
+	} else {
+		f.counter = 0
 	}
+	f.next = 0
+	f.lastWindow = now - elapsed%f.windowSize
 }
 
-func main() {
-	// 这里可以添加测试限流逻辑的代码
-	limiter := NewFixedWindowLimiter(1000, 10) // 创建一个每秒最多 10 个请求的限流器
-	if limiter.Allow() {
-		println("请求通过")
+func (f *FixedWindowLimiter) reserveN(now time.Time, n int, maxWait time.Duration) *Reservation {
+	if int64(n) > int64(f.maxRequests) {
+		return rejected(ErrExceedsBurst)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.advance(now.UnixMilli())
+	count := int32(n)
+	var target int64 // 请求计入的窗口
+	var wait time.Duration
+	switch {
+	case f.counter+count <= f.maxRequests:
+		target = f.lastWindow
+	case f.next+count <= f.maxRequests:
+		target = f.lastWindow + f.windowSize
+		wait = time.UnixMilli(target).Sub(now)
+	default:
+		return rejected(ErrWouldExceedDeadline)
+	}
+	if wait > maxWait {
+		return rejected(ErrWouldExceedDeadline)
+	}
+	if target == f.lastWindow {
+		f.counter += count
 	} else {
-		println("请求被限流")
+		f.next += count
 	}
+	return reserved(now.Add(wait), func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		// 窗口已经过去时无需归还
+		switch target {
+		case f.lastWindow:
+			f.counter -= count
+		case f.lastWindow + f.windowSize:
+			f.next -= count
+		}
+	})
 }
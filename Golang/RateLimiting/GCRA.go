@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Result 一次限流判定的详细结果，可以直接映射为RateLimit-*、Retry-After响应头
+type Result struct {
+	Allowed    bool
+	Limit      int           // 突发容量
+	Remaining  int           // 判定之后还能立即通过的请求数
+	ResetAfter time.Duration // 多久之后恢复满配额
+	RetryAfter time.Duration // 被拒绝时多久之后可以重试，允许时为0
+}
+
+// GCRA 通用信元速率算法(generic cell rate algorithm)，与redis-cell、throttled相同
+// 每个键只保存一个理论到达时间(TAT)：
+// - 每个请求把TAT推后一个发射间隔interval = period / rate
+// - 请求在 now >= TAT + n×interval - burst×interval 时允许，否则拒绝
+// 与令牌桶的突发/速率语义完全一致，但状态只有一个int64，可以用一次CAS无锁更新
+type GCRA struct {
+	interval  int64 // 发射间隔(纳秒)
+	tolerance int64 // 允许提前到达的时长 = burst × interval
+	burst     int
+	tat       atomic.Int64 // 理论到达时间(纳秒)
+}
+
+// NewGCRA 创建GCRA限流器，每period允许rate个请求，最多突发burst个
+func NewGCRA(rate int, period time.Duration, burst int) *GCRA {
+	interval := max(int64(period)/int64(max(rate, 1)), 1)
+	return &GCRA{
+		interval:  interval,
+		tolerance: interval * int64(burst),
+		burst:     burst,
+	}
+}
+
+// Allow 判断是否允许请求通过
+func (g *GCRA) Allow() bool {
+	return g.AllowN(1)
+}
+
+// AllowN 判断是否允许n个请求同时通过
+func (g *GCRA) AllowN(n int) bool {
+	return allowN(g, n)
+}
+
+// Reserve 预定n个请求，返回需要等待的时长
+func (g *GCRA) Reserve(n int) *Reservation {
+	return reserveN(g, n)
+}
+
+// Wait 阻塞直到n个请求被允许
+func (g *GCRA) Wait(ctx context.Context, n int) error {
+	return waitN(ctx, g, n)
+}
+
+// Decide 判定n个请求能否立即通过，返回剩余配额、恢复时间和重试时间
+func (g *GCRA) Decide(n int) Result {
+	for {
+		now := timeNow().UnixNano()
+		old := g.tat.Load()
+		newTat, res := gcraDecide(old, now, g.interval, g.tolerance, g.burst, n)
+		if !res.Allowed || g.tat.CompareAndSwap(old, newTat) {
+			return res
+		}
+	}
+}
+
+// gcraDecide GCRA的判定逻辑，Redis脚本中是同样的计算
+func gcraDecide(tat, now, interval, tolerance int64, burst, n int) (int64, Result) {
+	res := Result{Limit: burst}
+	if n > burst {
+		res.RetryAfter = infDuration
+		return tat, res
+	}
+	tat = max(tat, now)
+	newTat := tat + interval*int64(n)
+	if allowAt := newTat - tolerance; now < allowAt {
+		res.Remaining = int(max((tolerance-(tat-now))/interval, 0))
+		res.ResetAfter = time.Duration(tat - now)
+		res.RetryAfter = time.Duration(allowAt - now)
+		return tat, res
+	}
+	res.Allowed = true
+	res.Remaining = int(max((tolerance-(newTat-now))/interval, 0))
+	res.ResetAfter = time.Duration(newTat - now)
+	return newTat, res
+}
+
+func (g *GCRA) reserveN(now time.Time, n int, maxWait time.Duration) *Reservation {
+	if n > g.burst {
+		return rejected(ErrExceedsBurst)
+	}
+	nowNanos := now.UnixNano()
+	increment := g.interval * int64(n)
+	for {
+		old := g.tat.Load()
+		newTat := max(old, nowNanos) + increment
+		wait := time.Duration(max(newTat-g.tolerance-nowNanos, 0))
+		if wait > maxWait {
+			return rejected(ErrWouldExceedDeadline)
+		}
+		if g.tat.CompareAndSwap(old, newTat) {
+			return reserved(now.Add(wait), func() { g.tat.Add(-increment) })
+		}
+	}
+}
+
+// gcraScript 在Redis中原子执行GCRA判定，时间取自Redis服务器，单位为微秒(Lua数字是double，纳秒会丢失精度)
+// KEYS[1]: 限流键；ARGV: 发射间隔、容忍时长、请求数
+// 返回 {是否允许, 剩余配额, 恢复时间, 重试时间}；Lua默认按%.14g把数字转成字符串，写入时需要按整数格式化
+var gcraScript = redis.NewScript(`
+redis.replicate_commands()
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval * n
+local allow_at = new_tat - tolerance
+if now < allow_at then
+	return {0, math.floor((tolerance - (tat - now)) / interval), tat - now, allow_at - now}
+end
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((tolerance - (new_tat - now)) / interval), new_tat - now, 0}
+`)
+
+// RedisGCRA 基于Redis(或兼容EVAL的服务)的GCRA，多个进程共享同一份配额
+// 每个键只有一个字符串值，空闲后随过期时间自动删除
+type RedisGCRA struct {
+	client    redis.Scripter
+	prefix    string
+	interval  int64 // 发射间隔(微秒)
+	tolerance int64 // 容忍时长(微秒)
+	burst     int
+}
+
+// NewRedisGCRA 创建Redis GCRA限流器，每period允许rate个请求，最多突发burst个，键名加上prefix前缀
+func NewRedisGCRA(client redis.Scripter, prefix string, rate int, period time.Duration, burst int) *RedisGCRA {
+	interval := max(period.Microseconds()/int64(max(rate, 1)), 1)
+	return &RedisGCRA{
+		client:    client,
+		prefix:    prefix,
+		interval:  interval,
+		tolerance: interval * int64(burst),
+		burst:     burst,
+	}
+}
+
+// Decide 判定key上的n个请求能否立即通过
+func (r *RedisGCRA) Decide(ctx context.Context, key string, n int) (Result, error) {
+	res := Result{Limit: r.burst}
+	if n > r.burst {
+		res.RetryAfter = infDuration
+		return res, nil
+	}
+	vals, err := gcraScript.Run(ctx, r.client, []string{r.prefix + key}, r.interval, r.tolerance, n).Int64Slice()
+	if err != nil {
+		return res, fmt.Errorf("ratelimit: gcra %s: %w", key, err)
+	}
+	return scriptResult(res, key, vals, time.Microsecond)
+}
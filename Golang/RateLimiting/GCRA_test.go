@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGCRADecide(t *testing.T) {
+	clock := useFakeClock(t)
+	g := NewGCRA(10, time.Second, 5)
+
+	res := g.Decide(1)
+	if !res.Allowed || res.Limit != 5 || res.Remaining != 4 || res.ResetAfter != 100*time.Millisecond {
+		t.Fatalf("first = %+v", res)
+	}
+	if res := g.Decide(4); !res.Allowed || res.Remaining != 0 || res.ResetAfter != 500*time.Millisecond {
+		t.Fatalf("burst = %+v", res)
+	}
+	res = g.Decide(1)
+	if res.Allowed || res.RetryAfter != 100*time.Millisecond || res.ResetAfter != 500*time.Millisecond {
+		t.Fatalf("denied = %+v", res)
+	}
+	clock.Advance(res.RetryAfter)
+	if res := g.Decide(1); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("after retry = %+v", res)
+	}
+	if res := g.Decide(6); res.Allowed || res.RetryAfter != infDuration {
+		t.Fatalf("n > burst = %+v", res)
+	}
+	clock.Advance(time.Second)
+	if res := g.Decide(0); res.Remaining != 5 || res.ResetAfter != 0 {
+		t.Fatalf("idle = %+v", res)
+	}
+}
+
+// GCRA与同参数的令牌桶对任意请求序列给出完全相同的判定
+func TestGCRAMatchesTokenBucket(t *testing.T) {
+	clock := useFakeClock(t)
+	g := NewGCRA(10, time.Second, 5)
+	tb := NewTokenBucket(5, 10)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		clock.Advance(time.Duration(r.Intn(120)) * time.Millisecond)
+		n := 1 + r.Intn(3)
+		if got, want := g.AllowN(n), tb.AllowN(n); got != want {
+			t.Fatalf("step %d: gcra = %v, token bucket = %v", i, got, want)
+		}
+	}
+}
+
+// 在按键注册表中每个键只保存一个GCRA
+func TestGCRAKeyed(t *testing.T) {
+	useFakeClock(t)
+	k := newTestKeyed(t, KeyedConfig{New: func(string) Limiter { return NewGCRA(1, time.Minute, 2) }})
+	if !k.AllowN("10.0.0.1", 2) || k.Allow("10.0.0.1") || !k.Allow("10.0.0.2") {
+		t.Fatal("keys must be limited independently")
+	}
+	if res := k.Get("10.0.0.1").(*GCRA).Decide(1); res.RetryAfter != time.Minute {
+		t.Fatalf("retry after = %v", res.RetryAfter)
+	}
+}
+
+func TestRedisGCRA(t *testing.T) {
+	client := redisClient(t)
+	ctx := context.Background()
+	key := "test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	defer client.Del(ctx, "gcra:"+key)
+
+	g := NewRedisGCRA(client, "gcra:", 1, time.Minute, 3)
+	for i := 0; i < 3; i++ {
+		res, err := g.Decide(ctx, key, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("request %d = %+v", i, res)
+		}
+	}
+	res, err := g.Decide(ctx, key, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每分钟1个，突发用完后要等一个发射间隔，与进程内的GCRA一致
+	if res.Allowed || res.RetryAfter <= 59*time.Second || res.RetryAfter > time.Minute {
+		t.Fatalf("denied = %+v", res)
+	}
+	if ttl := client.PTTL(ctx, "gcra:"+key).Val(); ttl <= 0 || ttl > 3*time.Minute {
+		t.Fatalf("ttl = %v", ttl)
+	}
+}
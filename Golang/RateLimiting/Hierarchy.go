@@ -0,0 +1,193 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// QuotaLevel 层级配额中的一层，如全局、租户、用户
+type QuotaLevel struct {
+	Name      string                    // 层名，拒绝时报告
+	New       func(key string) Limiter  // 为该层新出现的键创建限流器
+	Overrides map[string]func() Limiter // 特定键使用的限流器，如大客户租户的专属配额
+	IdleTTL   time.Duration             // 同KeyedConfig.IdleTTL
+	MaxKeys   int                       // 同KeyedConfig.MaxKeys
+}
+
+// HierarchicalLimiter 层级配额限流器，一个请求必须同时通过每一层的配额
+// 判定时依次在每一层预定配额，任何一层需要等待或无法满足时取消已经预定的所有层，被拒绝的请求不消耗任何一层的配额
+// 整个判定持有同一把锁，其他请求看不到中途预定又回滚的配额；全局层本就被所有请求共享，这把锁几乎不增加竞争
+type HierarchicalLimiter struct {
+	levels []*quotaLevel
+	mu     sync.Mutex
+}
+
+type quotaLevel struct {
+	name  string
+	keyed *KeyedLimiter
+
+	mu        sync.RWMutex
+	overrides map[string]func() Limiter
+}
+
+// QuotaReservation 一次层级判定的结果
+// 通过时各层的配额已经扣减，请求没有真正执行(如下游调用之前就失败)时可以Rollback归还
+type QuotaReservation struct {
+	ok         bool
+	err        error
+	level      string
+	retryAfter time.Duration
+	reserved   []*Reservation
+
+	once sync.Once
+}
+
+// NewHierarchicalLimiter 按从上到下的顺序创建层级配额限流器，至少需要一层
+// 判定按levels的顺序进行
+func NewHierarchicalLimiter(levels ...QuotaLevel) (*HierarchicalLimiter, error) {
+	if len(levels) == 0 {
+		return nil, errors.New("ratelimit: hierarchy needs at least one level")
+	}
+	h := &HierarchicalLimiter{}
+	seen := make(map[string]bool, len(levels))
+	for _, cfg := range levels {
+		if cfg.Name == "" || seen[cfg.Name] {
+			h.Close()
+			return nil, fmt.Errorf("ratelimit: quota level name %q is empty or duplicated", cfg.Name)
+		}
+		seen[cfg.Name] = true
+		if cfg.New == nil {
+			h.Close()
+			return nil, fmt.Errorf("ratelimit: quota level %q has no New", cfg.Name)
+		}
+		l := &quotaLevel{name: cfg.Name, overrides: make(map[string]func() Limiter, len(cfg.Overrides))}
+		for key, f := range cfg.Overrides {
+			l.overrides[key] = f
+		}
+		newLimiter := cfg.New
+		keyed, err := NewKeyedLimiter(KeyedConfig{
+			New: func(key string) Limiter {
+				l.mu.RLock()
+				f := l.overrides[key]
+				l.mu.RUnlock()
+				if f != nil {
+					return f()
+				}
+				return newLimiter(key)
+			},
+			IdleTTL: cfg.IdleTTL,
+			MaxKeys: cfg.MaxKeys,
+		})
+		if err != nil {
+			h.Close()
+			return nil, fmt.Errorf("ratelimit: quota level %q: %w", cfg.Name, err)
+		}
+		l.keyed = keyed
+		h.levels = append(h.levels, l)
+	}
+	return h, nil
+}
+
+// Reserve 为n个请求在每一层预定配额，keys依次是每一层的键，数量必须与层数相同
+// 任何一层不能立即满足时整体拒绝，已预定的层全部回滚
+func (h *HierarchicalLimiter) Reserve(n int, keys ...string) *QuotaReservation {
+	if len(keys) != len(h.levels) {
+		return &QuotaReservation{
+			err:        fmt.Errorf("ratelimit: got %d keys for %d quota levels", len(keys), len(h.levels)),
+			retryAfter: infDuration,
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	q := &QuotaReservation{reserved: make([]*Reservation, 0, len(h.levels))}
+	for i, l := range h.levels {
+		r := l.keyed.Reserve(keys[i], n)
+		// 在预定之后取当前时间，立即可用的预定延迟为0
+		if delay := r.Delay(); !r.OK() || delay > 0 {
+			// 先取消本层，再从下往上回滚已经预定的层
+			r.Cancel()
+			q.rollback()
+			q.level = l.name
+			q.err = r.Err()
+			q.retryAfter = delay
+			q.reserved = nil
+			return q
+		}
+		q.reserved = append(q.reserved, r)
+	}
+	q.ok = true
+	return q
+}
+
+// Allow 等价于Reserve(1, keys...)，通过时直接提交
+func (h *HierarchicalLimiter) Allow(keys ...string) *QuotaReservation {
+	q := h.Reserve(1, keys...)
+	q.Commit()
+	return q
+}
+
+// Override 为某一层的键设置专属限流器，newLimiter为nil时取消设置
+// 该键已有的限流器被丢弃，下次请求时按新的设置创建，当前窗口内的用量随之清零
+func (h *HierarchicalLimiter) Override(level, key string, newLimiter func() Limiter) error {
+	for _, l := range h.levels {
+		if l.name != level {
+			continue
+		}
+		l.mu.Lock()
+		if newLimiter == nil {
+			delete(l.overrides, key)
+		} else {
+			l.overrides[key] = newLimiter
+		}
+		l.mu.Unlock()
+		l.keyed.Delete(key)
+		return nil
+	}
+	return fmt.Errorf("ratelimit: unknown quota level %q", level)
+}
+
+// Close 停止各层的后台清理协程
+func (h *HierarchicalLimiter) Close() {
+	for _, l := range h.levels {
+		l.keyed.Close()
+	}
+}
+
+// OK 请求是否通过了所有层
+func (q *QuotaReservation) OK() bool {
+	return q.ok
+}
+
+// RejectedBy 拒绝请求的层名，通过时为空
+func (q *QuotaReservation) RejectedBy() string {
+	return q.level
+}
+
+// Err 拒绝层的预定失败原因(如ErrExceedsBurst)或键数量错误，只是需要等待时为nil
+func (q *QuotaReservation) Err() error {
+	return q.err
+}
+
+// RetryAfter 拒绝层需要等待的时长，永远不可能通过时为infDuration，通过时为0
+// 只反映拒绝层，重试时其他层仍可能拒绝
+func (q *QuotaReservation) RetryAfter() time.Duration {
+	return q.retryAfter
+}
+
+// Commit 确认请求已经执行，之后Rollback无效
+func (q *QuotaReservation) Commit() {
+	q.once.Do(func() {})
+}
+
+// Rollback 请求没有执行，归还所有层的配额；已经Commit时无效
+func (q *QuotaReservation) Rollback() {
+	q.once.Do(q.rollback)
+}
+
+func (q *QuotaReservation) rollback() {
+	for i := len(q.reserved) - 1; i >= 0; i-- {
+		q.reserved[i].Cancel()
+	}
+}
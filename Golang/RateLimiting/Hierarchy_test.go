@@ -0,0 +1,199 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestHierarchy(t *testing.T, levels ...QuotaLevel) *HierarchicalLimiter {
+	h, err := NewHierarchicalLimiter(levels...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+// 全局10、每个租户5、每个用户2，均为每秒恢复1个的令牌桶
+func saasLevels() []QuotaLevel {
+	return []QuotaLevel{
+		{Name: "global", New: func(string) Limiter { return NewTokenBucket(10, 1) }},
+		{Name: "tenant", New: func(string) Limiter { return NewTokenBucket(5, 1) }},
+		{Name: "user", New: func(string) Limiter { return NewTokenBucket(2, 1) }},
+	}
+}
+
+func TestHierarchyRejectingLevel(t *testing.T) {
+	clock := useFakeClock(t)
+	h := newTestHierarchy(t, saasLevels()...)
+
+	q := h.Reserve(3, "all", "acme", "acme/alice")
+	if q.OK() || q.RejectedBy() != "user" || !errors.Is(q.Err(), ErrExceedsBurst) || q.RetryAfter() != infDuration {
+		t.Fatalf("n > user burst = %q, %v, %v", q.RejectedBy(), q.Err(), q.RetryAfter())
+	}
+	for i := 0; i < 2; i++ {
+		if q := h.Allow("all", "acme", "acme/alice"); !q.OK() || q.RejectedBy() != "" {
+			t.Fatalf("request %d rejected by %q", i, q.RejectedBy())
+		}
+	}
+	q = h.Allow("all", "acme", "acme/alice")
+	if q.OK() || q.RejectedBy() != "user" || q.RetryAfter() != time.Second || q.Err() != nil {
+		t.Fatalf("third request = ok %v, level %q, retry %v, err %v", q.OK(), q.RejectedBy(), q.RetryAfter(), q.Err())
+	}
+	// 被用户层拒绝的请求没有消耗租户和全局配额：租户还剩3个
+	for _, user := range []string{"bob", "bob", "carol"} {
+		if q := h.Allow("all", "acme", "acme/"+user); !q.OK() {
+			t.Fatalf("%s rejected by %q", user, q.RejectedBy())
+		}
+	}
+	if q := h.Allow("all", "acme", "acme/dave"); q.RejectedBy() != "tenant" {
+		t.Fatalf("sixth acme request rejected by %q, want tenant", q.RejectedBy())
+	}
+	// 全局还剩5个
+	for i := 0; i < 5; i++ {
+		tenant := fmt.Sprintf("t%d", i)
+		if q := h.Allow("all", tenant, tenant+"/u"); !q.OK() {
+			t.Fatalf("tenant %s rejected by %q", tenant, q.RejectedBy())
+		}
+	}
+	if q := h.Allow("all", "t9", "t9/u"); q.RejectedBy() != "global" {
+		t.Fatalf("request after global exhaustion rejected by %q, want global", q.RejectedBy())
+	}
+
+	clock.Advance(time.Second)
+	if q := h.Allow("all", "t9", "t9/u"); !q.OK() {
+		t.Fatalf("after refill rejected by %q", q.RejectedBy())
+	}
+	if q := h.Allow("all", "acme"); q.OK() || q.Err() == nil {
+		t.Fatal("wrong number of keys should be rejected")
+	}
+}
+
+// 回滚归还所有层的配额，提交之后回滚无效
+func TestHierarchyRollback(t *testing.T) {
+	useFakeClock(t)
+	h := newTestHierarchy(t, saasLevels()...)
+
+	q := h.Reserve(2, "all", "acme", "acme/alice")
+	if !q.OK() {
+		t.Fatal("reserve should succeed")
+	}
+	q.Rollback()
+	q.Rollback()
+	q = h.Reserve(2, "all", "acme", "acme/alice")
+	if !q.OK() {
+		t.Fatalf("quota should be returned by rollback, rejected by %q", q.RejectedBy())
+	}
+	q.Commit()
+	q.Rollback()
+	if q := h.Allow("all", "acme", "acme/alice"); q.OK() {
+		t.Fatal("rollback after commit must not return quota")
+	}
+}
+
+func TestHierarchyTenantOverrides(t *testing.T) {
+	useFakeClock(t)
+	levels := saasLevels()
+	levels[1].Overrides = map[string]func() Limiter{
+		"bigcorp": func() Limiter { return NewTokenBucket(8, 1) },
+	}
+	levels[2].New = func(string) Limiter { return NewTokenBucket(100, 1) }
+	h := newTestHierarchy(t, levels...)
+
+	allowed := func(tenant string) int {
+		n := 0
+		for i := 0; i < 20; i++ {
+			if h.Allow("all", tenant, tenant+"/u").OK() {
+				n++
+			}
+		}
+		return n
+	}
+	if n := allowed("bigcorp"); n != 8 {
+		t.Fatalf("bigcorp allowed %d, want its override of 8", n)
+	}
+
+	// 运行时调整租户配额
+	if err := h.Override("tenant", "small", func() Limiter { return NewTokenBucket(1, 1) }); err != nil {
+		t.Fatal(err)
+	}
+	if n := allowed("small"); n != 1 {
+		t.Fatalf("small allowed %d, want 1", n)
+	}
+	if err := h.Override("tenant", "small", nil); err != nil {
+		t.Fatal(err)
+	}
+	// 取消覆盖后使用默认配额，受全局剩余的1个限制
+	if n := allowed("small"); n != 1 {
+		t.Fatalf("small allowed %d after reset, want the last global token", n)
+	}
+	if err := h.Override("region", "eu", nil); err == nil {
+		t.Fatal("unknown level should be rejected")
+	}
+}
+
+// 并发请求下任何一层都不会超发，被拒绝的请求不泄漏配额
+func TestHierarchyConcurrent(t *testing.T) {
+	h := newTestHierarchy(t,
+		QuotaLevel{Name: "global", New: func(string) Limiter { return NewSlidingLog(60, time.Hour) }},
+		QuotaLevel{Name: "tenant", New: func(string) Limiter { return NewSlidingLog(20, time.Hour) }},
+		QuotaLevel{Name: "user", New: func(string) Limiter { return NewSlidingLog(3, time.Hour) }},
+	)
+	var allowed int64
+	var mu sync.Mutex
+	perTenant := map[string]int{}
+	perUser := map[string]int{}
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				tenant := fmt.Sprintf("t%d", (g+i)%4)
+				user := fmt.Sprintf("%s/u%d", tenant, i%10)
+				if q := h.Allow("all", tenant, user); q.OK() {
+					atomic.AddInt64(&allowed, 1)
+					mu.Lock()
+					perTenant[tenant]++
+					perUser[user]++
+					mu.Unlock()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	// 4个租户各10个用户，用户层最多30个，租户层最多20个，全局最多60个
+	if allowed != 60 {
+		t.Fatalf("allowed %d, want the global limit of 60", allowed)
+	}
+	for tenant, n := range perTenant {
+		if n > 20 {
+			t.Fatalf("tenant %s allowed %d", tenant, n)
+		}
+	}
+	for user, n := range perUser {
+		if n > 3 {
+			t.Fatalf("user %s allowed %d", user, n)
+		}
+	}
+}
+
+func TestNewHierarchicalLimiterValidation(t *testing.T) {
+	newLimiter := func(string) Limiter { return NewTokenBucket(1, 1) }
+	bad := [][]QuotaLevel{
+		nil,
+		{{Name: "", New: newLimiter}},
+		{{Name: "a", New: newLimiter}, {Name: "a", New: newLimiter}},
+		{{Name: "a"}},
+		{{Name: "a", New: newLimiter, MaxKeys: -1}},
+	}
+	for i, levels := range bad {
+		if _, err := NewHierarchicalLimiter(levels...); err == nil {
+			t.Fatalf("case %d should be rejected", i)
+		}
+	}
+}
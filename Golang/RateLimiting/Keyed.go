@@ -0,0 +1,222 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// KeyedConfig 按键限流配置
+type KeyedConfig struct {
+	New     func(key string) Limiter // 为新出现的键创建限流器，可以使用任意算法
+	Shards  int                      // 分片数，默认16
+	IdleTTL time.Duration            // 键空闲超过该时长后被淘汰，0表示不按时间淘汰
+	MaxKeys int                      // 最多保留的键数，超过时淘汰最久未使用的键，0表示不限制
+}
+
+// KeyedLimiter 按键(用户、IP、API Key等)限流的注册表
+// - 首次访问某个键时通过New惰性创建限流器
+// - 键分布在多个分片中，每个分片独立加锁并维护LRU链表
+// - 空闲超过IdleTTL的键由后台协程定期清理，MaxKeys按分片平均分配，超过时淘汰分片内最久未使用的键
+// 被淘汰的键再次出现时会得到一个全新的限流器，因此IdleTTL应不短于限流器恢复满配额所需的时间
+type KeyedLimiter struct {
+	cfg      KeyedConfig
+	shards   []*keyedShard
+	shardMax int // 每个分片最多保留的键数，0表示不限制
+
+	stopChan  chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup // 等待后台清理协程退出
+}
+
+type keyedShard struct {
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List // 队头是最近使用的键
+}
+
+type keyedEntry struct {
+	key      string
+	limiter  Limiter
+	lastUsed time.Time
+}
+
+// NewKeyedLimiter 创建按键限流注册表，设置了IdleTTL时启动后台清理协程，需要调用Close停止
+func NewKeyedLimiter(cfg KeyedConfig) (*KeyedLimiter, error) {
+	if cfg.New == nil {
+		return nil, errors.New("ratelimit: KeyedConfig.New is required")
+	}
+	if cfg.Shards <= 0 {
+		cfg.Shards = 16
+	}
+	if cfg.MaxKeys < 0 || cfg.IdleTTL < 0 {
+		return nil, errors.New("ratelimit: MaxKeys and IdleTTL must not be negative")
+	}
+	k := &KeyedLimiter{
+		cfg:      cfg,
+		shards:   make([]*keyedShard, cfg.Shards),
+		stopChan: make(chan struct{}),
+	}
+	if cfg.MaxKeys > 0 {
+		k.shardMax = max(1, (cfg.MaxKeys+cfg.Shards-1)/cfg.Shards)
+	}
+	for i := range k.shards {
+		k.shards[i] = &keyedShard{items: make(map[string]*list.Element), lru: list.New()}
+	}
+	if cfg.IdleTTL > 0 {
+		k.wg.Add(1)
+		go k.startCleaner(max(cfg.IdleTTL/2, time.Millisecond))
+	}
+	return k, nil
+}
+
+func (k *KeyedLimiter) getShard(key string) *keyedShard {
+	return k.shards[fnv1a32(key)%uint32(len(k.shards))]
+}
+
+// fnv1a32 FNV-1a哈希，用于把键分配到分片；与Third.go和Cache elimination中的fnv32(FNV-1)不同，先异或再乘
+func fnv1a32(key string) uint32 {
+	hash := uint32(2166136261)
+	const prime32 = uint32(16777619)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= prime32
+	}
+	return hash
+}
+
+// Get 返回键对应的限流器，不存在时创建
+func (k *KeyedLimiter) Get(key string) Limiter {
+	now := timeNow()
+	s := k.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		ent := el.Value.(*keyedEntry)
+		ent.lastUsed = now
+		s.lru.MoveToFront(el)
+		return ent.limiter
+	}
+	ent := &keyedEntry{key: key, limiter: k.cfg.New(key), lastUsed: now}
+	s.items[key] = s.lru.PushFront(ent)
+	if k.shardMax > 0 {
+		for s.lru.Len() > k.shardMax {
+			s.removeElement(s.lru.Back())
+		}
+	}
+	return ent.limiter
+}
+
+// Allow 键对应的限流器是否允许一个请求通过
+func (k *KeyedLimiter) Allow(key string) bool {
+	return k.Get(key).Allow()
+}
+
+// AllowN 键对应的限流器是否允许n个请求通过
+func (k *KeyedLimiter) AllowN(key string, n int) bool {
+	return k.Get(key).AllowN(n)
+}
+
+// Reserve 在键对应的限流器上预定n个请求
+func (k *KeyedLimiter) Reserve(key string, n int) *Reservation {
+	return k.Get(key).Reserve(n)
+}
+
+// Wait 阻塞直到键对应的限流器允许n个请求
+func (k *KeyedLimiter) Wait(ctx context.Context, key string, n int) error {
+	return k.Get(key).Wait(ctx, n)
+}
+
+// Decide 实现Decider，限流器能给出详细结果(所有内置算法都可以)时直接使用
+// 否则通过Reserve得到需要等待的时长并立即取消，此时Limit为0表示容量未知
+func (k *KeyedLimiter) Decide(ctx context.Context, key string, n int) (Result, error) {
+	l := k.Get(key)
+	if d, ok := l.(interface{ Decide(n int) Result }); ok {
+		return d.Decide(n), nil
+	}
+	r := l.Reserve(n)
+	if !r.OK() {
+		return Result{RetryAfter: infDuration}, nil
+	}
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel()
+		return Result{RetryAfter: delay}, nil
+	}
+	return Result{Allowed: true}, nil
+}
+
+// Delete 删除键对应的限流器
+func (k *KeyedLimiter) Delete(key string) bool {
+	s := k.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if ok {
+		s.removeElement(el)
+	}
+	return ok
+}
+
+// Len 当前保留的键数
+func (k *KeyedLimiter) Len() int {
+	n := 0
+	for _, s := range k.shards {
+		s.mu.Lock()
+		n += s.lru.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Cleanup 淘汰空闲超过IdleTTL的键，返回淘汰的数量
+func (k *KeyedLimiter) Cleanup() int {
+	if k.cfg.IdleTTL <= 0 {
+		return 0
+	}
+	deadline := timeNow().Add(-k.cfg.IdleTTL)
+	removed := 0
+	for _, s := range k.shards {
+		s.mu.Lock()
+		// 链表按最近使用排序，从队尾开始直到遇到未过期的键
+		for el := s.lru.Back(); el != nil; el = s.lru.Back() {
+			if el.Value.(*keyedEntry).lastUsed.After(deadline) {
+				break
+			}
+			s.removeElement(el)
+			removed++
+		}
+		s.mu.Unlock()
+	}
+	return removed
+}
+
+// removeElement 删除链表元素及其索引，调用方需持有锁
+func (s *keyedShard) removeElement(el *list.Element) {
+	s.lru.Remove(el)
+	delete(s.items, el.Value.(*keyedEntry).key)
+}
+
+// startCleaner 启动后台清理协程
+func (k *KeyedLimiter) startCleaner(interval time.Duration) {
+	defer k.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			k.Cleanup()
+		case <-k.stopChan:
+			return
+		}
+	}
+}
+
+// Close 停止后台清理协程并等待其退出，可以重复调用
+func (k *KeyedLimiter) Close() {
+	k.closeOnce.Do(func() { close(k.stopChan) })
+	k.wg.Wait()
+}
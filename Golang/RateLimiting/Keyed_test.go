@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestKeyed(t testing.TB, cfg KeyedConfig) *KeyedLimiter {
+	if cfg.New == nil {
+		cfg.New = func(string) Limiter { return NewTokenBucket(2, 1) }
+	}
+	k, err := NewKeyedLimiter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(k.Close)
+	return k
+}
+
+// 每个键拥有独立的限流器
+func TestKeyedLimiterPerKey(t *testing.T) {
+	useFakeClock(t)
+	created := map[string]int{}
+	k := newTestKeyed(t, KeyedConfig{New: func(key string) Limiter {
+		created[key]++
+		return NewSlidingLog(2, time.Second)
+	}})
+	if !k.AllowN("alice", 2) || k.Allow("alice") {
+		t.Fatal("alice should be limited after 2 requests")
+	}
+	if !k.Allow("bob") {
+		t.Fatal("bob must not share alice's quota")
+	}
+	if k.Get("alice") != k.Get("alice") || created["alice"] != 1 {
+		t.Fatal("limiter should be created once per key")
+	}
+	if !k.Delete("alice") || k.Delete("alice") || !k.Allow("alice") {
+		t.Fatal("deleted key should start with a fresh limiter")
+	}
+	if k.Len() != 2 {
+		t.Fatalf("Len = %d", k.Len())
+	}
+}
+
+func TestKeyedLimiterIdleTTL(t *testing.T) {
+	clock := useFakeClock(t)
+	k := newTestKeyed(t, KeyedConfig{IdleTTL: time.Minute})
+	k.Allow("idle")
+	k.Allow("busy")
+	clock.Advance(40 * time.Second)
+	k.Allow("busy")
+	clock.Advance(30 * time.Second)
+	if n := k.Cleanup(); n != 1 || k.Len() != 1 {
+		t.Fatalf("cleanup = %d, Len = %d", n, k.Len())
+	}
+	if k.Delete("idle") {
+		t.Fatal("idle key should have been evicted")
+	}
+}
+
+// 超过MaxKeys时淘汰分片内最久未使用的键
+func TestKeyedLimiterLRUBound(t *testing.T) {
+	useFakeClock(t)
+	k := newTestKeyed(t, KeyedConfig{Shards: 1, MaxKeys: 3})
+	for _, key := range []string{"a", "b", "c"} {
+		k.Allow(key)
+	}
+	k.Allow("a")
+	k.Allow("d")
+	if k.Len() != 3 {
+		t.Fatalf("Len = %d", k.Len())
+	}
+	if k.Delete("b") {
+		t.Fatal("least recently used key should be evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if !k.Delete(key) {
+			t.Fatalf("%s should be kept", key)
+		}
+	}
+}
+
+func TestKeyedLimiterConcurrent(t *testing.T) {
+	useFakeClock(t)
+	k := newTestKeyed(t, KeyedConfig{Shards: 4, MaxKeys: 64, IdleTTL: time.Millisecond})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprint("user:", (g*31+i)%100)
+				k.Allow(key)
+				if i%50 == 0 {
+					k.Cleanup()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if k.Len() > 64 {
+		t.Fatalf("Len = %d exceeds MaxKeys", k.Len())
+	}
+}
+
+func TestKeyedLimiterConfig(t *testing.T) {
+	if _, err := NewKeyedLimiter(KeyedConfig{}); err == nil {
+		t.Fatal("missing factory should be rejected")
+	}
+	k := newTestKeyed(t, KeyedConfig{IdleTTL: time.Millisecond})
+	k.Close()
+	k.Close()
+}
+
+func BenchmarkKeyedLimiterAllow(b *testing.B) {
+	k := newTestKeyed(b, KeyedConfig{New: func(string) Limiter { return NewTokenBucket(1<<30, 1<<30) }})
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprint("ip:", i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k.Allow(keys[i%len(keys)])
+			i++
+		}
+	})
+}
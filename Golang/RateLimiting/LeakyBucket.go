@@ -1,16 +1,17 @@
-package主干
+package ratelimit
 
 import (
-	"fmt"
+	"context"
 	"sync"
 	"time"
 )
 
 // LeakyBucket 定义漏桶结构体，用于实现漏桶算法限流。
+// 水量按经过的时间连续漏出(可以是小数)，避免频繁调用时每次都不足一秒而一直漏不出水。
 type LeakyBucket struct {
 	capacity     int64      // 漏桶的最大容量，即最多能容纳的请求数量。
 	rate         int64      // 漏桶漏水的速率，单位为每秒漏出的请求数量。
-	water        int64      // 当前漏桶中的水量，即已接收但还未处理的请求数量。
+	water        float64    // 当前漏桶中的水量，即已接收但还未处理的请求数量。
 	lastLeakTime time.Time  // 上次漏水的时间戳，用于计算本次需要漏出的水量。
 	mutex        sync.Mutex // 互斥锁，保证在并发环境下对漏桶状态的操作是线程安全的。
 }
@@ -22,53 +23,92 @@ func NewLeakyBucket(capacity, rate int64) *LeakyBucket {
 		capacity:     capacity,
 		rate:         rate,
 		water:        0,
-		lastLeakTime: time.Now(),
+		lastLeakTime: timeNow(),
 	}
 }
 
 // Allow 检查当前请求是否可以通过漏桶。
 // 如果漏桶有足够的空间容纳新请求，则返回 true；否则返回 false。
 func (lb *LeakyBucket) Allow() bool {
-	lb.mutex.Lock()         // 加锁，保证并发安全
-	defer lb.mutex.Unlock() // 函数结束时解锁
+	return lb.AllowN(1)
+}
+
+// AllowN 检查n个请求能否同时进入漏桶。
+func (lb *LeakyBucket) AllowN(n int) bool {
+	return allowN(lb, n)
+}
+
+// Reserve 为n个请求预定漏桶空间，桶满时返回需要等待漏出的时长。
+func (lb *LeakyBucket) Reserve(n int) *Reservation {
+	return reserveN(lb, n)
+}
+
+// Wait 阻塞直到n个请求可以进入漏桶。
+func (lb *LeakyBucket) Wait(ctx context.Context, n int) error {
+	return waitN(ctx, lb, n)
+}
+
+// Decide 判定n个请求能否立即进入漏桶，返回剩余空间、漏空所需时间和重试时间。
+func (lb *LeakyBucket) Decide(n int) Result {
+	now := timeNow()
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	lb.leak(now)
+	res := Result{Limit: int(lb.capacity)}
+	switch {
+	case int64(n) > lb.capacity:
+		res.RetryAfter = infDuration
+	case lb.water+float64(n) <= float64(lb.capacity):
+		lb.water += float64(n)
+		res.Allowed = true
+	default:
+		res.RetryAfter = durationFor(lb.water+float64(n)-float64(lb.capacity), float64(lb.rate))
+	}
+	res.Remaining = int(max(float64(lb.capacity)-lb.water, 0))
+	if lb.water > 0 {
+		res.ResetAfter = durationFor(lb.water, float64(lb.rate))
+	}
+	return res
+}
 
+// leak 按经过的时间漏水，调用方需持有锁。
+func (lb *LeakyBucket) leak(now time.Time) {
 	// 计算从上次漏水到现在经过的时间
-	now := time.Now()
 	elapsed := now.Sub(lb.lastLeakTime).Seconds()
-
-	// 计算这段时间内应该漏出的水量
-	leakedWater := int64(elapsed) * lb.rate
+	if elapsed <= 0 {
+		return
+	}
 
 	// 更新漏桶中的水量，确保水量不会小于 0
-	if leakedWater > lb.water {
-		lb.water = 0
-	} else {
-		lb.water -= leakedWater
-	}
+	lb.water = max(lb.water-elapsed*float64(lb.rate), 0)
 
 	// 更新上次漏水的时间戳
 	lb.lastLeakTime = now
-
-	// 检查是否有足够的空间容纳新请求
-	if lb.water+1 <= lb.capacity {
-		lb.water++ // 有空间，接收新请求，水量加 1
-		return true
-	}
-
-	return false // 没有空间，请求被限流
 }
 
-func main() {
-	// 创建一个容量为 10，速率为每秒 2 个请求的漏桶
-	bucket := NewLeakyBucket(10, 2)
+// reserveN 漏水后检查是否有足够的空间容纳n个请求。
+// 空间不足时先把水加进去(水量可以暂时超过容量)，等待水位漏回容量以内再执行。
+func (lb *LeakyBucket) reserveN(now time.Time, n int, maxWait time.Duration) *Reservation {
+	if int64(n) > lb.capacity {
+		return rejected(ErrExceedsBurst)
+	}
+	lb.mutex.Lock()         // 加锁，保证并发安全
+	defer lb.mutex.Unlock() // 函数结束时解锁
 
-	// 模拟 20 个请求
-	for i := 0; i < 20; i++ {
-		if bucket.Allow() {
-			fmt.Printf("请求 %d 通过\n", i+1)
-		} else {
-			fmt.Printf("请求 %d 被限流\n", i+1)
-		}
-		time.Sleep(200 * time.Millisecond)
+	lb.leak(now)
+	water := lb.water + float64(n)
+	var wait time.Duration
+	if overflow := water - float64(lb.capacity); overflow > 0 {
+		wait = durationFor(overflow, float64(lb.rate))
+	}
+	if wait > maxWait {
+		return rejected(ErrWouldExceedDeadline) // 没有空间，请求被限流
 	}
+	lb.water = water
+	return reserved(now.Add(wait), func() {
+		lb.mutex.Lock()
+		defer lb.mutex.Unlock()
+		lb.water = max(lb.water-float64(n), 0)
+	})
 }
@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// timeNow 当前时间，测试中可以替换为可控时钟
+var timeNow = time.Now
+
+// infDuration 不限制等待时长
+const infDuration = time.Duration(math.MaxInt64)
+
+var (
+	// ErrExceedsBurst 一次请求的数量超过了限流器的容量，无论等多久都不可能被允许
+	ErrExceedsBurst = errors.New("ratelimit: n exceeds limiter burst")
+	// ErrWouldExceedDeadline 需要等待的时间超过了ctx的截止时间
+	ErrWouldExceedDeadline = errors.New("ratelimit: wait would exceed context deadline")
+)
+
+// Limiter 统一的限流器接口
+// 令牌桶、漏桶、固定窗口、滑动窗口等算法都实现该接口，中间件可以通过配置切换算法
+type Limiter interface {
+	// Allow 等价于AllowN(1)
+	Allow() bool
+	// AllowN 立即判定n个请求能否通过，通过时消耗配额，不等待
+	AllowN(n int) bool
+	// Reserve 为n个请求预定配额，返回需要等待的时长；不再需要时可以取消并归还配额
+	Reserve(n int) *Reservation
+	// Wait 阻塞直到n个请求被允许，ctx结束或截止时间不够等待时返回错误且不消耗配额
+	Wait(ctx context.Context, n int) error
+}
+
+// Reservation 一次预定的结果
+type Reservation struct {
+	ok        bool
+	err       error     // 预定失败的原因
+	timeToAct time.Time // 可以执行请求的时间
+	cancel    func()    // 归还配额，由具体算法提供
+
+	once sync.Once
+}
+
+// reserved 创建成功的预定，cancel为nil表示该算法无法归还配额
+func reserved(timeToAct time.Time, cancel func()) *Reservation {
+	return &Reservation{ok: true, timeToAct: timeToAct, cancel: cancel}
+}
+
+// rejected 创建失败的预定
+func rejected(err error) *Reservation {
+	return &Reservation{err: err}
+}
+
+// OK 预定是否成功，失败时Delay没有意义
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Err 预定失败的原因(ErrExceedsBurst或ErrWouldExceedDeadline)，成功时为nil
+func (r *Reservation) Err() error {
+	return r.err
+}
+
+// Delay 从现在起需要等待的时长，0表示可以立即执行，预定失败时返回infDuration
+func (r *Reservation) Delay() time.Duration {
+	return r.DelayFrom(timeNow())
+}
+
+// DelayFrom 从now起需要等待的时长
+func (r *Reservation) DelayFrom(now time.Time) time.Duration {
+	if !r.ok {
+		return infDuration
+	}
+	if d := r.timeToAct.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Cancel 放弃预定并归还配额，重复调用无效
+// 预定的请求已经执行过时不应再取消，否则会让后续请求多得到配额
+func (r *Reservation) Cancel() {
+	if !r.ok || r.cancel == nil {
+		return
+	}
+	r.once.Do(r.cancel)
+}
+
+// reserver 各算法需要实现的核心操作
+// 在now时刻为n个请求预定配额，需要等待的时长超过maxWait时不预定，返回失败的预定
+// Allow、AllowN、Reserve、Wait都由它派生，保证不同算法的语义一致
+type reserver interface {
+	reserveN(now time.Time, n int, maxWait time.Duration) *Reservation
+}
+
+func allowN(r reserver, n int) bool {
+	return r.reserveN(timeNow(), n, 0).OK()
+}
+
+func reserveN(r reserver, n int) *Reservation {
+	return r.reserveN(timeNow(), n, infDuration)
+}
+
+func waitN(ctx context.Context, r reserver, n int) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	now := timeNow()
+	maxWait := infDuration
+	if deadline, ok := ctx.Deadline(); ok {
+		// ctx的截止时间总是真实时间
+		maxWait = time.Until(deadline)
+	}
+	res := r.reserveN(now, n, maxWait)
+	if !res.OK() {
+		return fmt.Errorf("ratelimit: Wait(n=%d): %w", n, res.Err())
+	}
+	delay := res.DelayFrom(now)
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// 放弃等待，把配额还给后面的请求
+		res.Cancel()
+		return ctx.Err()
+	}
+}
+
+// durationFor 以每秒rate的速率产生amount个单位所需的时长
+func durationFor(amount, rate float64) time.Duration {
+	if rate <= 0 {
+		return infDuration
+	}
+	seconds := amount / rate
+	if seconds >= float64(infDuration)/float64(time.Second) {
+		return infDuration
+	}
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
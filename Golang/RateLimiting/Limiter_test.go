@@ -0,0 +1,232 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// useFakeClock 在测试期间用可控时钟替换timeNow，测试结束后恢复
+func useFakeClock(t testing.TB) *fakeClock {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	old := timeNow
+	timeNow = clock.Now
+	t.Cleanup(func() { timeNow = old })
+	return clock
+}
+
+// limiterAlgorithm 参与统一接口测试的限流算法，都配置为每秒4个请求、突发4个
+type limiterAlgorithm struct {
+	name       string
+	newLimiter func() Limiter
+}
+
+var limiterAlgorithms = []limiterAlgorithm{
+	{"token-bucket", func() Limiter { return NewTokenBucket(4, 4) }},
+	{"leaky-bucket", func() Limiter { return NewLeakyBucket(4, 4) }},
+	{"fixed-window", func() Limiter { return NewFixedWindowLimiter(1000, 4) }},
+	{"sliding-log", func() Limiter { return NewSlidingLog(4, time.Second) }},
+	{"sliding-window-counter", func() Limiter { return NewSlidingWindowCounter(4, time.Second) }},
+	{"sliding-window-buckets", func() Limiter { return NewSlidingWindowBuckets(4, time.Second, 10) }},
+	{"gcra", func() Limiter { return NewGCRA(4, time.Second, 4) }},
+}
+
+func TestLimiterInterface(t *testing.T) {
+	for _, a := range limiterAlgorithms {
+		a := a
+		t.Run(a.name, func(t *testing.T) {
+			clock := useFakeClock(t)
+			l := a.newLimiter()
+
+			if !l.AllowN(3) || !l.Allow() {
+				t.Fatal("burst should be allowed")
+			}
+			if l.Allow() || l.AllowN(2) {
+				t.Fatal("limiter should be exhausted")
+			}
+			if r := l.Reserve(5); r.OK() || !errors.Is(r.Err(), ErrExceedsBurst) || r.Delay() != infDuration {
+				t.Fatalf("reserve beyond burst = %v %v", r.OK(), r.Err())
+			}
+
+			r := l.Reserve(1)
+			delay := r.Delay()
+			if !r.OK() || delay <= 0 || delay > 2*time.Second {
+				t.Fatalf("reserve delay = %v, ok = %v", delay, r.OK())
+			}
+			// 取消后配额归还，再次预定得到相同的等待时长
+			r.Cancel()
+			r.Cancel()
+			r2 := l.Reserve(1)
+			if r2.Delay() != delay {
+				t.Fatalf("delay after cancel = %v, want %v", r2.Delay(), delay)
+			}
+			r2.Cancel()
+
+			// 截止时间不够等待时立即返回错误且不消耗配额
+			ctx, cancel := context.WithTimeout(context.Background(), delay/2)
+			defer cancel()
+			if err := l.Wait(ctx, 1); !errors.Is(err, ErrWouldExceedDeadline) {
+				t.Fatalf("Wait = %v", err)
+			}
+			if r := l.Reserve(1); r.Delay() != delay {
+				t.Fatalf("Wait must not consume quota, delay = %v", r.Delay())
+			} else {
+				r.Cancel()
+			}
+
+			canceled, stop := context.WithCancel(context.Background())
+			stop()
+			if err := l.Wait(canceled, 1); !errors.Is(err, context.Canceled) {
+				t.Fatalf("Wait on canceled ctx = %v", err)
+			}
+
+			// 滑动窗口计数器在下一个窗口仍按比例计入上一个窗口，因此推进两个窗口
+			clock.Advance(2 * time.Second)
+			if !l.Allow() {
+				t.Fatal("quota should be replenished")
+			}
+			if err := l.Wait(context.Background(), 1); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// 所有内置算法都能给出详细判定结果，RetryAfter和ResetAfter恰好是配额恢复的时间
+func TestLimiterDecide(t *testing.T) {
+	for _, a := range limiterAlgorithms {
+		a := a
+		t.Run(a.name, func(t *testing.T) {
+			clock := useFakeClock(t)
+			l, ok := a.newLimiter().(interface{ Decide(n int) Result })
+			if !ok {
+				t.Fatal("limiter does not implement Decide")
+			}
+
+			if res := l.Decide(3); !res.Allowed || res.Limit != 4 || res.Remaining != 1 || res.ResetAfter <= 0 {
+				t.Fatalf("Decide(3) = %+v", res)
+			}
+			if res := l.Decide(5); res.Allowed || res.RetryAfter != infDuration {
+				t.Fatalf("Decide(5) = %+v", res)
+			}
+			res := l.Decide(2)
+			if res.Allowed || res.Remaining != 1 || res.RetryAfter <= 0 || res.RetryAfter > 2*time.Second {
+				t.Fatalf("Decide(2) = %+v", res)
+			}
+			// 被拒绝的判定不消耗配额
+			if again := l.Decide(2); again.Allowed || again.RetryAfter != res.RetryAfter {
+				t.Fatalf("rejected Decide consumed quota: %+v", again)
+			}
+
+			clock.Advance(res.RetryAfter - time.Millisecond)
+			if res := l.Decide(2); res.Allowed {
+				t.Fatalf("allowed before RetryAfter: %+v", res)
+			}
+			clock.Advance(time.Millisecond)
+			res = l.Decide(2)
+			if !res.Allowed {
+				t.Fatalf("rejected after RetryAfter: %+v", res)
+			}
+			clock.Advance(res.ResetAfter)
+			if res := l.Decide(0); res.Remaining != 4 || res.ResetAfter != 0 {
+				t.Fatalf("after ResetAfter: %+v", res)
+			}
+		})
+	}
+}
+
+// Wait按预定的时长真实等待，ctx结束时归还配额
+func TestLimiterWaitBlocks(t *testing.T) {
+	l := NewSlidingLog(1, 50*time.Millisecond)
+	if err := l.Wait(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := l.Wait(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("Wait returned after %v", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+	if err := l.Wait(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait = %v", err)
+	}
+	l.mu.Lock()
+	n := len(l.log)
+	l.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("canceled wait should return its slot, log has %d entries", n)
+	}
+}
+
+// 固定窗口最多预定到下一个窗口，窗口切换后预定的请求计入新窗口
+func TestFixedWindowReservesNextWindow(t *testing.T) {
+	clock := useFakeClock(t)
+	f := NewFixedWindowLimiter(1000, 2)
+	f.AllowN(2)
+	if r := f.Reserve(2); !r.OK() || r.Delay() != time.Second {
+		t.Fatalf("delay = %v", r.Delay())
+	}
+	if r := f.Reserve(1); r.OK() {
+		t.Fatal("only one window ahead may be reserved")
+	}
+	clock.Advance(time.Second)
+	if f.Allow() {
+		t.Fatal("reserved requests count against the new window")
+	}
+	clock.Advance(time.Second)
+	if !f.AllowN(2) {
+		t.Fatal("next window should be empty")
+	}
+}
+
+func TestLimiterConcurrentAllow(t *testing.T) {
+	useFakeClock(t)
+	for _, a := range limiterAlgorithms {
+		l := a.newLimiter()
+		var passed int64
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					if l.Allow() {
+						atomic.AddInt64(&passed, 1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if passed != 4 {
+			t.Errorf("%s: %d requests passed, want 4", a.name, passed)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyFunc 从请求中提取限流键，返回空字符串的请求共用一个键
+type KeyFunc func(r *http.Request) string
+
+// RouteRule 按路由的限流规则
+type RouteRule struct {
+	Method  string            // 请求方法，空表示任意方法
+	Path    string            // 请求路径，以/结尾时匹配其下所有路径，否则需要完全相等，空表示任意路径
+	Headers map[string]string // 需要匹配的请求头，值为空表示只要求该请求头存在
+	Limiter Decider           // 该路由使用的限流器
+	Key     KeyFunc           // 为空时使用MiddlewareConfig.Key
+	Cost    int               // 每个请求消耗的配额，默认1
+}
+
+// MiddlewareConfig 限流中间件配置
+type MiddlewareConfig struct {
+	Limiter     Decider     // 没有匹配的路由规则时使用，为空表示不限流
+	Key         KeyFunc     // 默认按客户端IP(不信任任何代理)
+	Rules       []RouteRule // 按顺序匹配，第一个匹配的规则生效
+	ExemptKeys  []string    // 不限流的键，如内部服务的IP或API Key
+	ExemptPaths []string    // 不限流的路径，规则同RouteRule.Path，如健康检查
+
+	DeniedBody        []byte       // 429响应体，默认"Too Many Requests"
+	DeniedContentType string       // 429响应的Content-Type，默认text/plain
+	DeniedHandler     http.Handler // 设置后由它生成429响应，限流响应头已写入
+	// OnError 限流器出错时调用，默认放行请求；需要其他降级策略时用Fallback包装限流器
+	OnError func(w http.ResponseWriter, r *http.Request, err error) bool
+}
+
+// Middleware net/http限流中间件
+// 设置RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset响应头，被拒绝时返回429和Retry-After
+type Middleware struct {
+	cfg    MiddlewareConfig
+	exempt map[string]bool
+}
+
+// NewMiddleware 创建限流中间件
+func NewMiddleware(cfg MiddlewareConfig) (*Middleware, error) {
+	if cfg.Key == nil {
+		cfg.Key = RemoteIPKey
+	}
+	for i, rule := range cfg.Rules {
+		if rule.Limiter == nil {
+			return nil, fmt.Errorf("ratelimit: rule %d (%s %s) has no limiter", i, rule.Method, rule.Path)
+		}
+		if rule.Cost <= 0 {
+			cfg.Rules[i].Cost = 1
+		}
+	}
+	if cfg.DeniedBody == nil {
+		cfg.DeniedBody = []byte("Too Many Requests\n")
+	}
+	if cfg.DeniedContentType == "" {
+		cfg.DeniedContentType = "text/plain; charset=utf-8"
+	}
+	m := &Middleware{cfg: cfg, exempt: make(map[string]bool, len(cfg.ExemptKeys))}
+	for _, key := range cfg.ExemptKeys {
+		m.exempt[key] = true
+	}
+	return m, nil
+}
+
+// Handler 用限流包装next
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.allow(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// allow 判定请求并写入响应头，被拒绝时写入429响应
+func (m *Middleware) allow(w http.ResponseWriter, r *http.Request) bool {
+	for _, p := range m.cfg.ExemptPaths {
+		if matchPath(p, r.URL.Path) {
+			return true
+		}
+	}
+	limiter, keyFunc, cost := m.cfg.Limiter, m.cfg.Key, 1
+	for _, rule := range m.cfg.Rules {
+		if rule.matches(r) {
+			limiter, cost = rule.Limiter, rule.Cost
+			if rule.Key != nil {
+				keyFunc = rule.Key
+			}
+			break
+		}
+	}
+	if limiter == nil {
+		return true
+	}
+	key := keyFunc(r)
+	if m.exempt[key] {
+		return true
+	}
+
+	res, err := limiter.Decide(r.Context(), key, cost)
+	if err != nil {
+		if m.cfg.OnError != nil {
+			return m.cfg.OnError(w, r, err)
+		}
+		return true
+	}
+	h := w.Header()
+	if res.Limit > 0 {
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", headerSeconds(res.ResetAfter))
+	}
+	if res.Allowed {
+		return true
+	}
+	if res.RetryAfter != infDuration {
+		h.Set("Retry-After", headerSeconds(res.RetryAfter))
+	}
+	if m.cfg.DeniedHandler != nil {
+		m.cfg.DeniedHandler.ServeHTTP(w, r)
+		return false
+	}
+	h.Set("Content-Type", m.cfg.DeniedContentType)
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write(m.cfg.DeniedBody)
+	return false
+}
+
+// matches 请求是否匹配规则的方法、路径和请求头
+func (rule *RouteRule) matches(r *http.Request) bool {
+	if rule.Method != "" && rule.Method != r.Method {
+		return false
+	}
+	if rule.Path != "" && !matchPath(rule.Path, r.URL.Path) {
+		return false
+	}
+	for name, want := range rule.Headers {
+		got, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !ok || (want != "" && (len(got) == 0 || got[0] != want)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPath pattern以/结尾时按前缀匹配，否则完全匹配
+func matchPath(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(path, pattern)
+	}
+	return pattern == path
+}
+
+// headerSeconds 响应头中的秒数，向上取整，避免客户端过早重试
+func headerSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// RemoteIPKey 按直连的客户端IP限流，不读取任何转发头
+func RemoteIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ClientIPKey 按客户端IP限流，只有直连地址属于可信代理时才读取X-Forwarded-For
+// 从右向左跳过可信代理，第一个不可信的地址就是客户端，客户端自己伪造的靠左的地址不会被采用
+func ClientIPKey(trustedProxies ...string) (KeyFunc, error) {
+	nets := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, p := range trustedProxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("ratelimit: trusted proxy %q: %w", p, err)
+		}
+		nets = append(nets, n)
+	}
+	trusted := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(r *http.Request) string {
+		ip := RemoteIPKey(r)
+		if !trusted(ip) {
+			return ip
+		}
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			ip = hop
+			if !trusted(hop) {
+				break
+			}
+		}
+		return ip
+	}, nil
+}
+
+// HeaderKey 按请求头限流，如X-API-Key
+func HeaderKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// PathKey 按请求路径限流，所有客户端共享同一路径的配额
+func PathKey(r *http.Request) string {
+	return r.URL.Path
+}
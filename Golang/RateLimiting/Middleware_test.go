@@ -0,0 +1,234 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func newTestMiddleware(t *testing.T, cfg MiddlewareConfig) http.Handler {
+	m, err := NewMiddleware(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.Handler(okHandler)
+}
+
+func serve(h http.Handler, method, path, remote string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	r.RemoteAddr = remote
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	clock := useFakeClock(t)
+	h := newTestMiddleware(t, MiddlewareConfig{
+		Limiter:    newDistributedFixedWindow(t, NewMemoryStore(), 2, time.Minute),
+		DeniedBody: []byte(`{"error":"slow down"}`),
+
+		DeniedContentType: "application/json",
+	})
+	clock.Advance(15500 * time.Millisecond)
+
+	w := serve(h, "GET", "/", "10.0.0.1:1234", nil)
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "1" {
+		t.Fatalf("first = %d %v", w.Code, w.Header())
+	}
+	if got := w.Header().Get("RateLimit-Reset"); got != "45" {
+		t.Fatalf("RateLimit-Reset = %q, want 45", got)
+	}
+	serve(h, "GET", "/", "10.0.0.1:1234", nil)
+
+	w = serve(h, "GET", "/", "10.0.0.1:1234", nil)
+	if w.Code != http.StatusTooManyRequests || w.Body.String() != `{"error":"slow down"}` {
+		t.Fatalf("denied = %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/json" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("denied headers = %v", w.Header())
+	}
+	// 重试时间向上取整到秒
+	if got := w.Header().Get("Retry-After"); got != "45" {
+		t.Fatalf("Retry-After = %q, want 45", got)
+	}
+
+	// 不同IP的配额相互独立，端口不影响键
+	if w := serve(h, "GET", "/", "10.0.0.2:1234", nil); w.Code != http.StatusOK {
+		t.Fatalf("other client = %d", w.Code)
+	}
+}
+
+func TestMiddlewareRulesAndExemptions(t *testing.T) {
+	useFakeClock(t)
+	store := NewMemoryStore()
+	h := newTestMiddleware(t, MiddlewareConfig{
+		Limiter: newDistributedSlidingWindow(t, store, 10, time.Minute),
+		Rules: []RouteRule{
+			{Method: "POST", Path: "/login", Limiter: newDistributedSlidingWindow(t, store, 1, time.Minute)},
+			{Path: "/api/", Limiter: NewDistributedTokenBucket(store, 5, 1), Key: HeaderKey("X-API-Key"), Cost: 5},
+		},
+		ExemptPaths: []string{"/healthz"},
+		ExemptKeys:  []string{"10.0.0.9"},
+	})
+	const client = "10.0.0.1:1"
+
+	if serve(h, "POST", "/login", client, nil).Code != http.StatusOK || serve(h, "POST", "/login", client, nil).Code != http.StatusTooManyRequests {
+		t.Fatal("login rule should allow a single attempt")
+	}
+	// 方法不匹配时使用默认限流器
+	if w := serve(h, "GET", "/login", client, nil); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "10" {
+		t.Fatalf("GET /login = %d %v", w.Code, w.Header())
+	}
+	// /login/extra不匹配精确路径
+	if w := serve(h, "POST", "/login/extra", client, nil); w.Header().Get("RateLimit-Limit") != "10" {
+		t.Fatalf("POST /login/extra used %v", w.Header())
+	}
+
+	alice := http.Header{"X-Api-Key": {"alice"}}
+	if serve(h, "GET", "/api/items", client, alice).Code != http.StatusOK {
+		t.Fatal("first api call should pass")
+	}
+	if w := serve(h, "GET", "/api/other", client, alice); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "5" {
+		t.Fatalf("api call with cost 5 = %d %v", w.Code, w.Header())
+	}
+	if serve(h, "GET", "/api/items", client, http.Header{"X-Api-Key": {"bob"}}).Code != http.StatusOK {
+		t.Fatal("api keys must not share quota")
+	}
+
+	for i := 0; i < 20; i++ {
+		if w := serve(h, "GET", "/healthz", client, nil); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("exempt path = %d %v", w.Code, w.Header())
+		}
+		if serve(h, "POST", "/login", "10.0.0.9:1", nil).Code != http.StatusOK {
+			t.Fatal("exempt key should never be limited")
+		}
+	}
+
+	if _, err := NewMiddleware(MiddlewareConfig{Rules: []RouteRule{{Path: "/"}}}); err == nil {
+		t.Fatal("rule without a limiter should be rejected")
+	}
+}
+
+func TestClientIPKey(t *testing.T) {
+	key, err := ClientIPKey("10.0.0.0/8", "192.168.1.1", "::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"direct client ignores header", "203.0.113.7:80", []string{"1.2.3.4"}, "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:80", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed entries on the left", "10.1.2.3:80", []string{"6.6.6.6, 198.51.100.1, 10.9.9.9"}, "198.51.100.1"},
+		{"multiple header lines", "192.168.1.1:80", []string{"6.6.6.6", "198.51.100.2, 10.0.0.5"}, "198.51.100.2"},
+		{"all hops trusted", "10.1.2.3:80", []string{"10.0.0.7, 10.0.0.8"}, "10.0.0.7"},
+		{"no header", "[::1]:80", nil, "::1"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remote
+		r.Header["X-Forwarded-For"] = c.xff
+		if got := key(r); got != c.want {
+			t.Errorf("%s: key = %q, want %q", c.name, got, c.want)
+		}
+	}
+	if _, err := ClientIPKey("not-an-ip"); err == nil {
+		t.Fatal("invalid proxy should be rejected")
+	}
+}
+
+// 任何Limiter都可以通过KeyedLimiter接入中间件
+func TestMiddlewareWithKeyedLimiter(t *testing.T) {
+	clock := useFakeClock(t)
+	keyed := newTestKeyed(t, KeyedConfig{New: func(string) Limiter { return NewTokenBucket(1, 1) }})
+	h := newTestMiddleware(t, MiddlewareConfig{Limiter: keyed, Key: PathKey})
+	if w := serve(h, "GET", "/a", "1.1.1.1:1", nil); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Reset") != "1" {
+		t.Fatalf("first = %d %v", w.Code, w.Header())
+	}
+	w := serve(h, "GET", "/a", "2.2.2.2:1", nil)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("same path = %d %v", w.Code, w.Header())
+	}
+	// 被拒绝的请求没有占用令牌
+	clock.Advance(time.Second)
+	if serve(h, "GET", "/a", "1.1.1.1:1", nil).Code != http.StatusOK {
+		t.Fatal("token should be available after one second")
+	}
+
+	gcra := newTestKeyed(t, KeyedConfig{New: func(string) Limiter { return NewGCRA(2, time.Second, 2) }})
+	h = newTestMiddleware(t, MiddlewareConfig{Limiter: gcra})
+	if w := serve(h, "GET", "/", "1.1.1.1:1", nil); w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "1" {
+		t.Fatalf("gcra headers = %v", w.Header())
+	}
+
+	// 自定义的Limiter不提供Decide时容量未知，不输出RateLimit-*响应头
+	custom := newTestKeyed(t, KeyedConfig{New: func(string) Limiter { return struct{ Limiter }{NewTokenBucket(1, 1)} }})
+	h = newTestMiddleware(t, MiddlewareConfig{Limiter: custom})
+	if w := serve(h, "GET", "/", "1.1.1.1:1", nil); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("custom limiter = %d %v", w.Code, w.Header())
+	}
+	if w := serve(h, "GET", "/", "1.1.1.1:1", nil); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("custom limiter denied = %d %v", w.Code, w.Header())
+	}
+}
+
+// 规则可以按请求头匹配，空路径匹配任意路径
+func TestMiddlewareHeaderRule(t *testing.T) {
+	useFakeClock(t)
+	store := NewMemoryStore()
+	h := newTestMiddleware(t, MiddlewareConfig{
+		Rules: []RouteRule{
+			{Headers: map[string]string{"x-plan": "free"}, Limiter: newDistributedSlidingWindow(t, store, 1, time.Minute)},
+			{Headers: map[string]string{"X-Debug": ""}, Limiter: newDistributedSlidingWindow(t, store, 2, time.Minute)},
+		},
+	})
+	free := http.Header{"X-Plan": {"free"}}
+	if serve(h, "GET", "/a", "1.1.1.1:1", free).Code != http.StatusOK || serve(h, "GET", "/b", "1.1.1.1:1", free).Code != http.StatusTooManyRequests {
+		t.Fatal("free plan should be limited to one request across paths")
+	}
+	if w := serve(h, "GET", "/a", "1.1.1.1:1", http.Header{"X-Plan": {"pro"}}); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("pro plan matched a rule: %v", w.Header())
+	}
+	if w := serve(h, "GET", "/a", "1.1.1.1:1", http.Header{"X-Debug": {"1"}}); w.Header().Get("RateLimit-Limit") != "2" {
+		t.Fatalf("presence-only header rule did not match: %v", w.Header())
+	}
+}
+
+type errDecider struct{}
+
+func (errDecider) Decide(context.Context, string, int) (Result, error) {
+	return Result{}, errBackendDown
+}
+
+func TestMiddlewareLimiterError(t *testing.T) {
+	h := newTestMiddleware(t, MiddlewareConfig{Limiter: errDecider{}})
+	if w := serve(h, "GET", "/", "1.1.1.1:1", nil); w.Code != http.StatusOK {
+		t.Fatalf("default should fail open, got %d", w.Code)
+	}
+
+	var got error
+	h = newTestMiddleware(t, MiddlewareConfig{
+		Limiter: errDecider{},
+		OnError: func(w http.ResponseWriter, r *http.Request, err error) bool {
+			got = err
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return false
+		},
+	})
+	if w := serve(h, "GET", "/", "1.1.1.1:1", nil); w.Code != http.StatusServiceUnavailable || got != errBackendDown {
+		t.Fatalf("OnError = %d, %v", w.Code, got)
+	}
+}
@@ -1,8 +1,12 @@
-package主干
+package ratelimit
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -10,65 +14,222 @@ import (
 
 // Redis实现滑动窗口限流
 // 使用有序集合（ZSet）来管理滑动窗口内的请求
-// 每个请求在ZSet中作为一个成员，分数为请求的时间戳
-var rdb = redis.NewClient(&redis.Options{
-	Addr:     "localhost:6379", // Redis Server
-	Password: "",               // 无密码
-	DB:       0,                // 使用默认数据库
-})
-
-// 窗口大小，单位为秒
-const windowSize = 60
-
-// 窗口内允许的最大请求数
-const limit = 100
-
-// slidingWindowRateLimit 实现滑动窗口限流逻辑
-// key 是用于标识限流的键，不同的API可以使用不同的键
-func slidingWindowRateLimit(key string) bool {
-	// 创建一个上下文，用于控制Redis操作的生命周期
-	ctx := context.Background()
-	// 获取当前时间的时间戳（秒）
-	currentTime := time.Now().Unix()
-	// 计算窗口的起始时间
-	windowStart := currentTime - windowSize
-
-	// 清理过期请求，删除ZSet中分数小于窗口起始时间的成员
-	_, err := rdb.ZRemRangeByScore(ctx, key, "0", fmt.Sprintf("%d", windowStart)).Result()
-	if err != nil {
-		// 打印错误信息，方便调试
-		fmt.Println("Error removing expired requests:", err)
-		return false
-	}
+// 每个请求在ZSet中作为一个成员，分数为请求的毫秒时间戳
+// 清理、计数、判定和写入在同一个Lua脚本中原子执行，先判定再写入，被拒绝的请求不会占用配额
+var slidingWindowScript = redis.NewScript(`
+redis.replicate_commands()
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local member = ARGV[4]
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
 
-	// 累加新请求，将当前时间戳作为成员添加到ZSet中，分数为当前时间戳
-	_, err = rdb.ZAdd(ctx, key, &redis.Z{Score: float64(currentTime), Member: currentTime}).Result()
-	if err != nil {
-		// 打印错误信息，方便调试
-		fmt.Println("Error adding new request:", err)
-		return false
-	}
+-- 清理过期请求，删除ZSet中分数不晚于窗口起始时间的成员
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
 
-	// 统计窗口内的请求数，计算ZSet中分数在窗口起始时间和当前时间之间的成员数量
-	count, err := rdb.ZCount(ctx, key, fmt.Sprintf("%d", windowStart), fmt.Sprintf("%d", currentTime)).Result()
-	if err != nil {
-		// 打印错误信息，方便调试
-		fmt.Println("Error counting requests:", err)
-		return false
+if count + n > limit then
+	local retry = -1
+	if n <= limit then
+		-- 最早的count+n-limit个请求滑出窗口后才放得下
+		local e = redis.call('ZRANGE', key, count + n - limit - 1, count + n - limit - 1, 'WITHSCORES')
+		retry = tonumber(e[2]) + window - now
+	end
+	local reset = 0
+	local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+	if newest[2] then
+		reset = tonumber(newest[2]) + window - now
+	end
+	return {0, limit - count, reset, retry}
+end
+
+-- 成员由客户端前缀和序号组成，同一毫秒内的请求不会合并
+for i = 1, n do
+	redis.call('ZADD', key, now, member .. ':' .. i)
+end
+redis.call('PEXPIRE', key, window)
+return {1, limit - count - n, window, 0}
+`)
+
+// RedisStore 基于Redis的Store实现，每次判定只需一次往返
+type RedisStore struct {
+	client redis.Scripter
+	prefix string // 键名前缀
+
+	member string        // 本实例的随机成员前缀，与序号一起保证ZSet成员唯一
+	seq    atomic.Uint64 // 成员序号
+}
+
+// NewRedisStore 创建Redis存储，client可以是*redis.Client、*redis.ClusterClient等
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	var b [8]byte
+	rand.Read(b[:])
+	return &RedisStore{client: client, prefix: prefix, member: hex.EncodeToString(b[:])}
+}
+
+// SlidingWindow 实现Store
+func (s *RedisStore) SlidingWindow(ctx context.Context, key string, limit int, window time.Duration, n int) (Result, error) {
+	if err := checkWindow(window); err != nil {
+		return Result{Limit: limit}, err
 	}
+	member := s.member + "-" + strconv.FormatUint(s.seq.Add(1), 36)
+	return s.run(ctx, slidingWindowScript, key, time.Millisecond, Result{Limit: limit}, limit, window.Milliseconds(), n, member)
+}
+
+// tokenBucketScript 令牌桶，状态为哈希{tokens, ts}，时间为Redis服务器的微秒时间
+// KEYS[1]: 限流键；ARGV: 容量、每秒补充的令牌数、请求数
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate / 1000000)
+	ts = now
+end
+
+local allowed, retry = 0, 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+elseif rate > 0 then
+	retry = math.ceil((n - tokens) * 1000000 / rate)
+else
+	retry = -1
+end
+local reset = 0
+if rate > 0 then
+	reset = math.ceil((capacity - tokens) * 1000000 / rate)
+end
 
-	// 判断是否超过限制，如果请求数超过允许的最大请求数，则返回false，表示请求被限流
-	if count > int64(limit) {
-		return false
+redis.call('HSET', key, 'tokens', tokens, 'ts', string.format('%d', ts))
+if rate > 0 then
+	-- 补满之后的状态与不存在的键相同
+	redis.call('PEXPIRE', key, math.ceil((capacity - tokens) * 1000 / rate) + 1)
+end
+return {allowed, math.floor(tokens), reset, retry}
+`)
+
+// leakyBucketScript 漏桶，状态为哈希{water, ts}，时间为Redis服务器的微秒时间
+// KEYS[1]: 限流键；ARGV: 容量、每秒漏出的量、请求数
+var leakyBucketScript = redis.NewScript(`
+redis.replicate_commands()
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local state = redis.call('HMGET', key, 'water', 'ts')
+local water = tonumber(state[1]) or 0
+local ts = tonumber(state[2]) or now
+if now > ts then
+	water = math.max(0, water - (now - ts) * rate / 1000000)
+	ts = now
+end
+
+local allowed, retry = 0, 0
+if water + n <= capacity then
+	water = water + n
+	allowed = 1
+elseif rate > 0 then
+	retry = math.ceil((water + n - capacity) * 1000000 / rate)
+else
+	retry = -1
+end
+local reset = 0
+if water > 0 and rate > 0 then
+	reset = math.ceil(water * 1000000 / rate)
+end
+
+redis.call('HSET', key, 'water', water, 'ts', string.format('%d', ts))
+if rate > 0 then
+	-- 漏空之后的状态与不存在的键相同
+	redis.call('PEXPIRE', key, math.ceil(water * 1000 / rate) + 1)
+end
+return {allowed, math.floor(capacity - water), reset, retry}
+`)
+
+// fixedWindowScript 固定窗口，状态为哈希{start, count}，窗口结束时过期，时间为Redis服务器的毫秒时间
+// KEYS[1]: 限流键；ARGV: 上限、窗口长度(毫秒)、请求数
+var fixedWindowScript = redis.NewScript(`
+redis.replicate_commands()
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local start = now - now % window
+local key = KEYS[1]
+local reset = start + window - now
+
+local state = redis.call('HMGET', key, 'start', 'count')
+local count = 0
+if tonumber(state[1]) == start then
+	count = tonumber(state[2])
+end
+if count + n > limit then
+	local retry = reset
+	if n > limit then
+		retry = -1
+	end
+	return {0, limit - count, reset, retry}
+end
+count = count + n
+redis.call('HSET', key, 'start', string.format('%d', start), 'count', count)
+redis.call('PEXPIRE', key, reset)
+return {1, limit - count, reset, 0}
+`)
+
+// TokenBucket 实现Store
+func (s *RedisStore) TokenBucket(ctx context.Context, key string, capacity int, rate float64, n int) (Result, error) {
+	return s.run(ctx, tokenBucketScript, key, time.Microsecond, Result{Limit: capacity}, capacity, rate, n)
+}
+
+// LeakyBucket 实现Store
+func (s *RedisStore) LeakyBucket(ctx context.Context, key string, capacity int, rate float64, n int) (Result, error) {
+	return s.run(ctx, leakyBucketScript, key, time.Microsecond, Result{Limit: capacity}, capacity, rate, n)
+}
+
+// FixedWindow 实现Store
+func (s *RedisStore) FixedWindow(ctx context.Context, key string, limit int, window time.Duration, n int) (Result, error) {
+	if err := checkWindow(window); err != nil {
+		return Result{Limit: limit}, err
 	}
+	return s.run(ctx, fixedWindowScript, key, time.Millisecond, Result{Limit: limit}, limit, window.Milliseconds(), n)
+}
 
-	return true
+// run 执行脚本并转换结果，unit为脚本返回的时长单位
+func (s *RedisStore) run(ctx context.Context, script *redis.Script, key string, unit time.Duration, res Result, args ...interface{}) (Result, error) {
+	vals, err := script.Run(ctx, s.client, []string{s.prefix + key}, args...).Int64Slice()
+	if err != nil {
+		return res, fmt.Errorf("ratelimit: %s: %w", key, err)
+	}
+	return scriptResult(res, key, vals, unit)
 }
 
-func main() {
-	if slidingWindowRateLimit("api_request") {
-		fmt.Println("请求通过")
+// scriptResult 把脚本返回的{是否允许, 剩余配额, 恢复时间, 重试时间}转换为Result，重试时间为-1表示永远不可能通过
+func scriptResult(res Result, key string, vals []int64, unit time.Duration) (Result, error) {
+	if len(vals) != 4 {
+		return res, fmt.Errorf("ratelimit: %s: unexpected reply %v", key, vals)
+	}
+	res.Allowed = vals[0] == 1
+	res.Remaining = int(max(vals[1], 0))
+	res.ResetAfter = time.Duration(vals[2]) * unit
+	if vals[3] < 0 {
+		res.RetryAfter = infDuration
 	} else {
-		fmt.Println("请求被限流")
+		res.RetryAfter = time.Duration(vals[3]) * unit
 	}
+	return res, nil
 }
@@ -0,0 +1,420 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RulesFile 声明式限流规则文件(JSON)
+//
+//	{
+//	  "trusted_proxies": ["10.0.0.0/8"],
+//	  "exempt_paths": ["/healthz"],
+//	  "rules": [
+//	    {"name": "login", "match": {"method": "POST", "path": "/login"},
+//	     "key": "ip", "algorithm": "sliding_window", "rate": 5, "window": "1m"},
+//	    {"name": "api", "match": {"path": "/api/", "headers": {"X-Plan": "free"}},
+//	     "key": "header:X-API-Key", "algorithm": "token_bucket", "rate": 10, "burst": 20}
+//	  ]
+//	}
+type RulesFile struct {
+	TrustedProxies []string `json:"trusted_proxies"` // 键表达式ip信任的代理，可以是IP或CIDR
+	ExemptPaths    []string `json:"exempt_paths"`    // 不限流的路径
+	Rules          []Rule   `json:"rules"`           // 按顺序匹配，第一个匹配的规则生效，没有匹配的请求不限流
+}
+
+// Rule 一条限流规则
+type Rule struct {
+	Name      string       `json:"name"`      // 规则名，必须唯一，重新加载时用于识别未改变的规则
+	Match     RuleMatch    `json:"match"`     // 为空匹配所有请求
+	Key       string       `json:"key"`       // 键表达式，默认ip
+	Algorithm string       `json:"algorithm"` // token_bucket、leaky_bucket、gcra、fixed_window、sliding_window、sliding_log
+	Rate      int          `json:"rate"`      // 每个window允许的请求数
+	Burst     int          `json:"burst"`     // 令牌桶、漏桶、GCRA的容量，默认等于rate；窗口算法不使用
+	Window    RuleDuration `json:"window"`    // 默认1秒
+}
+
+// RuleMatch 规则的匹配条件，与RouteRule相同
+type RuleMatch struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+}
+
+// RuleDuration JSON中写作"500ms"、"1m"等的时长
+type RuleDuration time.Duration
+
+// UnmarshalJSON 实现json.Unmarshaler
+func (d *RuleDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1m\": %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = RuleDuration(v)
+	return nil
+}
+
+// ParseRules 解析并校验规则文件，所有错误一起返回，未知字段视为错误以便发现拼写错误
+func ParseRules(data []byte) (*RulesFile, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var f RulesFile
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("ratelimit: rules: %w", err)
+	}
+	var errs []error
+	if _, err := ClientIPKey(f.TrustedProxies...); err != nil {
+		errs = append(errs, err)
+	}
+	seen := make(map[string]bool, len(f.Rules))
+	for i := range f.Rules {
+		r := &f.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("#%d", i)
+		} else if seen[r.Name] {
+			errs = append(errs, fmt.Errorf("ratelimit: rule %q: duplicate name", r.Name))
+		}
+		seen[r.Name] = true
+		if err := r.normalize(); err != nil {
+			errs = append(errs, fmt.Errorf("ratelimit: rule %q: %w", r.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &f, nil
+}
+
+// normalize 填充默认值并校验
+func (r *Rule) normalize() error {
+	if r.Key == "" {
+		r.Key = "ip"
+	}
+	if _, err := compileKey(r.Key, nil); err != nil {
+		return err
+	}
+	if r.Window == 0 {
+		r.Window = RuleDuration(time.Second)
+	}
+	window := time.Duration(r.Window)
+	if window < time.Millisecond {
+		return fmt.Errorf("window %v is shorter than 1ms", window)
+	}
+	if r.Rate <= 0 {
+		return fmt.Errorf("rate must be positive, got %d", r.Rate)
+	}
+	if r.Burst < 0 {
+		return fmt.Errorf("burst must not be negative, got %d", r.Burst)
+	}
+	switch r.Algorithm {
+	case "token_bucket", "leaky_bucket":
+		// 这两种算法按每秒的整数速率补充
+		if int64(r.Rate)*int64(time.Second)%int64(window) != 0 {
+			return fmt.Errorf("%s needs a whole number of requests per second, %d per %v is not; use gcra", r.Algorithm, r.Rate, window)
+		}
+		fallthrough
+	case "gcra":
+		if r.Burst == 0 {
+			r.Burst = r.Rate
+		}
+	case "fixed_window", "sliding_window", "sliding_log":
+		if r.Burst != 0 {
+			return fmt.Errorf("%s does not use burst", r.Algorithm)
+		}
+		if r.Algorithm == "fixed_window" && window%time.Millisecond != 0 {
+			return fmt.Errorf("fixed_window needs a whole number of milliseconds, got %v", window)
+		}
+	case "":
+		return errors.New("algorithm is required")
+	default:
+		return fmt.Errorf("unknown algorithm %q", r.Algorithm)
+	}
+	return nil
+}
+
+// newLimiter 按规则创建一个键的限流器
+func (r *Rule) newLimiter() Limiter {
+	window := time.Duration(r.Window)
+	switch r.Algorithm {
+	case "token_bucket":
+		return NewTokenBucket(int64(r.Burst), int64(r.Rate)*int64(time.Second)/int64(window))
+	case "leaky_bucket":
+		return NewLeakyBucket(int64(r.Burst), int64(r.Rate)*int64(time.Second)/int64(window))
+	case "gcra":
+		return NewGCRA(r.Rate, window, r.Burst)
+	case "fixed_window":
+		return NewFixedWindowLimiter(window.Milliseconds(), int32(r.Rate))
+	case "sliding_window":
+		return NewSlidingWindowCounter(r.Rate, window)
+	default:
+		return NewSlidingLog(r.Rate, window)
+	}
+}
+
+// state 决定限流状态的部分，相同时重新加载后沿用已有的每键状态；匹配条件的变化不影响状态
+func (r *Rule) state() string {
+	return fmt.Sprintf("%s|%d|%d|%v|%s", r.Algorithm, r.Rate, r.Burst, time.Duration(r.Window), r.Key)
+}
+
+// compileKey 编译键表达式，多个部分用+连接
+// ip(客户端IP，信任trusted_proxies中的代理)、header:名称、path、method、global(所有请求共用一个键)
+func compileKey(expr string, clientIP KeyFunc) (KeyFunc, error) {
+	var parts []KeyFunc
+	for _, part := range strings.Split(expr, "+") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "ip":
+			parts = append(parts, clientIP)
+		case part == "path":
+			parts = append(parts, PathKey)
+		case part == "method":
+			parts = append(parts, func(r *http.Request) string { return r.Method })
+		case part == "global":
+			parts = append(parts, func(*http.Request) string { return "" })
+		case strings.HasPrefix(part, "header:") && len(part) > len("header:"):
+			parts = append(parts, HeaderKey(strings.TrimPrefix(part, "header:")))
+		default:
+			return nil, fmt.Errorf("unknown key expression %q", part)
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return func(r *http.Request) string {
+		vals := make([]string, len(parts))
+		for i, p := range parts {
+			vals[i] = p(r)
+		}
+		return strings.Join(vals, "|")
+	}, nil
+}
+
+// RuleEngineConfig 规则引擎配置
+type RuleEngineConfig struct {
+	Path     string        // 规则文件路径
+	Interval time.Duration // 检查文件修改时间的间隔，默认1秒，负数表示不自动重新加载
+	IdleTTL  time.Duration // 每条规则的键注册表的空闲淘汰时间，同KeyedConfig.IdleTTL
+	MaxKeys  int           // 每条规则最多保留的键数，同KeyedConfig.MaxKeys
+	// Middleware 429响应体、OnError等其余中间件配置，Limiter、Rules、Key和ExemptPaths由规则文件决定
+	Middleware MiddlewareConfig
+	// OnReload 每次因文件变化重新加载后调用，err非空表示新文件无效，仍在使用上一份有效的规则
+	OnReload func(err error)
+}
+
+// RuleEngine 从规则文件编译出的限流中间件，文件修改后自动重新加载
+// - 每条规则编译为一个KeyedLimiter，规则的算法、速率、容量、窗口和键表达式都未改变时沿用原来的注册表和每键状态
+// - 新规则整体编译成功后原子替换，正在处理的请求继续使用旧规则
+// - 新文件无效时报告错误并保留上一份有效的规则
+type RuleEngine struct {
+	cfg     RuleEngineConfig
+	current atomic.Pointer[compiledRules]
+
+	mu      sync.Mutex // 串行化重新加载
+	modTime time.Time  // 最近一次加载的文件修改时间和大小，用于发现变化
+	size    int64
+
+	stopChan  chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// compiledRules 一份生效的规则
+type compiledRules struct {
+	file     *RulesFile
+	mw       *Middleware
+	limiters map[string]*compiledRule // 按规则名
+}
+
+type compiledRule struct {
+	state string
+	keyed *KeyedLimiter
+}
+
+// NewRuleEngine 加载规则文件并创建规则引擎，首次加载失败时返回错误
+// Interval不为负时启动后台轮询协程，需要调用Close停止
+func NewRuleEngine(cfg RuleEngineConfig) (*RuleEngine, error) {
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Second
+	}
+	e := &RuleEngine{cfg: cfg, stopChan: make(chan struct{})}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	if cfg.Interval > 0 {
+		e.wg.Add(1)
+		go e.watch()
+	}
+	return e, nil
+}
+
+// Reload 立即重新加载规则文件，失败时保留当前规则并返回错误
+func (e *RuleEngine) Reload() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	info, err := os.Stat(e.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("ratelimit: rules: %w", err)
+	}
+	// 无论成功与否都记录这次看到的版本，无效的文件在再次修改之前不会被反复加载
+	e.modTime, e.size = info.ModTime(), info.Size()
+	data, err := os.ReadFile(e.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("ratelimit: rules: %w", err)
+	}
+	f, err := ParseRules(data)
+	if err != nil {
+		return err
+	}
+	prev := e.current.Load()
+	next, err := e.compile(f, prev)
+	if err != nil {
+		return err
+	}
+	e.current.Store(next)
+	if prev != nil {
+		for name, c := range prev.limiters {
+			if next.limiters[name] != c {
+				c.keyed.Close()
+			}
+		}
+	}
+	return nil
+}
+
+// compile 编译规则，沿用prev中状态相同的规则的注册表
+func (e *RuleEngine) compile(f *RulesFile, prev *compiledRules) (*compiledRules, error) {
+	clientIP, err := ClientIPKey(f.TrustedProxies...)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiledRules{file: f, limiters: make(map[string]*compiledRule, len(f.Rules))}
+	routes := make([]RouteRule, 0, len(f.Rules))
+	for i := range f.Rules {
+		r := &f.Rules[i]
+		key, err := compileKey(r.Key, clientIP)
+		if err != nil {
+			c.close(prev)
+			return nil, fmt.Errorf("ratelimit: rule %q: %w", r.Name, err)
+		}
+		state := r.state()
+		cr := prev.lookup(r.Name, state)
+		if cr == nil {
+			keyed, err := NewKeyedLimiter(KeyedConfig{
+				New:     func(string) Limiter { return r.newLimiter() },
+				IdleTTL: e.cfg.IdleTTL,
+				MaxKeys: e.cfg.MaxKeys,
+			})
+			if err != nil {
+				c.close(prev)
+				return nil, fmt.Errorf("ratelimit: rule %q: %w", r.Name, err)
+			}
+			cr = &compiledRule{state: state, keyed: keyed}
+		}
+		c.limiters[r.Name] = cr
+		routes = append(routes, RouteRule{
+			Method:  r.Match.Method,
+			Path:    r.Match.Path,
+			Headers: r.Match.Headers,
+			Limiter: cr.keyed,
+			Key:     key,
+		})
+	}
+	mcfg := e.cfg.Middleware
+	mcfg.Limiter, mcfg.Key, mcfg.Rules, mcfg.ExemptPaths = nil, clientIP, routes, f.ExemptPaths
+	mw, err := NewMiddleware(mcfg)
+	if err != nil {
+		c.close(prev)
+		return nil, err
+	}
+	c.mw = mw
+	return c, nil
+}
+
+// lookup 查找名称和状态都相同的已编译规则
+func (c *compiledRules) lookup(name, state string) *compiledRule {
+	if c == nil {
+		return nil
+	}
+	if cr := c.limiters[name]; cr != nil && cr.state == state {
+		return cr
+	}
+	return nil
+}
+
+// close 编译失败时关闭新建的注册表，从prev沿用的保持不变
+func (c *compiledRules) close(prev *compiledRules) {
+	for name, cr := range c.limiters {
+		if prev.lookup(name, cr.state) != cr {
+			cr.keyed.Close()
+		}
+	}
+}
+
+// Handler 用当前生效的规则限流next
+func (e *RuleEngine) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e.current.Load().mw.allow(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Rules 当前生效的规则，调用方不应修改
+func (e *RuleEngine) Rules() *RulesFile {
+	return e.current.Load().file
+}
+
+// watch 定期检查文件的修改时间和大小，变化时重新加载
+func (e *RuleEngine) watch() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !e.changed() {
+				continue
+			}
+			err := e.Reload()
+			if e.cfg.OnReload != nil {
+				e.cfg.OnReload(err)
+			}
+		case <-e.stopChan:
+			return
+		}
+	}
+}
+
+func (e *RuleEngine) changed() bool {
+	info, err := os.Stat(e.cfg.Path)
+	if err != nil {
+		// 文件暂时不存在(如编辑器先删除再写入)时等待下一次检查
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !info.ModTime().Equal(e.modTime) || info.Size() != e.size
+}
+
+// Close 停止轮询并释放各规则的注册表
+func (e *RuleEngine) Close() {
+	e.closeOnce.Do(func() {
+		close(e.stopChan)
+		e.wg.Wait()
+		for _, c := range e.current.Load().limiters {
+			c.keyed.Close()
+		}
+	})
+}
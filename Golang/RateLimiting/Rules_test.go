@@ -0,0 +1,244 @@
+package ratelimit
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRulesValidation(t *testing.T) {
+	cases := []struct {
+		name string
+		json string
+		want []string // 错误信息中应包含的片段
+	}{
+		{"unknown field", `{"rules": [{"name": "a", "algorithm": "gcra", "rate": 1, "brust": 2}]}`, []string{"brust"}},
+		{"bad duration", `{"rules": [{"name": "a", "algorithm": "gcra", "rate": 1, "window": "soon"}]}`, []string{"soon"}},
+		{"numeric duration", `{"rules": [{"name": "a", "algorithm": "gcra", "rate": 1, "window": 60}]}`, []string{"duration"}},
+		{"bad proxy", `{"trusted_proxies": ["nope"], "rules": []}`, []string{"nope"}},
+		{
+			"all rule errors reported together",
+			`{"rules": [
+				{"name": "a", "algorithm": "leaky", "rate": 1},
+				{"name": "b", "algorithm": "gcra", "rate": 0},
+				{"name": "c", "algorithm": "token_bucket", "rate": 1, "window": "3s"},
+				{"name": "d", "algorithm": "sliding_log", "rate": 1, "burst": 5},
+				{"name": "e", "algorithm": "gcra", "rate": 1, "key": "cookie:sid"},
+				{"name": "e", "algorithm": "gcra", "rate": 1},
+				{"algorithm": "fixed_window", "rate": 1, "window": "1500us"},
+				{"name": "h", "rate": 1}
+			]}`,
+			[]string{`"a": unknown algorithm`, `"b": rate`, `"c": token_bucket needs a whole number`, `"d": sliding_log does not use burst`,
+				`"e": unknown key expression`, `"e": duplicate`, `"#6": fixed_window`, `"h": algorithm is required`},
+		},
+	}
+	for _, c := range cases {
+		_, err := ParseRules([]byte(c.json))
+		if err == nil {
+			t.Fatalf("%s: expected an error", c.name)
+		}
+		for _, want := range c.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not mention %q", c.name, err, want)
+			}
+		}
+	}
+
+	f, err := ParseRules([]byte(`{"rules": [{"name": "a", "algorithm": "token_bucket", "rate": 30, "window": "1m"}]}`))
+	if err == nil {
+		t.Fatalf("30 per minute is not a whole number per second, got %+v", f)
+	}
+	f, err = ParseRules([]byte(`{"rules": [{"name": "a", "algorithm": "gcra", "rate": 30, "window": "1m"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := f.Rules[0]; r.Key != "ip" || r.Burst != 30 || time.Duration(r.Window) != time.Minute {
+		t.Fatalf("defaults = %+v", r)
+	}
+}
+
+// 每种算法都能从规则文件编译，rate个请求之后被拒绝，响应带有RateLimit-*头
+func TestRuleAlgorithms(t *testing.T) {
+	for _, alg := range []string{"token_bucket", "leaky_bucket", "gcra", "fixed_window", "sliding_window", "sliding_log"} {
+		t.Run(alg, func(t *testing.T) {
+			useFakeClock(t)
+			e := newTestRuleEngine(t, `{"rules": [{"name": "r", "algorithm": "`+alg+`", "rate": 3}]}`, nil)
+			h := e.Handler(okHandler)
+			for i := 0; i < 3; i++ {
+				w := serve(h, "GET", "/", "1.1.1.1:1", nil)
+				if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "3" || w.Header().Get("RateLimit-Remaining") != strconv.Itoa(2-i) {
+					t.Fatalf("request %d = %d %v", i, w.Code, w.Header())
+				}
+			}
+			if w := serve(h, "GET", "/", "1.1.1.1:1", nil); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+				t.Fatalf("request beyond rate = %d %v", w.Code, w.Header())
+			}
+		})
+	}
+}
+
+func TestRuleMatchAndKeys(t *testing.T) {
+	useFakeClock(t)
+	e := newTestRuleEngine(t, `{
+		"trusted_proxies": ["10.0.0.0/8"],
+		"exempt_paths": ["/healthz"],
+		"rules": [
+			{"name": "free", "match": {"path": "/api/", "headers": {"X-Plan": "free"}},
+			 "key": "header:X-API-Key+path", "algorithm": "sliding_log", "rate": 1, "window": "1m"},
+			{"name": "login", "match": {"method": "POST", "path": "/login"}, "algorithm": "gcra", "rate": 1, "window": "1m"},
+			{"name": "everything", "key": "global", "algorithm": "fixed_window", "rate": 3, "window": "1m"}
+		]
+	}`, nil)
+	h := e.Handler(okHandler)
+
+	free := http.Header{"X-Plan": {"free"}, "X-Api-Key": {"k1"}}
+	if serve(h, "GET", "/api/a", "1.1.1.1:1", free).Code != http.StatusOK || serve(h, "GET", "/api/a", "2.2.2.2:1", free).Code != http.StatusTooManyRequests {
+		t.Fatal("free plan should be limited per API key and path")
+	}
+	if serve(h, "GET", "/api/b", "1.1.1.1:1", free).Code != http.StatusOK {
+		t.Fatal("a different path is a different key")
+	}
+
+	// ip键经过可信代理时取X-Forwarded-For中的客户端
+	xff := http.Header{"X-Forwarded-For": {"198.51.100.1"}}
+	if serve(h, "POST", "/login", "10.0.0.1:1", xff).Code != http.StatusOK || serve(h, "POST", "/login", "10.0.0.2:1", xff).Code != http.StatusTooManyRequests {
+		t.Fatal("login should be limited per forwarded client IP")
+	}
+	if serve(h, "POST", "/login", "10.0.0.1:1", http.Header{"X-Forwarded-For": {"198.51.100.2"}}).Code != http.StatusOK {
+		t.Fatal("another forwarded client has its own quota")
+	}
+
+	// 兜底规则所有请求共用一个键
+	for i, remote := range []string{"1.1.1.1:1", "2.2.2.2:1", "3.3.3.3:1"} {
+		if w := serve(h, "GET", "/other", remote, nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d = %d", i, w.Code)
+		}
+	}
+	if serve(h, "GET", "/other", "4.4.4.4:1", nil).Code != http.StatusTooManyRequests {
+		t.Fatal("global key should be shared by all clients")
+	}
+	if serve(h, "GET", "/healthz", "4.4.4.4:1", nil).Code != http.StatusOK {
+		t.Fatal("exempt path should not be limited")
+	}
+}
+
+func writeRules(t *testing.T, path, content string, version int) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// 显式推进修改时间，不依赖文件系统的时间精度
+	mtime := time.Unix(1700000000+int64(version), 0)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestRuleEngine(t *testing.T, content string, cfg *RuleEngineConfig) *RuleEngine {
+	t.Helper()
+	if cfg == nil {
+		cfg = &RuleEngineConfig{Interval: -1}
+	}
+	cfg.Path = filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, cfg.Path, content, 0)
+	e, err := NewRuleEngine(*cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	return e
+}
+
+func TestRuleEngineHotReload(t *testing.T) {
+	useFakeClock(t)
+	reloaded := make(chan error, 10)
+	cfg := &RuleEngineConfig{Interval: 5 * time.Millisecond, OnReload: func(err error) { reloaded <- err }}
+	const v1 = `{"rules": [
+		{"name": "api", "match": {"path": "/api/"}, "algorithm": "sliding_log", "rate": 2, "window": "1m"},
+		{"name": "login", "match": {"path": "/login"}, "algorithm": "sliding_log", "rate": 1, "window": "1m"}
+	]}`
+	e := newTestRuleEngine(t, v1, cfg)
+	h := e.Handler(okHandler)
+	wait := func() error {
+		t.Helper()
+		select {
+		case err := <-reloaded:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("rules were not reloaded")
+			return nil
+		}
+	}
+	allowed := func(path string) int {
+		n := 0
+		for i := 0; i < 10; i++ {
+			if serve(h, "GET", path, "1.1.1.1:1", nil).Code == http.StatusOK {
+				n++
+			}
+		}
+		return n
+	}
+	if allowed("/api/a") != 2 || allowed("/login") != 1 {
+		t.Fatal("initial rules not applied")
+	}
+
+	// api只改了匹配条件，沿用已有的每键状态；login的速率变了，状态重置
+	writeRules(t, cfg.Path, `{"rules": [
+		{"name": "api", "match": {"path": "/api/", "method": "GET"}, "algorithm": "sliding_log", "rate": 2, "window": "1m"},
+		{"name": "login", "match": {"path": "/login"}, "algorithm": "sliding_log", "rate": 4, "window": "1m"}
+	]}`, 1)
+	if err := wait(); err != nil {
+		t.Fatal(err)
+	}
+	if n := allowed("/api/a"); n != 0 {
+		t.Fatalf("unchanged rule lost its state: %d allowed", n)
+	}
+	if n := allowed("/login"); n != 4 {
+		t.Fatalf("changed rule allowed %d, want 4", n)
+	}
+
+	// 无效的文件报告错误，继续使用上一份有效的规则
+	writeRules(t, cfg.Path, `{"rules": [{"name": "api", "algorithm": "sliding_log", "rate": -1}]}`, 2)
+	if err := wait(); err == nil || !strings.Contains(err.Error(), "rate") {
+		t.Fatalf("invalid rules error = %v", err)
+	}
+	if len(e.Rules().Rules) != 2 || e.Rules().Rules[1].Rate != 4 {
+		t.Fatalf("last good rules replaced: %+v", e.Rules())
+	}
+	if n := allowed("/api/a"); n != 0 {
+		t.Fatalf("state lost after invalid reload: %d allowed", n)
+	}
+	// 无效文件在再次修改之前不会被反复加载
+	select {
+	case err := <-reloaded:
+		t.Fatalf("unchanged invalid file reloaded again: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 删除规则后该路径不再限流
+	writeRules(t, cfg.Path, `{"rules": [{"name": "login", "match": {"path": "/login"}, "algorithm": "sliding_log", "rate": 4, "window": "1m"}]}`, 3)
+	if err := wait(); err != nil {
+		t.Fatal(err)
+	}
+	if n := allowed("/api/a"); n != 10 {
+		t.Fatalf("removed rule still limits: %d allowed", n)
+	}
+	if n := allowed("/login"); n != 0 {
+		t.Fatalf("login state should survive an unrelated change: %d allowed", n)
+	}
+}
+
+func TestNewRuleEngineErrors(t *testing.T) {
+	if _, err := NewRuleEngine(RuleEngineConfig{Path: filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Fatal("missing file should be rejected")
+	}
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, path, `{"rules": [{"name": "a", "algorithm": "magic", "rate": 1}]}`, 0)
+	if _, err := NewRuleEngine(RuleEngineConfig{Path: path}); err == nil || !strings.Contains(err.Error(), "magic") {
+		t.Fatalf("invalid initial rules = %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	LimitQueue   = make(map[string][]int64)
+	limitQueueMu sync.Mutex // 保护LimitQueue
+)
+
+// 单机时间滑动窗口限流法
+// 队列名永远不会从LimitQueue中删除，键的数量不固定时(用户、IP等)应使用KeyedLimiter配合SlidingLog
+func LimitFreqSingle(queueName string, count uint, timeWindow int64) bool {
+	currTime := time.Now().Unix()
+	limitQueueMu.Lock()
+	defer limitQueueMu.Unlock()
+
+	if _, ok := LimitQueue[queueName]; !ok {
+		LimitQueue[queueName] = make([]int64, 0)
+	}
+
+	// 队列未满
+	if uint(len(LimitQueue[queueName])) < count {
+		LimitQueue[queueName] = append(LimitQueue[queueName], currTime)
+		return true
+	}
+
+	// 队列满了，取出最早访问的时间
+	earlyTime := LimitQueue[queueName][0]
+
+	// 说明最早期的时间还在时间窗口内，还没过期，所以不允许通过
+	if currTime-earlyTime <= timeWindow {
+		return false
+	} else {
+		// 说明最早期的访问应该过期了，去掉最早期的
+		LimitQueue[queueName] = LimitQueue[queueName][1:]
+		LimitQueue[queueName] = append(LimitQueue[queueName], currTime)
+	}
+
+	return true
+}
+
+// SlidingLog 滑动日志限流器，记录窗口内每个请求的时间戳
+// 任意长度为window的时间段内通过的请求都不超过limit，精确但内存与limit成正比
+// 预定的请求按可执行时间记入日志，因此日志中可能存在未来的时间戳
+type SlidingLog struct {
+	limit  int
+	window time.Duration
+	log    []time.Time // 按时间升序排列的请求时间戳
+	mu     sync.Mutex
+}
+
+// NewSlidingLog 创建滑动日志限流器，window内最多允许limit个请求
+func NewSlidingLog(limit int, window time.Duration) *SlidingLog {
+	return &SlidingLog{
+		limit:  limit,
+		window: window,
+		log:    make([]time.Time, 0, limit),
+	}
+}
+
+// Allow 判断是否允许请求通过
+func (s *SlidingLog) Allow() bool {
+	return s.AllowN(1)
+}
+
+// AllowN 判断窗口内是否还能容纳n个请求
+func (s *SlidingLog) AllowN(n int) bool {
+	return allowN(s, n)
+}
+
+// Reserve 预定n个请求，窗口已满时等待最早的请求滑出窗口
+func (s *SlidingLog) Reserve(n int) *Reservation {
+	return reserveN(s, n)
+}
+
+// Wait 阻塞直到n个请求被允许
+func (s *SlidingLog) Wait(ctx context.Context, n int) error {
+	return waitN(ctx, s, n)
+}
+
+// Decide 判定窗口内能否再容纳n个请求，返回剩余配额、日志全部滑出的时间和重试时间
+func (s *SlidingLog) Decide(n int) Result {
+	now := timeNow()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+	res := Result{Limit: s.limit}
+	switch over := len(s.log) + n - s.limit; {
+	case n > s.limit:
+		res.RetryAfter = infDuration
+	case over <= 0:
+		s.insert(now, n)
+		res.Allowed = true
+	default:
+		res.RetryAfter = s.log[over-1].Add(s.window).Sub(now)
+	}
+	res.Remaining = max(s.limit-len(s.log), 0)
+	if len(s.log) > 0 {
+		res.ResetAfter = s.log[len(s.log)-1].Add(s.window).Sub(now)
+	}
+	return res
+}
+
+// prune 去掉已经滑出窗口的时间戳，调用方需持有锁
+func (s *SlidingLog) prune(now time.Time) {
+	i := sort.Search(len(s.log), func(i int) bool {
+		return s.log[i].Add(s.window).After(now)
+	})
+	s.log = s.log[i:]
+}
+
+func (s *SlidingLog) reserveN(now time.Time, n int, maxWait time.Duration) *Reservation {
+	if n > s.limit {
+		return rejected(ErrExceedsBurst)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+	timeToAct := now
+	if over := len(s.log) + n - s.limit; over > 0 {
+		// 前over个时间戳滑出窗口后，剩下的请求加上这n个恰好不超过limit
+		timeToAct = s.log[over-1].Add(s.window)
+	}
+	if wait := timeToAct.Sub(now); wait > maxWait {
+		return rejected(ErrWouldExceedDeadline)
+	}
+	s.insert(timeToAct, n)
+	return reserved(timeToAct, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.remove(timeToAct, n)
+	})
+}
+
+// insert 按顺序插入n个时间戳t
+func (s *SlidingLog) insert(t time.Time, n int) {
+	i := sort.Search(len(s.log), func(i int) bool { return s.log[i].After(t) })
+	s.log = append(s.log, make([]time.Time, n)...)
+	copy(s.log[i+n:], s.log[i:])
+	for j := i; j < i+n; j++ {
+		s.log[j] = t
+	}
+}
+
+// remove 删除最多n个等于t的时间戳，已经滑出窗口的不再处理
+func (s *SlidingLog) remove(t time.Time, n int) {
+	i := sort.Search(len(s.log), func(i int) bool { return !s.log[i].Before(t) })
+	j := i
+	for j < len(s.log) && j-i < n && s.log[j].Equal(t) {
+		j++
+	}
+	s.log = append(s.log[:i], s.log[j:]...)
+}
@@ -1,45 +1,356 @@
-package主干
+package ratelimit
 
 import (
-	"fmt"
+	"context"
+	"math"
+	"sync"
 	"time"
 )
 
-var LimitQueue = make(map[string][]int64)
+// SlidingWindowCounter 滑动窗口计数器
+// 只保存上一个窗口和当前窗口的计数，按当前时刻在窗口中的位置对上一个窗口的计数加权插值：
+// 估计值 = 上一窗口计数 × (1 - 当前窗口已过去的比例) + 当前窗口计数
+// 内存固定为O(1)，时间按纳秒计算，临界区只有几次算术运算，锁竞争很低
+type SlidingWindowCounter struct {
+	limit  int64
+	window int64 // 窗口长度(纳秒)
 
-// 单机时间滑动窗口限流法
-func LimitFreqSingle(queueName string, count uint, timeWindow int64) bool {
-	currTime := time.Now().Unix()
+	start int64 // 当前窗口的开始时间(纳秒)，按window对齐
+	prev  int64 // 上一个窗口的计数
+	curr  int64 // 当前窗口的计数
+	next  int64 // 已预定到下一个窗口的计数
+	mu    sync.Mutex
+}
 
-	if _, ok := LimitQueue[queueName]; !ok {
-		LimitQueue[queueName] = make([]int64, 0)
+// NewSlidingWindowCounter 创建滑动窗口计数器，任意window长的时间段内估计最多允许limit个请求
+// window不足1毫秒(包括0和负数)时按1毫秒处理
+func NewSlidingWindowCounter(limit int, window time.Duration) *SlidingWindowCounter {
+	w := int64(max(window, time.Millisecond))
+	return &SlidingWindowCounter{
+		limit:  int64(limit),
+		window: w,
+		start:  timeNow().UnixNano() / w * w,
 	}
+}
+
+// Allow 判断是否允许请求通过
+func (s *SlidingWindowCounter) Allow() bool {
+	return s.AllowN(1)
+}
+
+// AllowN 判断滑动窗口内是否还能容纳n个请求
+func (s *SlidingWindowCounter) AllowN(n int) bool {
+	return allowN(s, n)
+}
+
+// Reserve 预定n个请求，最多预定到下一个窗口
+func (s *SlidingWindowCounter) Reserve(n int) *Reservation {
+	return reserveN(s, n)
+}
 
-	// 队列未满
-	if uint(len(LimitQueue[queueName])) < count {
-		LimitQueue[queueName] = append(LimitQueue[queueName], currTime)
-		return true
+// Wait 阻塞直到n个请求被允许
+func (s *SlidingWindowCounter) Wait(ctx context.Context, n int) error {
+	return waitN(ctx, s, n)
+}
+
+// Decide 判定滑动窗口内能否立即容纳n个请求，返回剩余配额、估计值降为0的时间和重试时间
+func (s *SlidingWindowCounter) Decide(n int) Result {
+	now := timeNow().UnixNano()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.advance(now)
+	res := Result{Limit: int(s.limit)}
+	count := int64(n)
+	if count > s.limit {
+		res.RetryAfter = infDuration
+	} else {
+		// 与reserveN相同的排队规则，下一个窗口也放不下时等它成为上一个窗口
+		var act int64
+		if off := s.fitOffset(s.prev, s.curr, count); s.next == 0 && off < s.window {
+			act = s.start + off
+		} else if off := s.fitOffset(s.curr, s.next, count); off <= s.window {
+			act = s.start + s.window + off
+		} else {
+			act = s.start + 2*s.window + s.fitOffset(s.next, 0, count)
+		}
+		if act <= now {
+			s.curr += count
+			res.Allowed = true
+		} else {
+			res.RetryAfter = time.Duration(act - now)
+		}
 	}
 
-	// 队列满了，取出最早访问的时间
-	earlyTime := LimitQueue[queueName][0]
+	if s.next == 0 {
+		weighted := math.Ceil(float64(s.prev) * float64(s.start+s.window-now) / float64(s.window))
+		res.Remaining = int(max(s.limit-s.curr-int64(weighted), 0))
+	}
+	var end int64 // 所有计数都不再计入估计值的时间
+	switch {
+	case s.next > 0:
+		end = s.start + 3*s.window
+	case s.curr > 0:
+		end = s.start + 2*s.window
+	case s.prev > 0:
+		end = s.start + s.window
+	}
+	res.ResetAfter = time.Duration(max(end-now, 0))
+	return res
+}
 
-	// 说明最早期的时间还在时间窗口内，还没过期，所以不允许通过
-	if currTime-earlyTime <= timeWindow {
-		return false
+// advance 切换到now所在的窗口，调用方需持有锁
+func (s *SlidingWindowCounter) advance(now int64) {
+	windows := (now - s.start) / s.window
+	switch {
+	case windows <= 0:
+		return
+	case windows == 1:
+		s.prev, s.curr = s.curr, s.next
+	case windows == 2:
+		s.prev, s.curr = s.next, 0
+	default:
+		s.prev, s.curr = 0, 0
+	}
+	s.next = 0
+	s.start += windows * s.window
+}
+
+// fitOffset 返回窗口内最早的偏移量，使weight × (1 - 偏移/窗口) + used + n 不超过limit
+// 返回值大于window表示这个窗口内都放不下
+func (s *SlidingWindowCounter) fitOffset(weight, used, n int64) int64 {
+	room := s.limit - used - n
+	if room < 0 {
+		return math.MaxInt64
+	}
+	if weight <= room {
+		return 0
+	}
+	// weight × (window - off) ≤ room × window
+	return s.window - int64(math.Floor(float64(room)*float64(s.window)/float64(weight)))
+}
+
+func (s *SlidingWindowCounter) reserveN(now time.Time, n int, maxWait time.Duration) *Reservation {
+	if int64(n) > s.limit {
+		return rejected(ErrExceedsBurst)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nowNanos := now.UnixNano()
+	s.advance(nowNanos)
+	count := int64(n)
+	target := s.start // 请求计入的窗口
+	act := nowNanos
+	// 已有请求预定到下一个窗口时，新的请求排在它们之后
+	if off := s.fitOffset(s.prev, s.curr, count); s.next == 0 && off < s.window {
+		act = max(s.start+off, nowNanos)
+	} else if off := s.fitOffset(s.curr, s.next, count); off <= s.window {
+		target = s.start + s.window
+		act = target + off
 	} else {
-		// 说明最早期的访问应该过期了，去掉最早期的
-		LimitQueue[queueName] = LimitQueue[queueName][1:]
-		LimitQueue[queueName] = append(LimitQueue[queueName], currTime)
+		return rejected(ErrWouldExceedDeadline)
 	}
+	wait := time.Duration(act - nowNanos)
+	if wait > maxWait {
+		return rejected(ErrWouldExceedDeadline)
+	}
+	if target == s.start {
+		s.curr += count
+	} else {
+		s.next += count
+	}
+	return reserved(now.Add(wait), func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch target {
+		case s.start - s.window:
+			s.prev -= count
+		case s.start:
+			s.curr -= count
+		case s.start + s.window:
+			s.next -= count
+		}
+	})
+}
 
-	return true
+// SlidingWindowBuckets 子窗口桶环滑动窗口
+// 把窗口划分为buckets个等长的子窗口，环形数组中每个桶记录一个子窗口的计数，
+// 窗口内的计数是最近buckets个桶之和；桶越多越接近精确的滑动日志，内存固定为O(buckets)
+// 预定到未来子窗口的请求单独记录在ahead环中，判定时保守地计入，保证任何窗口都不会超过limit
+type SlidingWindowBuckets struct {
+	limit int64
+	width int64 // 每个子窗口的长度(纳秒)
+
+	counts  []int64 // 子窗口(seq-len, seq]的计数，下标为子窗口序号对len取模
+	ahead   []int64 // 预定到子窗口(seq, seq+len]的计数
+	seq     int64   // 当前子窗口序号
+	total   int64   // counts之和
+	pending int64   // ahead之和
+	mu      sync.Mutex
 }
 
-func main() {
-	if LimitFreqSingle("api_request", 5, 10) {
-		fmt.Println("请求通过")
+// NewSlidingWindowBuckets 创建子窗口桶环限流器，window内最多允许limit个请求
+// window按buckets等分，精度为一个子窗口的长度
+func NewSlidingWindowBuckets(limit int, window time.Duration, buckets int) *SlidingWindowBuckets {
+	buckets = max(buckets, 1)
+	width := max(int64(window)/int64(buckets), 1)
+	return &SlidingWindowBuckets{
+		limit:  int64(limit),
+		width:  width,
+		counts: make([]int64, buckets),
+		ahead:  make([]int64, buckets),
+		seq:    timeNow().UnixNano() / width,
+	}
+}
+
+// Allow 判断是否允许请求通过
+func (b *SlidingWindowBuckets) Allow() bool {
+	return b.AllowN(1)
+}
+
+// AllowN 判断滑动窗口内是否还能容纳n个请求
+func (b *SlidingWindowBuckets) AllowN(n int) bool {
+	return allowN(b, n)
+}
+
+// Reserve 预定n个请求，等待最早的子窗口滑出，最多预定一个窗口
+func (b *SlidingWindowBuckets) Reserve(n int) *Reservation {
+	return reserveN(b, n)
+}
+
+// Wait 阻塞直到n个请求被允许
+func (b *SlidingWindowBuckets) Wait(ctx context.Context, n int) error {
+	return waitN(ctx, b, n)
+}
+
+// Decide 判定滑动窗口内能否立即容纳n个请求，返回剩余配额、计数全部滑出的时间和重试时间
+func (b *SlidingWindowBuckets) Decide(n int) Result {
+	now := timeNow().UnixNano()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(now / b.width)
+	res := Result{Limit: int(b.limit)}
+	count := int64(n)
+	size := int64(len(b.counts))
+	resetAt := b.lastSeq() + size // 最后一个有计数的子窗口滑出的时间
+	switch {
+	case count > b.limit:
+		res.RetryAfter = infDuration
+	case b.pending+b.total+count <= b.limit:
+		b.counts[b.seq%size] += count
+		b.total += count
+		resetAt = max(resetAt, b.seq+size)
+		res.Allowed = true
+	default:
+		// 预定占满时保守地等所有计数滑出
+		target := b.fitSeq(count)
+		if target < 0 {
+			target = resetAt
+		}
+		res.RetryAfter = time.Duration(max(target*b.width-now, 0))
+	}
+	res.Remaining = int(max(b.limit-b.total-b.pending, 0))
+	if b.total+b.pending > 0 {
+		res.ResetAfter = time.Duration(max(resetAt*b.width-now, 0))
+	}
+	return res
+}
+
+// fitSeq 子窗口seq-size+k在子窗口seq+k开始时滑出，返回最早放得下count个请求的子窗口序号
+// 一个窗口内都放不下时返回-1，调用方需持有锁
+func (b *SlidingWindowBuckets) fitSeq(count int64) int64 {
+	size := int64(len(b.counts))
+	freed := int64(0)
+	for k := int64(1); k <= size; k++ {
+		freed += b.counts[(b.seq+k)%size]
+		if b.total-freed+b.pending+count <= b.limit {
+			return b.seq + k
+		}
+	}
+	return -1
+}
+
+// lastSeq 最后一个有计数(含预定)的子窗口序号，没有计数时返回seq，调用方需持有锁
+func (b *SlidingWindowBuckets) lastSeq() int64 {
+	size := int64(len(b.counts))
+	for k := size; k >= 1; k-- {
+		if b.ahead[(b.seq+k)%size] != 0 {
+			return b.seq + k
+		}
+	}
+	for k := int64(0); k < size; k++ {
+		if b.counts[(b.seq-k)%size] != 0 {
+			return b.seq - k
+		}
+	}
+	return b.seq
+}
+
+// advance 把环推进到子窗口seq，调用方需持有锁
+// 每推进一格，最老的子窗口滑出，预定到新子窗口的计数转入counts
+func (b *SlidingWindowBuckets) advance(seq int64) {
+	steps := seq - b.seq
+	if steps <= 0 {
+		return
+	}
+	size := int64(len(b.counts))
+	if steps >= 2*size {
+		// 所有计数和预定都已滑出窗口
+		clear(b.counts)
+		clear(b.ahead)
+		b.total, b.pending = 0, 0
+		b.seq = seq
+		return
+	}
+	for i := b.seq + 1; i <= seq; i++ {
+		slot := i % size
+		b.total += b.ahead[slot] - b.counts[slot]
+		b.pending -= b.ahead[slot]
+		b.counts[slot], b.ahead[slot] = b.ahead[slot], 0
+	}
+	b.seq = seq
+}
+
+func (b *SlidingWindowBuckets) reserveN(now time.Time, n int, maxWait time.Duration) *Reservation {
+	if int64(n) > b.limit {
+		return rejected(ErrExceedsBurst)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	nowNanos := now.UnixNano()
+	b.advance(nowNanos / b.width)
+	count := int64(n)
+	size := int64(len(b.counts))
+	target := b.seq // 请求计入的子窗口
+	if b.pending+b.total+count > b.limit {
+		if target = b.fitSeq(count); target < 0 {
+			return rejected(ErrWouldExceedDeadline)
+		}
+	}
+	wait := time.Duration(max(target*b.width-nowNanos, 0))
+	if wait > maxWait {
+		return rejected(ErrWouldExceedDeadline)
+	}
+	if target == b.seq {
+		b.counts[target%size] += count
+		b.total += count
 	} else {
-		fmt.Println("请求被限流")
+		b.ahead[target%size] += count
+		b.pending += count
 	}
+	return reserved(now.Add(wait), func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		switch {
+		case target > b.seq:
+			b.ahead[target%size] -= count
+			b.pending -= count
+		case target > b.seq-size:
+			b.counts[target%size] -= count
+			b.total -= count
+		}
+	})
 }
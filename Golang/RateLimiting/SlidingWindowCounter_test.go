@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// 上一个窗口的计数按剩余比例计入，窗口边界两侧不会出现两倍突发
+func TestSlidingWindowCounterInterpolates(t *testing.T) {
+	clock := useFakeClock(t)
+	s := NewSlidingWindowCounter(10, time.Second)
+	clock.Advance(900 * time.Millisecond)
+	if !s.AllowN(10) {
+		t.Fatal("first window should allow its limit")
+	}
+	clock.Advance(100 * time.Millisecond)
+	if s.Allow() {
+		t.Fatal("previous window still weighs fully at the boundary")
+	}
+	if r := s.Reserve(3); r.Delay() != 300*time.Millisecond {
+		t.Fatalf("delay = %v, want 300ms", r.Delay())
+	} else {
+		r.Cancel()
+	}
+	clock.Advance(500 * time.Millisecond)
+	if !s.AllowN(5) || s.Allow() {
+		t.Fatal("half of the previous window should remain counted")
+	}
+
+	f := NewFixedWindowLimiter(1000, 10)
+	clock.Advance(900 * time.Millisecond)
+	f.AllowN(10)
+	clock.Advance(100 * time.Millisecond)
+	if !f.AllowN(10) {
+		t.Fatal("fixed window allows a second burst right after the boundary")
+	}
+}
+
+// 毫秒级窗口同样精确
+func TestSlidingWindowCounterMillisecondWindow(t *testing.T) {
+	clock := useFakeClock(t)
+	s := NewSlidingWindowCounter(2, 10*time.Millisecond)
+	s.AllowN(2)
+	clock.Advance(15 * time.Millisecond)
+	if !s.Allow() || s.Allow() {
+		t.Fatal("at 5ms into the next window, one request fits")
+	}
+	clock.Advance(10 * time.Millisecond)
+	if !s.Allow() || s.Allow() {
+		t.Fatal("one request counted in the previous window weighs half")
+	}
+}
+
+// 非正数和不足1毫秒的窗口按1毫秒处理，而不是在对齐窗口时除以0
+func TestSlidingWindowCounterClampsWindow(t *testing.T) {
+	clock := useFakeClock(t)
+	for _, window := range []time.Duration{0, -time.Second, time.Microsecond} {
+		s := NewSlidingWindowCounter(1, window)
+		if s.window != int64(time.Millisecond) {
+			t.Fatalf("window %v: got %v", window, time.Duration(s.window))
+		}
+		if !s.Allow() || s.Allow() {
+			t.Fatalf("window %v: limit not applied", window)
+		}
+		clock.Advance(2 * time.Millisecond)
+		if !s.Allow() {
+			t.Fatalf("window %v: window did not slide", window)
+		}
+	}
+}
+
+func TestSlidingWindowCounterQueuesBehindNextWindow(t *testing.T) {
+	clock := useFakeClock(t)
+	s := NewSlidingWindowCounter(4, time.Second)
+	s.AllowN(3)
+	r := s.Reserve(2)
+	if r.Delay() <= time.Second {
+		t.Fatalf("delay = %v", r.Delay())
+	}
+	if s.Allow() {
+		t.Fatal("new requests must queue behind reservations in the next window")
+	}
+	clock.Advance(r.Delay())
+	if s.Allow() {
+		t.Fatal("reserved requests count in their window")
+	}
+}
+
+func TestSlidingWindowBuckets(t *testing.T) {
+	clock := useFakeClock(t)
+	b := NewSlidingWindowBuckets(4, time.Second, 4)
+	b.AllowN(2)
+	clock.Advance(250 * time.Millisecond)
+	b.AllowN(2)
+	clock.Advance(500 * time.Millisecond)
+	if b.Allow() {
+		t.Fatal("window is full")
+	}
+	// 第一个子窗口在1秒时滑出
+	r1 := b.Reserve(1)
+	if r1.Delay() != 250*time.Millisecond {
+		t.Fatalf("delay = %v", r1.Delay())
+	}
+	// 预定保守地计入，需要等前两个子窗口都滑出
+	if r := b.Reserve(2); r.Delay() != 500*time.Millisecond {
+		t.Fatalf("delay = %v", r.Delay())
+	}
+	clock.Advance(250 * time.Millisecond)
+	if b.Allow() {
+		t.Fatal("reservations occupy the freed bucket")
+	}
+	clock.Advance(250 * time.Millisecond)
+	if !b.Allow() || b.Allow() {
+		t.Fatal("window holds 1 + 2 reserved requests, one slot remains")
+	}
+	clock.Advance(time.Second)
+	if !b.AllowN(4) {
+		t.Fatal("window should be empty after a full window")
+	}
+	clock.Advance(time.Hour)
+	if !b.AllowN(4) || b.total != 4 || b.pending != 0 {
+		t.Fatalf("long idle should reset the ring, total = %d", b.total)
+	}
+}
+
+func BenchmarkSlidingWindowCounterAllowParallel(b *testing.B) {
+	s := NewSlidingWindowCounter(1<<30, time.Second)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Allow()
+		}
+	})
+}
+
+func BenchmarkSlidingWindowBucketsAllowParallel(b *testing.B) {
+	s := NewSlidingWindowBuckets(1<<30, time.Second, 16)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Allow()
+		}
+	})
+}
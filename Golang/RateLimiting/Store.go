@@ -0,0 +1,304 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store 分布式限流的存储后端
+// 每次判定必须在后端原子完成(Redis中是一个Lua脚本)，时间取自后端，避免多个进程的时钟偏差
+type Store interface {
+	// SlidingWindow 滑动日志判定：清理滑出window的记录，window内的请求数加上n不超过limit时记录这n个请求
+	// 同一毫秒内的请求各自记录，不会合并；window短于1毫秒时返回错误
+	SlidingWindow(ctx context.Context, key string, limit int, window time.Duration, n int) (Result, error)
+	// TokenBucket 令牌桶判定：按经过的时间以每秒rate个补充令牌(不超过capacity)，令牌足够时扣减n个
+	TokenBucket(ctx context.Context, key string, capacity int, rate float64, n int) (Result, error)
+	// LeakyBucket 漏桶判定：水量按每秒rate连续漏出，加上n之后不超过capacity时放入
+	LeakyBucket(ctx context.Context, key string, capacity int, rate float64, n int) (Result, error)
+	// FixedWindow 固定窗口判定：窗口按window对齐，窗口内的计数加上n不超过limit时计入；window短于1毫秒时返回错误
+	FixedWindow(ctx context.Context, key string, limit int, window time.Duration, n int) (Result, error)
+}
+
+// Decider 按键给出详细判定结果的限流器，RedisGCRA和基于Store的分布式限流器都实现该接口
+type Decider interface {
+	Decide(ctx context.Context, key string, n int) (Result, error)
+}
+
+// MemoryStore 进程内的Store实现，与RedisStore遵守同样的约定
+// 用于测试和单机部署，也可以作为后端不可用时的本地降级
+// 过期的键只有在同一个键再次访问时才会被覆盖，键不断变化时(如按客户端IP限流)
+// 需要调用StartCleanup定期清理，或者由调用方自己定期调用Cleanup
+type MemoryStore struct {
+	mu      sync.Mutex
+	logs    map[string]*memoryLog
+	buckets map[string]*memoryBucket
+	windows map[string]*memoryWindow
+
+	startOnce sync.Once
+	stopChan  chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup // 等待后台清理协程退出
+}
+
+// memoryLog 一个键的滑动日志，时间戳为毫秒并升序排列
+type memoryLog struct {
+	stamps   []int64
+	expireAt int64 // 过期时间(毫秒)，与Redis中键的TTL对应
+}
+
+// memoryBucket 令牌桶或漏桶的状态，时间为微秒，与Redis脚本中的计算一致
+type memoryBucket struct {
+	level    float64 // 令牌桶的可用令牌数或漏桶的水量
+	ts       int64   // 上次更新的时间(微秒)
+	expireAt int64   // 过期时间(毫秒)
+}
+
+// memoryWindow 固定窗口的计数
+type memoryWindow struct {
+	start    int64 // 窗口开始时间(毫秒)
+	count    int
+	expireAt int64 // 过期时间(毫秒)
+}
+
+// NewMemoryStore 创建进程内存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		logs:     make(map[string]*memoryLog),
+		buckets:  make(map[string]*memoryBucket),
+		windows:  make(map[string]*memoryWindow),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// StartCleanup 启动后台协程，每隔interval调用一次Cleanup，需要调用Close停止
+// 重复调用时只有第一次生效
+func (m *MemoryStore) StartCleanup(interval time.Duration) {
+	m.startOnce.Do(func() {
+		m.wg.Add(1)
+		go m.startCleaner(max(interval, time.Millisecond))
+	})
+}
+
+// startCleaner 后台清理协程
+func (m *MemoryStore) startCleaner(interval time.Duration) {
+	defer m.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Cleanup()
+		case <-m.stopChan:
+			return
+		}
+	}
+}
+
+// Close 停止后台清理协程并等待其退出，可以重复调用
+func (m *MemoryStore) Close() {
+	m.closeOnce.Do(func() { close(m.stopChan) })
+	m.wg.Wait()
+}
+
+// SlidingWindow 实现Store
+func (m *MemoryStore) SlidingWindow(ctx context.Context, key string, limit int, window time.Duration, n int) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	if err := checkWindow(window); err != nil {
+		return Result{}, err
+	}
+	now := timeNow().UnixMilli()
+	win := window.Milliseconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log := m.logs[key]
+	if log == nil {
+		log = &memoryLog{}
+		m.logs[key] = log
+	}
+	// 与ZREMRANGEBYSCORE -inf now-window一致：时间戳不晚于now-window的记录滑出
+	i := sort.Search(len(log.stamps), func(i int) bool { return log.stamps[i] > now-win })
+	log.stamps = log.stamps[i:]
+	count := len(log.stamps)
+
+	res := Result{Limit: limit}
+	if count+n > limit {
+		res.Remaining = limit - count
+		if n <= limit {
+			res.RetryAfter = time.Duration(log.stamps[count+n-limit-1]+win-now) * time.Millisecond
+		} else {
+			res.RetryAfter = infDuration
+		}
+		if count > 0 {
+			res.ResetAfter = time.Duration(log.stamps[count-1]+win-now) * time.Millisecond
+		}
+		return res, nil
+	}
+	for j := 0; j < n; j++ {
+		log.stamps = append(log.stamps, now)
+	}
+	log.expireAt = now + win
+	res.Allowed = true
+	res.Remaining = limit - count - n
+	res.ResetAfter = window
+	return res, nil
+}
+
+// bucket 取出key对应的桶并记录本次访问时间，full为新建桶的初始量，调用方需持有锁
+func (m *MemoryStore) bucket(key string, full float64, now int64) *memoryBucket {
+	b := m.buckets[key]
+	if b == nil || b.expireAt <= now/1000 {
+		b = &memoryBucket{level: full, ts: now}
+		m.buckets[key] = b
+	}
+	return b
+}
+
+// TokenBucket 实现Store
+func (m *MemoryStore) TokenBucket(ctx context.Context, key string, capacity int, rate float64, n int) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	now := timeNow().UnixMicro()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := m.bucket(key, float64(capacity), now)
+	if now > b.ts {
+		b.level = min(float64(capacity), b.level+float64(now-b.ts)*rate/1e6)
+		b.ts = now
+	}
+	res := Result{Limit: capacity}
+	if b.level >= float64(n) {
+		b.level -= float64(n)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = microsFor(float64(n)-b.level, rate)
+	}
+	res.Remaining = int(b.level)
+	res.ResetAfter = microsFor(float64(capacity)-b.level, rate)
+	b.expireAt = bucketExpireAt(now, float64(capacity)-b.level, rate)
+	return res, nil
+}
+
+// LeakyBucket 实现Store
+func (m *MemoryStore) LeakyBucket(ctx context.Context, key string, capacity int, rate float64, n int) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	now := timeNow().UnixMicro()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := m.bucket(key, 0, now)
+	if now > b.ts {
+		b.level = max(0, b.level-float64(now-b.ts)*rate/1e6)
+		b.ts = now
+	}
+	res := Result{Limit: capacity}
+	if b.level+float64(n) <= float64(capacity) {
+		b.level += float64(n)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = microsFor(b.level+float64(n)-float64(capacity), rate)
+	}
+	res.Remaining = int(float64(capacity) - b.level)
+	res.ResetAfter = microsFor(b.level, rate)
+	b.expireAt = bucketExpireAt(now, b.level, rate)
+	return res, nil
+}
+
+// microsFor 以每秒rate的速率产生amount所需的时长，精确到微秒，与Redis脚本一致
+func microsFor(amount, rate float64) time.Duration {
+	if amount <= 0 {
+		return 0
+	}
+	if rate <= 0 {
+		return infDuration
+	}
+	return time.Duration(math.Ceil(amount*1e6/rate)) * time.Microsecond
+}
+
+// bucketExpireAt 桶中的量恢复到初始状态的时间(毫秒)，此后的状态与不存在的键相同
+func bucketExpireAt(now int64, amount, rate float64) int64 {
+	if rate <= 0 {
+		return math.MaxInt64
+	}
+	return now/1000 + int64(math.Ceil(amount*1000/rate)) + 1
+}
+
+// FixedWindow 实现Store
+func (m *MemoryStore) FixedWindow(ctx context.Context, key string, limit int, window time.Duration, n int) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	if err := checkWindow(window); err != nil {
+		return Result{}, err
+	}
+	now := timeNow().UnixMilli()
+	win := window.Milliseconds()
+	start := now - now%win
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w := m.windows[key]
+	if w == nil || w.start != start {
+		w = &memoryWindow{start: start}
+		m.windows[key] = w
+	}
+	res := Result{Limit: limit}
+	reset := time.Duration(start+win-now) * time.Millisecond
+	if w.count+n > limit {
+		res.RetryAfter = reset
+		if n > limit {
+			res.RetryAfter = infDuration
+		}
+	} else {
+		w.count += n
+		res.Allowed = true
+	}
+	res.Remaining = limit - w.count
+	res.ResetAfter = reset
+	w.expireAt = start + win
+	return res, nil
+}
+
+// Cleanup 删除已经过期的键，返回删除的数量
+func (m *MemoryStore) Cleanup() int {
+	now := timeNow().UnixMilli()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for key, log := range m.logs {
+		if log.expireAt <= now {
+			delete(m.logs, key)
+			removed++
+		}
+	}
+	for key, b := range m.buckets {
+		if b.expireAt <= now {
+			delete(m.buckets, key)
+			removed++
+		}
+	}
+	for key, w := range m.windows {
+		if w.expireAt <= now {
+			delete(m.windows, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len 当前保存的键数
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.logs) + len(m.buckets) + len(m.windows)
+}
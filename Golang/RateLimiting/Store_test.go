@@ -0,0 +1,231 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// redisClient 连接REDIS_ADDR指向的Redis，未设置时启动内嵌的miniredis，Lua脚本在默认测试中同样会执行
+func redisClient(t *testing.T) *redis.Client {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = miniredis.RunT(t).Addr()
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// runStoreContract 所有Store实现都必须满足的约定，使用真实时间(Redis的时间取自服务器)
+func runStoreContract(t *testing.T, store Store) {
+	ctx := context.Background()
+	prefix := strconv.FormatInt(time.Now().UnixNano(), 36) + ":"
+	const window = 300 * time.Millisecond
+
+	t.Run("admits up to limit", func(t *testing.T) {
+		key := prefix + "limit"
+		res, err := store.SlidingWindow(ctx, key, 3, window, 2)
+		if err != nil || !res.Allowed || res.Remaining != 1 || res.Limit != 3 || res.ResetAfter != window {
+			t.Fatalf("first = %+v, %v", res, err)
+		}
+		// 被拒绝的请求不占用配额
+		res, _ = store.SlidingWindow(ctx, key, 3, window, 2)
+		if res.Allowed || res.Remaining != 1 || res.RetryAfter <= 0 || res.RetryAfter > window {
+			t.Fatalf("denied = %+v", res)
+		}
+		if res.ResetAfter <= 0 || res.ResetAfter > window {
+			t.Fatalf("reset after = %v", res.ResetAfter)
+		}
+		res, _ = store.SlidingWindow(ctx, key, 3, window, 1)
+		if !res.Allowed || res.Remaining != 0 {
+			t.Fatalf("last = %+v", res)
+		}
+		res, _ = store.SlidingWindow(ctx, key, 3, window, 4)
+		if res.Allowed || res.RetryAfter != infDuration {
+			t.Fatalf("n > limit = %+v", res)
+		}
+	})
+
+	t.Run("same millisecond requests are distinct", func(t *testing.T) {
+		key := prefix + "distinct"
+		for i := 0; i < 5; i++ {
+			res, err := store.SlidingWindow(ctx, key, 5, window, 1)
+			if err != nil || !res.Allowed || res.Remaining != 4-i {
+				t.Fatalf("request %d = %+v, %v", i, res, err)
+			}
+		}
+	})
+
+	t.Run("window slides", func(t *testing.T) {
+		key := prefix + "slide"
+		store.SlidingWindow(ctx, key, 1, window, 1)
+		res, _ := store.SlidingWindow(ctx, key, 1, window, 1)
+		if res.Allowed {
+			t.Fatal("second request should be denied")
+		}
+		time.Sleep(res.RetryAfter + 10*time.Millisecond)
+		if res, _ := store.SlidingWindow(ctx, key, 1, window, 1); !res.Allowed {
+			t.Fatalf("after retry = %+v", res)
+		}
+	})
+
+	t.Run("keys are independent", func(t *testing.T) {
+		store.SlidingWindow(ctx, prefix+"a", 1, window, 1)
+		if res, _ := store.SlidingWindow(ctx, prefix+"b", 1, window, 1); !res.Allowed {
+			t.Fatal("key b must not share key a's quota")
+		}
+	})
+
+	// 每种算法在耗尽后给出重试时间，等待之后恢复
+	buckets := []struct {
+		name   string
+		decide func(key string, n int) (Result, error)
+	}{
+		{"token bucket", func(key string, n int) (Result, error) { return store.TokenBucket(ctx, key, 2, 10, n) }},
+		{"leaky bucket", func(key string, n int) (Result, error) { return store.LeakyBucket(ctx, key, 2, 10, n) }},
+		{"fixed window", func(key string, n int) (Result, error) { return store.FixedWindow(ctx, key, 2, window, n) }},
+	}
+	for _, b := range buckets {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			key := prefix + b.name
+			res, err := b.decide(key, 2)
+			if err != nil || !res.Allowed || res.Remaining != 0 || res.Limit != 2 {
+				t.Fatalf("burst = %+v, %v", res, err)
+			}
+			res, _ = b.decide(key, 1)
+			if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > window || res.ResetAfter <= 0 {
+				t.Fatalf("denied = %+v", res)
+			}
+			time.Sleep(res.RetryAfter + 10*time.Millisecond)
+			if res, _ := b.decide(key, 1); !res.Allowed {
+				t.Fatalf("after retry = %+v", res)
+			}
+		})
+	}
+
+	concurrent := []struct {
+		name   string
+		decide func(key string) (Result, error)
+	}{
+		{"sliding window", func(key string) (Result, error) { return store.SlidingWindow(ctx, key, 10, time.Minute, 1) }},
+		{"token bucket", func(key string) (Result, error) { return store.TokenBucket(ctx, key, 10, 0.001, 1) }},
+		{"leaky bucket", func(key string) (Result, error) { return store.LeakyBucket(ctx, key, 10, 0.001, 1) }},
+		{"fixed window", func(key string) (Result, error) { return store.FixedWindow(ctx, key, 10, time.Hour, 1) }},
+	}
+	for _, c := range concurrent {
+		c := c
+		t.Run("concurrent "+c.name+" never overshoots", func(t *testing.T) {
+			key := prefix + "concurrent " + c.name
+			var allowed int64
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 5; j++ {
+						res, err := c.decide(key)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						if res.Allowed {
+							atomic.AddInt64(&allowed, 1)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			if allowed != 10 {
+				t.Fatalf("%d requests allowed, want 10", allowed)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, NewMemoryStore())
+}
+
+func TestRedisStoreContract(t *testing.T) {
+	client := redisClient(t)
+	prefix := fmt.Sprintf("test:%d:", time.Now().UnixNano())
+	runStoreContract(t, NewRedisStore(client, prefix))
+
+	ctx := context.Background()
+	NewRedisStore(client, prefix).SlidingWindow(ctx, "ttl", 1, time.Minute, 1)
+	if ttl := client.PTTL(ctx, prefix+"ttl").Val(); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("ttl = %v", ttl)
+	}
+	keys, _ := client.Keys(ctx, prefix+"*").Result()
+	client.Del(ctx, keys...)
+}
+
+func TestMemoryStoreExpiresKeys(t *testing.T) {
+	clock := useFakeClock(t)
+	m := NewMemoryStore()
+	d := newDistributedSlidingWindow(t, m, 2, time.Second)
+	ctx := context.Background()
+	d.Decide(ctx, "a", 1)
+	d.Decide(ctx, "b", 2)
+	if res, _ := d.Decide(ctx, "b", 1); res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("denied = %+v", res)
+	}
+	if res, _ := d.Decide(ctx, "b", 3); res.Allowed || res.RetryAfter != infDuration {
+		t.Fatalf("n > limit = %+v", res)
+	}
+	clock.Advance(time.Second)
+	if n := m.Cleanup(); n != 2 || m.Len() != 0 {
+		t.Fatalf("cleanup = %d, Len = %d", n, m.Len())
+	}
+}
+
+func TestMemoryStoreStartCleanup(t *testing.T) {
+	clock := useFakeClock(t)
+	m := NewMemoryStore()
+	defer m.Close()
+	d := newDistributedFixedWindow(t, m, 1, time.Second)
+	for i := 0; i < 10; i++ {
+		d.Decide(context.Background(), strconv.Itoa(i), 1)
+	}
+	clock.Advance(time.Second)
+	m.StartCleanup(time.Millisecond)
+	m.StartCleanup(time.Millisecond) // 重复调用不会启动第二个协程
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d keys left after background cleanup", m.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	m.Close()
+	m.Close()
+}
+
+// 窗口短于1毫秒时返回错误，不会因为按0毫秒对齐而panic
+func TestMemoryStoreRejectsShortWindow(t *testing.T) {
+	m := NewMemoryStore()
+	ctx := context.Background()
+	if _, err := m.FixedWindow(ctx, "k", 1, 500*time.Microsecond, 1); err == nil {
+		t.Fatal("FixedWindow should reject a window shorter than 1ms")
+	}
+	if _, err := m.SlidingWindow(ctx, "k", 1, 0, 1); err == nil {
+		t.Fatal("SlidingWindow should reject a zero window")
+	}
+	if _, err := NewDistributedFixedWindow(m, 1, time.Microsecond); err == nil {
+		t.Fatal("NewDistributedFixedWindow should reject a window shorter than 1ms")
+	}
+	if _, err := NewDistributedSlidingWindow(m, 1, -time.Second); err == nil {
+		t.Fatal("NewDistributedSlidingWindow should reject a negative window")
+	}
+}
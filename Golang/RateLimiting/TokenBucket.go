@@ -1,23 +1,27 @@
-package主干
+package ratelimit
 
 import (
-	"fmt"
-	"sync/atomic"
+	"context"
+	"sync"
 	"time"
 )
 
 // TokenBucket 表示令牌桶结构体
 // 该结构体用于管理令牌桶的状态，包括容量、令牌生成速率、当前可用令牌数等信息
-// 使用原子操作保证并发安全
+// 令牌按纳秒精度连续生成，以十亿分之一个令牌为单位用整数记账，不足一个的部分保留到下次补充且没有浮点误差
+// 补充令牌、判断和扣减需要作为一个整体执行，因此使用互斥锁保证并发安全
+// 预定(Reserve/Wait)允许可用令牌数暂时为负，表示已经被预定的未来令牌
 type TokenBucket struct {
 	// capacity 表示令牌桶的最大容量，即令牌桶最多能容纳的令牌数量
 	capacity int64
 	// rate 表示令牌生成速率，即每秒生成的令牌数
 	rate int64
-	// available 表示当前可用的令牌数，使用原子操作保证并发安全
+	// available 表示当前可用的令牌数，单位为tokenScale分之一个令牌，为负时表示已被预定的未来令牌
 	available int64
-	// lastRefill 表示上次填充令牌的时间戳，单位为纳秒，用于计算新生成的令牌数
+	// lastRefill 表示令牌已经补充到的时间戳，单位为纳秒，用于计算新生成的令牌数
 	lastRefill int64
+	// mu 保护available和lastRefill
+	mu sync.Mutex
 }
 
 // NewTokenBucket 创建一个新的令牌桶
@@ -25,64 +29,131 @@ type TokenBucket struct {
 // 返回一个指向新创建的令牌桶的指针
 func NewTokenBucket(capacity, rate int64) *TokenBucket {
 	// 获取当前时间的纳秒时间戳
-	now := time.Now().UnixNano()
+	now := timeNow().UnixNano()
 	return &TokenBucket{
 		// 设置令牌桶的最大容量
 		capacity: capacity,
 		// 设置令牌生成速率
 		rate: rate,
 		// 初始化时，令牌桶为满状态
-		available: capacity,
+		available: capacity * tokenScale,
 		// 记录上次填充令牌的时间戳
 		lastRefill: now,
 	}
 }
 
 // Allow 检查是否允许请求通过
-// 该方法会根据当前令牌桶的状态，判断是否有足够的令牌来处理请求
 // 如果有足够的令牌，会消耗一个令牌并返回 true；否则返回 false
 func (tb *TokenBucket) Allow() bool {
-	// 获取当前时间的纳秒时间戳
-	now := time.Now().UnixNano()
-	// 原子性地加载上次填充令牌的时间戳
-	lastRefill := atomic.LoadInt64(&tb.lastRefill)
-	// 计算从上次填充令牌到现在经过的秒数
-	elapsed := (now - lastRefill) / int64(time.Second)
-	// 计算这段时间内新生成的令牌数
-	newTokens := elapsed * tb.rate
+	return tb.AllowN(1)
+}
 
-	// 如果有新生成的令牌
-	if newTokens > 0 {
-		// 原子性地增加可用令牌数
-		newAvailable := atomic.AddInt64(&tb.available, newTokens)
-		// 如果可用令牌数超过了令牌桶的最大容量
-		if newAvailable > tb.capacity {
-			// 将可用令牌数设置为令牌桶的最大容量
-			newAvailable = tb.capacity
-			// 原子性地存储新的可用令牌数
-			atomic.StoreInt64(&tb.available, newAvailable)
-		}
-		// 原子性地更新上次填充令牌的时间戳为当前时间
-		atomic.StoreInt64(&tb.lastRefill, now)
+// AllowN 检查是否允许n个请求同时通过，令牌不足时不消耗令牌
+func (tb *TokenBucket) AllowN(n int) bool {
+	return allowN(tb, n)
+}
+
+// Reserve 预定n个令牌，返回需要等待的时长
+func (tb *TokenBucket) Reserve(n int) *Reservation {
+	return reserveN(tb, n)
+}
+
+// Wait 阻塞直到获得n个令牌
+func (tb *TokenBucket) Wait(ctx context.Context, n int) error {
+	return waitN(ctx, tb, n)
+}
+
+// Decide 判定n个令牌能否立即获得，返回剩余令牌数、补满所需时间和重试时间
+func (tb *TokenBucket) Decide(n int) Result {
+	now := timeNow().UnixNano()
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.refill(now)
+	res := Result{Limit: int(tb.capacity)}
+	if int64(n) > tb.capacity {
+		res.RetryAfter = infDuration
+	} else if need := int64(n) * tokenScale; tb.available >= need {
+		tb.available -= need
+		res.Allowed = true
+	} else {
+		res.RetryAfter = tb.untilRefilled(need-tb.available, now)
 	}
+	res.Remaining = int(max(tb.available, 0) / tokenScale)
+	res.ResetAfter = tb.untilRefilled(tb.capacity*tokenScale-tb.available, now)
+	return res
+}
+
+// tokenScale 一个令牌的记账单位数
+// 经过的纳秒数乘以每秒令牌数恰好是新生成的令牌单位数，因此容量不能超过math.MaxInt64/tokenScale
+const tokenScale = int64(time.Second)
 
-	// 尝试原子性地消耗一个令牌
-	// 如果消耗后可用令牌数仍然大于等于 0，则表示请求可以通过
-	return atomic.AddInt64(&tb.available, -1) >= 0
+// Tokens 返回当前可用的令牌数，为负表示已被预定的未来令牌
+func (tb *TokenBucket) Tokens() float64 {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.refill(timeNow().UnixNano())
+	return float64(tb.available) / float64(tokenScale)
 }
 
-func main() {
-	// 创建一个容量为 10，每秒生成 5 个令牌的令牌桶
-	limiter := NewTokenBucket(10, 5)
+// refill 把令牌补充到now时刻，调用方需持有锁
+// 并发调用者在加锁前读取的now可能早于lastRefill，此时不补充，lastRefill也不会倒退
+func (tb *TokenBucket) refill(now int64) {
+	elapsed := now - tb.lastRefill
+	if elapsed <= 0 {
+		return
+	}
+	tb.lastRefill = now
+	if tb.rate <= 0 {
+		return
+	}
+	// 可用令牌数不能超过令牌桶的最大容量，先比较时长再相乘，避免长时间空闲后溢出
+	missing := tb.capacity*tokenScale - tb.available
+	if elapsed >= (missing+tb.rate-1)/tb.rate {
+		tb.available = tb.capacity * tokenScale
+		return
+	}
+	tb.available += elapsed * tb.rate
+}
+
+// reserveN 补充令牌后扣减n个令牌，令牌不足时计算补足缺口需要的时长
+func (tb *TokenBucket) reserveN(now time.Time, n int, maxWait time.Duration) *Reservation {
+	if int64(n) > tb.capacity {
+		return rejected(ErrExceedsBurst)
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
 
-	// 模拟 20 次请求
-	for i := 0; i < 20; i++ {
-		if limiter.Allow() {
-			fmt.Printf("请求 %d 通过\n", i+1)
-		} else {
-			fmt.Printf("请求 %d 被限流\n", i+1)
+	tb.refill(now.UnixNano())
+	need := int64(n) * tokenScale
+	available := tb.available - need
+	timeToAct := now
+	if available < 0 {
+		if tb.rate <= 0 {
+			return rejected(ErrWouldExceedDeadline)
 		}
-		// 每次请求间隔 100 毫秒
-		time.Sleep(100 * time.Millisecond)
+		timeToAct = now.Add(tb.untilRefilled(-available, now.UnixNano()))
+	}
+	if timeToAct.Sub(now) > maxWait {
+		return rejected(ErrWouldExceedDeadline)
+	}
+	tb.available = available
+	return reserved(timeToAct, func() {
+		tb.mu.Lock()
+		defer tb.mu.Unlock()
+		tb.available = min(tb.available+need, tb.capacity*tokenScale)
+	})
+}
+
+// untilRefilled 从now起再补充deficit个令牌单位需要的时长，调用方需持有锁
+// 令牌已经补充到lastRefill，缺口从那时起开始补，向上取整到纳秒
+func (tb *TokenBucket) untilRefilled(deficit, now int64) time.Duration {
+	if deficit <= 0 {
+		return 0
+	}
+	if tb.rate <= 0 {
+		return infDuration
 	}
+	ahead := max(tb.lastRefill-now, 0)
+	return time.Duration(ahead + (deficit+tb.rate-1)/tb.rate)
 }
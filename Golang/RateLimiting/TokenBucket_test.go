@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 每秒5个令牌时，200毫秒就应补充一个令牌，而不是等满一整秒
+func TestTokenBucketSubSecondRefill(t *testing.T) {
+	clock := useFakeClock(t)
+	tb := NewTokenBucket(5, 5)
+	tb.AllowN(5)
+	clock.Advance(199 * time.Millisecond)
+	if tb.Allow() {
+		t.Fatal("token granted before 200ms")
+	}
+	clock.Advance(time.Millisecond)
+	if !tb.Allow() {
+		t.Fatal("one token should be refilled after 200ms")
+	}
+}
+
+// 频繁调用时不足一个令牌的部分不能丢失
+func TestTokenBucketKeepsFractionalTokens(t *testing.T) {
+	clock := useFakeClock(t)
+	tb := NewTokenBucket(10, 5)
+	tb.AllowN(10)
+	passed := 0
+	for i := 0; i < 100; i++ {
+		clock.Advance(10 * time.Millisecond)
+		if tb.Allow() {
+			passed++
+		}
+	}
+	if passed != 5 {
+		t.Fatalf("%d tokens in one second, want 5", passed)
+	}
+	if got := tb.Tokens(); math.Abs(got) > 1e-9 {
+		t.Fatalf("tokens = %v", got)
+	}
+}
+
+func TestTokenBucketCapsAtCapacity(t *testing.T) {
+	clock := useFakeClock(t)
+	tb := NewTokenBucket(3, 100)
+	clock.Advance(time.Hour)
+	if got := tb.Tokens(); got != 3 {
+		t.Fatalf("tokens = %v, want 3", got)
+	}
+	if r := tb.Reserve(3); r.Delay() != 0 {
+		t.Fatal("full bucket should grant its capacity immediately")
+	}
+	if r := tb.Reserve(2); r.Delay() != 20*time.Millisecond {
+		t.Fatalf("delay = %v", r.Delay())
+	}
+}
+
+func TestTokenBucketZeroRate(t *testing.T) {
+	useFakeClock(t)
+	tb := NewTokenBucket(1, 0)
+	if !tb.Allow() || tb.Allow() {
+		t.Fatal("only the initial burst is available without refill")
+	}
+	if r := tb.Reserve(1); r.OK() {
+		t.Fatal("reservation can never be satisfied")
+	}
+}
+
+// 并发调用者的时钟读取先后交错时，通过的请求数不能超过容量加上这段时间生成的令牌
+func TestTokenBucketConcurrentNeverOvershoots(t *testing.T) {
+	clock := useFakeClock(t)
+	const capacity, rate = 10, 1000
+	tb := NewTokenBucket(capacity, rate)
+
+	var passed int64
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			clock.Advance(100 * time.Microsecond)
+		}
+		close(stop)
+	}()
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if tb.Allow() {
+					atomic.AddInt64(&passed, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 1000次推进共100毫秒，生成100个令牌
+	if max := int64(capacity + rate/10); passed > max {
+		t.Fatalf("%d requests passed, at most %d allowed", passed, max)
+	}
+	if tokens := tb.Tokens(); tokens < 0 || tokens > capacity {
+		t.Fatalf("tokens = %v", tokens)
+	}
+}
+
+func BenchmarkTokenBucketAllow(b *testing.B) {
+	tb := NewTokenBucket(1<<30, 1<<30)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tb.Allow()
+	}
+}
+
+func BenchmarkTokenBucketAllowParallel(b *testing.B) {
+	tb := NewTokenBucket(1<<30, 1<<30)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tb.Allow()
+		}
+	})
+}
+
+func BenchmarkTokenBucketReserveCancel(b *testing.B) {
+	tb := NewTokenBucket(1, 1)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tb.Reserve(1).Cancel()
+	}
+}
@@ -0,0 +1,299 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransportConfig 客户端限流配置
+type TransportConfig struct {
+	Base       http.RoundTripper         // 实际发送请求的RoundTripper，默认http.DefaultTransport
+	Limiter    func(host string) Limiter // 为每个主机创建本地限流器，为空时只遵守服务端返回的限制
+	Backoff    time.Duration             // 429响应没有Retry-After时的初始退避时长，之后每次翻倍，默认1秒
+	MaxBackoff time.Duration             // 退避时长上限，默认1分钟
+	MaxRetries int                       // 收到429后最多重试的次数，默认不重试，直接返回429响应
+	IdleTTL    time.Duration             // 主机的限制解除后空闲超过IdleTTL即丢弃其状态和本地限流器，默认10分钟，应不短于本地限流器恢复满配额的时间
+}
+
+// Transport 客户端限流的http.RoundTripper，调用第三方API时保证不超出对方的配额
+// 每个请求发出前先等待服务端要求的暂停和节奏，再通过主机对应的Limiter.Wait排队
+// 根据响应中的Retry-After和RateLimit-Remaining、RateLimit-Reset自动调整发送速率
+type Transport struct {
+	base  http.RoundTripper
+	cfg   TransportConfig
+	local *KeyedLimiter // 为空表示没有本地限流器
+
+	mu    sync.Mutex
+	hosts map[string]*hostGate
+	swept time.Time // 上次清理hosts的时间
+}
+
+// hostGate 服务端对一个主机施加的限制
+type hostGate struct {
+	active   int       // 正在使用该状态的请求数，受Transport.mu保护
+	lastUsed time.Time // 最近一次请求结束的时间，受Transport.mu保护
+
+	mu        sync.Mutex
+	notBefore time.Time     // 在此之前不发送请求(Retry-After、配额耗尽或退避)
+	interval  time.Duration // 剩余配额均匀分布到重置时间内时相邻请求的间隔
+	paceUntil time.Time     // interval的有效期，即服务端配额的重置时间
+	next      time.Time     // 按interval下一个请求最早的发送时间
+	failures  int           // 连续收到429的次数
+}
+
+// NewTransport 创建客户端限流的RoundTripper
+func NewTransport(cfg TransportConfig) (*Transport, error) {
+	if cfg.Base == nil {
+		cfg.Base = http.DefaultTransport
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		return nil, fmt.Errorf("ratelimit: MaxBackoff %v is less than Backoff %v", cfg.MaxBackoff, cfg.Backoff)
+	}
+	if cfg.MaxRetries < 0 {
+		return nil, fmt.Errorf("ratelimit: negative MaxRetries %d", cfg.MaxRetries)
+	}
+	if cfg.IdleTTL < 0 {
+		return nil, fmt.Errorf("ratelimit: negative IdleTTL %v", cfg.IdleTTL)
+	}
+	if cfg.IdleTTL == 0 {
+		cfg.IdleTTL = 10 * time.Minute
+	}
+	t := &Transport{base: cfg.Base, cfg: cfg, hosts: make(map[string]*hostGate), swept: timeNow()}
+	if cfg.Limiter != nil {
+		local, err := NewKeyedLimiter(KeyedConfig{New: cfg.Limiter, IdleTTL: cfg.IdleTTL})
+		if err != nil {
+			return nil, err
+		}
+		t.local = local
+	}
+	return t, nil
+}
+
+// RoundTrip 实现http.RoundTripper
+// 等待被ctx取消或ctx的截止时间不够等待时返回错误，请求不会被发出，请求体照常关闭
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	host := req.URL.Host
+	g := t.gate(host)
+	defer t.release(g)
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(ctx, host, g); err != nil {
+			// 第一次发送前返回时请求体还没有交给base，按RoundTripper的约定由这里关闭
+			if attempt == 0 && req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		out := req
+		if attempt > 0 {
+			// 重试时使用新的请求体，RoundTripper不能修改调用方的请求
+			out = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				out.Body = body
+			}
+		}
+		resp, err := t.base.RoundTrip(out)
+		if err != nil {
+			return nil, err
+		}
+		throttled := g.observe(resp, timeNow(), t.cfg.Backoff, t.cfg.MaxBackoff)
+		if !throttled || attempt >= t.cfg.MaxRetries || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+			return resp, nil
+		}
+		// 读完响应体才能复用连接
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+	}
+}
+
+// Delay 向host发送下一个请求前需要等待服务端限制解除的时长，不包括本地限流器的等待
+func (t *Transport) Delay(host string) time.Duration {
+	t.mu.Lock()
+	g := t.hosts[host]
+	t.mu.Unlock()
+	if g == nil {
+		return 0
+	}
+	return g.delay(timeNow())
+}
+
+// Close 释放本地限流器
+func (t *Transport) Close() {
+	if t.local != nil {
+		t.local.Close()
+	}
+}
+
+// gate 取出host的状态并标记为使用中，请求结束后需要调用release
+// 每隔IdleTTL清理一次空闲的主机，hosts不会随访问过的主机数无限增长
+func (t *Transport) gate(host string) *hostGate {
+	now := timeNow()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.swept) >= t.cfg.IdleTTL {
+		t.sweep(now)
+	}
+	g, ok := t.hosts[host]
+	if !ok {
+		g = &hostGate{}
+		t.hosts[host] = g
+	}
+	g.active++
+	return g
+}
+
+// release 请求结束，记录主机的最近使用时间
+func (t *Transport) release(g *hostGate) {
+	now := timeNow()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g.active--
+	g.lastUsed = now
+}
+
+// sweep 删除没有请求在用、空闲超过IdleTTL且服务端限制已经解除的主机，调用方需持有t.mu
+func (t *Transport) sweep(now time.Time) {
+	t.swept = now
+	for host, g := range t.hosts {
+		if g.active == 0 && now.Sub(g.lastUsed) >= t.cfg.IdleTTL && g.idle(now) {
+			delete(t.hosts, host)
+		}
+	}
+}
+
+// wait 先等待服务端的限制，再通过本地限流器排队
+func (t *Transport) wait(ctx context.Context, host string, g *hostGate) error {
+	if d := g.reserve(timeNow()); d > 0 {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+			return fmt.Errorf("ratelimit: %s: server asked to wait %v: %w", host, d, ErrWouldExceedDeadline)
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	if t.local == nil {
+		return nil
+	}
+	return t.local.Wait(ctx, host, 1)
+}
+
+// idle 服务端的暂停和节奏都已过期，丢弃该状态后只有连续429的退避次数会重新计算
+func (g *hostGate) idle(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !g.notBefore.After(now) && !g.paceUntil.After(now)
+}
+
+// delay 从now起需要等待的时长
+func (g *hostGate) delay(now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.at(now).Sub(now)
+}
+
+// reserve 为一个请求占用发送时间，返回需要等待的时长
+func (g *hostGate) reserve(now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	at := g.at(now)
+	if at.Before(g.paceUntil) {
+		g.next = at.Add(g.interval)
+	}
+	return at.Sub(now)
+}
+
+// at 下一个请求最早的发送时间
+func (g *hostGate) at(now time.Time) time.Time {
+	at := now
+	if g.notBefore.After(at) {
+		at = g.notBefore
+	}
+	if at.Before(g.paceUntil) && g.next.After(at) {
+		at = g.next
+	}
+	return at
+}
+
+// observe 根据响应调整限制，返回是否被服务端限流
+func (g *hostGate) observe(resp *http.Response, now time.Time, backoff, maxBackoff time.Duration) bool {
+	h := resp.Header
+	retryAfter, hasRetry := parseRetryAfter(h.Get("Retry-After"), now)
+	throttled := resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusServiceUnavailable && hasRetry)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if throttled {
+		if !hasRetry {
+			retryAfter = backoff
+			for i := 0; i < g.failures && retryAfter < maxBackoff; i++ {
+				retryAfter *= 2
+			}
+			retryAfter = min(retryAfter, maxBackoff)
+		}
+		g.failures++
+		g.pause(now.Add(retryAfter))
+		return true
+	}
+	g.failures = 0
+
+	remaining, err1 := strconv.Atoi(strings.TrimSpace(h.Get("RateLimit-Remaining")))
+	reset, err2 := strconv.Atoi(strings.TrimSpace(h.Get("RateLimit-Reset")))
+	if err1 != nil || err2 != nil || reset < 0 {
+		return false
+	}
+	resetAt := now.Add(time.Duration(reset) * time.Second)
+	if remaining <= 0 {
+		g.pause(resetAt)
+		return false
+	}
+	// 把剩余配额均匀分布到重置之前，避免一次用完后整段时间被拒绝
+	g.interval = time.Duration(reset) * time.Second / time.Duration(remaining)
+	g.paceUntil = resetAt
+	return false
+}
+
+// pause 在until之前不发送请求
+func (g *hostGate) pause(until time.Time) {
+	if until.After(g.notBefore) {
+		g.notBefore = until
+	}
+}
+
+// parseRetryAfter 解析Retry-After，可以是秒数或HTTP日期
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
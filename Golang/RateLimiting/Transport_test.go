@@ -0,0 +1,332 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedServer 按顺序返回预设的响应，用完后返回200
+type scriptedServer struct {
+	mu        sync.Mutex
+	responses []func(w http.ResponseWriter)
+	bodies    []string
+}
+
+func (s *scriptedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.bodies = append(s.bodies, string(body))
+	var respond func(w http.ResponseWriter)
+	if len(s.responses) > 0 {
+		respond, s.responses = s.responses[0], s.responses[1:]
+	}
+	s.mu.Unlock()
+	if respond != nil {
+		respond(w)
+	}
+}
+
+func (s *scriptedServer) push(status int, header ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+		w.WriteHeader(status)
+	})
+}
+
+func (s *scriptedServer) requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func newTestTransport(t *testing.T, cfg TransportConfig) (*Transport, *http.Client) {
+	tr, err := NewTransport(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tr.Close)
+	return tr, &http.Client{Transport: tr}
+}
+
+func get(t *testing.T, client *http.Client, url string) *http.Response {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+func hostOf(t *testing.T, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
+
+// 剩余配额均匀分布到重置时间之前，配额耗尽时暂停到重置
+func TestTransportPacesByRateLimitHeaders(t *testing.T) {
+	clock := useFakeClock(t)
+	s := &scriptedServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	tr, client := newTestTransport(t, TransportConfig{})
+	host := hostOf(t, srv.URL)
+
+	s.push(http.StatusOK, "RateLimit-Remaining", "4", "RateLimit-Reset", "8")
+	get(t, client, srv.URL)
+	if d := tr.Delay(host); d != 0 {
+		t.Fatalf("delay after first response = %v", d)
+	}
+	s.push(http.StatusOK, "RateLimit-Remaining", "4", "RateLimit-Reset", "8")
+	get(t, client, srv.URL)
+	if d := tr.Delay(host); d != 2*time.Second {
+		t.Fatalf("paced delay = %v, want 2s", d)
+	}
+	clock.Advance(2 * time.Second)
+
+	s.push(http.StatusOK, "RateLimit-Remaining", "0", "RateLimit-Reset", "5")
+	get(t, client, srv.URL)
+	if d := tr.Delay(host); d != 5*time.Second {
+		t.Fatalf("exhausted delay = %v, want 5s", d)
+	}
+	clock.Advance(5 * time.Second)
+	if d := tr.Delay(host); d != 0 {
+		t.Fatalf("delay after reset = %v", d)
+	}
+	// 其他主机不受影响
+	if d := tr.Delay("other.example:80"); d != 0 {
+		t.Fatalf("other host delay = %v", d)
+	}
+}
+
+func TestTransportBacksOffAfter429(t *testing.T) {
+	clock := useFakeClock(t)
+	s := &scriptedServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	tr, client := newTestTransport(t, TransportConfig{Backoff: time.Second, MaxBackoff: 8 * time.Second})
+	host := hostOf(t, srv.URL)
+
+	s.push(http.StatusTooManyRequests, "Retry-After", "7")
+	if resp := get(t, client, srv.URL); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if d := tr.Delay(host); d != 7*time.Second {
+		t.Fatalf("Retry-After delay = %v, want 7s", d)
+	}
+	clock.Advance(7 * time.Second)
+
+	s.push(http.StatusServiceUnavailable, "Retry-After", clock.Now().Add(4*time.Second).Format(http.TimeFormat))
+	get(t, client, srv.URL)
+	if d := tr.Delay(host); d != 4*time.Second {
+		t.Fatalf("HTTP-date delay = %v, want 4s", d)
+	}
+	clock.Advance(4 * time.Second)
+
+	// 没有Retry-After时按连续限流次数指数退避，上限为MaxBackoff
+	for _, want := range []time.Duration{4, 8, 8} {
+		s.push(http.StatusTooManyRequests)
+		get(t, client, srv.URL)
+		if d := tr.Delay(host); d != want*time.Second {
+			t.Fatalf("backoff = %v, want %vs", d, want)
+		}
+		clock.Advance(want * time.Second)
+	}
+	// 成功后退避重新开始
+	get(t, client, srv.URL)
+	s.push(http.StatusTooManyRequests)
+	get(t, client, srv.URL)
+	if d := tr.Delay(host); d != time.Second {
+		t.Fatalf("backoff after success = %v, want 1s", d)
+	}
+}
+
+func TestTransportRetries(t *testing.T) {
+	useFakeClock(t)
+	s := &scriptedServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	_, client := newTestTransport(t, TransportConfig{MaxRetries: 2})
+
+	s.push(http.StatusTooManyRequests, "Retry-After", "0")
+	s.push(http.StatusTooManyRequests, "Retry-After", "0")
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || s.requests() != 3 {
+		t.Fatalf("status = %d after %d requests", resp.StatusCode, s.requests())
+	}
+	for i, b := range s.bodies {
+		if b != "payload" {
+			t.Fatalf("attempt %d body = %q", i, b)
+		}
+	}
+
+	// 重试次数用完后返回429
+	for i := 0; i < 3; i++ {
+		s.push(http.StatusTooManyRequests, "Retry-After", "0")
+	}
+	if resp := get(t, client, srv.URL); resp.StatusCode != http.StatusTooManyRequests || s.requests() != 6 {
+		t.Fatalf("status = %d after %d requests", resp.StatusCode, s.requests())
+	}
+}
+
+// 需要等待的时间超过ctx的截止时间时不发送请求
+func TestTransportRespectsContext(t *testing.T) {
+	useFakeClock(t)
+	s := &scriptedServer{}
+	a := httptest.NewServer(s)
+	defer a.Close()
+	b := httptest.NewServer(s)
+	defer b.Close()
+	_, client := newTestTransport(t, TransportConfig{
+		Limiter: func(string) Limiter { return NewTokenBucket(1, 1) },
+	})
+
+	do := func(client *http.Client, url string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	if err := do(client, a.URL); err != nil {
+		t.Fatal(err)
+	}
+	// 本地限流器按主机区分
+	if err := do(client, a.URL); !errors.Is(err, ErrWouldExceedDeadline) {
+		t.Fatalf("second request to a = %v", err)
+	}
+	if err := do(client, b.URL); err != nil {
+		t.Fatalf("request to b = %v", err)
+	}
+
+	_, unlimited := newTestTransport(t, TransportConfig{})
+	s.push(http.StatusTooManyRequests, "Retry-After", "30")
+	do(unlimited, a.URL)
+	if err := do(unlimited, a.URL); !errors.Is(err, ErrWouldExceedDeadline) || s.requests() != 3 {
+		t.Fatalf("request during Retry-After = %v after %d requests", err, s.requests())
+	}
+}
+
+// 与服务端中间件配合时客户端主动放慢，不会收到429
+func TestTransportWithMiddleware(t *testing.T) {
+	clock := useFakeClock(t)
+	m, err := NewMiddleware(MiddlewareConfig{Limiter: newDistributedFixedWindow(t, NewMemoryStore(), 2, time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(m.Handler(okHandler))
+	defer srv.Close()
+	tr, client := newTestTransport(t, TransportConfig{})
+	host := hostOf(t, srv.URL)
+
+	for i := 0; i < 6; i++ {
+		clock.Advance(tr.Delay(host))
+		if resp := get(t, client, srv.URL); resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d status = %d", i, resp.StatusCode)
+		}
+	}
+}
+
+// closeRecorder 记录请求体是否被关闭
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+// 等待失败时请求没有发出，请求体也要关闭
+func TestTransportClosesBodyWhenWaitFails(t *testing.T) {
+	useFakeClock(t)
+	s := &scriptedServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	tr, _ := newTestTransport(t, TransportConfig{})
+	s.push(http.StatusTooManyRequests, "Retry-After", "30")
+	get(t, &http.Client{Transport: tr}, srv.URL)
+
+	body := &closeRecorder{Reader: strings.NewReader("payload")}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "POST", srv.URL, body)
+	if _, err := tr.RoundTrip(req); !errors.Is(err, ErrWouldExceedDeadline) {
+		t.Fatalf("RoundTrip = %v", err)
+	}
+	if !body.closed || s.requests() != 1 {
+		t.Fatalf("body closed = %v after %d requests", body.closed, s.requests())
+	}
+}
+
+// roundTripFunc 不经过网络直接返回响应
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// 空闲超过IdleTTL且限制已解除的主机被清理，仍在暂停中的主机保留
+func TestTransportExpiresIdleHosts(t *testing.T) {
+	clock := useFakeClock(t)
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}
+		if req.URL.Host == "paused.example" {
+			resp.StatusCode = http.StatusTooManyRequests
+			resp.Header.Set("Retry-After", "3600")
+		}
+		return resp, nil
+	})
+	tr, client := newTestTransport(t, TransportConfig{Base: base, IdleTTL: time.Minute})
+	for i := 0; i < 100; i++ {
+		get(t, client, "http://"+strconv.Itoa(i)+".example/")
+	}
+	get(t, client, "http://paused.example/")
+	if len(tr.hosts) != 101 {
+		t.Fatalf("%d hosts tracked", len(tr.hosts))
+	}
+
+	clock.Advance(time.Minute)
+	get(t, client, "http://fresh.example/")
+	if len(tr.hosts) != 2 || tr.hosts["paused.example"] == nil {
+		t.Fatalf("hosts after sweep = %v", tr.hosts)
+	}
+	if d := tr.Delay("paused.example"); d != 59*time.Minute {
+		t.Fatalf("paused host delay = %v", d)
+	}
+}
+
+func TestNewTransportValidation(t *testing.T) {
+	if _, err := NewTransport(TransportConfig{Backoff: time.Minute, MaxBackoff: time.Second}); err == nil {
+		t.Fatal("MaxBackoff < Backoff should be rejected")
+	}
+	if _, err := NewTransport(TransportConfig{MaxRetries: -1}); err == nil {
+		t.Fatal("negative MaxRetries should be rejected")
+	}
+	if _, err := NewTransport(TransportConfig{IdleTTL: -time.Second}); err == nil {
+		t.Fatal("negative IdleTTL should be rejected")
+	}
+}
@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// exampleClock 示例中用可控时钟代替真实等待，返回恢复timeNow的函数
+func exampleClock() (*fakeClock, func()) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	timeNow = clock.Now
+	return clock, func() { timeNow = time.Now }
+}
+
+func ExampleTokenBucket() {
+	clock, restore := exampleClock()
+	defer restore()
+
+	// 创建一个容量为 10，每秒生成 5 个令牌的令牌桶
+	limiter := NewTokenBucket(10, 5)
+
+	// 模拟 20 次请求
+	for i := 0; i < 20; i++ {
+		if limiter.Allow() {
+			fmt.Printf("请求 %d 通过\n", i+1)
+		} else {
+			fmt.Printf("请求 %d 被限流\n", i+1)
+		}
+		// 每次请求间隔 100 毫秒
+		clock.Advance(100 * time.Millisecond)
+	}
+	// Output:
+	// 请求 1 通过
+	// 请求 2 通过
+	// 请求 3 通过
+	// 请求 4 通过
+	// 请求 5 通过
+	// 请求 6 通过
+	// 请求 7 通过
+	// 请求 8 通过
+	// 请求 9 通过
+	// 请求 10 通过
+	// 请求 11 通过
+	// 请求 12 通过
+	// 请求 13 通过
+	// 请求 14 通过
+	// 请求 15 通过
+	// 请求 16 通过
+	// 请求 17 通过
+	// 请求 18 通过
+	// 请求 19 通过
+	// 请求 20 被限流
+}
+
+func ExampleLeakyBucket() {
+	clock, restore := exampleClock()
+	defer restore()
+
+	// 创建一个容量为 10，速率为每秒 2 个请求的漏桶
+	bucket := NewLeakyBucket(10, 2)
+
+	// 模拟 20 个请求
+	for i := 0; i < 20; i++ {
+		if bucket.Allow() {
+			fmt.Printf("请求 %d 通过\n", i+1)
+		} else {
+			fmt.Printf("请求 %d 被限流\n", i+1)
+		}
+		clock.Advance(200 * time.Millisecond)
+	}
+	// Output:
+	// 请求 1 通过
+	// 请求 2 通过
+	// 请求 3 通过
+	// 请求 4 通过
+	// 请求 5 通过
+	// 请求 6 通过
+	// 请求 7 通过
+	// 请求 8 通过
+	// 请求 9 通过
+	// 请求 10 通过
+	// 请求 11 通过
+	// 请求 12 通过
+	// 请求 13 通过
+	// 请求 14 通过
+	// 请求 15 通过
+	// 请求 16 通过
+	// 请求 17 被限流
+	// 请求 18 被限流
+	// 请求 19 通过
+	// 请求 20 被限流
+}
+
+func ExampleFixedWindowLimiter() {
+	limiter := NewFixedWindowLimiter(1000, 10) // 创建一个每秒最多 10 个请求的限流器
+	if limiter.Allow() {
+		fmt.Println("请求通过")
+	} else {
+		fmt.Println("请求被限流")
+	}
+	// Output:
+	// 请求通过
+}
+
+func ExampleLimitFreqSingle() {
+	// 10秒内最多5个请求
+	if LimitFreqSingle("api_request", 5, 10) {
+		fmt.Println("请求通过")
+	} else {
+		fmt.Println("请求被限流")
+	}
+	// Output:
+	// 请求通过
+}
+
+func ExampleDistributedSlidingWindow() {
+	// 示例使用内嵌的miniredis，生产环境换成真实的Redis地址
+	mr, err := miniredis.Run()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     mr.Addr(), // Redis Server
+		Password: "",        // 无密码
+		DB:       0,         // 使用默认数据库
+	})
+	defer rdb.Close()
+
+	// 每个键60秒内最多100个请求
+	limiter, err := NewDistributedSlidingWindow(NewRedisStore(rdb, "ratelimit:"), 100, 60*time.Second)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	res, err := limiter.Decide(context.Background(), "api_request", 1)
+	switch {
+	case err != nil:
+		fmt.Println("限流后端不可用:", err)
+	case res.Allowed:
+		fmt.Println("请求通过，剩余", res.Remaining)
+	default:
+		fmt.Println("请求被限流，", res.RetryAfter, "后重试")
+	}
+	// Output:
+	// 请求通过，剩余 99
+}
@@ -517,6 +517,9 @@ func (ps *PubSub) Close() {
 // 参数建议:
 // - rate: 根据系统负载设置
 // - capacity: 应对突发流量的能力
+// 注意: 这是本文件自带的教学示例，没有实现RateLimiting中的Limiter接口。
+// 本文件通过go run单独运行，RateLimiting是独立模块中的package main，无法被导入；
+// 需要AllowN、Reserve、Wait或中间件接入时使用RateLimiting/TokenBucket.go
 type RateLimiter struct {
 	capacity int64
 	tokens   int64
@@ -374,6 +374,9 @@ func ChannelMultiplexing() {
 }
 
 // RateLimiter 基于令牌桶的速率限制器
+// 注意: 这是本文件自带的教学示例，没有实现RateLimiting中的Limiter接口。
+// 本文件通过go run单独运行，RateLimiting是独立模块中的package main，无法被导入；
+// 需要AllowN、Reserve或带ctx的Wait时使用RateLimiting/TokenBucket.go
 type RateLimiter struct {
 	tokens      int32
 	maxTokens   int32